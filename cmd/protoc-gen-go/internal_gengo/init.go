@@ -10,6 +10,7 @@ import (
 
 	"google.golang.org/protobuf/compiler/protogen"
 	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
 
 	"google.golang.org/protobuf/types/descriptorpb"
 )
@@ -125,6 +126,7 @@ type messageInfo struct {
 
 	isTracked bool
 	hasWeak   bool
+	hasLazy   bool
 }
 
 func newMessageInfo(f *fileInfo, message *protogen.Message) *messageInfo {
@@ -134,10 +136,23 @@ func newMessageInfo(f *fileInfo, message *protogen.Message) *messageInfo {
 	m.isTracked = isTrackedMessage(m)
 	for _, field := range m.Fields {
 		m.hasWeak = m.hasWeak || field.Desc.IsWeak()
+		m.hasLazy = m.hasLazy || isLazyField(field)
 	}
 	return m
 }
 
+// isLazyField reports whether the field is a message field
+// that may be decoded lazily.
+func isLazyField(field *protogen.Field) bool {
+	if field.Desc.Kind() != protoreflect.MessageKind || field.Desc.IsMap() || field.Desc.IsWeak() {
+		return false
+	}
+	if field.Oneof != nil && !field.Oneof.Desc.IsSynthetic() {
+		return false
+	}
+	return field.Desc.Options().(*descriptorpb.FieldOptions).GetLazy()
+}
+
 // isTrackedMessage reports whether field tracking is enabled on the message.
 func isTrackedMessage(m *messageInfo) (tracked bool) {
 	const trackFieldUse_fieldNumber = 37383685
@@ -39,6 +39,7 @@ const (
 	mathPackage    = protogen.GoImportPath("math")
 	reflectPackage = protogen.GoImportPath("reflect")
 	sortPackage    = protogen.GoImportPath("sort")
+	strconvPackage = protogen.GoImportPath("strconv")
 	stringsPackage = protogen.GoImportPath("strings")
 	syncPackage    = protogen.GoImportPath("sync")
 	timePackage    = protogen.GoImportPath("time")
@@ -394,6 +395,10 @@ func genMessageInternalFields(g *protogen.GeneratedFile, f *fileInfo, m *message
 		g.P(genid.ExtensionFields_goname, " ", protoimplPackage.Ident("ExtensionFields"))
 		sf.append(genid.ExtensionFields_goname)
 	}
+	if m.hasLazy {
+		g.P(genid.LazyFields_goname, " ", protoimplPackage.Ident("LazyFields"))
+		sf.append(genid.LazyFields_goname)
+	}
 	if sf.count > 0 {
 		g.P()
 	}
@@ -451,17 +456,28 @@ func genMessageField(g *protogen.GeneratedFile, f *fileInfo, m *messageInfo, fie
 		tags = append(tags, gotrackTags...)
 	}
 
-	name := field.GoName
-	if field.Desc.IsWeak() {
-		name = genid.WeakFieldPrefix_goname + name
-	}
+	name := fieldStructName(field)
 	g.Annotate(m.GoIdent.GoName+"."+name, field.Location)
 	leadingComments := appendDeprecationSuffix(field.Comments.Leading,
 		field.Desc.Options().(*descriptorpb.FieldOptions).GetDeprecated())
 	g.P(leadingComments,
 		name, " ", goType, tags,
 		trailingComment(field.Comments.Trailing))
-	sf.append(field.GoName)
+	sf.append(name)
+}
+
+// fieldStructName returns the name of the struct field for a field.
+//
+// Lazy fields are unexported, since reading or writing them directly would
+// bypass their undecoded data; they are accessed through getters and setters.
+func fieldStructName(field *protogen.Field) string {
+	switch {
+	case field.Desc.IsWeak():
+		return genid.WeakFieldPrefix_goname + field.GoName
+	case isLazyField(field):
+		return genid.LazyFieldPrefix_goname + field.GoName
+	}
+	return field.GoName
 }
 
 // genMessageDefaultDecls generates consts and vars holding the default
@@ -680,11 +696,18 @@ func genMessageGetterMethods(g *protogen.GeneratedFile, f *fileInfo, m *messageI
 			//	return 0
 			// }
 
+			name := fieldStructName(field)
 			g.P(leadingComments, "func (x *", m.GoIdent, ") Get", field.GoName, "() ", goType, " {")
 			if !field.Desc.HasPresence() || defaultValue == "nil" {
 				g.P("if x != nil {")
 			} else {
-				g.P("if x != nil && x.", field.GoName, " != nil {")
+				g.P("if x != nil && x.", name, " != nil {")
+			}
+
+			if isLazyField(field) {
+				g.P("if x.", genid.LazyFields_goname, " != nil {")
+				g.P(protoimplPackage.Ident("X"), ".UnmarshalLazyField(x, ", field.Desc.Number(), ")")
+				g.P("}")
 			}
 
 			star := ""
@@ -692,7 +715,7 @@ func genMessageGetterMethods(g *protogen.GeneratedFile, f *fileInfo, m *messageI
 				star = "*"
 			}
 
-			g.P("return ", star, " x.", field.GoName)
+			g.P("return ", star, " x.", name)
 			g.P("}")
 			g.P("return ", defaultValue)
 			g.P("}")
@@ -703,6 +726,10 @@ func genMessageGetterMethods(g *protogen.GeneratedFile, f *fileInfo, m *messageI
 
 func genMessageSetterMethods(g *protogen.GeneratedFile, f *fileInfo, m *messageInfo) {
 	for _, field := range m.Fields {
+		if isLazyField(field) {
+			genLazyFieldSetterMethod(g, f, m, field)
+			continue
+		}
 		if !field.Desc.IsWeak() {
 			continue
 		}
@@ -725,6 +752,23 @@ func genMessageSetterMethods(g *protogen.GeneratedFile, f *fileInfo, m *messageI
 	}
 }
 
+// genLazyFieldSetterMethod generates the setter of a lazy field,
+// which drops any undecoded data for the field.
+func genLazyFieldSetterMethod(g *protogen.GeneratedFile, f *fileInfo, m *messageInfo, field *protogen.Field) {
+	genNoInterfacePragma(g, m.isTracked)
+
+	goType, _ := fieldGoType(g, f, field)
+	g.Annotate(m.GoIdent.GoName+".Set"+field.GoName, field.Location)
+	leadingComments := appendDeprecationSuffix("", field.Desc.Options().(*descriptorpb.FieldOptions).GetDeprecated())
+	g.P(leadingComments, "func (x *", m.GoIdent, ") Set", field.GoName, "(v ", goType, ") {")
+	g.P("if x.", genid.LazyFields_goname, " != nil {")
+	g.P(protoimplPackage.Ident("X"), ".DiscardLazyField(x, ", field.Desc.Number(), ")")
+	g.P("}")
+	g.P("x.", fieldStructName(field), " = v")
+	g.P("}")
+	g.P()
+}
+
 // fieldGoType returns the Go type used for a field.
 //
 // If it returns pointer=true, the struct field is a pointer to the type.
@@ -957,16 +957,7 @@ func genMessageKnownFunctions(g *protogen.GeneratedFile, f *fileInfo, m *message
 		g.P("			if md == nil {")
 		g.P("				return false // not within a message")
 		g.P("			}")
-		g.P("			fd := md.Fields().ByName(", protoreflectPackage.Ident("Name"), "(field))")
-		g.P("			// The real field name of a group is the message name.")
-		g.P("			if fd == nil {")
-		g.P("				gd := md.Fields().ByName(", protoreflectPackage.Ident("Name"), "(", stringsPackage.Ident("ToLower"), "(field)))")
-		g.P("				if gd != nil && gd.Kind() == ", protoreflectPackage.Ident("GroupKind"), " && string(gd.Message().Name()) == field {")
-		g.P("					fd = gd")
-		g.P("				}")
-		g.P("			} else if fd.Kind() == ", protoreflectPackage.Ident("GroupKind"), " && string(fd.Message().Name()) != field {")
-		g.P("				fd = nil")
-		g.P("			}")
+		g.P("			fd := findField(md, field)")
 		g.P("			if fd == nil {")
 		g.P("				return false // message has does not have this field")
 		g.P("			}")
@@ -1053,6 +1044,375 @@ func genMessageKnownFunctions(g *protogen.GeneratedFile, f *fileInfo, m *message
 		g.P("}")
 		g.P()
 
+		g.P("// Filter clears all fields of m that are not selected by the mask,")
+		g.P("// including unknown fields.")
+		g.P("//")
+		g.P("// In addition to the paths accepted by IsValid, Filter, Prune, and Overwrite")
+		g.P("// accept paths which select individual entries of a map field by following")
+		g.P("// the name of the map field with a key. A key which contains a dot or")
+		g.P("// a backtick must be quoted with backticks, with any backtick in the key")
+		g.P("// doubled (for example, \"labels.`example.com/name`\"). For maps with")
+		g.P("// message values, the path may continue with fields of the value.")
+		g.P("func (x *FieldMask) Filter(m ", protoPackage.Ident("Message"), ") error {")
+		g.P("	t, err := newPathTree(m, x.GetPaths())")
+		g.P("	if err != nil {")
+		g.P("		return err")
+		g.P("	}")
+		g.P("	t.filter(m.ProtoReflect())")
+		g.P("	return nil")
+		g.P("}")
+		g.P()
+
+		g.P("// Prune clears all fields of m that are selected by the mask.")
+		g.P("// See Filter for the paths which select map entries.")
+		g.P("func (x *FieldMask) Prune(m ", protoPackage.Ident("Message"), ") error {")
+		g.P("	t, err := newPathTree(m, x.GetPaths())")
+		g.P("	if err != nil {")
+		g.P("		return err")
+		g.P("	}")
+		g.P("	t.prune(m.ProtoReflect())")
+		g.P("	return nil")
+		g.P("}")
+		g.P()
+
+		g.P("// Overwrite replaces the fields of dst selected by the mask with the")
+		g.P("// corresponding fields of src, which must have the same message type.")
+		g.P("// A selected field which is not populated in src is cleared in dst.")
+		g.P("// Values are copied, so that dst does not share memory with src.")
+		g.P("// See Filter for the paths which select map entries.")
+		g.P("func (x *FieldMask) Overwrite(dst, src ", protoPackage.Ident("Message"), ") error {")
+		g.P("	dm, sm := dst.ProtoReflect(), src.ProtoReflect()")
+		g.P("	if dm.Descriptor().FullName() != sm.Descriptor().FullName() {")
+		g.P("		return ", protoimplPackage.Ident("X"), ".NewError(\"mismatching message types %q and %q\", dm.Descriptor().FullName(), sm.Descriptor().FullName())")
+		g.P("	}")
+		g.P("	t, err := newPathTree(dst, x.GetPaths())")
+		g.P("	if err != nil {")
+		g.P("		return err")
+		g.P("	}")
+		g.P("	t.overwrite(dm, sm)")
+		g.P("	return nil")
+		g.P("}")
+		g.P()
+
+		g.P("// pathTree is a set of paths parsed according to a message type.")
+		g.P("// Each node selects a field of a message, or an entry of a map field.")
+		g.P("type pathTree struct {")
+		g.P("	fd   ", protoreflectPackage.Ident("FieldDescriptor"), " // the selected field, or the map field of an entry")
+		g.P("	key  ", protoreflectPackage.Ident("MapKey"), "          // the key of a selected map entry")
+		g.P("	all  bool                         // whether the entire field or entry is selected")
+		g.P("	next []*pathTree                  // the fields or entries selected within")
+		g.P("}")
+		g.P()
+
+		g.P("func newPathTree(m ", protoPackage.Ident("Message"), ", paths []string) (*pathTree, error) {")
+		g.P("	root := new(pathTree)")
+		g.P("	md0 := m.ProtoReflect().Descriptor()")
+		g.P("	for _, path := range paths {")
+		g.P("		md := md0")
+		g.P("		var mapField ", protoreflectPackage.Ident("FieldDescriptor"), " // set if the next segment is a map key")
+		g.P("		t := root")
+		g.P("		segs, ok := splitPath(path)")
+		g.P("	segments:")
+		g.P("		for _, seg := range segs {")
+		g.P("			switch {")
+		g.P("			case mapField != nil:")
+		g.P("				k, kok := parseMapKey(mapField.MapKey(), seg)")
+		g.P("				if !kok {")
+		g.P("					ok = false")
+		g.P("					break segments")
+		g.P("				}")
+		g.P("				t = t.entry(mapField, k)")
+		g.P("				md = mapField.MapValue().Message() // may be nil")
+		g.P("				mapField = nil")
+		g.P("			case md != nil:")
+		g.P("				fd := findField(md, seg)")
+		g.P("				if fd == nil {")
+		g.P("					ok = false")
+		g.P("					break segments")
+		g.P("				}")
+		g.P("				t = t.field(fd)")
+		g.P("				md = fd.Message() // may be nil")
+		g.P("				if fd.IsMap() {")
+		g.P("					mapField, md = fd, nil")
+		g.P("				} else if fd.IsList() {")
+		g.P("					md = nil")
+		g.P("				}")
+		g.P("			default:")
+		g.P("				ok = false")
+		g.P("				break segments")
+		g.P("			}")
+		g.P("		}")
+		g.P("		if !ok {")
+		g.P("			return nil, ", protoimplPackage.Ident("X"), ".NewError(\"invalid path %q for message %q\", path, md0.FullName())")
+		g.P("		}")
+		g.P("		t.all, t.next = true, nil")
+		g.P("	}")
+		g.P("	return root, nil")
+		g.P("}")
+		g.P()
+
+		g.P("// field returns the node for the field fd, adding one if necessary.")
+		g.P("func (t *pathTree) field(fd ", protoreflectPackage.Ident("FieldDescriptor"), ") *pathTree {")
+		g.P("	if n := t.lookupField(fd); n != nil {")
+		g.P("		return n")
+		g.P("	}")
+		g.P("	n := &pathTree{fd: fd}")
+		g.P("	t.next = append(t.next, n)")
+		g.P("	return n")
+		g.P("}")
+		g.P()
+
+		g.P("// entry returns the node for the entry k of the map field fd, adding one if necessary.")
+		g.P("func (t *pathTree) entry(fd ", protoreflectPackage.Ident("FieldDescriptor"), ", k ", protoreflectPackage.Ident("MapKey"), ") *pathTree {")
+		g.P("	if n := t.lookupEntry(k); n != nil {")
+		g.P("		return n")
+		g.P("	}")
+		g.P("	n := &pathTree{fd: fd, key: k}")
+		g.P("	t.next = append(t.next, n)")
+		g.P("	return n")
+		g.P("}")
+		g.P()
+
+		g.P("func (t *pathTree) lookupField(fd ", protoreflectPackage.Ident("FieldDescriptor"), ") *pathTree {")
+		g.P("	for _, n := range t.next {")
+		g.P("		if n.fd.Number() == fd.Number() {")
+		g.P("			return n")
+		g.P("		}")
+		g.P("	}")
+		g.P("	return nil")
+		g.P("}")
+		g.P()
+
+		g.P("func (t *pathTree) lookupEntry(k ", protoreflectPackage.Ident("MapKey"), ") *pathTree {")
+		g.P("	for _, n := range t.next {")
+		g.P("		if n.key.Interface() == k.Interface() {")
+		g.P("			return n")
+		g.P("		}")
+		g.P("	}")
+		g.P("	return nil")
+		g.P("}")
+		g.P()
+
+		g.P("func (t *pathTree) filter(m ", protoreflectPackage.Ident("Message"), ") {")
+		g.P("	m.Range(func(fd ", protoreflectPackage.Ident("FieldDescriptor"), ", v ", protoreflectPackage.Ident("Value"), ") bool {")
+		g.P("		switch n := t.lookupField(fd); {")
+		g.P("		case n == nil:")
+		g.P("			m.Clear(fd)")
+		g.P("		case n.all:")
+		g.P("		case fd.IsMap():")
+		g.P("			n.filterMap(m.Mutable(fd).Map())")
+		g.P("		default:")
+		g.P("			n.filter(m.Mutable(fd).Message())")
+		g.P("		}")
+		g.P("		return true")
+		g.P("	})")
+		g.P("	if len(m.GetUnknown()) > 0 {")
+		g.P("		m.SetUnknown(nil)")
+		g.P("	}")
+		g.P("}")
+		g.P()
+
+		g.P("func (t *pathTree) filterMap(mp ", protoreflectPackage.Ident("Map"), ") {")
+		g.P("	var clear []", protoreflectPackage.Ident("MapKey"))
+		g.P("	mp.Range(func(k ", protoreflectPackage.Ident("MapKey"), ", v ", protoreflectPackage.Ident("Value"), ") bool {")
+		g.P("		switch n := t.lookupEntry(k); {")
+		g.P("		case n == nil:")
+		g.P("			clear = append(clear, k)")
+		g.P("		case n.all:")
+		g.P("		default:")
+		g.P("			n.filter(v.Message())")
+		g.P("		}")
+		g.P("		return true")
+		g.P("	})")
+		g.P("	for _, k := range clear {")
+		g.P("		mp.Clear(k)")
+		g.P("	}")
+		g.P("}")
+		g.P()
+
+		g.P("func (t *pathTree) prune(m ", protoreflectPackage.Ident("Message"), ") {")
+		g.P("	for _, n := range t.next {")
+		g.P("		switch fd := n.fd; {")
+		g.P("		case n.all:")
+		g.P("			m.Clear(fd)")
+		g.P("		case !m.Has(fd):")
+		g.P("		case fd.IsMap():")
+		g.P("			mp := m.Mutable(fd).Map()")
+		g.P("			for _, e := range n.next {")
+		g.P("				switch {")
+		g.P("				case !mp.Has(e.key):")
+		g.P("				case e.all:")
+		g.P("					mp.Clear(e.key)")
+		g.P("				default:")
+		g.P("					e.prune(mp.Mutable(e.key).Message())")
+		g.P("				}")
+		g.P("			}")
+		g.P("		default:")
+		g.P("			n.prune(m.Mutable(fd).Message())")
+		g.P("		}")
+		g.P("	}")
+		g.P("}")
+		g.P()
+
+		g.P("func (t *pathTree) overwrite(dst, src ", protoreflectPackage.Ident("Message"), ") {")
+		g.P("	for _, n := range t.next {")
+		g.P("		switch fd := n.fd; {")
+		g.P("		case n.all:")
+		g.P("			copyField(dst, src, fd)")
+		g.P("		case !dst.Has(fd) && !src.Has(fd):")
+		g.P("		case fd.IsMap():")
+		g.P("			dmp, smp := dst.Mutable(fd).Map(), src.Get(fd).Map()")
+		g.P("			for _, e := range n.next {")
+		g.P("				switch {")
+		g.P("				case e.all && smp.Has(e.key):")
+		g.P("					dmp.Set(e.key, copyValue(fd.MapValue(), smp.Get(e.key), dmp.NewValue))")
+		g.P("				case e.all:")
+		g.P("					dmp.Clear(e.key)")
+		g.P("				case !dmp.Has(e.key) && !smp.Has(e.key):")
+		g.P("				case smp.Has(e.key):")
+		g.P("					e.overwrite(dmp.Mutable(e.key).Message(), smp.Get(e.key).Message())")
+		g.P("				default:")
+		g.P("					e.overwrite(dmp.Mutable(e.key).Message(), dmp.NewValue().Message())")
+		g.P("				}")
+		g.P("			}")
+		g.P("		default:")
+		g.P("			n.overwrite(dst.Mutable(fd).Message(), src.Get(fd).Message())")
+		g.P("		}")
+		g.P("	}")
+		g.P("}")
+		g.P()
+
+		g.P("// copyField replaces the field fd of dst with a copy of the field in src.")
+		g.P("func copyField(dst, src ", protoreflectPackage.Ident("Message"), ", fd ", protoreflectPackage.Ident("FieldDescriptor"), ") {")
+		g.P("	dst.Clear(fd)")
+		g.P("	if !src.Has(fd) {")
+		g.P("		return")
+		g.P("	}")
+		g.P("	switch {")
+		g.P("	case fd.IsList():")
+		g.P("		sl, dl := src.Get(fd).List(), dst.Mutable(fd).List()")
+		g.P("		for i := 0; i < sl.Len(); i++ {")
+		g.P("			dl.Append(copyValue(fd, sl.Get(i), dl.NewElement))")
+		g.P("		}")
+		g.P("	case fd.IsMap():")
+		g.P("		smp, dmp := src.Get(fd).Map(), dst.Mutable(fd).Map()")
+		g.P("		smp.Range(func(k ", protoreflectPackage.Ident("MapKey"), ", v ", protoreflectPackage.Ident("Value"), ") bool {")
+		g.P("			dmp.Set(k, copyValue(fd.MapValue(), v, dmp.NewValue))")
+		g.P("			return true")
+		g.P("		})")
+		g.P("	default:")
+		g.P("		dst.Set(fd, copyValue(fd, src.Get(fd), func() ", protoreflectPackage.Ident("Value"), " { return dst.NewField(fd) }))")
+		g.P("	}")
+		g.P("}")
+		g.P()
+
+		g.P("// copyValue returns a copy of the value v of field fd.")
+		g.P("// Message values are copied into a new value obtained from newValue.")
+		g.P("func copyValue(fd ", protoreflectPackage.Ident("FieldDescriptor"), ", v ", protoreflectPackage.Ident("Value"), ", newValue func() ", protoreflectPackage.Ident("Value"), ") ", protoreflectPackage.Ident("Value"), " {")
+		g.P("	switch {")
+		g.P("	case fd.Message() != nil:")
+		g.P("		nv := newValue()")
+		g.P("		", protoPackage.Ident("Merge"), "(nv.Message().Interface(), v.Message().Interface())")
+		g.P("		return nv")
+		g.P("	case fd.Kind() == ", protoreflectPackage.Ident("BytesKind"), ":")
+		g.P("		return ", protoreflectPackage.Ident("ValueOfBytes"), "(append([]byte(nil), v.Bytes()...))")
+		g.P("	default:")
+		g.P("		return v")
+		g.P("	}")
+		g.P("}")
+		g.P()
+
+		g.P("// findField returns the field of md with the given name in a path,")
+		g.P("// or nil if there is none.")
+		g.P("func findField(md ", protoreflectPackage.Ident("MessageDescriptor"), ", name string) ", protoreflectPackage.Ident("FieldDescriptor"), " {")
+		g.P("	fd := md.Fields().ByName(", protoreflectPackage.Ident("Name"), "(name))")
+		g.P("	// The real field name of a group is the message name.")
+		g.P("	if fd == nil {")
+		g.P("		gd := md.Fields().ByName(", protoreflectPackage.Ident("Name"), "(", stringsPackage.Ident("ToLower"), "(name)))")
+		g.P("		if gd != nil && gd.Kind() == ", protoreflectPackage.Ident("GroupKind"), " && string(gd.Message().Name()) == name {")
+		g.P("			fd = gd")
+		g.P("		}")
+		g.P("	} else if fd.Kind() == ", protoreflectPackage.Ident("GroupKind"), " && string(fd.Message().Name()) != name {")
+		g.P("		fd = nil")
+		g.P("	}")
+		g.P("	return fd")
+		g.P("}")
+		g.P()
+
+		g.P("// splitPath splits a path into its dot-separated segments,")
+		g.P("// removing the backticks from quoted segments.")
+		g.P("func splitPath(path string) ([]string, bool) {")
+		g.P("	var segs []string")
+		g.P("	for {")
+		g.P("		var seg string")
+		g.P("		if ", stringsPackage.Ident("HasPrefix"), "(path, \"`\") {")
+		g.P("			var b []byte")
+		g.P("			i := 1")
+		g.P("			for {")
+		g.P("				j := ", stringsPackage.Ident("IndexByte"), "(path[i:], '`')")
+		g.P("				if j < 0 {")
+		g.P("					return nil, false // unterminated quote")
+		g.P("				}")
+		g.P("				b = append(b, path[i:i+j]...)")
+		g.P("				i += j + 1")
+		g.P("				if i < len(path) && path[i] == '`' {")
+		g.P("					b = append(b, '`')")
+		g.P("					i++")
+		g.P("					continue")
+		g.P("				}")
+		g.P("				break")
+		g.P("			}")
+		g.P("			seg, path = string(b), path[i:]")
+		g.P("			if len(path) > 0 && path[0] != '.' {")
+		g.P("				return nil, false")
+		g.P("			}")
+		g.P("		} else if i := ", stringsPackage.Ident("IndexByte"), "(path, '.'); i >= 0 {")
+		g.P("			seg, path = path[:i], path[i:]")
+		g.P("		} else {")
+		g.P("			seg, path = path, \"\"")
+		g.P("		}")
+		g.P("		segs = append(segs, seg)")
+		g.P("		if len(path) == 0 {")
+		g.P("			return segs, true")
+		g.P("		}")
+		g.P("		path = path[1:]")
+		g.P("	}")
+		g.P("}")
+		g.P()
+
+		g.P("// parseMapKey parses s as a key of the map key field fd.")
+		g.P("func parseMapKey(fd ", protoreflectPackage.Ident("FieldDescriptor"), ", s string) (", protoreflectPackage.Ident("MapKey"), ", bool) {")
+		g.P("	switch fd.Kind() {")
+		g.P("	case ", protoreflectPackage.Ident("StringKind"), ":")
+		g.P("		return ", protoreflectPackage.Ident("ValueOfString"), "(s).MapKey(), true")
+		g.P("	case ", protoreflectPackage.Ident("BoolKind"), ":")
+		g.P("		switch s {")
+		g.P("		case \"true\":")
+		g.P("			return ", protoreflectPackage.Ident("ValueOfBool"), "(true).MapKey(), true")
+		g.P("		case \"false\":")
+		g.P("			return ", protoreflectPackage.Ident("ValueOfBool"), "(false).MapKey(), true")
+		g.P("		}")
+		g.P("	case ", protoreflectPackage.Ident("Int32Kind"), ", ", protoreflectPackage.Ident("Sint32Kind"), ", ", protoreflectPackage.Ident("Sfixed32Kind"), ":")
+		g.P("		if v, err := ", strconvPackage.Ident("ParseInt"), "(s, 10, 32); err == nil {")
+		g.P("			return ", protoreflectPackage.Ident("ValueOfInt32"), "(int32(v)).MapKey(), true")
+		g.P("		}")
+		g.P("	case ", protoreflectPackage.Ident("Int64Kind"), ", ", protoreflectPackage.Ident("Sint64Kind"), ", ", protoreflectPackage.Ident("Sfixed64Kind"), ":")
+		g.P("		if v, err := ", strconvPackage.Ident("ParseInt"), "(s, 10, 64); err == nil {")
+		g.P("			return ", protoreflectPackage.Ident("ValueOfInt64"), "(v).MapKey(), true")
+		g.P("		}")
+		g.P("	case ", protoreflectPackage.Ident("Uint32Kind"), ", ", protoreflectPackage.Ident("Fixed32Kind"), ":")
+		g.P("		if v, err := ", strconvPackage.Ident("ParseUint"), "(s, 10, 32); err == nil {")
+		g.P("			return ", protoreflectPackage.Ident("ValueOfUint32"), "(uint32(v)).MapKey(), true")
+		g.P("		}")
+		g.P("	case ", protoreflectPackage.Ident("Uint64Kind"), ", ", protoreflectPackage.Ident("Fixed64Kind"), ":")
+		g.P("		if v, err := ", strconvPackage.Ident("ParseUint"), "(s, 10, 64); err == nil {")
+		g.P("			return ", protoreflectPackage.Ident("ValueOfUint64"), "(v).MapKey(), true")
+		g.P("		}")
+		g.P("	}")
+		g.P("	return ", protoreflectPackage.Ident("MapKey"), "{}, false")
+		g.P("}")
+		g.P()
+
 	case genid.BoolValue_message_fullname,
 		genid.Int32Value_message_fullname,
 		genid.Int64Value_message_fullname,
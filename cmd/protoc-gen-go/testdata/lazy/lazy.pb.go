@@ -0,0 +1,264 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: cmd/protoc-gen-go/testdata/lazy/lazy.proto
+
+package lazy
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Message struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+	lazyFields    protoimpl.LazyFields
+
+	xxx_lazy_LazyMessage  *Message            `protobuf:"bytes,1,opt,name=lazy_message,json=lazyMessage" json:"lazy_message,omitempty"`
+	xxx_lazy_LazyRepeated []*Message          `protobuf:"bytes,2,rep,name=lazy_repeated,json=lazyRepeated" json:"lazy_repeated,omitempty"`
+	EagerMessage          *Message            `protobuf:"bytes,3,opt,name=eager_message,json=eagerMessage" json:"eager_message,omitempty"`
+	EagerMap              map[string]*Message `protobuf:"bytes,4,rep,name=eager_map,json=eagerMap" json:"eager_map,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Types that are assignable to EagerOneof:
+	//	*Message_EagerOneofMessage
+	EagerOneof isMessage_EagerOneof `protobuf_oneof:"eager_oneof"`
+}
+
+func (x *Message) Reset() {
+	*x = Message{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Message) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Message) ProtoMessage() {}
+
+func (x *Message) ProtoReflect() protoreflect.Message {
+	mi := &file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Message.ProtoReflect.Descriptor instead.
+func (*Message) Descriptor() ([]byte, []int) {
+	return file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Message) GetLazyMessage() *Message {
+	if x != nil {
+		if x.lazyFields != nil {
+			protoimpl.X.UnmarshalLazyField(x, 1)
+		}
+		return x.xxx_lazy_LazyMessage
+	}
+	return nil
+}
+
+func (x *Message) GetLazyRepeated() []*Message {
+	if x != nil {
+		if x.lazyFields != nil {
+			protoimpl.X.UnmarshalLazyField(x, 2)
+		}
+		return x.xxx_lazy_LazyRepeated
+	}
+	return nil
+}
+
+func (x *Message) GetEagerMessage() *Message {
+	if x != nil {
+		return x.EagerMessage
+	}
+	return nil
+}
+
+func (x *Message) GetEagerMap() map[string]*Message {
+	if x != nil {
+		return x.EagerMap
+	}
+	return nil
+}
+
+func (m *Message) GetEagerOneof() isMessage_EagerOneof {
+	if m != nil {
+		return m.EagerOneof
+	}
+	return nil
+}
+
+func (x *Message) GetEagerOneofMessage() *Message {
+	if x, ok := x.GetEagerOneof().(*Message_EagerOneofMessage); ok {
+		return x.EagerOneofMessage
+	}
+	return nil
+}
+
+func (x *Message) SetLazyMessage(v *Message) {
+	if x.lazyFields != nil {
+		protoimpl.X.DiscardLazyField(x, 1)
+	}
+	x.xxx_lazy_LazyMessage = v
+}
+
+func (x *Message) SetLazyRepeated(v []*Message) {
+	if x.lazyFields != nil {
+		protoimpl.X.DiscardLazyField(x, 2)
+	}
+	x.xxx_lazy_LazyRepeated = v
+}
+
+type isMessage_EagerOneof interface {
+	isMessage_EagerOneof()
+}
+
+type Message_EagerOneofMessage struct {
+	EagerOneofMessage *Message `protobuf:"bytes,5,opt,name=eager_oneof_message,json=eagerOneofMessage,oneof"`
+}
+
+func (*Message_EagerOneofMessage) isMessage_EagerOneof() {}
+
+var File_cmd_protoc_gen_go_testdata_lazy_lazy_proto protoreflect.FileDescriptor
+
+var file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_rawDesc = []byte{
+	0x0a, 0x2a, 0x63, 0x6d, 0x64, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x2d, 0x67, 0x65, 0x6e,
+	0x2d, 0x67, 0x6f, 0x2f, 0x74, 0x65, 0x73, 0x74, 0x64, 0x61, 0x74, 0x61, 0x2f, 0x6c, 0x61, 0x7a,
+	0x79, 0x2f, 0x6c, 0x61, 0x7a, 0x79, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x13, 0x67, 0x6f,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x2e, 0x6c, 0x61, 0x7a,
+	0x79, 0x22, 0xe3, 0x03, 0x0a, 0x07, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x43, 0x0a,
+	0x0c, 0x6c, 0x61, 0x7a, 0x79, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x67, 0x6f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x63, 0x2e, 0x6c, 0x61, 0x7a, 0x79, 0x2e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x42, 0x02, 0x28, 0x01, 0x52, 0x0b, 0x6c, 0x61, 0x7a, 0x79, 0x4d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x12, 0x45, 0x0a, 0x0d, 0x6c, 0x61, 0x7a, 0x79, 0x5f, 0x72, 0x65, 0x70, 0x65, 0x61,
+	0x74, 0x65, 0x64, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x67, 0x6f, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x2e, 0x6c, 0x61, 0x7a, 0x79, 0x2e,
+	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x42, 0x02, 0x28, 0x01, 0x52, 0x0c, 0x6c, 0x61, 0x7a,
+	0x79, 0x52, 0x65, 0x70, 0x65, 0x61, 0x74, 0x65, 0x64, 0x12, 0x41, 0x0a, 0x0d, 0x65, 0x61, 0x67,
+	0x65, 0x72, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x1c, 0x2e, 0x67, 0x6f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x63, 0x2e, 0x6c, 0x61, 0x7a, 0x79, 0x2e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x52, 0x0c,
+	0x65, 0x61, 0x67, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x4b, 0x0a, 0x09,
+	0x65, 0x61, 0x67, 0x65, 0x72, 0x5f, 0x6d, 0x61, 0x70, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x2a, 0x2e, 0x67, 0x6f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63,
+	0x2e, 0x6c, 0x61, 0x7a, 0x79, 0x2e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x45, 0x61,
+	0x67, 0x65, 0x72, 0x4d, 0x61, 0x70, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x42, 0x02, 0x28, 0x01, 0x52,
+	0x08, 0x65, 0x61, 0x67, 0x65, 0x72, 0x4d, 0x61, 0x70, 0x12, 0x52, 0x0a, 0x13, 0x65, 0x61, 0x67,
+	0x65, 0x72, 0x5f, 0x6f, 0x6e, 0x65, 0x6f, 0x66, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x67, 0x6f, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x2e, 0x6c, 0x61, 0x7a, 0x79, 0x2e, 0x4d, 0x65, 0x73,
+	0x73, 0x61, 0x67, 0x65, 0x42, 0x02, 0x28, 0x01, 0x48, 0x00, 0x52, 0x11, 0x65, 0x61, 0x67, 0x65,
+	0x72, 0x4f, 0x6e, 0x65, 0x6f, 0x66, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x1a, 0x59, 0x0a,
+	0x0d, 0x45, 0x61, 0x67, 0x65, 0x72, 0x4d, 0x61, 0x70, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10,
+	0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79,
+	0x12, 0x32, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x1c, 0x2e, 0x67, 0x6f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63,
+	0x2e, 0x6c, 0x61, 0x7a, 0x79, 0x2e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x52, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x42, 0x0d, 0x0a, 0x0b, 0x65, 0x61, 0x67, 0x65,
+	0x72, 0x5f, 0x6f, 0x6e, 0x65, 0x6f, 0x66, 0x42, 0x3c, 0x5a, 0x3a, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
+	0x65, 0x2e, 0x67, 0x6f, 0x6c, 0x61, 0x6e, 0x67, 0x2e, 0x6f, 0x72, 0x67, 0x2f, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x63, 0x6d, 0x64, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63,
+	0x2d, 0x67, 0x65, 0x6e, 0x2d, 0x67, 0x6f, 0x2f, 0x74, 0x65, 0x73, 0x74, 0x64, 0x61, 0x74, 0x61,
+	0x2f, 0x6c, 0x61, 0x7a, 0x79,
+}
+
+var (
+	file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_rawDescOnce sync.Once
+	file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_rawDescData = file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_rawDesc
+)
+
+func file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_rawDescGZIP() []byte {
+	file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_rawDescOnce.Do(func() {
+		file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_rawDescData = protoimpl.X.CompressGZIP(file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_rawDescData)
+	})
+	return file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_rawDescData
+}
+
+var file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_goTypes = []interface{}{
+	(*Message)(nil), // 0: goproto.protoc.lazy.Message
+	nil,             // 1: goproto.protoc.lazy.Message.EagerMapEntry
+}
+var file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_depIdxs = []int32{
+	0, // 0: goproto.protoc.lazy.Message.lazy_message:type_name -> goproto.protoc.lazy.Message
+	0, // 1: goproto.protoc.lazy.Message.lazy_repeated:type_name -> goproto.protoc.lazy.Message
+	0, // 2: goproto.protoc.lazy.Message.eager_message:type_name -> goproto.protoc.lazy.Message
+	1, // 3: goproto.protoc.lazy.Message.eager_map:type_name -> goproto.protoc.lazy.Message.EagerMapEntry
+	0, // 4: goproto.protoc.lazy.Message.eager_oneof_message:type_name -> goproto.protoc.lazy.Message
+	0, // 5: goproto.protoc.lazy.Message.EagerMapEntry.value:type_name -> goproto.protoc.lazy.Message
+	6, // [6:6] is the sub-list for method output_type
+	6, // [6:6] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_init() }
+func file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_init() {
+	if File_cmd_protoc_gen_go_testdata_lazy_lazy_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Message); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			case 3:
+				return &v.lazyFields
+			case 4:
+				return &v.xxx_lazy_LazyMessage
+			case 5:
+				return &v.xxx_lazy_LazyRepeated
+			default:
+				return nil
+			}
+		}
+	}
+	file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_msgTypes[0].OneofWrappers = []interface{}{
+		(*Message_EagerOneofMessage)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_goTypes,
+		DependencyIndexes: file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_depIdxs,
+		MessageInfos:      file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_msgTypes,
+	}.Build()
+	File_cmd_protoc_gen_go_testdata_lazy_lazy_proto = out.File
+	file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_rawDesc = nil
+	file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_goTypes = nil
+	file_cmd_protoc_gen_go_testdata_lazy_lazy_proto_depIdxs = nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The prototextfmt binary formats files in the protocol buffer text format.
+//
+// Without arguments, it formats its standard input to its standard output.
+// Given files, it prints them formatted, or with -w rewrites them in place.
+//
+// Formatting uses the schema of the message of a file if a descriptor set
+// containing it is given with -descriptor_set, and the name of the message
+// is given with -message or in a comment at the start of the file:
+//
+//	# proto-message: pkg.Message
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"google.golang.org/protobuf/encoding/textfmt"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func main() {
+	var (
+		write         = flag.Bool("w", false, "write the result to the files instead of standard output")
+		list          = flag.Bool("l", false, "list the files whose formatting differs")
+		indent        = flag.String("indent", "  ", "indentation of each level of nesting")
+		sortFields    = flag.Bool("sort", false, "sort the fields of every message")
+		messageColon  = flag.Bool("message_colon", false, "write a ':' before message values")
+		listSyntax    = flag.Bool("list_syntax", false, "write repeated scalar fields as lists")
+		descriptorSet = flag.String("descriptor_set", "", "file containing a FileDescriptorSet with the schema")
+		message       = flag.String("message", "", "full name of the message of the files")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS]... [FILES]...\n\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "Format files in the protocol buffer text format.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	opts := textfmt.Options{
+		Indent:       *indent,
+		SortFields:   *sortFields,
+		MessageColon: *messageColon,
+		ListSyntax:   *listSyntax,
+		Message:      protoreflect.FullName(*message),
+	}
+	if *descriptorSet != "" {
+		b, err := ioutil.ReadFile(*descriptorSet)
+		if err != nil {
+			exitf("%v", err)
+		}
+		fds := new(descriptorpb.FileDescriptorSet)
+		if err := proto.Unmarshal(b, fds); err != nil {
+			exitf("%v: %v", *descriptorSet, err)
+		}
+		files, err := protodesc.NewFiles(fds)
+		if err != nil {
+			exitf("%v: %v", *descriptorSet, err)
+		}
+		opts.Resolver = files
+	}
+
+	if flag.NArg() == 0 {
+		if *write || *list {
+			exitf("cannot use -w or -l with standard input")
+		}
+		in, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			exitf("%v", err)
+		}
+		out, err := opts.Format(in)
+		if err != nil {
+			exitf("<stdin>: %v", err)
+		}
+		os.Stdout.Write(out)
+		return
+	}
+
+	failed := false
+	for _, path := range flag.Args() {
+		if err := formatFile(path, opts, *write, *list); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// formatFile formats the file at path, printing the result, or its path
+// if list is set and its formatting differs, or rewriting it if write is set.
+func formatFile(path string, opts textfmt.Options, write, list bool) error {
+	in, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	out, err := opts.Format(in)
+	if err != nil {
+		return fmt.Errorf("%v: %v", path, err)
+	}
+	changed := !bytes.Equal(in, out)
+	if list && changed {
+		fmt.Println(path)
+	}
+	if write {
+		if changed {
+			return ioutil.WriteFile(path, out, 0666)
+		}
+		return nil
+	}
+	if !list {
+		os.Stdout.Write(out)
+	}
+	return nil
+}
+
+func exitf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,282 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protoparse
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// tokenKind is the kind of a token.
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenInt
+	tokenFloat
+	tokenString
+	tokenPunct
+)
+
+// pos is a position in a source file. Lines and columns are zero-based,
+// as in SourceCodeInfo; columns count bytes.
+type pos struct {
+	line, col int
+}
+
+// token is a lexical token.
+type token struct {
+	kind   tokenKind
+	text   string // as written in the source
+	start  pos
+	end    pos // position after the last character
+	off    int // byte offset of the start
+	endOff int // byte offset after the end
+
+	// comments are the comments between the previous token and this one.
+	comments []comment
+	// blankBefore reports whether there is a blank line between the last
+	// comment, or the previous token if there is none, and this token.
+	blankBefore bool
+	// prevLine is the line of the end of the previous token, or -1.
+	prevLine int
+}
+
+// comment is a comment, or a block of consecutive line comments.
+type comment struct {
+	text       string // without comment markers, as in SourceCodeInfo
+	start, end pos
+	line       bool // whether it is a line comment
+	// blankAfter reports whether the comment is followed by a blank line.
+	blankAfter bool
+}
+
+type lexer struct {
+	filename string
+	in       string
+	off      int
+	p        pos
+}
+
+func (l *lexer) errorf(p pos, format string, args ...interface{}) error {
+	return newError(l.filename, p, format, args...)
+}
+
+// advance moves past the next n bytes.
+func (l *lexer) advance(n int) {
+	for _, c := range []byte(l.in[l.off : l.off+n]) {
+		if c == '\n' {
+			l.p.line++
+			l.p.col = 0
+		} else {
+			l.p.col++
+		}
+	}
+	l.off += n
+}
+
+// next returns the next token.
+func (l *lexer) next() (token, error) {
+	var comments []comment
+	lastLine := l.p.line // line of the end of the previous token or comment
+	prevLine := l.p.line // line of the end of the previous token
+	if l.off == 0 {
+		prevLine = -1
+	}
+	blank := false
+	for {
+		l.skipSpace()
+		if l.p.line > lastLine+1 {
+			blank = true
+			if n := len(comments); n > 0 {
+				comments[n-1].blankAfter = true
+			}
+		}
+		c, ok, err := l.comment()
+		if err != nil {
+			return token{}, err
+		}
+		if !ok {
+			break
+		}
+		// Consecutive line comments form a single block, except for one on
+		// the line of the previous token.
+		if n := len(comments); n > 0 && !blank && comments[n-1].line && c.line &&
+			c.start.line == comments[n-1].end.line && comments[n-1].start.line != prevLine {
+			comments[n-1].text += c.text
+			comments[n-1].end = c.end
+		} else {
+			comments = append(comments, c)
+		}
+		blank = false
+		lastLine = l.p.line
+		if c.line && c.end.col == 0 {
+			// The newline ending a line comment is part of it.
+			lastLine--
+		}
+	}
+
+	tok := token{start: l.p, off: l.off, comments: comments, blankBefore: blank, prevLine: prevLine}
+	if l.off == len(l.in) {
+		tok.end = l.p
+		tok.endOff = l.off
+		return tok, nil
+	}
+	s := l.in[l.off:]
+	switch c := s[0]; {
+	case isLetter(c):
+		n := 1
+		for n < len(s) && (isLetter(s[n]) || isDigit(s[n])) {
+			n++
+		}
+		tok.kind = tokenIdent
+		l.advance(n)
+	case isDigit(c) || (c == '.' && len(s) > 1 && isDigit(s[1])):
+		n, float := scanNumber(s)
+		if n < len(s) && (isLetter(s[n]) || isDigit(s[n]) || s[n] == '.') {
+			return token{}, l.errorf(l.p, "invalid number %q", s[:n+1])
+		}
+		tok.kind = tokenInt
+		if float {
+			tok.kind = tokenFloat
+		}
+		l.advance(n)
+	case c == '"' || c == '\'':
+		n := 1
+		for ; n < len(s) && s[n] != c; n++ {
+			switch s[n] {
+			case '\\':
+				n++
+			case '\n':
+				n = len(s)
+			}
+		}
+		if n >= len(s) {
+			return token{}, l.errorf(l.p, "unterminated string")
+		}
+		tok.kind = tokenString
+		l.advance(n + 1)
+	default:
+		r, n := utf8.DecodeRuneInString(s)
+		if r == utf8.RuneError || r >= utf8.RuneSelf || !strings.ContainsRune("{}[]()<>;,.=-+:/", r) {
+			return token{}, l.errorf(l.p, "invalid character %q", r)
+		}
+		tok.kind = tokenPunct
+		l.advance(n)
+	}
+	tok.text = l.in[tok.off:l.off]
+	tok.end = l.p
+	tok.endOff = l.off
+	return tok, nil
+}
+
+func (l *lexer) skipSpace() {
+	n := 0
+	for n < len(l.in)-l.off {
+		switch l.in[l.off+n] {
+		case ' ', '\t', '\n', '\r', '\v', '\f':
+			n++
+			continue
+		}
+		break
+	}
+	l.advance(n)
+}
+
+// comment reads a comment, if there is one.
+func (l *lexer) comment() (c comment, ok bool, err error) {
+	s := l.in[l.off:]
+	c.start = l.p
+	switch {
+	case strings.HasPrefix(s, "//"):
+		n := strings.IndexByte(s, '\n') + 1
+		if n == 0 {
+			n = len(s)
+		}
+		c.text = strings.TrimSuffix(s[2:n], "\r\n")
+		c.text = strings.TrimSuffix(c.text, "\n") + "\n"
+		c.line = true
+		l.advance(n)
+	case strings.HasPrefix(s, "/*"):
+		n := strings.Index(s[2:], "*/")
+		if n < 0 {
+			return c, false, l.errorf(l.p, "unterminated comment")
+		}
+		c.text = blockCommentText(s[2 : 2+n])
+		l.advance(n + 4)
+	default:
+		return c, false, nil
+	}
+	c.end = l.p
+	return c, true, nil
+}
+
+// blockCommentText returns the text of a block comment as in SourceCodeInfo,
+// in which a leading '*' on each line after the first is removed.
+func blockCommentText(s string) string {
+	lines := strings.Split(s, "\n")
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimLeft(lines[i], " \t")
+		if strings.HasPrefix(line, "*") {
+			lines[i] = line[1:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// scanNumber returns the length of the number at the start of s, and
+// whether it is a floating-point number.
+func scanNumber(s string) (n int, float bool) {
+	if len(s) > 1 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		n = 2
+		for n < len(s) && isHexDigit(s[n]) {
+			n++
+		}
+		return n, false
+	}
+	for n < len(s) && isDigit(s[n]) {
+		n++
+	}
+	if n < len(s) && s[n] == '.' {
+		float = true
+		n++
+		for n < len(s) && isDigit(s[n]) {
+			n++
+		}
+	}
+	if n < len(s) && (s[n] == 'e' || s[n] == 'E') {
+		m := n + 1
+		if m < len(s) && (s[m] == '+' || s[m] == '-') {
+			m++
+		}
+		if m < len(s) && isDigit(s[m]) {
+			float = true
+			for n = m; n < len(s) && isDigit(s[n]); n++ {
+			}
+		}
+	}
+	return n, float
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of file"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
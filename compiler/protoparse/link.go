@@ -0,0 +1,489 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protoparse
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/internal/encoding/defval"
+	"google.golang.org/protobuf/internal/errors"
+	"google.golang.org/protobuf/internal/strs"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// symKind is the kind of a named declaration.
+type symKind int
+
+const (
+	symPackage symKind = iota
+	symMessage
+	symEnum
+	symEnumValue
+	symField
+	symOneof
+	symExtension
+	symService
+	symMethod
+)
+
+func (k symKind) String() string {
+	switch k {
+	case symPackage:
+		return "package"
+	case symMessage:
+		return "message"
+	case symEnum:
+		return "enum"
+	case symEnumValue:
+		return "enum value"
+	case symField:
+		return "field"
+	case symOneof:
+		return "oneof"
+	case symExtension:
+		return "extension"
+	case symService:
+		return "service"
+	case symMethod:
+		return "method"
+	default:
+		return "<unknown>"
+	}
+}
+
+// isAggregate reports whether declarations of the kind contain other
+// declarations.
+func (k symKind) isAggregate() bool {
+	switch k {
+	case symPackage, symMessage, symEnum, symService:
+		return true
+	}
+	return false
+}
+
+// linker resolves the names in a file to fully-qualified names.
+type linker struct {
+	c *compiler
+	p *parser
+
+	local    map[string]*decl // declarations of the file
+	visible  map[string]bool  // paths of files whose declarations are visible
+	packages map[string]bool  // visible packages, along with their prefixes
+	enums    map[string]*descriptorpb.EnumDescriptorProto
+}
+
+func newLinker(c *compiler, p *parser) *linker {
+	l := &linker{
+		c:        c,
+		p:        p,
+		local:    make(map[string]*decl),
+		visible:  make(map[string]bool),
+		packages: make(map[string]bool),
+		enums:    make(map[string]*descriptorpb.EnumDescriptorProto),
+	}
+	l.addPackage(p.file.GetPackage())
+	for _, dep := range p.file.Dependency {
+		l.addVisible(l.c.results[dep].fd)
+	}
+	return l
+}
+
+// addVisible adds fd to the visible files, along with the files it imports
+// publicly.
+func (l *linker) addVisible(fd protoreflect.FileDescriptor) {
+	if l.visible[fd.Path()] {
+		return
+	}
+	l.visible[fd.Path()] = true
+	l.addPackage(string(fd.Package()))
+	imports := fd.Imports()
+	for i := 0; i < imports.Len(); i++ {
+		if imp := imports.Get(i); imp.IsPublic && !imp.IsPlaceholder() {
+			l.addVisible(imp.FileDescriptor)
+		}
+	}
+}
+
+func (l *linker) addPackage(name string) {
+	for name != "" {
+		l.packages[name] = true
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+}
+
+func (l *linker) errorf(at pos, format string, args ...interface{}) error {
+	return newError(l.p.lex.filename, at, format, args...)
+}
+
+func (l *linker) link() error {
+	for _, d := range l.p.decls {
+		if prev, ok := l.local[d.name]; ok {
+			return l.errorf(d.pos, "%v is already defined as a %v at line %d", d.name, prev.kind, prev.pos.line+1)
+		}
+		if l.packages[d.name] {
+			return l.errorf(d.pos, "%v is already defined as a package", d.name)
+		}
+		if desc, err := l.c.files.FindDescriptorByName(protoreflect.FullName(d.name)); err == nil {
+			return l.errorf(d.pos, "%v is already defined in %q", d.name, desc.ParentFile().Path())
+		}
+		l.local[d.name] = d
+	}
+	l.rangeEnums(l.p.file.GetPackage(), l.p.file.EnumType, l.p.file.MessageType)
+
+	for _, ref := range l.p.refs {
+		if err := l.resolve(ref); err != nil {
+			return err
+		}
+	}
+	if err := l.checkMessages(l.p.file.GetPackage(), l.p.file.MessageType); err != nil {
+		return err
+	}
+	setJSONNames(l.p.file.Extension)
+	l.setJSONNames(l.p.file.MessageType)
+	return nil
+}
+
+// rangeEnums records the enums declared in the file by their full names.
+func (l *linker) rangeEnums(scope string, enums []*descriptorpb.EnumDescriptorProto, msgs []*descriptorpb.DescriptorProto) {
+	for _, ed := range enums {
+		l.enums[qualify(scope, ed.GetName())] = ed
+	}
+	for _, md := range msgs {
+		l.rangeEnums(qualify(scope, md.GetName()), md.EnumType, md.NestedType)
+	}
+}
+
+// lookup returns the kind of the declaration with the given full name,
+// and the file it is declared in, which is empty for the file itself.
+func (l *linker) lookup(name string) (kind symKind, file string, ok bool) {
+	if d, ok := l.local[name]; ok {
+		return d.kind, "", true
+	}
+	if l.packages[name] {
+		return symPackage, "", true
+	}
+	desc, err := l.c.files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return 0, "", false
+	}
+	switch desc := desc.(type) {
+	case protoreflect.MessageDescriptor:
+		kind = symMessage
+	case protoreflect.EnumDescriptor:
+		kind = symEnum
+	case protoreflect.EnumValueDescriptor:
+		kind = symEnumValue
+	case protoreflect.FieldDescriptor:
+		kind = symField
+		if desc.IsExtension() {
+			kind = symExtension
+		}
+	case protoreflect.OneofDescriptor:
+		kind = symOneof
+	case protoreflect.ServiceDescriptor:
+		kind = symService
+	case protoreflect.MethodDescriptor:
+		kind = symMethod
+	}
+	return kind, desc.ParentFile().Path(), true
+}
+
+// lookupName resolves name relative to scope by the rules of C++: the
+// innermost scope in which the first component of the name is declared is
+// the one in which the name is resolved. Only declarations for which accept
+// returns true are considered for names with a single component.
+func (l *linker) lookupName(name, scope string, at pos, accept func(symKind) bool) (string, symKind, error) {
+	full, kind, file, err := l.lookupNameFile(name, scope, at, accept)
+	if err != nil {
+		return "", 0, err
+	}
+	if file != "" && !l.visible[file] {
+		return "", 0, l.errorf(at, "%v is declared in %q, which is not imported", name, file)
+	}
+	return full, kind, nil
+}
+
+func (l *linker) lookupNameFile(name, scope string, at pos, accept func(symKind) bool) (full string, kind symKind, file string, err error) {
+	if strings.HasPrefix(name, ".") {
+		kind, file, ok := l.lookup(name[1:])
+		if !ok {
+			return "", 0, "", l.errorf(at, "%v is not defined", name)
+		}
+		return name[1:], kind, file, nil
+	}
+	first := name
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		first = name[:i]
+	}
+	for {
+		kind, file, ok := l.lookup(qualify(scope, first))
+		switch {
+		case !ok:
+		case first == name && accept(kind):
+			return qualify(scope, name), kind, file, nil
+		case first != name && kind.isAggregate():
+			full := qualify(scope, name)
+			kind, file, ok := l.lookup(full)
+			if !ok {
+				return "", 0, "", l.errorf(at, "%v is resolved to %v, which is not defined; the innermost scope is searched first, so consider using a leading '.' to refer to the outermost scope", name, full)
+			}
+			return full, kind, file, nil
+		}
+		if scope == "" {
+			return "", 0, "", l.errorf(at, "%v is not defined", name)
+		}
+		if i := strings.LastIndexByte(scope, '.'); i >= 0 {
+			scope = scope[:i]
+		} else {
+			scope = ""
+		}
+	}
+}
+
+func isType(k symKind) bool {
+	return k == symMessage || k == symEnum
+}
+
+func (l *linker) resolve(ref *typeRef) error {
+	full, kind, err := l.lookupName(ref.name, ref.scope, ref.pos, isType)
+	if err != nil {
+		return err
+	}
+	switch ref.kind {
+	case refFieldType:
+		fd := ref.field
+		switch {
+		case kind == symMessage && fd.Type == nil:
+			fd.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+		case kind == symEnum && fd.Type == nil:
+			fd.Type = descriptorpb.FieldDescriptorProto_TYPE_ENUM.Enum()
+		case fd.Type == nil:
+			return l.errorf(ref.pos, "%v is not a type", ref.name)
+		}
+		fd.TypeName = proto.String("." + full)
+		if ref.defaultValue != nil {
+			if err := l.setEnumDefault(fd, full, kind, ref.defaultValue); err != nil {
+				return err
+			}
+		}
+	case refExtendee:
+		if kind != symMessage {
+			return l.errorf(ref.pos, "%v is not a message", ref.name)
+		}
+		ref.field.Extendee = proto.String("." + full)
+	case refInputType, refOutputType:
+		if kind != symMessage {
+			return l.errorf(ref.pos, "%v is not a message", ref.name)
+		}
+		if ref.kind == refInputType {
+			ref.method.InputType = proto.String("." + full)
+		} else {
+			ref.method.OutputType = proto.String("." + full)
+		}
+	}
+	return nil
+}
+
+// setEnumDefault sets the default value of a field whose type has the given
+// full name and kind.
+func (l *linker) setEnumDefault(fd *descriptorpb.FieldDescriptorProto, typeName string, kind symKind, v *value) error {
+	if kind != symEnum {
+		return l.errorf(v.pos, "fields of message types cannot have default values")
+	}
+	if v.kind != tokenIdent || v.neg || v.aggregate != nil {
+		return l.errorf(v.pos, "default value %v is not an enum value name", v)
+	}
+	found := false
+	if ed, ok := l.enums[typeName]; ok {
+		for _, vd := range ed.Value {
+			found = found || vd.GetName() == v.text
+		}
+	} else if desc, err := l.c.files.FindDescriptorByName(protoreflect.FullName(typeName)); err == nil {
+		found = desc.(protoreflect.EnumDescriptor).Values().ByName(protoreflect.Name(v.text)) != nil
+	}
+	if !found {
+		return l.errorf(v.pos, "enum %v has no value named %v", typeName, v.text)
+	}
+	fd.DefaultValue = proto.String(v.text)
+	return nil
+}
+
+// defaultString returns the default value v of a field of a scalar type
+// in the form of a FieldDescriptorProto.
+func defaultString(typ descriptorpb.FieldDescriptorProto_Type, v value) (string, error) {
+	k := protoreflect.Kind(typ)
+	pv, err := scalarValue(k, v)
+	if err != nil {
+		return "", err
+	}
+	if k == protoreflect.StringKind {
+		return v.text, nil
+	}
+	return defval.Marshal(pv, nil, k, defval.Descriptor)
+}
+
+// scalarValue returns the value v for a field of kind k,
+// which is not a message, group or enum.
+func scalarValue(k protoreflect.Kind, v value) (protoreflect.Value, error) {
+	if v.aggregate != nil {
+		return protoreflect.Value{}, errors.New("%v is not a valid %v value", v, k)
+	}
+	switch k {
+	case protoreflect.BoolKind:
+		if v.kind == tokenIdent && !v.neg && (v.text == "true" || v.text == "false") {
+			return protoreflect.ValueOfBool(v.text == "true"), nil
+		}
+	case protoreflect.StringKind:
+		if v.kind == tokenString {
+			if !utf8.ValidString(v.text) {
+				return protoreflect.Value{}, errors.New("string value %v is not valid UTF-8", v)
+			}
+			return protoreflect.ValueOfString(v.text), nil
+		}
+	case protoreflect.BytesKind:
+		if v.kind == tokenString {
+			return protoreflect.ValueOfBytes([]byte(v.text)), nil
+		}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		var f float64
+		switch v.kind {
+		case tokenInt, tokenFloat:
+			var err error
+			if f, err = strconv.ParseFloat(v.text, 64); err != nil {
+				if n, err := strconv.ParseUint(v.text, 0, 64); err == nil {
+					f = float64(n)
+				} else {
+					f = math.Inf(1)
+				}
+			}
+		case tokenIdent:
+			switch v.text {
+			case "inf":
+				f = math.Inf(1)
+			case "nan":
+				f = math.NaN()
+			default:
+				return protoreflect.Value{}, errors.New("%v is not a valid %v value", v, k)
+			}
+		default:
+			return protoreflect.Value{}, errors.New("%v is not a valid %v value", v, k)
+		}
+		if v.neg {
+			f = -f
+		}
+		if k == protoreflect.FloatKind {
+			return protoreflect.ValueOfFloat32(float32(f)), nil
+		}
+		return protoreflect.ValueOfFloat64(f), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if v.kind != tokenInt {
+			break
+		}
+		n, err := strconv.ParseUint(v.text, 0, 64)
+		if err != nil {
+			return protoreflect.Value{}, errors.New("integer %v is out of range for %v", v, k)
+		}
+		switch k {
+		case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+			if (!v.neg && n > math.MaxInt32) || (v.neg && n > -math.MinInt32) {
+				return protoreflect.Value{}, errors.New("integer %v is out of range for %v", v, k)
+			}
+			if v.neg {
+				return protoreflect.ValueOfInt32(int32(-int64(n))), nil
+			}
+			return protoreflect.ValueOfInt32(int32(n)), nil
+		case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+			if (!v.neg && n > math.MaxInt64) || (v.neg && n > 1<<63) {
+				return protoreflect.Value{}, errors.New("integer %v is out of range for %v", v, k)
+			}
+			if v.neg {
+				return protoreflect.ValueOfInt64(int64(-n)), nil
+			}
+			return protoreflect.ValueOfInt64(int64(n)), nil
+		case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+			if v.neg || n > math.MaxUint32 {
+				return protoreflect.Value{}, errors.New("integer %v is out of range for %v", v, k)
+			}
+			return protoreflect.ValueOfUint32(uint32(n)), nil
+		default:
+			if v.neg {
+				return protoreflect.Value{}, errors.New("integer %v is out of range for %v", v, k)
+			}
+			return protoreflect.ValueOfUint64(n), nil
+		}
+	}
+	return protoreflect.Value{}, errors.New("%v is not a valid %v value", v, k)
+}
+
+// checkMessages checks that the numbers of the fields of the messages are
+// neither used twice nor reserved, and that the names of the fields are
+// not reserved.
+func (l *linker) checkMessages(scope string, msgs []*descriptorpb.DescriptorProto) error {
+	for _, md := range msgs {
+		fullName := qualify(scope, md.GetName())
+		if err := l.checkMessages(fullName, md.NestedType); err != nil {
+			return err
+		}
+		if md.GetOptions().GetMapEntry() {
+			continue
+		}
+		numbers := make(map[int32]string)
+		for _, fd := range md.Field {
+			at := l.local[qualify(fullName, fd.GetName())].pos
+			n := fd.GetNumber()
+			if prev, ok := numbers[n]; ok {
+				return l.errorf(at, "field number %d has already been used in %v by field %v", n, fullName, prev)
+			}
+			numbers[n] = fd.GetName()
+			for _, r := range md.ReservedRange {
+				if r.GetStart() <= n && n < r.GetEnd() {
+					return l.errorf(at, "field %v uses reserved number %d", fd.GetName(), n)
+				}
+			}
+			for _, r := range md.ExtensionRange {
+				if r.GetStart() <= n && n < r.GetEnd() {
+					return l.errorf(at, "field %v uses number %d, which is in an extension range", fd.GetName(), n)
+				}
+			}
+			for _, name := range md.ReservedName {
+				if name == fd.GetName() {
+					return l.errorf(at, "field %v uses a reserved name", fd.GetName())
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// setJSONNames sets the JSON names of the fields and extensions of the
+// messages which have none, as protoc does.
+func (l *linker) setJSONNames(msgs []*descriptorpb.DescriptorProto) {
+	for _, md := range msgs {
+		setJSONNames(md.Field)
+		setJSONNames(md.Extension)
+		l.setJSONNames(md.NestedType)
+	}
+}
+
+func setJSONNames(fields []*descriptorpb.FieldDescriptorProto) {
+	for _, fd := range fields {
+		if fd.JsonName == nil {
+			fd.JsonName = proto.String(strs.JSONCamelCase(fd.GetName()))
+		}
+	}
+}
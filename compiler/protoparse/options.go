@@ -0,0 +1,281 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protoparse
+
+import (
+	"strings"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// interpreter sets the options of the declarations in a file.
+type interpreter struct {
+	l *linker
+
+	// local contains the file being compiled, without any options, for
+	// looking up the custom options it declares.
+	local *protoregistry.Files
+
+	xts map[protoreflect.ExtensionDescriptor]protoreflect.ExtensionType
+}
+
+func newInterpreter(l *linker) *interpreter {
+	return &interpreter{l: l, xts: make(map[protoreflect.ExtensionDescriptor]protoreflect.ExtensionType)}
+}
+
+func (in *interpreter) interpret() error {
+	p := in.l.p
+	if len(p.options) == 0 {
+		return nil
+	}
+
+	// Standard options are set first, since some of them are needed to
+	// build the file, which is needed in turn for custom options declared in
+	// the file itself.
+	owners := make(map[proto.Message]bool)
+	for _, o := range p.options {
+		if !o.name[0].ext {
+			if err := in.set(o); err != nil {
+				return err
+			}
+			owners[o.owner] = true
+		}
+	}
+	fd, err := p.newFile(in.l.c.files)
+	if err != nil {
+		return err
+	}
+	in.local = new(protoregistry.Files)
+	if err := in.local.RegisterFile(fd); err != nil {
+		return &Error{Filename: p.lex.filename, err: err}
+	}
+	for _, o := range p.options {
+		if o.name[0].ext {
+			if err := in.set(o); err != nil {
+				return err
+			}
+			owners[o.owner] = true
+		}
+	}
+
+	// Unmarshal the options afresh, so that they are as if they were
+	// unmarshaled from the output of protoc: custom options are unknown
+	// fields unless their types are linked into the program.
+	for _, o := range p.options {
+		if !owners[o.owner] {
+			continue
+		}
+		delete(owners, o.owner)
+		m := o.owner.ProtoReflect()
+		fd := m.Descriptor().Fields().ByName("options")
+		opts := m.Get(fd).Message()
+		b, err := proto.MarshalOptions{Deterministic: true}.Marshal(opts.Interface())
+		if err != nil {
+			return newError(p.lex.filename, o.loc.start, "invalid options: %v", err)
+		}
+		opts = opts.New()
+		if err := proto.Unmarshal(b, opts.Interface()); err != nil {
+			return newError(p.lex.filename, o.loc.start, "invalid options: %v", err)
+		}
+		m.Set(fd, protoreflect.ValueOfMessage(opts))
+	}
+	return nil
+}
+
+func (in *interpreter) errorf(at pos, format string, args ...interface{}) error {
+	return newError(in.l.p.lex.filename, at, format, args...)
+}
+
+// set sets the option o, and the path of its location.
+func (in *interpreter) set(o *optionInfo) error {
+	owner := o.owner.ProtoReflect()
+	m := owner.Mutable(owner.Descriptor().Fields().ByName("options")).Message()
+	path := o.loc.pb.Path
+	for i, part := range o.name {
+		var fd protoreflect.FieldDescriptor
+		if part.ext {
+			xd, err := in.findExtension(part, o.scope, m.Descriptor())
+			if err != nil {
+				return err
+			}
+			fd = xd
+		} else {
+			fd = m.Descriptor().Fields().ByName(protoreflect.Name(part.name))
+			switch {
+			case fd == nil:
+				return in.errorf(part.pos, "option %v is unknown for %v", part.name, m.Descriptor().FullName())
+			case fd.Name() == "uninterpreted_option":
+				return in.errorf(part.pos, "option %v cannot be set", part.name)
+			case fd.Name() == "map_entry" && i == 0:
+				return in.errorf(part.pos, "option map_entry cannot be set; use map<K, V> fields instead")
+			}
+		}
+		path = append(path, int32(fd.Number()))
+
+		if i < len(o.name)-1 {
+			if fd.Message() == nil || fd.IsList() {
+				return in.errorf(part.pos, "option %v is not a singular message", o)
+			}
+			m = m.Mutable(fd).Message()
+			continue
+		}
+
+		v, err := in.value(o, m, fd)
+		if err != nil {
+			return err
+		}
+		switch {
+		case fd.IsList():
+			m.Mutable(fd).List().Append(v)
+		case m.Has(fd):
+			return in.errorf(part.pos, "option %v was already set", o)
+		default:
+			m.Set(fd, v)
+		}
+	}
+	o.loc.pb.Path = path
+	return nil
+}
+
+// findExtension returns the extension of the message md with the given name.
+func (in *interpreter) findExtension(part namePart, scope string, md protoreflect.MessageDescriptor) (protoreflect.ExtensionTypeDescriptor, error) {
+	full, kind, err := in.l.lookupName(part.name, scope, part.pos, func(k symKind) bool { return k == symExtension })
+	if err != nil {
+		return nil, err
+	}
+	if kind != symExtension {
+		return nil, in.errorf(part.pos, "%v is not an extension", part.name)
+	}
+	desc, err := in.findDescriptor(protoreflect.FullName(full))
+	if err != nil {
+		return nil, in.errorf(part.pos, "%v", err)
+	}
+	xd := desc.(protoreflect.ExtensionDescriptor)
+	if xd.ContainingMessage().FullName() != md.FullName() {
+		return nil, in.errorf(part.pos, "%v extends %v, not %v", full, xd.ContainingMessage().FullName(), md.FullName())
+	}
+	return in.extensionType(xd).TypeDescriptor(), nil
+}
+
+func (in *interpreter) extensionType(xd protoreflect.ExtensionDescriptor) protoreflect.ExtensionType {
+	xt, ok := in.xts[xd]
+	if !ok {
+		xt = dynamicpb.NewExtensionType(xd)
+		in.xts[xd] = xt
+	}
+	return xt
+}
+
+// value returns the value of the option o, which sets the field fd of m.
+func (in *interpreter) value(o *optionInfo, m protoreflect.Message, fd protoreflect.FieldDescriptor) (protoreflect.Value, error) {
+	v := o.value
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if v.aggregate == nil {
+			return protoreflect.Value{}, in.errorf(v.pos, "option %v must be a message value in braces", o)
+		}
+		mv := m.NewField(fd)
+		if err := (prototext.UnmarshalOptions{Resolver: types{in}}).Unmarshal([]byte(*v.aggregate), mv.Message().Interface()); err != nil {
+			return protoreflect.Value{}, in.errorf(v.pos, "invalid value for option %v: %v", o, err)
+		}
+		return mv, nil
+	case protoreflect.EnumKind:
+		if v.kind == tokenIdent && !v.neg && v.aggregate == nil {
+			if ev := fd.Enum().Values().ByName(protoreflect.Name(v.text)); ev != nil {
+				return protoreflect.ValueOfEnum(ev.Number()), nil
+			}
+		}
+		return protoreflect.Value{}, in.errorf(v.pos, "%v is not a value of enum %v", v, fd.Enum().FullName())
+	default:
+		pv, err := scalarValue(fd.Kind(), v)
+		if err != nil {
+			return protoreflect.Value{}, in.errorf(v.pos, "invalid value for option %v: %v", o, err)
+		}
+		return pv, nil
+	}
+}
+
+// findDescriptor looks up a descriptor in the file being compiled and the
+// files it depends on.
+func (in *interpreter) findDescriptor(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if d, err := in.local.FindDescriptorByName(name); err == nil {
+		return d, nil
+	}
+	return in.l.c.files.FindDescriptorByName(name)
+}
+
+// types resolves the types in message values of options,
+// which are dynamic messages.
+type types struct{ in *interpreter }
+
+func (t types) FindMessageByName(name protoreflect.FullName) (protoreflect.MessageType, error) {
+	d, err := t.in.findDescriptor(name)
+	if err != nil {
+		return nil, err
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, protoregistry.NotFound
+	}
+	return dynamicpb.NewMessageType(md), nil
+}
+
+func (t types) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	return t.FindMessageByName(protoreflect.FullName(url[strings.LastIndexByte(url, '/')+1:]))
+}
+
+func (t types) FindExtensionByName(name protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	d, err := t.in.findDescriptor(name)
+	if err != nil {
+		return nil, err
+	}
+	xd, ok := d.(protoreflect.ExtensionDescriptor)
+	if !ok {
+		return nil, protoregistry.NotFound
+	}
+	return t.in.extensionType(xd), nil
+}
+
+func (t types) FindExtensionByNumber(message protoreflect.FullName, number protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	var found protoreflect.ExtensionDescriptor
+	find := func(fd protoreflect.FileDescriptor) bool {
+		rangeExtensions(fd, func(xd protoreflect.ExtensionDescriptor) {
+			if xd.ContainingMessage().FullName() == message && xd.Number() == number {
+				found = xd
+			}
+		})
+		return found == nil
+	}
+	t.in.local.RangeFiles(find)
+	if found == nil {
+		t.in.l.c.files.RangeFiles(find)
+	}
+	if found == nil {
+		return nil, protoregistry.NotFound
+	}
+	return t.in.extensionType(found), nil
+}
+
+// rangeExtensions calls f for every extension declared in fd.
+func rangeExtensions(fd protoreflect.FileDescriptor, f func(protoreflect.ExtensionDescriptor)) {
+	var rangeMessages func(protoreflect.MessageDescriptors)
+	rangeMessages = func(mds protoreflect.MessageDescriptors) {
+		for i := 0; i < mds.Len(); i++ {
+			md := mds.Get(i)
+			for j := 0; j < md.Extensions().Len(); j++ {
+				f(md.Extensions().Get(j))
+			}
+			rangeMessages(md.Messages())
+		}
+	}
+	for i := 0; i < fd.Extensions().Len(); i++ {
+		f(fd.Extensions().Get(i))
+	}
+	rangeMessages(fd.Messages())
+}
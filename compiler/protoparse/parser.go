@@ -0,0 +1,1311 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protoparse
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/internal/encoding/text"
+	"google.golang.org/protobuf/internal/errors"
+	"google.golang.org/protobuf/internal/strs"
+	"google.golang.org/protobuf/proto"
+
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Field numbers of descriptor fields, used in SourceCodeInfo paths.
+const (
+	fileDependencyNum       = 3
+	fileMessageTypeNum      = 4
+	fileEnumTypeNum         = 5
+	fileServiceNum          = 6
+	fileExtensionNum        = 7
+	fileOptionsNum          = 8
+	filePackageNum          = 2
+	filePublicDependencyNum = 10
+	fileWeakDependencyNum   = 11
+	fileSyntaxNum           = 12
+
+	messageNameNum           = 1
+	messageFieldNum          = 2
+	messageNestedTypeNum     = 3
+	messageEnumTypeNum       = 4
+	messageExtensionRangeNum = 5
+	messageExtensionNum      = 6
+	messageOptionsNum        = 7
+	messageOneofDeclNum      = 8
+	messageReservedRangeNum  = 9
+	messageReservedNameNum   = 10
+
+	extensionRangeStartNum   = 1
+	extensionRangeEndNum     = 2
+	extensionRangeOptionsNum = 3
+
+	reservedRangeStartNum = 1
+	reservedRangeEndNum   = 2
+
+	fieldNameNum         = 1
+	fieldExtendeeNum     = 2
+	fieldNumberNum       = 3
+	fieldLabelNum        = 4
+	fieldTypeNum         = 5
+	fieldTypeNameNum     = 6
+	fieldDefaultValueNum = 7
+	fieldOptionsNum      = 8
+	fieldJSONNameNum     = 10
+
+	oneofNameNum    = 1
+	oneofOptionsNum = 2
+
+	enumNameNum          = 1
+	enumValueNum         = 2
+	enumOptionsNum       = 3
+	enumReservedRangeNum = 4
+	enumReservedNameNum  = 5
+
+	enumValueNameNum    = 1
+	enumValueNumberNum  = 2
+	enumValueOptionsNum = 3
+
+	serviceNameNum    = 1
+	serviceMethodNum  = 2
+	serviceOptionsNum = 3
+
+	methodNameNum            = 1
+	methodInputTypeNum       = 2
+	methodOutputTypeNum      = 3
+	methodOptionsNum         = 4
+	methodClientStreamingNum = 5
+	methodServerStreamingNum = 6
+)
+
+const (
+	maxFieldNumber     = 536870911
+	firstReservedField = 19000
+	lastReservedField  = 19999
+)
+
+var scalarTypes = map[string]descriptorpb.FieldDescriptorProto_Type{
+	"double":   descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+	"float":    descriptorpb.FieldDescriptorProto_TYPE_FLOAT,
+	"int64":    descriptorpb.FieldDescriptorProto_TYPE_INT64,
+	"uint64":   descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+	"int32":    descriptorpb.FieldDescriptorProto_TYPE_INT32,
+	"fixed64":  descriptorpb.FieldDescriptorProto_TYPE_FIXED64,
+	"fixed32":  descriptorpb.FieldDescriptorProto_TYPE_FIXED32,
+	"bool":     descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+	"string":   descriptorpb.FieldDescriptorProto_TYPE_STRING,
+	"bytes":    descriptorpb.FieldDescriptorProto_TYPE_BYTES,
+	"uint32":   descriptorpb.FieldDescriptorProto_TYPE_UINT32,
+	"sfixed32": descriptorpb.FieldDescriptorProto_TYPE_SFIXED32,
+	"sfixed64": descriptorpb.FieldDescriptorProto_TYPE_SFIXED64,
+	"sint32":   descriptorpb.FieldDescriptorProto_TYPE_SINT32,
+	"sint64":   descriptorpb.FieldDescriptorProto_TYPE_SINT64,
+}
+
+// parser parses a single file. Besides the descriptor, it records what the
+// linker and the option interpreter need to complete it, along with the
+// positions of declarations for reporting errors.
+type parser struct {
+	lex  lexer
+	tok  token // the next token
+	prev token // the last token read
+
+	file    *descriptorpb.FileDescriptorProto
+	proto3  bool
+	imports []*importInfo
+	locs    []*location
+	options []*optionInfo
+	refs    []*typeRef
+	decls   []*decl
+}
+
+// importInfo is an import statement.
+type importInfo struct {
+	filename string // of the importing file
+	path     string
+	pos      pos
+}
+
+func (imp *importInfo) errorf(format string, args ...interface{}) error {
+	return newError(imp.filename, imp.pos, format, args...)
+}
+
+// location is the location of a declaration, which becomes a location in
+// the SourceCodeInfo.
+type location struct {
+	pb         *descriptorpb.SourceCodeInfo_Location
+	start, end pos
+}
+
+// optionInfo is an option of a declaration, which is set by the option
+// interpreter once the types it refers to are resolved.
+type optionInfo struct {
+	owner proto.Message // the descriptor with the options
+	scope string        // for resolving extension names
+	name  []namePart
+	value value
+	loc   *location // whose path lacks the option field numbers
+}
+
+type namePart struct {
+	name string
+	ext  bool // whether the name is an extension name in parentheses
+	pos  pos
+}
+
+func (o *optionInfo) String() string {
+	var b strings.Builder
+	for i, part := range o.name {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		if part.ext {
+			b.WriteString("(" + part.name + ")")
+		} else {
+			b.WriteString(part.name)
+		}
+	}
+	return b.String()
+}
+
+// value is the value of an option.
+type value struct {
+	kind tokenKind // tokenIdent, tokenInt, tokenFloat, or tokenString
+	neg  bool      // whether a number or identifier is preceded by '-'
+	text string    // the identifier or number, or the decoded string
+	pos  pos
+
+	// aggregate is the text format of a message value in braces,
+	// if the value is one.
+	aggregate *string
+}
+
+func (v value) String() string {
+	switch {
+	case v.aggregate != nil:
+		return "{" + *v.aggregate + "}"
+	case v.kind == tokenString:
+		return strconv.Quote(v.text)
+	case v.neg:
+		return "-" + v.text
+	}
+	return v.text
+}
+
+// typeRef is a reference to a type, which the linker resolves.
+type typeRef struct {
+	name  string
+	scope string
+	pos   pos
+
+	field  *descriptorpb.FieldDescriptorProto // a field type or extendee
+	method *descriptorpb.MethodDescriptorProto
+	kind   refKind
+
+	// defaultValue is the default value of a field, which may only be
+	// checked once its type is known.
+	defaultValue *value
+}
+
+type refKind int
+
+const (
+	refFieldType refKind = iota
+	refExtendee
+	refInputType
+	refOutputType
+)
+
+// decl is a declaration of a name.
+type decl struct {
+	name string
+	kind symKind
+	pos  pos
+}
+
+// bailout is a panic value for aborting parsing on an error.
+type bailout struct{ err error }
+
+// parse parses the source b of the named file.
+func parse(filename string, b []byte) (p *parser, err error) {
+	if !utf8.Valid(b) {
+		return nil, &Error{Filename: filename, err: errors.New("file is not valid UTF-8")}
+	}
+	p = &parser{
+		lex:  lexer{filename: filename, in: string(b)},
+		file: &descriptorpb.FileDescriptorProto{Name: proto.String(filename)},
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			b, ok := r.(bailout)
+			if !ok {
+				panic(r)
+			}
+			p, err = nil, b.err
+		}
+	}()
+	p.next()
+	p.parseFile()
+	return p, nil
+}
+
+func (p *parser) fail(err error) {
+	panic(bailout{err})
+}
+
+func (p *parser) errorf(at pos, format string, args ...interface{}) {
+	p.fail(newError(p.lex.filename, at, format, args...))
+}
+
+// next reads the next token, returning the current one.
+func (p *parser) next() token {
+	tok, err := p.lex.next()
+	if err != nil {
+		p.fail(err)
+	}
+	p.prev, p.tok = p.tok, tok
+	return p.prev
+}
+
+// peek returns the token after the current one.
+func (p *parser) peek() token {
+	l := p.lex
+	tok, err := l.next()
+	if err != nil {
+		return token{}
+	}
+	return tok
+}
+
+// is reports whether the current token is the punctuation or keyword s.
+func (p *parser) is(s string) bool {
+	return (p.tok.kind == tokenPunct || p.tok.kind == tokenIdent) && p.tok.text == s
+}
+
+// accept reads the current token if it is the punctuation or keyword s.
+func (p *parser) accept(s string) bool {
+	if p.is(s) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) token {
+	if !p.is(s) {
+		p.errorf(p.tok.start, "expected %q, found %v", s, p.tok)
+	}
+	return p.next()
+}
+
+func (p *parser) ident() token {
+	if p.tok.kind != tokenIdent {
+		p.errorf(p.tok.start, "expected identifier, found %v", p.tok)
+	}
+	return p.next()
+}
+
+// fullIdent reads a dot-separated name, optionally starting with a dot if
+// leadingDot is set.
+func (p *parser) fullIdent(leadingDot bool) (name string, start pos) {
+	start = p.tok.start
+	if leadingDot && p.accept(".") {
+		name = "."
+	}
+	name += p.ident().text
+	for p.accept(".") {
+		name += "." + p.ident().text
+	}
+	return name, start
+}
+
+func (p *parser) number() (uint64, pos) {
+	tok := p.tok
+	if tok.kind != tokenInt {
+		p.errorf(tok.start, "expected integer, found %v", tok)
+	}
+	p.next()
+	n, err := strconv.ParseUint(tok.text, 0, 64)
+	if err != nil {
+		p.errorf(tok.start, "invalid integer %v", tok.text)
+	}
+	return n, tok.start
+}
+
+// int32 reads an integer in the range of an int32, with an optional sign.
+func (p *parser) int32() (int32, pos) {
+	start := p.tok.start
+	neg := p.accept("-")
+	n, _ := p.number()
+	if (!neg && n > 1<<31-1) || (neg && n > 1<<31) {
+		p.errorf(start, "integer out of range")
+	}
+	if neg {
+		return int32(-int64(n)), start
+	}
+	return int32(n), start
+}
+
+// str reads one or more adjacent string literals.
+func (p *parser) str() (string, pos) {
+	if p.tok.kind != tokenString {
+		p.errorf(p.tok.start, "expected string, found %v", p.tok)
+	}
+	start := p.tok.start
+	var s string
+	for p.tok.kind == tokenString {
+		tok := p.next()
+		v, err := text.UnmarshalString(tok.text)
+		if err != nil {
+			p.errorf(tok.start, "invalid string %v", tok.text)
+		}
+		s += v
+	}
+	return s, start
+}
+
+func (p *parser) decl(name string, kind symKind, at pos) {
+	p.decls = append(p.decls, &decl{name: name, kind: kind, pos: at})
+}
+
+func appendPath(path []int32, elems ...int32) []int32 {
+	return append(append([]int32(nil), path...), elems...)
+}
+
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// newLoc records the location of an element which starts at start,
+// and ends at the last token read once end is called.
+func (p *parser) newLoc(path []int32, start pos) *location {
+	l := &location{
+		pb:    &descriptorpb.SourceCodeInfo_Location{Path: appendPath(path)},
+		start: start,
+		end:   p.prev.end,
+	}
+	p.locs = append(p.locs, l)
+	return l
+}
+
+// newDeclLoc records the location of a declaration starting with the current
+// token, along with its leading comments.
+func (p *parser) newDeclLoc(path []int32) *location {
+	return p.newDeclLocAt(path, p.tok)
+}
+
+// newDeclLocAt records the location of a declaration starting with tok,
+// along with its leading comments.
+func (p *parser) newDeclLocAt(path []int32, tok token) *location {
+	l := p.newLoc(path, tok.start)
+	cs := tok.comments
+	if len(cs) > 0 && cs[0].start.line == tok.prevLine {
+		// A comment after the end of the previous declaration on its line
+		// belongs to neither declaration.
+		cs = cs[1:]
+	}
+	if len(cs) > 0 && !tok.blankBefore {
+		l.pb.LeadingComments = proto.String(cs[len(cs)-1].text)
+		cs = cs[:len(cs)-1]
+	}
+	for _, c := range cs {
+		l.pb.LeadingDetachedComments = append(l.pb.LeadingDetachedComments, c.text)
+	}
+	return l
+}
+
+// endLoc ends the location at the last token read.
+func (p *parser) endLoc(l *location) {
+	l.end = p.prev.end
+}
+
+// trailing records the trailing comment after the last token read, which is
+// either on the same line, or on the next line and not directly followed by
+// the next token.
+func (p *parser) trailing(l *location) {
+	cs := p.tok.comments
+	if len(cs) == 0 {
+		return
+	}
+	c := cs[0]
+	if c.start.line == p.prev.end.line ||
+		(c.start.line == p.prev.end.line+1 && (c.blankAfter || len(cs) > 1 || p.tok.kind == tokenEOF)) {
+		l.pb.TrailingComments = proto.String(c.text)
+		p.tok.comments = cs[1:]
+	}
+}
+
+// endDecl ends the location of a declaration ending with the last token read.
+func (p *parser) endDecl(l *location) {
+	p.endLoc(l)
+	p.trailing(l)
+}
+
+func (p *parser) parseFile() {
+	fileLoc := p.newLoc(nil, p.tok.start)
+	if p.is("syntax") {
+		l := p.newDeclLoc([]int32{fileSyntaxNum})
+		p.next()
+		p.expect("=")
+		s, at := p.str()
+		switch s {
+		case "proto2":
+		case "proto3":
+			p.proto3 = true
+			p.file.Syntax = proto.String(s)
+		default:
+			p.errorf(at, "unknown syntax %q", s)
+		}
+		p.expect(";")
+		p.endDecl(l)
+	}
+
+	scope := ""
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.accept(";"):
+		case p.is("import"):
+			p.parseImport()
+		case p.is("package"):
+			if p.file.Package != nil {
+				p.errorf(p.tok.start, "multiple package declarations")
+			}
+			l := p.newDeclLoc([]int32{filePackageNum})
+			p.next()
+			name, at := p.fullIdent(false)
+			p.expect(";")
+			p.endDecl(l)
+			if len(p.file.MessageType)+len(p.file.EnumType)+len(p.file.Service)+len(p.file.Extension) > 0 {
+				p.errorf(at, "package declaration must precede all definitions")
+			}
+			p.file.Package = proto.String(name)
+			scope = name
+		case p.is("option"):
+			if p.file.Options == nil {
+				p.file.Options = new(descriptorpb.FileOptions)
+			}
+			p.parseOption(p.file, []int32{fileOptionsNum}, scope)
+		case p.is("message"):
+			path := []int32{fileMessageTypeNum, int32(len(p.file.MessageType))}
+			p.file.MessageType = append(p.file.MessageType, p.parseMessage(path, scope))
+		case p.is("enum"):
+			path := []int32{fileEnumTypeNum, int32(len(p.file.EnumType))}
+			p.file.EnumType = append(p.file.EnumType, p.parseEnum(path, scope))
+		case p.is("extend"):
+			p.parseExtend(&fieldList{
+				fields:     &p.file.Extension,
+				path:       []int32{fileExtensionNum},
+				nested:     &p.file.MessageType,
+				nestedPath: []int32{fileMessageTypeNum},
+				scope:      scope,
+			})
+		case p.is("service"):
+			path := []int32{fileServiceNum, int32(len(p.file.Service))}
+			p.file.Service = append(p.file.Service, p.parseService(path, scope))
+		default:
+			p.errorf(p.tok.start, "unexpected %v", p.tok)
+		}
+	}
+	p.endLoc(fileLoc)
+}
+
+func (p *parser) parseImport() {
+	l := p.newDeclLoc([]int32{fileDependencyNum, int32(len(p.file.Dependency))})
+	p.next()
+	index := int32(len(p.file.Dependency))
+	switch {
+	case p.accept("public"):
+		p.file.PublicDependency = append(p.file.PublicDependency, index)
+	case p.accept("weak"):
+		p.file.WeakDependency = append(p.file.WeakDependency, index)
+	}
+	path, at := p.str()
+	p.expect(";")
+	p.endDecl(l)
+	for _, dep := range p.file.Dependency {
+		if dep == path {
+			p.errorf(at, "%q was already imported", path)
+		}
+	}
+	p.file.Dependency = append(p.file.Dependency, path)
+	p.imports = append(p.imports, &importInfo{filename: p.lex.filename, path: path, pos: at})
+}
+
+// parseOption parses an option statement for the options of owner, whose
+// path in the SourceCodeInfo is optsPath.
+func (p *parser) parseOption(owner proto.Message, optsPath []int32, scope string) {
+	l := p.newDeclLoc(optsPath)
+	p.next()
+	o := &optionInfo{owner: owner, scope: scope, loc: l}
+	o.name = p.parseOptionName()
+	p.expect("=")
+	o.value = p.parseValue()
+	p.expect(";")
+	p.endDecl(l)
+	p.options = append(p.options, o)
+}
+
+func (p *parser) parseOptionName() []namePart {
+	var parts []namePart
+	for {
+		part := namePart{pos: p.tok.start}
+		if p.accept("(") {
+			part.name, _ = p.fullIdent(true)
+			part.ext = true
+			p.expect(")")
+		} else {
+			part.name = p.ident().text
+		}
+		parts = append(parts, part)
+		if !p.accept(".") {
+			return parts
+		}
+	}
+}
+
+func (p *parser) parseValue() value {
+	v := value{kind: p.tok.kind, pos: p.tok.start}
+	switch {
+	case p.is("{"):
+		open := p.next()
+		for depth := 1; ; {
+			switch {
+			case p.tok.kind == tokenEOF:
+				p.errorf(open.start, "unterminated message value")
+			case p.is("{"):
+				depth++
+			case p.is("}"):
+				depth--
+			}
+			if depth == 0 {
+				break
+			}
+			p.next()
+		}
+		s := p.lex.in[open.endOff:p.tok.off]
+		v.aggregate = &s
+		p.next()
+	case p.tok.kind == tokenString:
+		v.text, _ = p.str()
+	case p.is("-") || p.is("+"):
+		v.neg = p.next().text == "-"
+		v.kind = p.tok.kind
+		if v.kind != tokenInt && v.kind != tokenFloat && v.kind != tokenIdent {
+			p.errorf(p.tok.start, "expected number, found %v", p.tok)
+		}
+		v.text = p.next().text
+	case v.kind == tokenInt || v.kind == tokenFloat || v.kind == tokenIdent:
+		v.text = p.next().text
+	default:
+		p.errorf(p.tok.start, "expected option value, found %v", p.tok)
+	}
+	return v
+}
+
+// parseCompactOptions parses options in brackets, if any, for the options
+// of owner, whose path in the SourceCodeInfo is optsPath. If field is set,
+// the options are of a field, which may also have the pseudo-options
+// "default" and "json_name".
+func (p *parser) parseCompactOptions(owner proto.Message, optsPath []int32, scope string, field *descriptorpb.FieldDescriptorProto, fieldPath []int32) (defaultValue *value) {
+	if !p.accept("[") {
+		return nil
+	}
+	for {
+		start := p.tok.start
+		name := p.parseOptionName()
+		p.expect("=")
+		v := p.parseValue()
+		switch {
+		case field != nil && len(name) == 1 && !name[0].ext && name[0].name == "default":
+			if defaultValue != nil {
+				p.errorf(start, "option default was already set")
+			}
+			defaultValue = &v
+			p.newLoc(appendPath(fieldPath, fieldDefaultValueNum), start)
+		case field != nil && len(name) == 1 && !name[0].ext && name[0].name == "json_name":
+			if field.JsonName != nil {
+				p.errorf(start, "option json_name was already set")
+			}
+			if v.kind != tokenString || v.aggregate != nil {
+				p.errorf(v.pos, "option json_name must be a string")
+			}
+			if field.Extendee != nil {
+				p.errorf(start, "option json_name is not allowed on extensions")
+			}
+			field.JsonName = proto.String(v.text)
+			p.newLoc(appendPath(fieldPath, fieldJSONNameNum), start)
+		default:
+			l := p.newLoc(optsPath, start)
+			p.options = append(p.options, &optionInfo{owner: owner, scope: scope, name: name, value: v, loc: l})
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+	p.expect("]")
+	return defaultValue
+}
+
+func (p *parser) parseMessage(path []int32, scope string) *descriptorpb.DescriptorProto {
+	l := p.newDeclLoc(path)
+	p.next()
+	name := p.ident()
+	p.newLoc(appendPath(path, messageNameNum), name.start)
+	msg := &descriptorpb.DescriptorProto{Name: proto.String(name.text)}
+	fullName := qualify(scope, name.text)
+	p.decl(fullName, symMessage, name.start)
+	p.expect("{")
+	p.trailing(l)
+	p.parseMessageBody(msg, path, fullName)
+	p.endLoc(l)
+	return msg
+}
+
+// parseMessageBody parses the declarations of a message up to and including
+// the closing brace.
+func (p *parser) parseMessageBody(msg *descriptorpb.DescriptorProto, path []int32, fullName string) {
+	fields := &fieldList{
+		fields:     &msg.Field,
+		path:       appendPath(path, messageFieldNum),
+		nested:     &msg.NestedType,
+		nestedPath: appendPath(path, messageNestedTypeNum),
+		scope:      fullName,
+	}
+	for !p.accept("}") {
+		switch {
+		case p.tok.kind == tokenEOF:
+			p.errorf(p.tok.start, "unexpected end of file in message %v", fullName)
+		case p.accept(";"):
+		case p.is("message"):
+			nestedPath := appendPath(path, messageNestedTypeNum, int32(len(msg.NestedType)))
+			msg.NestedType = append(msg.NestedType, p.parseMessage(nestedPath, fullName))
+		case p.is("enum"):
+			enumPath := appendPath(path, messageEnumTypeNum, int32(len(msg.EnumType)))
+			msg.EnumType = append(msg.EnumType, p.parseEnum(enumPath, fullName))
+		case p.is("extend"):
+			p.parseExtend(&fieldList{
+				fields:     &msg.Extension,
+				path:       appendPath(path, messageExtensionNum),
+				nested:     &msg.NestedType,
+				nestedPath: appendPath(path, messageNestedTypeNum),
+				scope:      fullName,
+			})
+		case p.is("option"):
+			if msg.Options == nil {
+				msg.Options = new(descriptorpb.MessageOptions)
+			}
+			p.parseOption(msg, appendPath(path, messageOptionsNum), fullName)
+		case p.is("oneof"):
+			p.parseOneof(msg, path, fields)
+		case p.is("extensions"):
+			p.parseExtensionRanges(msg, path, fullName)
+		case p.is("reserved"):
+			p.parseReserved(path, messageReservedRangeNum, messageReservedNameNum, func(start, end int32) {
+				msg.ReservedRange = append(msg.ReservedRange, &descriptorpb.DescriptorProto_ReservedRange{
+					Start: proto.Int32(start),
+					End:   proto.Int32(end + 1),
+				})
+			}, func(name string) {
+				msg.ReservedName = append(msg.ReservedName, name)
+			}, maxFieldNumber)
+		default:
+			p.parseField(fields)
+		}
+	}
+
+	// Every proto3 optional field is in a synthetic oneof, which follows
+	// the other oneofs.
+	for _, fd := range msg.Field {
+		if !fd.GetProto3Optional() {
+			continue
+		}
+		name := "_" + fd.GetName()
+		for hasOneof(msg, name) {
+			name = "X" + name
+		}
+		fd.OneofIndex = proto.Int32(int32(len(msg.OneofDecl)))
+		msg.OneofDecl = append(msg.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String(name)})
+	}
+}
+
+func hasOneof(msg *descriptorpb.DescriptorProto, name string) bool {
+	for _, od := range msg.OneofDecl {
+		if od.GetName() == name {
+			return true
+		}
+	}
+	for _, fd := range msg.Field {
+		if fd.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldList is a list of fields or extensions being parsed.
+type fieldList struct {
+	fields *[]*descriptorpb.FieldDescriptorProto
+	path   []int32
+
+	// nested are the messages to which groups and map entries are added.
+	nested     *[]*descriptorpb.DescriptorProto
+	nestedPath []int32
+
+	// scope is the full name of the message or package of the fields.
+	scope string
+
+	// extendee is the name of the extended message, for extensions.
+	extendee    *string
+	extendeePos pos
+	extendeeEnd pos
+
+	// oneof is the index of the oneof of the fields, if any.
+	oneof *int32
+}
+
+func (p *parser) parseField(list *fieldList) {
+	index := int32(len(*list.fields))
+	fieldPath := appendPath(list.path, index)
+	l := p.newDeclLoc(fieldPath)
+	fd := &descriptorpb.FieldDescriptorProto{}
+	*list.fields = append(*list.fields, fd)
+	if list.extendee != nil {
+		fd.Extendee = proto.String(*list.extendee)
+		el := p.newLoc(appendPath(fieldPath, fieldExtendeeNum), list.extendeePos)
+		el.end = list.extendeeEnd
+		p.refs = append(p.refs, &typeRef{name: *list.extendee, scope: list.scope, pos: list.extendeePos, field: fd, kind: refExtendee})
+	}
+	if list.oneof != nil {
+		fd.OneofIndex = proto.Int32(*list.oneof)
+	}
+
+	isMap := p.is("map") && p.peek().text == "<"
+	switch {
+	case p.is("optional") || p.is("required") || p.is("repeated"):
+		tok := p.next()
+		if list.oneof != nil {
+			p.errorf(tok.start, "fields in oneofs must not have labels")
+		}
+		fd.Label = descriptorpb.FieldDescriptorProto_Label(descriptorpb.FieldDescriptorProto_Label_value["LABEL_"+strings.ToUpper(tok.text)]).Enum()
+		switch {
+		case p.proto3 && tok.text == "required":
+			p.errorf(tok.start, "required fields are not allowed in proto3")
+		case p.proto3 && tok.text == "optional":
+			fd.Proto3Optional = proto.Bool(true)
+		}
+		p.newLoc(appendPath(fieldPath, fieldLabelNum), tok.start)
+		isMap = false
+	case !p.proto3 && list.oneof == nil && !isMap:
+		p.errorf(p.tok.start, `expected "required", "optional", or "repeated"`)
+	default:
+		fd.Label = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	}
+
+	var ref *typeRef
+	switch {
+	case isMap:
+		if list.extendee != nil {
+			p.errorf(p.tok.start, "map fields are not allowed in extensions")
+		}
+		p.parseMapField(fd, fieldPath, list)
+		p.endDecl(l)
+		return
+	case p.is("group") && !p.proto3 && p.peek().kind == tokenIdent:
+		p.parseGroup(fd, l, fieldPath, list)
+		return
+	case p.tok.kind == tokenIdent && scalarTypes[p.tok.text] != 0:
+		tok := p.next()
+		fd.Type = scalarTypes[tok.text].Enum()
+		p.newLoc(appendPath(fieldPath, fieldTypeNum), tok.start)
+	default:
+		name, at := p.fullIdent(true)
+		fd.TypeName = proto.String(name)
+		p.newLoc(appendPath(fieldPath, fieldTypeNameNum), at)
+		ref = &typeRef{name: name, scope: list.scope, pos: at, field: fd, kind: refFieldType}
+		p.refs = append(p.refs, ref)
+	}
+
+	name := p.ident()
+	fd.Name = proto.String(name.text)
+	p.newLoc(appendPath(fieldPath, fieldNameNum), name.start)
+	p.decl(qualify(list.scope, name.text), symField, name.start)
+	if list.extendee != nil {
+		p.decls[len(p.decls)-1].kind = symExtension
+	}
+	p.expect("=")
+	fd.Number = proto.Int32(p.fieldNumber(fieldPath, list.extendee != nil))
+	n := len(p.options)
+	fd.Options = new(descriptorpb.FieldOptions)
+	defaultValue := p.parseCompactOptions(fd, appendPath(fieldPath, fieldOptionsNum), list.scope, fd, fieldPath)
+	if len(p.options) == n {
+		fd.Options = nil
+	}
+	p.expect(";")
+	p.endDecl(l)
+
+	if defaultValue != nil {
+		if fd.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+			p.errorf(defaultValue.pos, "repeated fields cannot have default values")
+		}
+		if p.proto3 {
+			p.errorf(defaultValue.pos, "explicit default values are not allowed in proto3")
+		}
+		if ref != nil {
+			// The default value of a field of a message or enum type is
+			// checked once the type is resolved.
+			ref.defaultValue = defaultValue
+		} else {
+			s, err := defaultString(fd.GetType(), *defaultValue)
+			if err != nil {
+				p.errorf(defaultValue.pos, "%v", err)
+			}
+			fd.DefaultValue = proto.String(s)
+		}
+	}
+}
+
+func (p *parser) fieldNumber(fieldPath []int32, extension bool) int32 {
+	n, at := p.number()
+	p.newLoc(appendPath(fieldPath, fieldNumberNum), at)
+	max := uint64(maxFieldNumber)
+	if extension {
+		// Extensions of a MessageSet may have larger numbers,
+		// which is checked once the extended message is known.
+		max = 1<<31 - 1
+	}
+	switch {
+	case n < 1 || n > max:
+		p.errorf(at, "field number %d is out of range", n)
+	case n >= firstReservedField && n <= lastReservedField:
+		p.errorf(at, "field numbers %d to %d are reserved for the protocol buffer library implementation", firstReservedField, lastReservedField)
+	}
+	return int32(n)
+}
+
+func (p *parser) parseMapField(fd *descriptorpb.FieldDescriptorProto, fieldPath []int32, list *fieldList) {
+	start := p.next().start
+	fd.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	fd.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+	p.expect("<")
+	keyTok := p.ident()
+	keyType := scalarTypes[keyTok.text]
+	switch keyType {
+	case 0, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, descriptorpb.FieldDescriptorProto_TYPE_FLOAT, descriptorpb.FieldDescriptorProto_TYPE_BYTES:
+		p.errorf(keyTok.start, "invalid map key type %v", keyTok.text)
+	}
+	p.expect(",")
+	key := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String("key"),
+		Number:   proto.Int32(1),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     keyType.Enum(),
+		JsonName: proto.String("key"),
+	}
+	val := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String("value"),
+		Number:   proto.Int32(2),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		JsonName: proto.String("value"),
+	}
+	if p.tok.kind == tokenIdent && scalarTypes[p.tok.text] != 0 {
+		val.Type = scalarTypes[p.next().text].Enum()
+	} else {
+		name, at := p.fullIdent(true)
+		val.TypeName = proto.String(name)
+		p.refs = append(p.refs, &typeRef{name: name, scope: list.scope, pos: at, field: val, kind: refFieldType})
+	}
+	p.expect(">")
+	p.newLoc(appendPath(fieldPath, fieldTypeNameNum), start)
+
+	name := p.ident()
+	fd.Name = proto.String(name.text)
+	p.newLoc(appendPath(fieldPath, fieldNameNum), name.start)
+	p.decl(qualify(list.scope, name.text), symField, name.start)
+	p.expect("=")
+	fd.Number = proto.Int32(p.fieldNumber(fieldPath, list.extendee != nil))
+	n := len(p.options)
+	fd.Options = new(descriptorpb.FieldOptions)
+	if v := p.parseCompactOptions(fd, appendPath(fieldPath, fieldOptionsNum), list.scope, fd, fieldPath); v != nil {
+		p.errorf(v.pos, "map fields cannot have default values")
+	}
+	if len(p.options) == n {
+		fd.Options = nil
+	}
+	p.expect(";")
+
+	entryName := strs.MapEntryName(name.text)
+	entryFullName := qualify(list.scope, entryName)
+	fd.TypeName = proto.String("." + entryFullName)
+	p.decl(entryFullName, symMessage, name.start)
+	*list.nested = append(*list.nested, &descriptorpb.DescriptorProto{
+		Name:    proto.String(entryName),
+		Field:   []*descriptorpb.FieldDescriptorProto{key, val},
+		Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+	})
+}
+
+func (p *parser) parseGroup(fd *descriptorpb.FieldDescriptorProto, l *location, fieldPath []int32, list *fieldList) {
+	tok := p.next()
+	fd.Type = descriptorpb.FieldDescriptorProto_TYPE_GROUP.Enum()
+	p.newLoc(appendPath(fieldPath, fieldTypeNum), tok.start)
+	name := p.ident()
+	if c := name.text[0]; c < 'A' || c > 'Z' {
+		p.errorf(name.start, "group names must start with a capital letter")
+	}
+	fd.Name = proto.String(strings.ToLower(name.text))
+	p.newLoc(appendPath(fieldPath, fieldNameNum), name.start)
+	p.decl(qualify(list.scope, fd.GetName()), symField, name.start)
+	if list.extendee != nil {
+		p.decls[len(p.decls)-1].kind = symExtension
+	}
+	fullName := qualify(list.scope, name.text)
+	fd.TypeName = proto.String("." + fullName)
+	p.decl(fullName, symMessage, name.start)
+	p.expect("=")
+	fd.Number = proto.Int32(p.fieldNumber(fieldPath, list.extendee != nil))
+	n := len(p.options)
+	fd.Options = new(descriptorpb.FieldOptions)
+	if v := p.parseCompactOptions(fd, appendPath(fieldPath, fieldOptionsNum), list.scope, fd, fieldPath); v != nil {
+		p.errorf(v.pos, "group fields cannot have default values")
+	}
+	if len(p.options) == n {
+		fd.Options = nil
+	}
+
+	msgPath := appendPath(list.nestedPath, int32(len(*list.nested)))
+	msgLoc := p.newLoc(msgPath, l.start)
+	p.newLoc(appendPath(msgPath, messageNameNum), name.start)
+	msg := &descriptorpb.DescriptorProto{Name: proto.String(name.text)}
+	*list.nested = append(*list.nested, msg)
+	p.expect("{")
+	p.trailing(l)
+	p.parseMessageBody(msg, msgPath, fullName)
+	p.endLoc(msgLoc)
+	p.endLoc(l)
+}
+
+func (p *parser) parseOneof(msg *descriptorpb.DescriptorProto, path []int32, fields *fieldList) {
+	index := int32(len(msg.OneofDecl))
+	oneofPath := appendPath(path, messageOneofDeclNum, index)
+	l := p.newDeclLoc(oneofPath)
+	p.next()
+	name := p.ident()
+	p.newLoc(appendPath(oneofPath, oneofNameNum), name.start)
+	p.decl(qualify(fields.scope, name.text), symOneof, name.start)
+	od := &descriptorpb.OneofDescriptorProto{Name: proto.String(name.text)}
+	msg.OneofDecl = append(msg.OneofDecl, od)
+	p.expect("{")
+	p.trailing(l)
+
+	list := *fields
+	list.oneof = &index
+	n := len(msg.Field)
+	for !p.accept("}") {
+		switch {
+		case p.tok.kind == tokenEOF:
+			p.errorf(p.tok.start, "unexpected end of file in oneof %v", name.text)
+		case p.accept(";"):
+		case p.is("option"):
+			if od.Options == nil {
+				od.Options = new(descriptorpb.OneofOptions)
+			}
+			p.parseOption(od, appendPath(oneofPath, oneofOptionsNum), fields.scope)
+		default:
+			p.parseField(&list)
+		}
+	}
+	if len(msg.Field) == n {
+		p.errorf(name.start, "oneof %v must have at least one field", name.text)
+	}
+	p.endLoc(l)
+}
+
+func (p *parser) parseExtensionRanges(msg *descriptorpb.DescriptorProto, path []int32, scope string) {
+	l := p.newDeclLoc(appendPath(path, messageExtensionRangeNum))
+	p.next()
+	first := len(msg.ExtensionRange)
+	for {
+		index := int32(len(msg.ExtensionRange))
+		rangePath := appendPath(path, messageExtensionRangeNum, index)
+		start, end, startPos := p.parseRange(rangePath, maxFieldNumber)
+		if start < 1 {
+			p.errorf(startPos, "extension numbers must be positive")
+		}
+		msg.ExtensionRange = append(msg.ExtensionRange, &descriptorpb.DescriptorProto_ExtensionRange{
+			Start: proto.Int32(start),
+			End:   proto.Int32(end + 1),
+		})
+		if !p.accept(",") {
+			break
+		}
+	}
+	if p.is("[") {
+		// The options apply to each of the ranges.
+		xr := msg.ExtensionRange[first]
+		xr.Options = new(descriptorpb.ExtensionRangeOptions)
+		n := len(p.options)
+		p.parseCompactOptions(xr, appendPath(path, messageExtensionRangeNum, int32(first), extensionRangeOptionsNum), scope, nil, nil)
+		opts := p.options[n:]
+		for i, xr := range msg.ExtensionRange[first+1:] {
+			xr.Options = new(descriptorpb.ExtensionRangeOptions)
+			optsPath := appendPath(path, messageExtensionRangeNum, int32(first+1+i), extensionRangeOptionsNum)
+			for _, o := range opts {
+				o2 := *o
+				o2.owner = xr
+				o2.loc = p.newLoc(optsPath, o.loc.start)
+				o2.loc.end = o.loc.end
+				p.options = append(p.options, &o2)
+			}
+		}
+	}
+	p.expect(";")
+	p.endDecl(l)
+}
+
+// parseRange parses a range of numbers, such as "1", "1 to 5", or "1 to max",
+// with the given value for max, and records its location at path.
+func (p *parser) parseRange(path []int32, max int32) (start, end int32, startPos pos) {
+	l := p.newLoc(path, p.tok.start)
+	start, startPos = p.int32()
+	p.newLoc(appendPath(path, reservedRangeStartNum), startPos)
+	end = start
+	if p.accept("to") {
+		if p.is("max") {
+			end = max
+			p.newLoc(appendPath(path, reservedRangeEndNum), p.next().start)
+		} else {
+			var endPos pos
+			end, endPos = p.int32()
+			p.newLoc(appendPath(path, reservedRangeEndNum), endPos)
+		}
+		if end < start {
+			p.errorf(startPos, "range end %d is less than range start %d", end, start)
+		}
+	} else {
+		// The end of a single number shares its location with the start.
+		p.newLoc(appendPath(path, reservedRangeEndNum), startPos)
+	}
+	p.endLoc(l)
+	return start, end, startPos
+}
+
+// parseReserved parses a reserved statement of a message or enum, calling
+// addRange for every range of numbers, with an inclusive end, and addName
+// for every name.
+func (p *parser) parseReserved(path []int32, rangeNum, nameNum int32, addRange func(start, end int32), addName func(string), max int32) {
+	keyword := p.next()
+	if p.tok.kind == tokenString {
+		l := p.newDeclLocAt(appendPath(path, nameNum), keyword)
+		var index int32
+		for {
+			tok := p.tok
+			name, at := p.str()
+			if !isIdent(name) {
+				p.errorf(at, "reserved name %q is not a valid identifier", name)
+			}
+			nl := p.newLoc(appendPath(path, nameNum, index), tok.start)
+			p.endLoc(nl)
+			addName(name)
+			index++
+			if !p.accept(",") {
+				break
+			}
+		}
+		p.expect(";")
+		p.endDecl(l)
+		return
+	}
+
+	l := p.newDeclLocAt(appendPath(path, rangeNum), keyword)
+	var index int32
+	for {
+		s, e, _ := p.parseRange(appendPath(path, rangeNum, index), max)
+		addRange(s, e)
+		index++
+		if !p.accept(",") {
+			break
+		}
+	}
+	p.expect(";")
+	p.endDecl(l)
+}
+
+func isIdent(s string) bool {
+	if s == "" || !isLetter(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isLetter(s[i]) && !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *parser) parseEnum(path []int32, scope string) *descriptorpb.EnumDescriptorProto {
+	l := p.newDeclLoc(path)
+	p.next()
+	name := p.ident()
+	p.newLoc(appendPath(path, enumNameNum), name.start)
+	p.decl(qualify(scope, name.text), symEnum, name.start)
+	ed := &descriptorpb.EnumDescriptorProto{Name: proto.String(name.text)}
+	p.expect("{")
+	p.trailing(l)
+	for !p.accept("}") {
+		switch {
+		case p.tok.kind == tokenEOF:
+			p.errorf(p.tok.start, "unexpected end of file in enum %v", name.text)
+		case p.accept(";"):
+		case p.is("option"):
+			if ed.Options == nil {
+				ed.Options = new(descriptorpb.EnumOptions)
+			}
+			p.parseOption(ed, appendPath(path, enumOptionsNum), qualify(scope, name.text))
+		case p.is("reserved"):
+			p.parseReserved(path, enumReservedRangeNum, enumReservedNameNum, func(start, end int32) {
+				ed.ReservedRange = append(ed.ReservedRange, &descriptorpb.EnumDescriptorProto_EnumReservedRange{
+					Start: proto.Int32(start),
+					End:   proto.Int32(end),
+				})
+			}, func(name string) {
+				ed.ReservedName = append(ed.ReservedName, name)
+			}, 1<<31-1)
+		default:
+			valuePath := appendPath(path, enumValueNum, int32(len(ed.Value)))
+			vl := p.newDeclLoc(valuePath)
+			valueName := p.ident()
+			p.newLoc(appendPath(valuePath, enumValueNameNum), valueName.start)
+			// Enum values are siblings of their enum, rather than children.
+			p.decl(qualify(scope, valueName.text), symEnumValue, valueName.start)
+			p.expect("=")
+			n, at := p.int32()
+			p.newLoc(appendPath(valuePath, enumValueNumberNum), at)
+			vd := &descriptorpb.EnumValueDescriptorProto{Name: proto.String(valueName.text), Number: proto.Int32(n)}
+			if p.is("[") {
+				vd.Options = new(descriptorpb.EnumValueOptions)
+				p.parseCompactOptions(vd, appendPath(valuePath, enumValueOptionsNum), qualify(scope, name.text), nil, nil)
+			}
+			p.expect(";")
+			p.endDecl(vl)
+			ed.Value = append(ed.Value, vd)
+		}
+	}
+	if len(ed.Value) == 0 {
+		p.errorf(name.start, "enum %v must have at least one value", name.text)
+	}
+	p.endLoc(l)
+	return ed
+}
+
+func (p *parser) parseExtend(list *fieldList) {
+	l := p.newDeclLoc(list.path)
+	p.next()
+	extendee, at := p.fullIdent(true)
+	list.extendee = &extendee
+	list.extendeePos, list.extendeeEnd = at, p.prev.end
+	p.expect("{")
+	p.trailing(l)
+	n := len(*list.fields)
+	for !p.accept("}") {
+		switch {
+		case p.tok.kind == tokenEOF:
+			p.errorf(p.tok.start, "unexpected end of file in extend %v", extendee)
+		case p.accept(";"):
+		default:
+			p.parseField(list)
+		}
+	}
+	if len(*list.fields) == n {
+		p.errorf(at, "extend %v must have at least one field", extendee)
+	}
+	p.endLoc(l)
+}
+
+func (p *parser) parseService(path []int32, scope string) *descriptorpb.ServiceDescriptorProto {
+	l := p.newDeclLoc(path)
+	p.next()
+	name := p.ident()
+	p.newLoc(appendPath(path, serviceNameNum), name.start)
+	fullName := qualify(scope, name.text)
+	p.decl(fullName, symService, name.start)
+	sd := &descriptorpb.ServiceDescriptorProto{Name: proto.String(name.text)}
+	p.expect("{")
+	p.trailing(l)
+	for !p.accept("}") {
+		switch {
+		case p.tok.kind == tokenEOF:
+			p.errorf(p.tok.start, "unexpected end of file in service %v", name.text)
+		case p.accept(";"):
+		case p.is("option"):
+			if sd.Options == nil {
+				sd.Options = new(descriptorpb.ServiceOptions)
+			}
+			p.parseOption(sd, appendPath(path, serviceOptionsNum), fullName)
+		case p.is("rpc"):
+			methodPath := appendPath(path, serviceMethodNum, int32(len(sd.Method)))
+			sd.Method = append(sd.Method, p.parseMethod(methodPath, fullName, scope))
+		default:
+			p.errorf(p.tok.start, "unexpected %v", p.tok)
+		}
+	}
+	p.endLoc(l)
+	return sd
+}
+
+func (p *parser) parseMethod(path []int32, fullName, scope string) *descriptorpb.MethodDescriptorProto {
+	l := p.newDeclLoc(path)
+	p.next()
+	name := p.ident()
+	p.newLoc(appendPath(path, methodNameNum), name.start)
+	p.decl(qualify(fullName, name.text), symMethod, name.start)
+	md := &descriptorpb.MethodDescriptorProto{Name: proto.String(name.text)}
+	messageType := func(streamingNum, typeNum int32, kind refKind) (streaming bool, typeName *string) {
+		p.expect("(")
+		if p.is("stream") && p.peek().text != ")" {
+			p.newLoc(appendPath(path, streamingNum), p.next().start)
+			streaming = true
+		}
+		typ, at := p.fullIdent(true)
+		p.newLoc(appendPath(path, typeNum), at)
+		p.refs = append(p.refs, &typeRef{name: typ, scope: scope, pos: at, method: md, kind: kind})
+		p.expect(")")
+		return streaming, proto.String(typ)
+	}
+	var streaming bool
+	if streaming, md.InputType = messageType(methodClientStreamingNum, methodInputTypeNum, refInputType); streaming {
+		md.ClientStreaming = proto.Bool(true)
+	}
+	p.expect("returns")
+	if streaming, md.OutputType = messageType(methodServerStreamingNum, methodOutputTypeNum, refOutputType); streaming {
+		md.ServerStreaming = proto.Bool(true)
+	}
+	if p.accept("{") {
+		for !p.accept("}") {
+			switch {
+			case p.tok.kind == tokenEOF:
+				p.errorf(p.tok.start, "unexpected end of file in method %v", name.text)
+			case p.accept(";"):
+			case p.is("option"):
+				if md.Options == nil {
+					md.Options = new(descriptorpb.MethodOptions)
+				}
+				p.parseOption(md, appendPath(path, methodOptionsNum), fullName)
+			default:
+				p.errorf(p.tok.start, "unexpected %v", p.tok)
+			}
+		}
+		p.endDecl(l)
+		return md
+	}
+	p.expect(";")
+	p.endDecl(l)
+	return md
+}
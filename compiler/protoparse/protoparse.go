@@ -0,0 +1,271 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package protoparse parses and compiles .proto source files into
+// descriptors, without running protoc.
+//
+// The descriptors are equivalent to those produced by protoc: the types
+// referenced by fields and methods are fully qualified, options are
+// interpreted, including custom options, and optionally the positions and
+// comments of declarations are recorded as SourceCodeInfo.
+// Use protodesc.NewFile to turn them into protoreflect.FileDescriptors.
+//
+// As with protodesc, files which use weak fields or message sets
+// cannot be compiled.
+package protoparse
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"google.golang.org/protobuf/internal/errors"
+	"google.golang.org/protobuf/internal/pragma"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Parser parses .proto source files.
+type Parser struct {
+	pragma.NoUnkeyedLiterals
+
+	// Accessor opens the file with the given path, as named in an import
+	// statement or passed to ParseFiles.
+	// If nil, files are opened from the directories in ImportPaths.
+	Accessor func(filename string) (io.ReadCloser, error)
+
+	// ImportPaths are the directories in which files are looked up if
+	// Accessor is nil. If empty, files are looked up in the current directory.
+	ImportPaths []string
+
+	// Resolver is used for looking up imported files which cannot be opened,
+	// such as the well-known types in google/protobuf/.
+	// If nil, this defaults to using protoregistry.GlobalFiles.
+	Resolver interface {
+		FindFileByPath(string) (protoreflect.FileDescriptor, error)
+	}
+
+	// IncludeSourceCodeInfo populates the SourceCodeInfo of each file with
+	// the positions and comments of its declarations.
+	IncludeSourceCodeInfo bool
+}
+
+// Error is an error in a .proto source file.
+type Error struct {
+	// Filename is the path of the file.
+	Filename string
+
+	// Line and Column are the 1-based position of the error in the file.
+	// They are 0 if the error is not specific to a position.
+	Line, Column int
+
+	msg string // the message, if err was created by newError
+	err error
+}
+
+func newError(filename string, p pos, format string, args ...interface{}) *Error {
+	msg := fmt.Sprintf(format, args...)
+	return &Error{Filename: filename, Line: p.line + 1, Column: p.col + 1, msg: msg, err: errors.New("%s", msg)}
+}
+
+// Error returns the error prefixed by the position, such as
+// "foo.proto:3:10: unknown type Bar".
+func (e *Error) Error() string {
+	msg := e.msg
+	if msg == "" {
+		msg = e.err.Error()
+	}
+	if e.Line == 0 {
+		return fmt.Sprintf("%v: %v", e.Filename, msg)
+	}
+	return fmt.Sprintf("%v:%d:%d: %v", e.Filename, e.Line, e.Column, msg)
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// ParseFiles parses the files with the given paths, along with the files
+// they import, and returns the descriptors of the named files in order.
+//
+// An error in a file is reported as an *Error.
+func (p Parser) ParseFiles(filenames ...string) ([]*descriptorpb.FileDescriptorProto, error) {
+	c := &compiler{
+		opts:    p,
+		files:   new(protoregistry.Files),
+		results: make(map[string]*result),
+	}
+	var fdps []*descriptorpb.FileDescriptorProto
+	for _, filename := range filenames {
+		r, err := c.compile(filename, nil)
+		if err != nil {
+			return nil, err
+		}
+		fdp := r.fdp
+		if fdp == nil {
+			// The file was found by the Resolver.
+			fdp = protodesc.ToFileDescriptorProto(r.fd)
+		}
+		fdps = append(fdps, fdp)
+	}
+	return fdps, nil
+}
+
+// compiler compiles files along with their imports.
+type compiler struct {
+	opts    Parser
+	files   *protoregistry.Files // files which have been compiled
+	results map[string]*result
+}
+
+// result is a compiled file. Files which are not parsed from source,
+// but found by the Resolver, have no fdp.
+type result struct {
+	fdp *descriptorpb.FileDescriptorProto
+	fd  protoreflect.FileDescriptor
+}
+
+// compile compiles the file, unless it has already been compiled.
+// If it is imported, imp is the import statement.
+func (c *compiler) compile(filename string, imp *importInfo) (*result, error) {
+	if r, ok := c.results[filename]; ok {
+		if r == nil {
+			return nil, imp.errorf("import cycle involving %q", filename)
+		}
+		return r, nil
+	}
+	c.results[filename] = nil // for detecting cycles
+
+	b, err := c.open(filename)
+	if err != nil {
+		// Use a file which has already been compiled, if there is one.
+		resolver := c.opts.Resolver
+		if resolver == nil {
+			resolver = protoregistry.GlobalFiles
+		}
+		fd, err2 := resolver.FindFileByPath(filename)
+		if err2 != nil {
+			if imp == nil {
+				return nil, &Error{Filename: filename, err: err}
+			}
+			return nil, imp.errorf("%v", err)
+		}
+		if err := c.register(fd); err != nil {
+			return nil, &Error{Filename: filename, err: err}
+		}
+		r := &result{fd: fd}
+		c.results[filename] = r
+		return r, nil
+	}
+
+	p, err := parse(filename, b)
+	if err != nil {
+		return nil, err
+	}
+	for _, imp := range p.imports {
+		if _, err := c.compile(imp.path, imp); err != nil {
+			return nil, err
+		}
+	}
+	l := newLinker(c, p)
+	if err := l.link(); err != nil {
+		return nil, err
+	}
+	if err := newInterpreter(l).interpret(); err != nil {
+		return nil, err
+	}
+	fd, err := p.newFile(c.files)
+	if err != nil {
+		return nil, err
+	}
+	if c.opts.IncludeSourceCodeInfo {
+		p.file.SourceCodeInfo = p.sourceCodeInfo()
+	}
+	if err := c.files.RegisterFile(fd); err != nil {
+		return nil, &Error{Filename: filename, err: err}
+	}
+	r := &result{fdp: p.file, fd: fd}
+	c.results[filename] = r
+	return r, nil
+}
+
+// newFile creates the descriptor of the parsed file. A violation of the
+// semantics of protobuf found by protodesc is reported at the position of
+// the declaration with the violation.
+func (p *parser) newFile(r protodesc.Resolver) (protoreflect.FileDescriptor, error) {
+	// The source locations are needed for the positions,
+	// even if they are not included in the result.
+	info := p.file.SourceCodeInfo
+	p.file.SourceCodeInfo = p.sourceCodeInfo()
+	defer func() { p.file.SourceCodeInfo = info }()
+
+	fd, ds, err := protodesc.FileOptions{}.NewWithDiagnostics(p.file, r)
+	if err == nil {
+		return fd, nil
+	}
+	for _, d := range ds {
+		if d.Err != err || d.Descriptor == nil {
+			continue
+		}
+		loc := d.Descriptor.ParentFile().SourceLocations().ByDescriptor(d.Descriptor)
+		if loc.Path == nil {
+			break
+		}
+		return nil, &Error{Filename: p.lex.filename, Line: loc.StartLine + 1, Column: loc.StartColumn + 1, err: err}
+	}
+	return nil, &Error{Filename: p.lex.filename, err: err}
+}
+
+// register registers fd along with the files it imports.
+func (c *compiler) register(fd protoreflect.FileDescriptor) error {
+	if _, err := c.files.FindFileByPath(fd.Path()); err == nil {
+		return nil
+	}
+	imports := fd.Imports()
+	for i := 0; i < imports.Len(); i++ {
+		imp := imports.Get(i)
+		if imp.IsPlaceholder() {
+			continue
+		}
+		if _, ok := c.results[imp.Path()]; !ok {
+			c.results[imp.Path()] = &result{fd: imp.FileDescriptor}
+		}
+		if err := c.register(imp.FileDescriptor); err != nil {
+			return err
+		}
+	}
+	return c.files.RegisterFile(fd)
+}
+
+// open reads the file with the given path.
+func (c *compiler) open(filename string) ([]byte, error) {
+	if c.opts.Accessor != nil {
+		r, err := c.opts.Accessor(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	}
+	dirs := c.opts.ImportPaths
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+	for _, dir := range dirs {
+		b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(filename)))
+		if err == nil {
+			return b, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return nil, errors.New("file not found")
+}
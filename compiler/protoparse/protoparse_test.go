@@ -0,0 +1,346 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protoparse_test
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"google.golang.org/protobuf/compiler/protoparse"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	_ "google.golang.org/protobuf/cmd/protoc-gen-go/testdata/comments"
+	_ "google.golang.org/protobuf/cmd/protoc-gen-go/testdata/fieldnames"
+	_ "google.golang.org/protobuf/cmd/protoc-gen-go/testdata/import_public"
+	_ "google.golang.org/protobuf/cmd/protoc-gen-go/testdata/imports"
+	_ "google.golang.org/protobuf/cmd/protoc-gen-go/testdata/nopackage"
+	_ "google.golang.org/protobuf/cmd/protoc-gen-go/testdata/proto2"
+	_ "google.golang.org/protobuf/cmd/protoc-gen-go/testdata/proto3"
+	_ "google.golang.org/protobuf/internal/testprotos/enums"
+	_ "google.golang.org/protobuf/internal/testprotos/required"
+	_ "google.golang.org/protobuf/internal/testprotos/test3"
+	_ "google.golang.org/protobuf/internal/testprotos/textpb3"
+)
+
+// TestGenerated compares the descriptors of .proto files in this repository
+// with the descriptors generated for them by protoc.
+func TestGenerated(t *testing.T) {
+	filenames := []string{
+		"cmd/protoc-gen-go/testdata/comments/comments.proto",
+		"cmd/protoc-gen-go/testdata/comments/deprecated.proto",
+		"cmd/protoc-gen-go/testdata/fieldnames/fieldnames.proto",
+		"cmd/protoc-gen-go/testdata/import_public/a.proto",
+		"cmd/protoc-gen-go/testdata/imports/test_import_all.proto",
+		"cmd/protoc-gen-go/testdata/nopackage/nopackage.proto",
+		"cmd/protoc-gen-go/testdata/proto2/enum.proto",
+		"cmd/protoc-gen-go/testdata/proto2/fields.proto",
+		"cmd/protoc-gen-go/testdata/proto2/nested_messages.proto",
+		"cmd/protoc-gen-go/testdata/proto2/proto2.proto",
+		"cmd/protoc-gen-go/testdata/proto3/enum.proto",
+		"cmd/protoc-gen-go/testdata/proto3/fields.proto",
+		"internal/testprotos/enums/enums.proto",
+		"internal/testprotos/required/required.proto",
+		"internal/testprotos/test3/test.proto",
+		"internal/testprotos/test3/test_extension.proto",
+		"internal/testprotos/textpb3/test.proto",
+	}
+	for _, filename := range filenames {
+		t.Run(filename, func(t *testing.T) {
+			fdps, err := protoparse.Parser{ImportPaths: []string{"../.."}}.ParseFiles(filename)
+			if err != nil {
+				t.Fatalf("ParseFiles() error: %v", err)
+			}
+			fd, err := protoregistry.GlobalFiles.FindFileByPath(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := protodesc.ToFileDescriptorProto(fd)
+			if diff := cmp.Diff(want, fdps[0], protocmp.Transform()); diff != "" {
+				t.Errorf("ParseFiles() mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+// accessor returns an accessor for the files with the given contents.
+func accessor(files map[string]string) func(string) (io.ReadCloser, error) {
+	return func(filename string) (io.ReadCloser, error) {
+		s, ok := files[filename]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return ioutil.NopCloser(strings.NewReader(s)), nil
+	}
+}
+
+func TestSourceCodeInfo(t *testing.T) {
+	const src = `// Detached.
+
+// Leading syntax.
+syntax = "proto3";
+
+package foo;
+
+// Leading M.
+message M { // Trailing M.
+  // Leading f.
+  string f = 1;
+  int32 g = 2; // Trailing g.
+}
+`
+	fdps, err := protoparse.Parser{
+		Accessor:              accessor(map[string]string{"test.proto": src}),
+		IncludeSourceCodeInfo: true,
+	}.ParseFiles("test.proto")
+	if err != nil {
+		t.Fatalf("ParseFiles() error: %v", err)
+	}
+	locs := make(map[string]*descriptorpb.SourceCodeInfo_Location)
+	for _, loc := range fdps[0].GetSourceCodeInfo().GetLocation() {
+		locs[fmtPath(loc.Path)] = loc
+	}
+	tests := []struct {
+		path string
+		want *descriptorpb.SourceCodeInfo_Location
+	}{{
+		path: "",
+		want: &descriptorpb.SourceCodeInfo_Location{
+			Span: []int32{3, 0, 12, 1},
+		},
+	}, {
+		path: "12",
+		want: &descriptorpb.SourceCodeInfo_Location{
+			Path:                    []int32{12},
+			Span:                    []int32{3, 0, 18},
+			LeadingComments:         proto.String(" Leading syntax.\n"),
+			LeadingDetachedComments: []string{" Detached.\n"},
+		},
+	}, {
+		path: "2",
+		want: &descriptorpb.SourceCodeInfo_Location{
+			Path: []int32{2},
+			Span: []int32{5, 0, 12},
+		},
+	}, {
+		path: "4.0",
+		want: &descriptorpb.SourceCodeInfo_Location{
+			Path:             []int32{4, 0},
+			Span:             []int32{8, 0, 12, 1},
+			LeadingComments:  proto.String(" Leading M.\n"),
+			TrailingComments: proto.String(" Trailing M.\n"),
+		},
+	}, {
+		path: "4.0.2.0",
+		want: &descriptorpb.SourceCodeInfo_Location{
+			Path:            []int32{4, 0, 2, 0},
+			Span:            []int32{10, 2, 15},
+			LeadingComments: proto.String(" Leading f.\n"),
+		},
+	}, {
+		path: "4.0.2.0.1",
+		want: &descriptorpb.SourceCodeInfo_Location{
+			Path: []int32{4, 0, 2, 0, 1},
+			Span: []int32{10, 9, 10},
+		},
+	}, {
+		path: "4.0.2.1",
+		want: &descriptorpb.SourceCodeInfo_Location{
+			Path:             []int32{4, 0, 2, 1},
+			Span:             []int32{11, 2, 14},
+			TrailingComments: proto.String(" Trailing g.\n"),
+		},
+	}}
+	for _, tt := range tests {
+		got := locs[tt.path]
+		if got == nil {
+			t.Errorf("no location for path %q", tt.path)
+			continue
+		}
+		if got.Path == nil {
+			got.Path = []int32{}
+		}
+		if tt.want.Path == nil {
+			tt.want.Path = []int32{}
+		}
+		if diff := cmp.Diff(tt.want, got, protocmp.Transform()); diff != "" {
+			t.Errorf("location %q mismatch (-want +got):\n%v", tt.path, diff)
+		}
+	}
+}
+
+func fmtPath(path []int32) string {
+	var ss []string
+	for _, n := range path {
+		ss = append(ss, strconv.Itoa(int(n)))
+	}
+	return strings.Join(ss, ".")
+}
+
+func TestCustomOptions(t *testing.T) {
+	const src = `syntax = "proto2";
+
+package foo;
+
+import "google/protobuf/descriptor.proto";
+
+message Opt {
+  optional string name = 1;
+  repeated int32 nums = 2;
+  optional Opt sub = 3;
+}
+
+extend google.protobuf.MessageOptions {
+  optional Opt opt = 50000;
+  repeated string tags = 50001;
+}
+
+message M {
+  option deprecated = true;
+  option (opt).name = "x";
+  option (opt).sub = { name: "y" nums: [1, 2] };
+  option (tags) = "a";
+  option (foo.tags) = "b";
+}
+`
+	fdps, err := protoparse.Parser{
+		Accessor: accessor(map[string]string{"test.proto": src}),
+	}.ParseFiles("test.proto")
+	if err != nil {
+		t.Fatalf("ParseFiles() error: %v", err)
+	}
+	fd, err := protodesc.NewFile(fdps[0], protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := new(protoregistry.Types)
+	for i := 0; i < fd.Extensions().Len(); i++ {
+		if err := types.RegisterExtension(dynamicpb.NewExtensionType(fd.Extensions().Get(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Custom options are unknown fields in the options message.
+	b, err := proto.Marshal(fdps[0].MessageType[1].GetOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := new(descriptorpb.MessageOptions)
+	if err := (proto.UnmarshalOptions{Resolver: types}).Unmarshal(b, opts); err != nil {
+		t.Fatal(err)
+	}
+	got := prototext.MarshalOptions{Resolver: types}.Format(opts)
+	want := `deprecated:true [foo.opt]:{name:"x" sub:{name:"y" nums:1 nums:2}} [foo.tags]:"a" [foo.tags]:"b"`
+	if normalize(got) != normalize(want) {
+		t.Errorf("options:\ngot  %v\nwant %v", got, want)
+	}
+}
+
+// normalize removes the insignificant whitespace from text format output,
+// which is randomized.
+func normalize(s string) string {
+	return strings.Join(strings.Fields(strings.Replace(s, ":", ": ", -1)), " ")
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		desc  string
+		files map[string]string
+		want  string
+	}{{
+		desc:  "syntax error",
+		files: map[string]string{"a.proto": "syntax = \"proto3\";\nmessage M {\n  int32 x 1;\n}\n"},
+		want:  `a.proto:3:11: expected "=", found "1"`,
+	}, {
+		desc:  "unknown syntax",
+		files: map[string]string{"a.proto": `syntax = "proto4";`},
+		want:  `a.proto:1:10: unknown syntax "proto4"`,
+	}, {
+		desc:  "unknown type",
+		files: map[string]string{"a.proto": "syntax = \"proto3\";\nmessage M {\n  Foo x = 1;\n}\n"},
+		want:  "a.proto:3:3: Foo is not defined",
+	}, {
+		desc:  "duplicate name",
+		files: map[string]string{"a.proto": "syntax = \"proto3\";\nmessage M {}\nenum M { X = 0; }\n"},
+		want:  "a.proto:3:6: M is already defined as a message at line 2",
+	}, {
+		desc:  "duplicate field number",
+		files: map[string]string{"a.proto": "syntax = \"proto3\";\nmessage M {\n  int32 x = 1;\n  int32 y = 1;\n}\n"},
+		want:  "a.proto:4:9: field number 1 has already been used in M by field x",
+	}, {
+		desc:  "reserved field number",
+		files: map[string]string{"a.proto": "syntax = \"proto3\";\nmessage M {\n  reserved 1 to 3;\n  int32 x = 2;\n}\n"},
+		want:  "a.proto:4:9: field x uses reserved number 2",
+	}, {
+		desc:  "import not found",
+		files: map[string]string{"a.proto": "syntax = \"proto3\";\nimport \"b.proto\";\n"},
+		want:  "a.proto:2:8: file does not exist",
+	}, {
+		desc: "type not imported",
+		files: map[string]string{
+			"a.proto": "syntax = \"proto3\";\nimport \"b.proto\";\nmessage M {\n  C x = 1;\n}\n",
+			"b.proto": "syntax = \"proto3\";\nimport \"c.proto\";\n",
+			"c.proto": "syntax = \"proto3\";\nmessage C {}\n",
+		},
+		want: `a.proto:4:3: C is declared in "c.proto", which is not imported`,
+	}, {
+		desc: "import cycle",
+		files: map[string]string{
+			"a.proto": "syntax = \"proto3\";\nimport \"b.proto\";\n",
+			"b.proto": "syntax = \"proto3\";\nimport \"a.proto\";\n",
+		},
+		want: `b.proto:2:8: import cycle involving "a.proto"`,
+	}, {
+		desc:  "unknown option",
+		files: map[string]string{"a.proto": "syntax = \"proto3\";\noption foo = true;\n"},
+		want:  "a.proto:2:8: option foo is unknown for google.protobuf.FileOptions",
+	}, {
+		desc:  "invalid enum default",
+		files: map[string]string{"a.proto": "syntax = \"proto2\";\nenum E { A = 0; }\nmessage M {\n  optional E e = 1 [default = B];\n}\n"},
+		want:  "a.proto:4:31: enum E has no value named B",
+	}, {
+		desc:  "proto3 JSON name conflict",
+		files: map[string]string{"a.proto": "syntax = \"proto3\";\nmessage M {\n  int32 foo_bar = 1;\n  int32 fooBar = 2;\n}\n"},
+		want:  `a.proto:4:3: proto: message "M" using proto3 semantics has conflict: "fooBar" with "foo_bar"`,
+	}, {
+		desc:  "overlapping reserved ranges",
+		files: map[string]string{"a.proto": "syntax = \"proto3\";\nmessage M {\n  reserved 1 to 5;\n  reserved 3;\n}\n"},
+		want:  `a.proto:2:1: proto: message "M" reserved ranges has overlapping ranges: 1 to 5 with 3`,
+	}, {
+		desc:  "duplicate enum value without alias",
+		files: map[string]string{"a.proto": "syntax = \"proto3\";\nenum E {\n  A = 0;\n  B = 0;\n}\n"},
+		want:  `a.proto:4:3: proto: enum "E" has conflicting non-aliased values on number 0: "B" with "A"`,
+	}, {
+		desc:  "not packable",
+		files: map[string]string{"a.proto": "syntax = \"proto3\";\nmessage M {\n  repeated string s = 1 [packed = true];\n}\n"},
+		want:  `a.proto:3:3: proto: message field "M.s" is not packable`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := protoparse.Parser{Accessor: accessor(tt.files)}.ParseFiles("a.proto")
+			if err == nil {
+				t.Fatalf("ParseFiles() succeeded, want error %q", tt.want)
+			}
+			if _, ok := err.(*protoparse.Error); !ok {
+				t.Errorf("ParseFiles() error is %T, want *protoparse.Error", err)
+			}
+			// Error messages may use a non-breaking space after the prefix.
+			if got := strings.Replace(err.Error(), "\u00a0", " ", -1); got != tt.want {
+				t.Errorf("ParseFiles() error:\ngot  %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
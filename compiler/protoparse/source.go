@@ -0,0 +1,24 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protoparse
+
+import (
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// sourceCodeInfo returns the locations recorded while parsing,
+// in the order in which the declarations start.
+func (p *parser) sourceCodeInfo() *descriptorpb.SourceCodeInfo {
+	info := new(descriptorpb.SourceCodeInfo)
+	for _, l := range p.locs {
+		// The span has three elements if it is on a single line.
+		l.pb.Span = []int32{int32(l.start.line), int32(l.start.col), int32(l.end.line), int32(l.end.col)}
+		if l.start.line == l.end.line {
+			l.pb.Span = []int32{int32(l.start.line), int32(l.start.col), int32(l.end.col)}
+		}
+		info.Location = append(info.Location, l.pb)
+	}
+	return info
+}
@@ -0,0 +1,335 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protoprint
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/internal/errors"
+	"google.golang.org/protobuf/internal/genid"
+	"google.golang.org/protobuf/internal/strs"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func (p *printer) messageElem(md protoreflect.MessageDescriptor, path protoreflect.SourcePath) elem {
+	loc := p.loc(path)
+	return elem{loc: loc, block: true, print: func() {
+		p.leading(loc)
+		p.open("message "+string(md.Name()), loc)
+		p.messageBody(md, path)
+		p.close()
+	}}
+}
+
+func (p *printer) messageBody(md protoreflect.MessageDescriptor, path protoreflect.SourcePath) {
+	p.optionStatements(md, md.Options(), appendPath(path, genid.DescriptorProto_Options_field_number), md.FullName())
+
+	// The messages of map and group fields are declared along with the
+	// fields, so the other messages are declared in between the fields to
+	// preserve the order of the messages.
+	var elems []elem
+	groups := groupMessages(md.Fields(), md.Extensions())
+	nested := 0
+	declareMessages := func(end int) {
+		for ; nested < end; nested++ {
+			if m := md.Messages().Get(nested); !m.IsMapEntry() && !groups[m] {
+				elems = append(elems, p.messageElem(m, appendPath(path, genid.DescriptorProto_NestedType_field_number, nested)))
+			}
+		}
+	}
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if od := fd.ContainingOneof(); od != nil && !od.IsSynthetic() {
+			if od.Fields().Get(0) == fd {
+				for j := 0; j < od.Fields().Len(); j++ {
+					if fd := od.Fields().Get(j); isGroup(fd) {
+						declareMessages(fd.Message().Index())
+						break
+					}
+				}
+				elems = append(elems, p.oneofElem(od, path))
+			}
+			continue
+		}
+		if fd.IsMap() || isGroup(fd) {
+			declareMessages(fd.Message().Index())
+		}
+		elems = append(elems, p.fieldElem(fd, appendPath(path, genid.DescriptorProto_Field_field_number, i)))
+	}
+	declareMessages(md.Messages().Len())
+	for i := 0; i < md.Enums().Len(); i++ {
+		elems = append(elems, p.enumElem(md.Enums().Get(i), appendPath(path, genid.DescriptorProto_EnumType_field_number, i)))
+	}
+	for i := 0; i < md.Extensions().Len(); i++ {
+		elems = append(elems, p.fieldElem(md.Extensions().Get(i), appendPath(path, genid.DescriptorProto_Extension_field_number, i)))
+	}
+	for i := 0; i < md.ExtensionRanges().Len(); i++ {
+		r := md.ExtensionRanges().Get(i)
+		rangePath := appendPath(path, genid.DescriptorProto_ExtensionRange_field_number, i)
+		loc := p.loc(rangePath)
+		opts := p.compactOptions(md, md.ExtensionRangeOptions(i), md.FullName())
+		elems = append(elems, elem{loc: loc, print: func() {
+			p.leading(loc)
+			p.decl("extensions "+rangeString(int64(r[0]), int64(r[1])-1, int64(protowire.MaxValidNumber))+optionsString(opts)+";", loc)
+		}})
+	}
+	if rs := md.ReservedRanges(); rs.Len() > 0 {
+		var ss []string
+		for i := 0; i < rs.Len(); i++ {
+			r := rs.Get(i)
+			ss = append(ss, rangeString(int64(r[0]), int64(r[1])-1, int64(protowire.MaxValidNumber)))
+		}
+		elems = append(elems, p.reservedElem(ss, appendPath(path, genid.DescriptorProto_ReservedRange_field_number)))
+	}
+	if ns := md.ReservedNames(); ns.Len() > 0 {
+		var ss []string
+		for i := 0; i < ns.Len(); i++ {
+			ss = append(ss, strconv.Quote(string(ns.Get(i))))
+		}
+		elems = append(elems, p.reservedElem(ss, appendPath(path, genid.DescriptorProto_ReservedName_field_number)))
+	}
+	p.printElems(elems, false)
+}
+
+func (p *printer) reservedElem(ss []string, path protoreflect.SourcePath) elem {
+	loc := p.loc(path)
+	return elem{loc: loc, print: func() {
+		p.leading(loc)
+		p.decl("reserved "+strings.Join(ss, ", ")+";", loc)
+	}}
+}
+
+// rangeString returns the range from start to end inclusive, as in a reserved
+// or extensions statement.
+func rangeString(start, end, max int64) string {
+	switch {
+	case start == end:
+		return strconv.FormatInt(start, 10)
+	case end == max:
+		return strconv.FormatInt(start, 10) + " to max"
+	default:
+		return strconv.FormatInt(start, 10) + " to " + strconv.FormatInt(end, 10)
+	}
+}
+
+// groupMessages returns the messages of the group fields among the fields,
+// which are printed as part of the fields.
+func groupMessages(lists ...interface {
+	Len() int
+	Get(int) protoreflect.FieldDescriptor
+}) map[protoreflect.MessageDescriptor]bool {
+	groups := make(map[protoreflect.MessageDescriptor]bool)
+	for _, fds := range lists {
+		for i := 0; i < fds.Len(); i++ {
+			if fd := fds.Get(i); isGroup(fd) {
+				groups[fd.Message()] = true
+			}
+		}
+	}
+	return groups
+}
+
+// isGroup reports whether fd is a group field, whose message is declared
+// along with it.
+func isGroup(fd protoreflect.FieldDescriptor) bool {
+	if fd.Kind() != protoreflect.GroupKind {
+		return false
+	}
+	md := fd.Message()
+	return md.Parent() == fd.Parent() && string(fd.Name()) == strings.ToLower(string(md.Name()))
+}
+
+func (p *printer) oneofElem(od protoreflect.OneofDescriptor, msgPath protoreflect.SourcePath) elem {
+	path := appendPath(msgPath, genid.DescriptorProto_OneofDecl_field_number, od.Index())
+	loc := p.loc(path)
+	return elem{loc: loc, block: true, print: func() {
+		p.leading(loc)
+		p.open("oneof "+string(od.Name()), loc)
+		md := od.Parent().(protoreflect.MessageDescriptor)
+		p.optionStatements(od, od.Options(), appendPath(path, genid.OneofDescriptorProto_Options_field_number), md.FullName())
+		var elems []elem
+		for i := 0; i < od.Fields().Len(); i++ {
+			fd := od.Fields().Get(i)
+			elems = append(elems, p.fieldElem(fd, appendPath(msgPath, genid.DescriptorProto_Field_field_number, fd.Index())))
+		}
+		p.printElems(elems, false)
+		p.close()
+	}}
+}
+
+func (p *printer) fieldElem(fd protoreflect.FieldDescriptor, path protoreflect.SourcePath) elem {
+	loc := p.loc(path)
+	e := elem{loc: loc, block: isGroup(fd), print: func() {
+		p.leading(loc)
+		p.field(fd, path, loc)
+	}}
+	if fd.IsExtension() {
+		e.extendee = fd.ContainingMessage().FullName()
+		e.scope = fd.Parent().FullName()
+	}
+	return e
+}
+
+func (p *printer) field(fd protoreflect.FieldDescriptor, path protoreflect.SourcePath, loc protoreflect.SourceLocation) {
+	scope := fd.Parent().FullName()
+	s := label(fd)
+	switch {
+	case isGroup(fd):
+		s += "group " + string(fd.Message().Name())
+	case fd.Kind() == protoreflect.GroupKind:
+		p.fail(errors.New("group field %v cannot be printed, since its message is declared elsewhere", fd.FullName()))
+		return
+	case fd.IsMap():
+		s += "map<" + p.typeName(fd.MapKey(), scope) + ", " + p.typeName(fd.MapValue(), scope) + "> " + string(fd.Name())
+	default:
+		s += p.typeName(fd, scope) + " " + string(fd.Name())
+	}
+	s += " = " + strconv.Itoa(int(fd.Number()))
+
+	var opts []string
+	switch {
+	case fd.Kind() == protoreflect.EnumKind && fd.HasDefault():
+		// The default may be an alias of another value with the same number.
+		opts = append(opts, "default = "+string(fd.DefaultEnumValue().Name()))
+	case fd.HasDefault():
+		opts = append(opts, "default = "+p.scalar(fd, fd.Default()))
+	}
+	if !fd.IsExtension() && fd.HasJSONName() && fd.JSONName() != strs.JSONCamelCase(string(fd.Name())) {
+		opts = append(opts, "json_name = "+strconv.Quote(fd.JSONName()))
+	}
+	opts = append(opts, p.compactOptions(fd, fd.Options(), scope)...)
+	s += optionsString(opts)
+
+	if !isGroup(fd) {
+		p.decl(s+";", loc)
+		return
+	}
+	md := fd.Message()
+	mdPath := appendPath(path[:len(path)-2], genid.DescriptorProto_NestedType_field_number, md.Index())
+	if _, ok := md.Parent().(protoreflect.FileDescriptor); ok {
+		mdPath = appendPath(nil, genid.FileDescriptorProto_MessageType_field_number, md.Index())
+	}
+	p.open(s, loc)
+	p.messageBody(md, mdPath)
+	p.close()
+}
+
+// label returns the label of a field, as written before its type.
+func label(fd protoreflect.FieldDescriptor) string {
+	if od := fd.ContainingOneof(); (od != nil && !od.IsSynthetic()) || fd.IsMap() {
+		return ""
+	}
+	switch {
+	case fd.Cardinality() == protoreflect.Repeated:
+		return "repeated "
+	case fd.Cardinality() == protoreflect.Required:
+		return "required "
+	case fd.Syntax() == protoreflect.Proto2 || fd.HasOptionalKeyword():
+		return "optional "
+	}
+	return ""
+}
+
+// typeName returns the type of a field, as written in its declaration.
+func (p *printer) typeName(fd protoreflect.FieldDescriptor, scope protoreflect.FullName) string {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return p.relName(fd.Message().FullName(), scope, true)
+	case protoreflect.EnumKind:
+		return p.relName(fd.Enum().FullName(), scope, true)
+	}
+	return fd.Kind().String()
+}
+
+func optionsString(opts []string) string {
+	if len(opts) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(opts, ", ") + "]"
+}
+
+func (p *printer) enumElem(ed protoreflect.EnumDescriptor, path protoreflect.SourcePath) elem {
+	loc := p.loc(path)
+	return elem{loc: loc, block: true, print: func() {
+		p.leading(loc)
+		p.open("enum "+string(ed.Name()), loc)
+		p.optionStatements(ed, ed.Options(), appendPath(path, genid.EnumDescriptorProto_Options_field_number), ed.FullName())
+
+		var elems []elem
+		for i := 0; i < ed.Values().Len(); i++ {
+			vd := ed.Values().Get(i)
+			valuePath := appendPath(path, genid.EnumDescriptorProto_Value_field_number, i)
+			loc := p.loc(valuePath)
+			elems = append(elems, elem{loc: loc, print: func() {
+				opts := p.compactOptions(vd, vd.Options(), ed.FullName())
+				p.leading(loc)
+				p.decl(string(vd.Name())+" = "+strconv.Itoa(int(vd.Number()))+optionsString(opts)+";", loc)
+			}})
+		}
+		if rs := ed.ReservedRanges(); rs.Len() > 0 {
+			var ss []string
+			for i := 0; i < rs.Len(); i++ {
+				r := rs.Get(i)
+				ss = append(ss, rangeString(int64(r[0]), int64(r[1]), math.MaxInt32))
+			}
+			elems = append(elems, p.reservedElem(ss, appendPath(path, genid.EnumDescriptorProto_ReservedRange_field_number)))
+		}
+		if ns := ed.ReservedNames(); ns.Len() > 0 {
+			var ss []string
+			for i := 0; i < ns.Len(); i++ {
+				ss = append(ss, strconv.Quote(string(ns.Get(i))))
+			}
+			elems = append(elems, p.reservedElem(ss, appendPath(path, genid.EnumDescriptorProto_ReservedName_field_number)))
+		}
+		p.printElems(elems, false)
+		p.close()
+	}}
+}
+
+func (p *printer) serviceElem(sd protoreflect.ServiceDescriptor, path protoreflect.SourcePath) elem {
+	loc := p.loc(path)
+	return elem{loc: loc, block: true, print: func() {
+		p.leading(loc)
+		p.open("service "+string(sd.Name()), loc)
+		p.optionStatements(sd, sd.Options(), appendPath(path, genid.ServiceDescriptorProto_Options_field_number), sd.FullName())
+
+		var elems []elem
+		for i := 0; i < sd.Methods().Len(); i++ {
+			md := sd.Methods().Get(i)
+			methodPath := appendPath(path, genid.ServiceDescriptorProto_Method_field_number, i)
+			loc := p.loc(methodPath)
+			elems = append(elems, elem{loc: loc, print: func() {
+				p.leading(loc)
+				p.method(md, methodPath, loc)
+			}})
+		}
+		p.printElems(elems, false)
+		p.close()
+	}}
+}
+
+func (p *printer) method(md protoreflect.MethodDescriptor, path protoreflect.SourcePath, loc protoreflect.SourceLocation) {
+	scope := md.Parent().FullName()
+	typ := func(streaming bool, md protoreflect.MessageDescriptor) string {
+		s := p.relName(md.FullName(), scope, true)
+		if streaming {
+			s = "stream " + s
+		}
+		return "(" + s + ")"
+	}
+	s := "rpc " + string(md.Name()) + typ(md.IsStreamingClient(), md.Input()) + " returns " + typ(md.IsStreamingServer(), md.Output())
+	opts := p.options(md, md.Options(), scope)
+	if len(opts) == 0 {
+		p.decl(s+";", loc)
+		return
+	}
+	p.open(s, loc)
+	p.printOptions(opts, appendPath(path, genid.MethodDescriptorProto_Options_field_number))
+	p.close()
+}
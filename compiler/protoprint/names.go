@@ -0,0 +1,127 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protoprint
+
+import (
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// nameKind is the kind of a declared name.
+type nameKind int
+
+const (
+	nameOther nameKind = iota // such as a field or an enum value
+	namePackage
+	nameService
+	nameType // a message or an enum
+)
+
+// extensionKey identifies an extension by the message it extends and its number.
+type extensionKey struct {
+	message protoreflect.FullName
+	number  protoreflect.FieldNumber
+}
+
+// addNames adds the names declared in fd and the files it imports,
+// unless they have been seen already.
+func (p *printer) addNames(fd protoreflect.FileDescriptor, seen map[string]bool) {
+	if seen[fd.Path()] {
+		return
+	}
+	seen[fd.Path()] = true
+	for pkg := fd.Package(); pkg != ""; pkg = pkg.Parent() {
+		if _, ok := p.names[pkg]; !ok {
+			p.names[pkg] = namePackage
+		}
+	}
+	for i := 0; i < fd.Imports().Len(); i++ {
+		p.addNames(fd.Imports().Get(i).FileDescriptor, seen)
+	}
+	p.addMessages(fd.Messages())
+	p.addEnums(fd.Enums())
+	p.addExtensions(fd.Extensions())
+	for i := 0; i < fd.Services().Len(); i++ {
+		sd := fd.Services().Get(i)
+		p.names[sd.FullName()] = nameService
+		for j := 0; j < sd.Methods().Len(); j++ {
+			p.names[sd.Methods().Get(j).FullName()] = nameOther
+		}
+	}
+}
+
+func (p *printer) addMessages(mds protoreflect.MessageDescriptors) {
+	for i := 0; i < mds.Len(); i++ {
+		md := mds.Get(i)
+		p.names[md.FullName()] = nameType
+		for j := 0; j < md.Fields().Len(); j++ {
+			p.names[md.Fields().Get(j).FullName()] = nameOther
+		}
+		for j := 0; j < md.Oneofs().Len(); j++ {
+			p.names[md.Oneofs().Get(j).FullName()] = nameOther
+		}
+		p.addMessages(md.Messages())
+		p.addEnums(md.Enums())
+		p.addExtensions(md.Extensions())
+	}
+}
+
+func (p *printer) addEnums(eds protoreflect.EnumDescriptors) {
+	for i := 0; i < eds.Len(); i++ {
+		ed := eds.Get(i)
+		p.names[ed.FullName()] = nameType
+		for j := 0; j < ed.Values().Len(); j++ {
+			p.names[ed.Values().Get(j).FullName()] = nameOther
+		}
+	}
+}
+
+func (p *printer) addExtensions(xds protoreflect.ExtensionDescriptors) {
+	for i := 0; i < xds.Len(); i++ {
+		xd := xds.Get(i)
+		p.names[xd.FullName()] = nameOther
+		p.exts[xd.FullName()] = xd
+		p.extsByNumber[extensionKey{xd.ContainingMessage().FullName(), xd.Number()}] = xd
+	}
+}
+
+// relName returns the shortest name which refers to the declaration with the
+// full name, when used in scope. If typ is set, the name refers to a type.
+func (p *printer) relName(full, scope protoreflect.FullName, typ bool) string {
+	parts := strings.Split(string(full), ".")
+	for i := len(parts) - 1; i >= 0; i-- {
+		name := strings.Join(parts[i:], ".")
+		if p.resolve(name, scope, typ) == full {
+			return name
+		}
+	}
+	return "." + string(full)
+}
+
+// resolve returns the full name which the name refers to when used in scope,
+// as resolved by protoc: the scope and then its enclosing scopes are searched
+// for the first component of the name, and the rest of the name is then
+// looked up in the first scope in which it is found.
+func (p *printer) resolve(name string, scope protoreflect.FullName, typ bool) protoreflect.FullName {
+	first := name
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		first = name[:i]
+	}
+	for s := scope; ; s = s.Parent() {
+		full := s.Append(protoreflect.Name(first))
+		if kind, ok := p.names[full]; ok {
+			switch {
+			case first != name && kind != nameOther:
+				return protoreflect.FullName(string(full) + name[len(first):])
+			case first == name && (!typ || kind == nameType):
+				return full
+			}
+		}
+		if s == "" {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protoprint
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/internal/encoding/defval"
+	"google.golang.org/protobuf/internal/encoding/text"
+	"google.golang.org/protobuf/internal/errors"
+	"google.golang.org/protobuf/internal/order"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// option is an option set on a declaration.
+// An option which is a repeated field has an option for each element.
+type option struct {
+	name string // such as "deprecated" or "(foo.bar)"
+	fd   protoreflect.FieldDescriptor
+	v    protoreflect.Value
+}
+
+// options returns the options of the declaration d, in the order of their
+// field numbers. The names of custom options are resolved in scope.
+func (p *printer) options(d protoreflect.Descriptor, opts proto.Message, scope protoreflect.FullName) []option {
+	if opts == nil || !opts.ProtoReflect().IsValid() {
+		return nil
+	}
+	b, err := proto.MarshalOptions{AllowPartial: true, Deterministic: true}.Marshal(opts)
+	if err != nil {
+		p.fail(errors.Wrap(err, "invalid options of %v", d.FullName()))
+		return nil
+	}
+	if len(b) == 0 {
+		return nil
+	}
+
+	// Unmarshal the options afresh, so that custom options which are unknown
+	// fields become extension fields.
+	m := opts.ProtoReflect().New()
+	if err := (proto.UnmarshalOptions{AllowPartial: true, Resolver: resolver{p}}).Unmarshal(b, m.Interface()); err != nil {
+		p.fail(errors.Wrap(err, "invalid options of %v", d.FullName()))
+		return nil
+	}
+	if unknown := m.GetUnknown(); len(unknown) > 0 {
+		num, _, _ := protowire.ConsumeTag(unknown)
+		p.fail(errors.New("option with field number %d of %v cannot be resolved", num, d.FullName()))
+		return nil
+	}
+
+	var list []option
+	order.RangeFields(m, order.NumberFieldOrder, func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		name := string(fd.Name())
+		if fd.IsExtension() {
+			name = "(" + p.relName(fd.FullName(), scope, false) + ")"
+		}
+		if fd.IsList() {
+			for i := 0; i < v.List().Len(); i++ {
+				list = append(list, option{name, fd, v.List().Get(i)})
+			}
+		} else {
+			list = append(list, option{name, fd, v})
+		}
+		return true
+	})
+	return list
+}
+
+// optionStatements prints the options of the declaration d as option
+// statements. The path is that of the options in the declaration.
+func (p *printer) optionStatements(d protoreflect.Descriptor, opts proto.Message, path protoreflect.SourcePath, scope protoreflect.FullName) {
+	p.printOptions(p.options(d, opts, scope), path)
+}
+
+func (p *printer) printOptions(opts []option, path protoreflect.SourcePath) {
+	for i, o := range opts {
+		var loc protoreflect.SourceLocation
+		if i == 0 || opts[i-1].fd != o.fd {
+			loc = p.loc(appendPath(path, o.fd.Number()))
+		}
+		p.leading(loc)
+		p.decl("option "+o.name+" = "+p.value(o.fd, o.v, true)+";", loc)
+	}
+	if len(opts) > 0 {
+		p.softBlank = true
+	}
+}
+
+// compactOptions returns the options of the declaration d as they are written
+// in brackets after a field or an enum value.
+func (p *printer) compactOptions(d protoreflect.Descriptor, opts proto.Message, scope protoreflect.FullName) []string {
+	var ss []string
+	for _, o := range p.options(d, opts, scope) {
+		ss = append(ss, o.name+" = "+p.value(o.fd, o.v, false))
+	}
+	return ss
+}
+
+// value returns the value v of the field fd. A message value is written in
+// the text format, on multiple lines if multiline is set.
+func (p *printer) value(fd protoreflect.FieldDescriptor, v protoreflect.Value, multiline bool) string {
+	if fd.Message() == nil {
+		return p.scalar(fd, v)
+	}
+	var b strings.Builder
+	p.message(&b, v.Message(), p.depth, multiline)
+	return b.String()
+}
+
+// scalar returns the value v of the field fd, which is not a message.
+func (p *printer) scalar(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return strconv.FormatBool(v.Bool())
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return strconv.FormatInt(int64(v.Enum()), 10)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return strconv.FormatInt(v.Int(), 10)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return strconv.FormatUint(v.Uint(), 10)
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		f := v.Float()
+		switch {
+		case math.IsNaN(f):
+			return "nan"
+		case math.IsInf(f, +1):
+			return "inf"
+		case math.IsInf(f, -1):
+			return "-inf"
+		case fd.Kind() == protoreflect.FloatKind:
+			return strconv.FormatFloat(f, 'g', -1, 32)
+		default:
+			return strconv.FormatFloat(f, 'g', -1, 64)
+		}
+	case protoreflect.StringKind:
+		return string(text.AppendString(nil, v.String()))
+	case protoreflect.BytesKind:
+		s, err := defval.Marshal(v, nil, protoreflect.BytesKind, defval.Descriptor)
+		if err != nil {
+			p.fail(err)
+		}
+		return `"` + s + `"`
+	}
+	return v.String()
+}
+
+// message writes m in the text format, in braces. The fields are on their
+// own lines, indented by depth+1, if multiline is set.
+func (p *printer) message(b *strings.Builder, m protoreflect.Message, depth int, multiline bool) {
+	b.WriteByte('{')
+	n := 0
+	writeField := func(name string, fd protoreflect.FieldDescriptor, v protoreflect.Value) {
+		switch {
+		case multiline:
+			b.WriteByte('\n')
+			b.WriteString(strings.Repeat(p.indent, depth+1))
+		case n > 0:
+			b.WriteByte(' ')
+		}
+		n++
+		b.WriteString(name)
+		if fd.Message() == nil {
+			b.WriteString(": " + p.scalar(fd, v))
+			return
+		}
+		b.WriteByte(' ')
+		p.message(b, v.Message(), depth+1, multiline)
+	}
+	order.RangeFields(m, order.NumberFieldOrder, func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		name := string(fd.Name())
+		switch {
+		case fd.IsExtension():
+			name = "[" + string(fd.FullName()) + "]"
+		case fd.Kind() == protoreflect.GroupKind:
+			name = string(fd.Message().Name())
+		}
+		switch {
+		case fd.IsList():
+			for i := 0; i < v.List().Len(); i++ {
+				writeField(name, fd, v.List().Get(i))
+			}
+		case fd.IsMap():
+			order.RangeEntries(v.Map(), order.GenericKeyOrder, func(k protoreflect.MapKey, v protoreflect.Value) bool {
+				entry := dynamicpb.NewMessage(fd.Message())
+				entry.Set(fd.MapKey(), k.Value())
+				entry.Set(fd.MapValue(), v)
+				writeField(name, fd, protoreflect.ValueOfMessage(entry))
+				return true
+			})
+		default:
+			writeField(name, fd, v)
+		}
+		return true
+	})
+	if len(m.GetUnknown()) > 0 {
+		p.fail(errors.New("option value of type %v has unknown fields", m.Descriptor().FullName()))
+	}
+	if multiline && n > 0 {
+		b.WriteByte('\n')
+		b.WriteString(strings.Repeat(p.indent, depth))
+	}
+	b.WriteByte('}')
+}
+
+// resolver resolves the extensions used as custom options.
+type resolver struct{ p *printer }
+
+func (r resolver) FindExtensionByName(name protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	if xd, ok := r.p.exts[name]; ok {
+		return r.p.extensionType(xd), nil
+	}
+	return r.p.opts.Resolver.FindExtensionByName(name)
+}
+
+func (r resolver) FindExtensionByNumber(message protoreflect.FullName, number protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	if xd, ok := r.p.extsByNumber[extensionKey{message, number}]; ok {
+		return r.p.extensionType(xd), nil
+	}
+	return r.p.opts.Resolver.FindExtensionByNumber(message, number)
+}
+
+func (p *printer) extensionType(xd protoreflect.ExtensionDescriptor) protoreflect.ExtensionType {
+	if xt, ok := xd.(protoreflect.ExtensionTypeDescriptor); ok {
+		return xt.Type()
+	}
+	xt, ok := p.extTypes[xd.FullName()]
+	if !ok {
+		xt = dynamicpb.NewExtensionType(xd)
+		p.extTypes[xd.FullName()] = xt
+	}
+	return xt
+}
+
+var _ protoregistry.ExtensionTypeResolver = resolver{}
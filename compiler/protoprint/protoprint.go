@@ -0,0 +1,347 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package protoprint prints file descriptors as .proto source files.
+//
+// The printed source declares the same messages, enums, extensions, and
+// services as the descriptor, with the same options, so that compiling it
+// with protoc or protoparse produces an equivalent descriptor.
+// Comments are printed from the source locations of the descriptor,
+// which also determine the order of declarations if every declaration
+// has one.
+package protoprint
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/internal/genid"
+	"google.golang.org/protobuf/internal/pragma"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	// The options of descriptors are messages of this package.
+	_ "google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Print prints fd as a .proto source file using the default options.
+func Print(fd protoreflect.FileDescriptor) ([]byte, error) {
+	return Printer{}.Print(fd)
+}
+
+// Printer is a configurable .proto source printer.
+type Printer struct {
+	pragma.NoUnkeyedLiterals
+
+	// Indent is the indentation of nested declarations.
+	// If empty, two spaces are used.
+	Indent string
+
+	// OmitComments specifies whether to omit the comments recorded in the
+	// source locations of the descriptor.
+	OmitComments bool
+
+	// Resolver is used for looking up the extensions used as custom options
+	// which are declared neither in the file nor in the files it imports.
+	// If nil, this defaults to using protoregistry.GlobalTypes.
+	Resolver interface {
+		protoregistry.ExtensionTypeResolver
+	}
+}
+
+// Print prints fd as a .proto source file.
+//
+// It reports an error if fd cannot be represented in .proto source,
+// such as when it has options which cannot be resolved.
+func (o Printer) Print(fd protoreflect.FileDescriptor) ([]byte, error) {
+	p := &printer{
+		opts:         o,
+		file:         fd,
+		locs:         fd.SourceLocations(),
+		indent:       o.Indent,
+		names:        make(map[protoreflect.FullName]nameKind),
+		exts:         make(map[protoreflect.FullName]protoreflect.ExtensionDescriptor),
+		extsByNumber: make(map[extensionKey]protoreflect.ExtensionDescriptor),
+		extTypes:     make(map[protoreflect.FullName]protoreflect.ExtensionType),
+	}
+	if p.indent == "" {
+		p.indent = "  "
+	}
+	if p.opts.Resolver == nil {
+		p.opts.Resolver = protoregistry.GlobalTypes
+	}
+	p.addNames(fd, make(map[string]bool))
+	p.printFile()
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.out, nil
+}
+
+type printer struct {
+	opts   Printer
+	file   protoreflect.FileDescriptor
+	locs   protoreflect.SourceLocations
+	indent string
+
+	// names are the names declared in the file and the files it imports,
+	// for finding the shortest names which refer to declarations.
+	names map[protoreflect.FullName]nameKind
+
+	// exts are the extensions declared in the file and the files it imports,
+	// for resolving custom options.
+	exts         map[protoreflect.FullName]protoreflect.ExtensionDescriptor
+	extsByNumber map[extensionKey]protoreflect.ExtensionDescriptor
+	extTypes     map[protoreflect.FullName]protoreflect.ExtensionType
+
+	out   []byte
+	depth int
+	// softBlank and hardBlank report whether a blank line is to be written
+	// before the next line. A soft blank line is not written at the start
+	// of a block.
+	softBlank, hardBlank bool
+
+	err error // the first error
+}
+
+func (p *printer) fail(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// line writes a line at the current indentation.
+func (p *printer) line(s string) {
+	if len(p.out) > 0 && (p.hardBlank || (p.softBlank && !bytes.HasSuffix(p.out, []byte("{\n")))) {
+		p.out = append(p.out, '\n')
+	}
+	p.softBlank, p.hardBlank = false, false
+	for i := 0; i < p.depth; i++ {
+		p.out = append(p.out, p.indent...)
+	}
+	p.out = append(p.out, s...)
+	p.out = append(p.out, '\n')
+}
+
+// decl writes the line of a declaration, followed by its trailing comment.
+func (p *printer) decl(s string, loc protoreflect.SourceLocation) {
+	lines := p.commentLines(loc.TrailingComments)
+	if len(lines) == 1 {
+		s += " //" + lines[0]
+		lines = nil
+	}
+	p.line(s)
+	for _, l := range lines {
+		p.line("//" + l)
+	}
+	if len(lines) > 0 {
+		// A comment on the lines after a declaration is only a trailing
+		// comment if it is followed by a blank line.
+		p.hardBlank = true
+	}
+}
+
+// open writes the first line of a block, and indents the lines after it.
+func (p *printer) open(s string, loc protoreflect.SourceLocation) {
+	p.decl(s+" {", loc)
+	p.depth++
+}
+
+// close writes the last line of a block.
+func (p *printer) close() {
+	p.depth--
+	p.softBlank = false
+	p.line("}")
+}
+
+// leading writes the leading comments of a declaration.
+func (p *printer) leading(loc protoreflect.SourceLocation) {
+	if p.opts.OmitComments {
+		return
+	}
+	for _, c := range loc.LeadingDetachedComments {
+		// A blank line separates the comment from the preceding declaration,
+		// of which it could otherwise be the trailing comment.
+		p.hardBlank = true
+		for _, l := range p.commentLines(c) {
+			p.line("//" + l)
+		}
+		p.hardBlank = true
+	}
+	for _, l := range p.commentLines(loc.LeadingComments) {
+		p.line("//" + l)
+	}
+}
+
+// commentLines returns the lines of a comment, without the comment markers.
+func (p *printer) commentLines(s string) []string {
+	if p.opts.OmitComments || s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// loc returns the source location with the given path.
+func (p *printer) loc(path protoreflect.SourcePath) protoreflect.SourceLocation {
+	return p.locs.ByPath(path)
+}
+
+// extendLoc returns the location of the extend block which contains the
+// extension at loc. All extend blocks in a scope have the path of the list
+// of extensions in the scope, so the block is found by its span.
+func (p *printer) extendLoc(loc protoreflect.SourceLocation) protoreflect.SourceLocation {
+	if len(loc.Path) == 0 {
+		return protoreflect.SourceLocation{}
+	}
+	path := loc.Path[:len(loc.Path)-1]
+	for i := 0; i < p.locs.Len(); i++ {
+		l := p.locs.Get(i)
+		if !equalPath(l.Path, path) {
+			continue
+		}
+		startsBefore := l.StartLine < loc.StartLine || (l.StartLine == loc.StartLine && l.StartColumn <= loc.StartColumn)
+		endsAfter := l.EndLine > loc.EndLine || (l.EndLine == loc.EndLine && l.EndColumn >= loc.EndColumn)
+		if startsBefore && endsAfter {
+			return l
+		}
+	}
+	return protoreflect.SourceLocation{}
+}
+
+func equalPath(x, y protoreflect.SourcePath) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// appendPath returns the path of a field of the declaration with the given
+// path, followed by an index if the field is repeated.
+func appendPath(path protoreflect.SourcePath, field protoreflect.FieldNumber, index ...int) protoreflect.SourcePath {
+	path = append(path[:len(path):len(path)], int32(field))
+	for _, i := range index {
+		path = append(path, int32(i))
+	}
+	return path
+}
+
+func (p *printer) printFile() {
+	fd := p.file
+	loc := p.loc(appendPath(nil, genid.FileDescriptorProto_Syntax_field_number))
+	p.leading(loc)
+	p.decl("syntax = "+strconv.Quote(fd.Syntax().String())+";", loc)
+
+	if fd.Package() != "" {
+		loc := p.loc(appendPath(nil, genid.FileDescriptorProto_Package_field_number))
+		p.softBlank = true
+		p.leading(loc)
+		p.decl("package "+string(fd.Package())+";", loc)
+	}
+
+	p.softBlank = true
+	for i := 0; i < fd.Imports().Len(); i++ {
+		imp := fd.Imports().Get(i)
+		loc := p.loc(appendPath(nil, genid.FileDescriptorProto_Dependency_field_number, i))
+		var modifier string
+		switch {
+		case imp.IsPublic:
+			modifier = "public "
+		case imp.IsWeak:
+			modifier = "weak "
+		}
+		p.leading(loc)
+		p.decl("import "+modifier+strconv.Quote(imp.Path())+";", loc)
+	}
+
+	p.softBlank = true
+	p.optionStatements(fd, fd.Options(), appendPath(nil, genid.FileDescriptorProto_Options_field_number), fd.Package())
+
+	var elems []elem
+	groups := groupMessages(fd.Extensions())
+	for i := 0; i < fd.Messages().Len(); i++ {
+		if md := fd.Messages().Get(i); !groups[md] {
+			elems = append(elems, p.messageElem(md, appendPath(nil, genid.FileDescriptorProto_MessageType_field_number, i)))
+		}
+	}
+	for i := 0; i < fd.Enums().Len(); i++ {
+		elems = append(elems, p.enumElem(fd.Enums().Get(i), appendPath(nil, genid.FileDescriptorProto_EnumType_field_number, i)))
+	}
+	for i := 0; i < fd.Extensions().Len(); i++ {
+		elems = append(elems, p.fieldElem(fd.Extensions().Get(i), appendPath(nil, genid.FileDescriptorProto_Extension_field_number, i)))
+	}
+	for i := 0; i < fd.Services().Len(); i++ {
+		elems = append(elems, p.serviceElem(fd.Services().Get(i), appendPath(nil, genid.FileDescriptorProto_Service_field_number, i)))
+	}
+	p.softBlank = true
+	p.printElems(elems, true)
+}
+
+// elem is a declaration in a file or in the body of a message,
+// which may be sorted by its location.
+type elem struct {
+	loc   protoreflect.SourceLocation
+	block bool // whether it is printed on multiple lines
+	print func()
+
+	// extendee is the message extended by an extension,
+	// and scope is the scope in which the extension is declared.
+	extendee, scope protoreflect.FullName
+}
+
+// printElems prints the declarations, separated by blank lines if spaced
+// is set, and otherwise only around blocks.
+//
+// The declarations are sorted by their locations if they all have one.
+// Consecutive extensions of the same message are printed in an extend block.
+func (p *printer) printElems(elems []elem, spaced bool) {
+	sorted := true
+	for _, e := range elems {
+		sorted = sorted && e.loc.Path != nil
+	}
+	if sorted {
+		sort.SliceStable(elems, func(i, j int) bool {
+			x, y := elems[i].loc, elems[j].loc
+			if x.StartLine != y.StartLine {
+				return x.StartLine < y.StartLine
+			}
+			return x.StartColumn < y.StartColumn
+		})
+	}
+
+	prevBlock := false
+	for i := 0; i < len(elems); {
+		e := elems[i]
+		i++
+		if e.extendee != "" {
+			exts := []elem{e}
+			for i < len(elems) && elems[i].extendee == e.extendee && elems[i].scope == e.scope {
+				exts = append(exts, elems[i])
+				i++
+			}
+			for k := range exts {
+				exts[k].extendee = ""
+			}
+			extendee, scope := e.extendee, e.scope
+			loc := p.extendLoc(e.loc)
+			e = elem{block: true, print: func() {
+				p.leading(loc)
+				p.open("extend "+p.relName(extendee, scope, true), loc)
+				p.printElems(exts, false)
+				p.close()
+			}}
+		}
+		if spaced || e.block || prevBlock {
+			p.softBlank = true
+		}
+		e.print()
+		prevBlock = e.block
+	}
+}
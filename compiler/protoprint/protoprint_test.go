@@ -0,0 +1,290 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protoprint_test
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"google.golang.org/protobuf/compiler/protoparse"
+	"google.golang.org/protobuf/compiler/protoprint"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	_ "google.golang.org/protobuf/cmd/protoc-gen-go/testdata/comments"
+	_ "google.golang.org/protobuf/cmd/protoc-gen-go/testdata/fieldnames"
+	_ "google.golang.org/protobuf/cmd/protoc-gen-go/testdata/import_public"
+	_ "google.golang.org/protobuf/cmd/protoc-gen-go/testdata/imports"
+	_ "google.golang.org/protobuf/cmd/protoc-gen-go/testdata/nopackage"
+	_ "google.golang.org/protobuf/cmd/protoc-gen-go/testdata/proto2"
+	_ "google.golang.org/protobuf/cmd/protoc-gen-go/testdata/proto3"
+	_ "google.golang.org/protobuf/internal/testprotos/enums"
+	_ "google.golang.org/protobuf/internal/testprotos/required"
+	_ "google.golang.org/protobuf/internal/testprotos/test3"
+	_ "google.golang.org/protobuf/internal/testprotos/textpb3"
+	_ "google.golang.org/protobuf/types/known/anypb"
+	_ "google.golang.org/protobuf/types/known/structpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// accessor returns an accessor for the files with the given contents.
+func accessor(files map[string]string) func(string) (io.ReadCloser, error) {
+	return func(filename string) (io.ReadCloser, error) {
+		s, ok := files[filename]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return ioutil.NopCloser(strings.NewReader(s)), nil
+	}
+}
+
+// compile compiles the .proto source of a file.
+func compile(t *testing.T, filename, src string) protoreflect.FileDescriptor {
+	t.Helper()
+	fdps, err := protoparse.Parser{
+		Accessor:              accessor(map[string]string{filename: src}),
+		IncludeSourceCodeInfo: true,
+	}.ParseFiles(filename)
+	if err != nil {
+		t.Fatalf("ParseFiles() error: %v\nsource:\n%s", err, src)
+	}
+	fd, err := protodesc.NewFile(fdps[0], protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd
+}
+
+// TestRoundTrip checks that printing the descriptors of generated files
+// produces source which compiles to the same descriptors.
+func TestRoundTrip(t *testing.T) {
+	filenames := []string{
+		"cmd/protoc-gen-go/testdata/comments/comments.proto",
+		"cmd/protoc-gen-go/testdata/fieldnames/fieldnames.proto",
+		"cmd/protoc-gen-go/testdata/import_public/a.proto",
+		"cmd/protoc-gen-go/testdata/imports/test_import_all.proto",
+		"cmd/protoc-gen-go/testdata/nopackage/nopackage.proto",
+		"cmd/protoc-gen-go/testdata/proto2/enum.proto",
+		"cmd/protoc-gen-go/testdata/proto2/fields.proto",
+		"cmd/protoc-gen-go/testdata/proto2/nested_messages.proto",
+		"cmd/protoc-gen-go/testdata/proto2/proto2.proto",
+		"cmd/protoc-gen-go/testdata/proto3/enum.proto",
+		"cmd/protoc-gen-go/testdata/proto3/fields.proto",
+		"google/protobuf/any.proto",
+		"google/protobuf/descriptor.proto",
+		"google/protobuf/struct.proto",
+		"google/protobuf/timestamp.proto",
+		"internal/testprotos/enums/enums.proto",
+		"internal/testprotos/required/required.proto",
+		"internal/testprotos/test3/test.proto",
+		"internal/testprotos/textpb3/test.proto",
+	}
+	for _, filename := range filenames {
+		t.Run(filename, func(t *testing.T) {
+			fd, err := protoregistry.GlobalFiles.FindFileByPath(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := protoprint.Print(fd)
+			if err != nil {
+				t.Fatalf("Print() error: %v", err)
+			}
+			fdps, err := protoparse.Parser{
+				Accessor: accessor(map[string]string{filename: string(b)}),
+			}.ParseFiles(filename)
+			if err != nil {
+				t.Fatalf("ParseFiles() error: %v\nsource:\n%s", err, b)
+			}
+			want := protodesc.ToFileDescriptorProto(fd)
+			if diff := cmp.Diff(want, fdps[0], protocmp.Transform()); diff != "" {
+				t.Errorf("descriptor mismatch (-want +got):\n%v\nsource:\n%s", diff, b)
+			}
+		})
+	}
+}
+
+// TestRoundTripComments checks that printing the descriptor of a source file
+// keeps the comments of its declarations.
+func TestRoundTripComments(t *testing.T) {
+	const filename = "cmd/protoc-gen-go/testdata/comments/comments.proto"
+	src, err := ioutil.ReadFile("../../" + filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd := compile(t, filename, string(src))
+	b, err := protoprint.Print(fd)
+	if err != nil {
+		t.Fatalf("Print() error: %v", err)
+	}
+	got := compile(t, filename, string(b))
+	if diff := cmp.Diff(comments(fd), comments(got)); diff != "" {
+		t.Errorf("comments mismatch (-want +got):\n%v\nsource:\n%s", diff, b)
+	}
+}
+
+// comments returns the comments in the source locations of a file
+// by the paths of the declarations they belong to.
+func comments(fd protoreflect.FileDescriptor) map[string][]string {
+	m := make(map[string][]string)
+	locs := fd.SourceLocations()
+	for i := 0; i < locs.Len(); i++ {
+		loc := locs.Get(i)
+		cs := append([]string{loc.LeadingComments, loc.TrailingComments}, loc.LeadingDetachedComments...)
+		if strings.Join(cs, "") != "" {
+			m[loc.Path.String()] = append(m[loc.Path.String()], cs...)
+		}
+	}
+	return m
+}
+
+// TestPrint checks that printing the descriptors of source files produces
+// the same source, for files which are formatted as the printer does.
+func TestPrint(t *testing.T) {
+	tests := []struct {
+		desc string
+		src  string
+	}{{
+		desc: "comments",
+		src: `// Copyright header.
+
+// Syntax comment.
+syntax = "proto3";
+
+// Package comment.
+package foo.bar;
+
+import "google/protobuf/descriptor.proto";
+
+option go_package = "example.com/foo";
+
+// Leading comment of M.
+message M { // Trailing comment of M.
+  // Leading comment of f.
+  string f = 1;
+  int32 g = 2; // Trailing comment of g.
+
+  // Detached comment.
+
+  // Leading comment of o.
+  oneof o {
+    string a = 3;
+    int64 b = 4;
+  }
+
+  optional N n = 5;
+  map<string, N> m = 6 [json_name = "mm"];
+
+  message N {
+    repeated E e = 1 [packed = false];
+  }
+
+  enum E {
+    option allow_alias = true;
+
+    E_UNSPECIFIED = 0;
+    E_ALIAS = 0 [deprecated = true];
+  }
+
+  reserved 10, 20 to 30, 100 to max;
+  reserved "x", "y";
+}
+
+extend google.protobuf.MessageOptions {
+  // Leading comment of x.
+  M x = 50000;
+  repeated string y = 50001;
+  // Trailing comment of y,
+  // on two lines.
+
+}
+
+service S {
+  rpc Unary(M) returns (M);
+  rpc Stream(stream M) returns (stream M) {
+    option deprecated = true;
+  }
+}
+`,
+	}, {
+		desc: "options",
+		src: `syntax = "proto2";
+
+package foo;
+
+import "google/protobuf/descriptor.proto";
+
+option (file_opt) = "x";
+
+message Opt {
+  optional string name = 1;
+  repeated int32 nums = 2;
+  optional Opt sub = 3;
+  map<string, int32> m = 4;
+}
+
+extend google.protobuf.FileOptions {
+  optional string file_opt = 50000;
+}
+
+extend google.protobuf.MessageOptions {
+  optional Opt opt = 50000;
+  repeated string tags = 50001;
+}
+
+extend google.protobuf.FieldOptions {
+  optional bytes data = 50000;
+  optional double num = 50001;
+  optional Opt field_opt = 50002;
+}
+
+message M {
+  option deprecated = true;
+  option (opt) = {
+    name: "x"
+    nums: 1
+    nums: 2
+    sub {
+      name: "y"
+    }
+    m {
+      key: "a"
+      value: 1
+    }
+  };
+  option (tags) = "a";
+  option (tags) = "b";
+
+  optional int32 f = 1 [deprecated = true, (data) = "\001\377", (num) = -inf];
+  optional string s = 2 [default = "\"hi\"\n"];
+  optional foo.Opt o = 3 [(field_opt) = {name: "z" sub {}}];
+  optional Opt p = 4;
+
+  message Opt {
+  }
+
+  extensions 100 to 199;
+}
+`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			fd := compile(t, "test.proto", tt.src)
+			b, err := protoprint.Print(fd)
+			if err != nil {
+				t.Fatalf("Print() error: %v", err)
+			}
+			if diff := cmp.Diff(tt.src, string(b)); diff != "" {
+				t.Errorf("Print() mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package protodelim marshals and unmarshals varint size-delimited messages.
+//
+// Each message is preceded by its wire-format size encoded as a varint.
+// This is the same framing produced by writeDelimitedTo and consumed by
+// parseDelimitedFrom in the Java protobuf implementation.
+package protodelim
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/internal/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+// MarshalOptions is a configurable varint size-delimited marshaler.
+type MarshalOptions struct{ proto.MarshalOptions }
+
+// MarshalTo writes a varint size-delimited wire-format message to w
+// with the default options.
+func MarshalTo(w io.Writer, m proto.Message) (int, error) {
+	return MarshalOptions{}.MarshalTo(w, m)
+}
+
+// MarshalTo writes a varint size-delimited wire-format message to w.
+// It returns the number of bytes written.
+// If w returns an error, MarshalTo returns it unchanged.
+func (o MarshalOptions) MarshalTo(w io.Writer, m proto.Message) (int, error) {
+	b, err := o.MarshalOptions.Marshal(m)
+	if err != nil {
+		return 0, err
+	}
+
+	// Write the size prefix and the message in a single call so that
+	// writers which are not safe for concurrent use still observe
+	// each record as one unit.
+	buf := make([]byte, 0, protowire.SizeVarint(uint64(len(b)))+len(b))
+	buf = protowire.AppendVarint(buf, uint64(len(b)))
+	buf = append(buf, b...)
+	return w.Write(buf)
+}
+
+// UnmarshalOptions is a configurable varint size-delimited unmarshaler.
+type UnmarshalOptions struct {
+	proto.UnmarshalOptions
+
+	// MaxSize is the maximum size in wire-format bytes of a single message.
+	// Unmarshaling a message larger than MaxSize returns a *SizeTooLargeError.
+	// If zero, a default limit of 4 MiB is applied.
+	// If negative, no limit is applied.
+	MaxSize int64
+}
+
+// defaultMaxSize matches the default gRPC maximum message size.
+const defaultMaxSize = 4 << 20
+
+// SizeTooLargeError is returned when the unmarshaler encounters a message
+// whose size prefix is larger than the configured MaxSize.
+type SizeTooLargeError struct {
+	// Size is the size of the message as encoded in the varint prefix.
+	Size uint64
+
+	// MaxSize is the limit that Size exceeded.
+	MaxSize uint64
+}
+
+func (e *SizeTooLargeError) Error() string {
+	return fmt.Sprintf("proto: message size %d exceeds maximum size %d", e.Size, e.MaxSize)
+}
+
+// Is reports whether target is proto.Error.
+func (e *SizeTooLargeError) Is(target error) bool {
+	return target == errors.Error
+}
+
+// Reader is the interface expected by UnmarshalFrom.
+// It is implemented by *bufio.Reader and *bytes.Reader.
+type Reader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// UnmarshalFrom parses and consumes a varint size-delimited wire-format message
+// from r with the default options.
+func UnmarshalFrom(r Reader, m proto.Message) error {
+	return UnmarshalOptions{}.UnmarshalFrom(r, m)
+}
+
+// UnmarshalFrom parses and consumes a varint size-delimited wire-format message
+// from r. The provided message must be mutable (e.g., a non-nil pointer to a message).
+//
+// The returned error is io.EOF only if r is at EOF before any byte is read,
+// which marks the clean end of a stream of messages.
+// If r reaches EOF in the middle of the size prefix or of the message,
+// the returned error wraps io.ErrUnexpectedEOF.
+// Any other error returned by r is returned unchanged.
+//
+// If AliasBuffer or AliasStrings is set, each message is read into a buffer
+// of its own, which the message may alias, and never aliases the buffer of r.
+func (o UnmarshalOptions) UnmarshalFrom(r Reader, m proto.Message) error {
+	size, err := readSize(r)
+	if err != nil {
+		return err
+	}
+
+	maxSize := o.MaxSize
+	if maxSize == 0 {
+		maxSize = defaultMaxSize
+	}
+	if maxSize > 0 && size > uint64(maxSize) {
+		return &SizeTooLargeError{Size: size, MaxSize: uint64(maxSize)}
+	}
+	if size > maxInt {
+		return &SizeTooLargeError{Size: size, MaxSize: maxInt}
+	}
+
+	var b []byte
+	if br, ok := r.(*bufio.Reader); ok && size <= uint64(br.Size()) && !o.AliasBuffer && !o.AliasStrings {
+		// Avoid copying the message out of the buffered reader.
+		// The bytes are only valid until the next read on br,
+		// so they must be discarded after unmarshaling.
+		// This is not possible if the message may alias the bytes.
+		b, err = br.Peek(int(size))
+		if err == nil {
+			defer br.Discard(int(size))
+		}
+	} else {
+		b, err = readMessage(r, int(size))
+	}
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		return errors.Wrap(io.ErrUnexpectedEOF, "truncated message: want %d bytes", size)
+	case err != nil:
+		return err
+	}
+	return o.UnmarshalOptions.Unmarshal(b, m)
+}
+
+// maxInt is the largest message size that fits in a slice.
+const maxInt = uint64(^uint(0) >> 1)
+
+// readChunkSize is the initial capacity of the buffer read by readMessage.
+const readChunkSize = 64 << 10
+
+// readMessage reads a message of the given size from r.
+// The size comes from the input, so the buffer is grown as the bytes are
+// read rather than allocated up front. A corrupt size then fails with a
+// truncation error instead of a huge allocation.
+func readMessage(r io.Reader, size int) ([]byte, error) {
+	b := make([]byte, 0, minInt(size, readChunkSize))
+	for len(b) < size {
+		if len(b) == cap(b) {
+			b = append(b, make([]byte, minInt(size-len(b), cap(b)))...)[:len(b)]
+		}
+		n, err := io.ReadFull(r, b[len(b):minInt(size, cap(b))])
+		b = b[:len(b)+n]
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func minInt(x, y int) int {
+	if x < y {
+		return x
+	}
+	return y
+}
+
+// readSize reads a varint size prefix from r one byte at a time
+// so that no bytes beyond the prefix are consumed.
+func readSize(r io.ByteReader) (uint64, error) {
+	var buf [binary.MaxVarintLen64]byte
+	for i := range buf {
+		c, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF && i > 0 {
+				return 0, errors.Wrap(io.ErrUnexpectedEOF, "truncated size prefix")
+			}
+			return 0, err
+		}
+		buf[i] = c
+		if c < 0x80 {
+			v, n := protowire.ConsumeVarint(buf[:i+1])
+			if n < 0 {
+				return 0, errors.Wrap(protowire.ParseError(n), "invalid size prefix")
+			}
+			return v, nil
+		}
+	}
+	_, n := protowire.ConsumeVarint(buf[:])
+	return 0, errors.Wrap(protowire.ParseError(n), "invalid size prefix")
+}
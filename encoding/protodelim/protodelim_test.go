@@ -0,0 +1,197 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protodelim_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"testing/iotest"
+
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	test3pb "google.golang.org/protobuf/internal/testprotos/test3"
+)
+
+func TestRoundTrip(t *testing.T) {
+	msgs := []*test3pb.TestAllTypes{
+		{SingularInt32: 1},
+		{SingularString: "hello", RepeatedInt32: []int32{1, 2, 3}},
+		{},
+		{SingularBytes: bytes.Repeat([]byte{'x'}, 1000)},
+	}
+
+	buf := &bytes.Buffer{}
+	for _, m := range msgs {
+		n, err := protodelim.MarshalTo(buf, m)
+		if err != nil {
+			t.Fatalf("MarshalTo() error: %v", err)
+		}
+		if want := protowire.SizeBytes(proto.Size(m)); n != want {
+			t.Errorf("MarshalTo() wrote %d bytes, want %d", n, want)
+		}
+	}
+
+	for _, name := range []string{"bytes.Reader", "bufio.Reader", "small bufio.Reader"} {
+		t.Run(name, func(t *testing.T) {
+			var r protodelim.Reader
+			switch name {
+			case "bytes.Reader":
+				r = bytes.NewReader(buf.Bytes())
+			case "bufio.Reader":
+				r = bufio.NewReader(bytes.NewReader(buf.Bytes()))
+			case "small bufio.Reader":
+				r = bufio.NewReaderSize(bytes.NewReader(buf.Bytes()), 16)
+			}
+			for i, want := range msgs {
+				got := &test3pb.TestAllTypes{}
+				if err := protodelim.UnmarshalFrom(r, got); err != nil {
+					t.Fatalf("UnmarshalFrom() message %d: %v", i, err)
+				}
+				if !proto.Equal(got, want) {
+					t.Errorf("UnmarshalFrom() message %d mismatch:\ngot  %v\nwant %v", i, got, want)
+				}
+			}
+			if err := protodelim.UnmarshalFrom(r, &test3pb.TestAllTypes{}); err != io.EOF {
+				t.Errorf("UnmarshalFrom() at end of stream = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestAliasBuffer(t *testing.T) {
+	msgs := []*test3pb.TestAllTypes{
+		{SingularString: "first", SingularBytes: []byte("AAAA")},
+		{SingularString: "BBBB", SingularBytes: []byte("ZZZZZ")},
+		{SingularString: "third", SingularBytes: []byte("CCCC")},
+	}
+	buf := &bytes.Buffer{}
+	for _, m := range msgs {
+		if _, err := protodelim.MarshalTo(buf, m); err != nil {
+			t.Fatalf("MarshalTo() error: %v", err)
+		}
+	}
+
+	// Messages which alias the input must not share memory with the
+	// buffer of the bufio.Reader, which is reused by later reads.
+	r := bufio.NewReaderSize(iotest.OneByteReader(buf), 16)
+	o := protodelim.UnmarshalOptions{UnmarshalOptions: proto.UnmarshalOptions{AliasBuffer: true}}
+	var got []*test3pb.TestAllTypes
+	for i := range msgs {
+		m := &test3pb.TestAllTypes{}
+		if err := o.UnmarshalFrom(r, m); err != nil {
+			t.Fatalf("UnmarshalFrom() message %d: %v", i, err)
+		}
+		got = append(got, m)
+	}
+	for i, want := range msgs {
+		if !proto.Equal(got[i], want) {
+			t.Errorf("message %d after reading all messages:\ngot  %v\nwant %v", i, got[i], want)
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	m := &test3pb.TestAllTypes{SingularString: "hello, world"}
+	b, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := protowire.AppendBytes(nil, b)
+
+	tests := []struct {
+		desc    string
+		in      []byte
+		opts    protodelim.UnmarshalOptions
+		wantErr error
+	}{{
+		desc:    "truncated size prefix",
+		in:      []byte{0x80, 0x80},
+		wantErr: io.ErrUnexpectedEOF,
+	}, {
+		desc:    "truncated message",
+		in:      full[:len(full)-1],
+		wantErr: io.ErrUnexpectedEOF,
+	}, {
+		desc:    "size only",
+		in:      full[:1],
+		wantErr: io.ErrUnexpectedEOF,
+	}, {
+		desc:    "overlong size prefix",
+		in:      bytes.Repeat([]byte{0xff}, 11),
+		wantErr: proto.Error,
+	}, {
+		desc:    "size exceeds limit",
+		in:      full,
+		opts:    protodelim.UnmarshalOptions{MaxSize: int64(len(b) - 1)},
+		wantErr: &protodelim.SizeTooLargeError{Size: uint64(len(b)), MaxSize: uint64(len(b) - 1)},
+	}, {
+		desc:    "size exceeds default limit",
+		in:      protowire.AppendVarint(nil, 5<<20),
+		wantErr: &protodelim.SizeTooLargeError{Size: 5 << 20, MaxSize: 4 << 20},
+	}, {
+		desc:    "huge size without limit",
+		in:      append(bytes.Repeat([]byte{0xff}, 8), 0x7f, 'x'),
+		opts:    protodelim.UnmarshalOptions{MaxSize: -1},
+		wantErr: io.ErrUnexpectedEOF,
+	}, {
+		desc:    "size exceeds addressable memory",
+		in:      protowire.AppendVarint(nil, math.MaxUint64),
+		opts:    protodelim.UnmarshalOptions{MaxSize: -1},
+		wantErr: &protodelim.SizeTooLargeError{Size: math.MaxUint64, MaxSize: uint64(^uint(0) >> 1)},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			err := tt.opts.UnmarshalFrom(bytes.NewReader(tt.in), &test3pb.TestAllTypes{})
+			if want, ok := tt.wantErr.(*protodelim.SizeTooLargeError); ok {
+				got, ok := err.(*protodelim.SizeTooLargeError)
+				if !ok || *got != *want {
+					t.Fatalf("UnmarshalFrom() error = %v, want %v", err, want)
+				}
+				if !errors.Is(err, proto.Error) {
+					t.Errorf("UnmarshalFrom() error does not match proto.Error")
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("UnmarshalFrom() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnlimitedSize(t *testing.T) {
+	m := &test3pb.TestAllTypes{SingularBytes: bytes.Repeat([]byte{'x'}, 5<<20)}
+	buf := &bytes.Buffer{}
+	if _, err := protodelim.MarshalTo(buf, m); err != nil {
+		t.Fatal(err)
+	}
+	got := &test3pb.TestAllTypes{}
+	opts := protodelim.UnmarshalOptions{MaxSize: -1}
+	if err := opts.UnmarshalFrom(bufio.NewReader(buf), got); err != nil {
+		t.Fatalf("UnmarshalFrom() error: %v", err)
+	}
+	if !proto.Equal(got, m) {
+		t.Errorf("UnmarshalFrom() mismatch")
+	}
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+func (r errReader) ReadByte() (byte, error)  { return 0, r.err }
+
+func TestReaderError(t *testing.T) {
+	want := errors.New("read failure")
+	if err := protodelim.UnmarshalFrom(errReader{want}, &test3pb.TestAllTypes{}); err != want {
+		t.Errorf("UnmarshalFrom() error = %v, want %v", err, want)
+	}
+}
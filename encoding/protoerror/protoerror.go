@@ -0,0 +1,149 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package protoerror provides the structured error reported when the
+// protojson or prototext packages fail to unmarshal their input.
+//
+// For example, to report the location of an error in a configuration file:
+//
+//	err := prototext.Unmarshal(b, m)
+//	if e, ok := err.(*protoerror.DecodeError); ok {
+//		fmt.Printf("%s:%d:%d: %v\n", filename, e.Line, e.Column, e.Path)
+//	}
+package protoerror
+
+import (
+	"strconv"
+
+	"google.golang.org/protobuf/reflect/protopath"
+)
+
+// Category classifies the cause of a DecodeError.
+type Category int
+
+const (
+	// Other is the category of errors not covered by any other category,
+	// such as duplicate fields or unresolvable google.protobuf.Any types.
+	Other Category = iota
+
+	// Syntax is the category of errors in the syntax of the input,
+	// including input which ends prematurely.
+	Syntax
+
+	// UnknownField is the category of errors for field names which do not
+	// match any field of the message.
+	UnknownField
+
+	// TypeMismatch is the category of errors for values which are not valid
+	// for the type of the field, such as a string for an integer field or
+	// an unknown enum value name.
+	TypeMismatch
+
+	// Range is the category of errors for numeric values which are not
+	// representable in the type of the field, such as 1e20 for an int32.
+	Range
+
+	// InvalidUTF8 is the category of errors for invalid UTF-8 in the input
+	// or in the value of a string field.
+	InvalidUTF8
+
+	// RequiredField is the category of errors for required fields which are
+	// not populated. It is only reported in an ErrorList, since otherwise
+	// required fields are checked after unmarshaling by proto.CheckInitialized.
+	RequiredField
+)
+
+// String returns the name of c.
+func (c Category) String() string {
+	switch c {
+	case Other:
+		return "other"
+	case Syntax:
+		return "syntax"
+	case UnknownField:
+		return "unknown field"
+	case TypeMismatch:
+		return "type mismatch"
+	case Range:
+		return "range"
+	case InvalidUTF8:
+		return "invalid UTF-8"
+	case RequiredField:
+		return "required field"
+	default:
+		return "<unknown:" + strconv.Itoa(int(c)) + ">"
+	}
+}
+
+// DecodeError is an error which occurred at a particular position
+// of the input while unmarshaling.
+type DecodeError struct {
+	// Category classifies the cause of the error.
+	Category Category
+
+	// Offset is the byte offset in the input at which the error occurred,
+	// starting at 0.
+	Offset int
+
+	// Line and Column are the line and column number in the input at which
+	// the error occurred, both starting at 1. The column is counted in
+	// runes rather than bytes.
+	//
+	// If the cause of the error is not specific to a position within a
+	// value, such as a google.protobuf.Duration value which is out of range,
+	// the position is that of the start of the value or of the name of the
+	// field containing it. If the error is not specific to any part of the
+	// input, such as for a required field which is not populated, the
+	// offset, line, and column are all 0.
+	Line, Column int
+
+	// Path is the path from the message being unmarshaled to the value in
+	// which the error occurred. It is nil if the error is not within
+	// a message, such as for an error in the structure of a stream.
+	// If the error occurred in a field name, such as for an unknown field,
+	// the path is to the message containing the field.
+	Path protopath.Path
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error returns the message of the underlying error.
+func (e *DecodeError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorList is a list of errors reported when unmarshaling with the
+// AllErrors option, in the order in which they occurred.
+// It is never empty.
+type ErrorList []*DecodeError
+
+// Error returns the message of the first error, followed by the number of
+// other errors, if any.
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	case 2:
+		return l[0].Error() + " (and 1 more error)"
+	default:
+		return l[0].Error() + " (and " + strconv.Itoa(len(l)-1) + " more errors)"
+	}
+}
+
+// Unwrap returns the errors in the list.
+func (l ErrorList) Unwrap() []error {
+	errs := make([]error, len(l))
+	for i, e := range l {
+		errs[i] = e
+	}
+	return errs
+}
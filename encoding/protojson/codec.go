@@ -0,0 +1,151 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protojson
+
+import (
+	"google.golang.org/protobuf/internal/encoding/json"
+	"google.golang.org/protobuf/internal/errors"
+	"google.golang.org/protobuf/proto"
+	pref "google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Codec provides a custom JSON representation for messages of a type,
+// in place of the representation specified by the protobuf JSON mapping.
+// For example, a message for an amount of money might be represented as
+// a JSON string such as "12.34 USD".
+type Codec interface {
+	// Marshal returns the JSON representation of m,
+	// which must be a single valid JSON value.
+	Marshal(m proto.Message) ([]byte, error)
+
+	// Unmarshal populates the empty message m from the JSON value b.
+	Unmarshal(b []byte, m proto.Message) error
+}
+
+// Codecs is a set of custom codecs, keyed by the full name of the message
+// type that each applies to. Codecs take precedence over the special
+// representations of well-known types, and are also used for messages
+// embedded in a google.protobuf.Any, which are then represented in the
+// same way as well-known types, in a "value" field.
+type Codecs map[pref.FullName]Codec
+
+// typeMarshaler returns a marshal function if the message type has
+// specialized serialization behavior, either from a custom codec or as a
+// well-known type. It returns nil otherwise.
+func (e encoder) typeMarshaler(name pref.FullName) marshalFunc {
+	if c, ok := e.opts.Codecs[name]; ok {
+		return func(e encoder, m pref.Message) error {
+			return e.marshalCodec(c, m)
+		}
+	}
+	return wellKnownTypeMarshaler(name)
+}
+
+// typeUnmarshaler returns an unmarshal function if the message type has
+// specialized serialization behavior, either from a custom codec or as a
+// well-known type. It returns nil otherwise.
+func (d decoder) typeUnmarshaler(name pref.FullName) unmarshalFunc {
+	if c, ok := d.opts.Codecs[name]; ok {
+		return func(d decoder, m pref.Message) error {
+			return d.unmarshalCodec(c, m)
+		}
+	}
+	return wellKnownTypeUnmarshaler(name)
+}
+
+func (e encoder) marshalCodec(c Codec, m pref.Message) error {
+	name := m.Descriptor().FullName()
+	b, err := c.Marshal(m.Interface())
+	if err != nil {
+		return errors.Wrap(err, "%v: custom codec", name)
+	}
+
+	// Copy the output token by token, in order to validate it and to
+	// format it consistently with the rest of the output.
+	d := decoder{Decoder: json.NewDecoder(b)}
+	if err := d.copyJSONValue(e); err != nil {
+		return errors.New("%v: custom codec produced invalid JSON: %v", name, err)
+	}
+	if tok, err := d.Read(); err != nil || tok.Kind() != json.EOF {
+		return errors.New("%v: custom codec produced more than one JSON value", name)
+	}
+	return nil
+}
+
+func (d decoder) unmarshalCodec(c Codec, m pref.Message) error {
+	start, err := d.Peek()
+	if err != nil {
+		return err
+	}
+	enc, _ := json.NewEncoder("")
+	if err := d.copyJSONValue(encoder{enc, MarshalOptions{}, nil}); err != nil {
+		return err
+	}
+	if err := c.Unmarshal(enc.Bytes(), m.Interface()); err != nil {
+		return d.newError(start.Pos(), "invalid value for %v: %v", m.Descriptor().FullName(), err)
+	}
+	return nil
+}
+
+// copyJSONValue reads the next JSON value and writes it to e.
+// It relies on the decoder returning an error if the tokens are not in
+// a valid sequence.
+func (d decoder) copyJSONValue(e encoder) error {
+	tok, err := d.Read()
+	if err != nil {
+		return err
+	}
+	switch tok.Kind() {
+	case json.Null:
+		e.WriteNull()
+	case json.Bool:
+		e.WriteBool(tok.Bool())
+	case json.Number:
+		e.WriteNumber(tok.RawString())
+	case json.String:
+		if err := e.WriteString(tok.ParsedString()); err != nil {
+			return err
+		}
+	case json.ObjectOpen:
+		e.StartObject()
+		for {
+			tok, err := d.Read()
+			if err != nil {
+				return err
+			}
+			switch tok.Kind() {
+			case json.ObjectClose:
+				e.EndObject()
+				return nil
+			case json.Name:
+				if err := e.WriteName(tok.Name()); err != nil {
+					return err
+				}
+				if err := d.copyJSONValue(e); err != nil {
+					return err
+				}
+			}
+		}
+	case json.ArrayOpen:
+		e.StartArray()
+		for {
+			tok, err := d.Peek()
+			if err != nil {
+				return err
+			}
+			if tok.Kind() == json.ArrayClose {
+				d.Read()
+				e.EndArray()
+				return nil
+			}
+			if err := d.copyJSONValue(e); err != nil {
+				return err
+			}
+		}
+	default:
+		return d.unexpectedTokenError(tok)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protojson_test
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	pb3 "google.golang.org/protobuf/internal/testprotos/textpb3"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// nestedCodec represents a pb3.Nested as a JSON string of its s_string field.
+type nestedCodec struct{}
+
+func (nestedCodec) Marshal(m proto.Message) ([]byte, error) {
+	return []byte(strconv.Quote(m.(*pb3.Nested).SString)), nil
+}
+
+func (nestedCodec) Unmarshal(b []byte, m proto.Message) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return fmt.Errorf("want a string, got %s", b)
+	}
+	m.(*pb3.Nested).SString = s
+	return nil
+}
+
+// timestampCodec represents a google.protobuf.Timestamp as an object
+// containing the number of seconds.
+type timestampCodec struct{}
+
+func (timestampCodec) Marshal(m proto.Message) ([]byte, error) {
+	return []byte(fmt.Sprintf(`{ "unix" : %d }`, m.(*timestamppb.Timestamp).Seconds)), nil
+}
+
+func (timestampCodec) Unmarshal(b []byte, m proto.Message) error {
+	var secs int64
+	if _, err := fmt.Sscanf(string(b), `{"unix":%d}`, &secs); err != nil {
+		return err
+	}
+	m.(*timestamppb.Timestamp).Seconds = secs
+	return nil
+}
+
+// badCodec produces invalid JSON.
+type badCodec struct{ out string }
+
+func (c badCodec) Marshal(proto.Message) ([]byte, error) { return []byte(c.out), nil }
+func (badCodec) Unmarshal([]byte, proto.Message) error   { return nil }
+
+func TestCodecs(t *testing.T) {
+	codecs := protojson.Codecs{
+		"pb3.Nested":                nestedCodec{},
+		"google.protobuf.Timestamp": timestampCodec{},
+	}
+	anyNested, err := anypb.New(&pb3.Nested{SString: "in any"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc  string
+		input proto.Message
+		want  string
+	}{{
+		desc:  "message field",
+		input: &pb3.Nests{SNested: &pb3.Nested{SString: "hello", SNested: &pb3.Nested{}}},
+		want:  `{"sNested":"hello"}`,
+	}, {
+		desc:  "top-level message",
+		input: &pb3.Nested{SString: `"quoted"`},
+		want:  `"\"quoted\""`,
+	}, {
+		desc:  "well-known type",
+		input: timestamppb.New(time.Unix(1234, 0)),
+		want:  `{"unix":1234}`,
+	}, {
+		desc:  "embedded in Any",
+		input: anyNested,
+		want:  `{"@type":"type.googleapis.com/pb3.Nested","value":"in any"}`,
+	}}
+
+	for _, tt := range tests {
+		b, err := protojson.MarshalOptions{Codecs: codecs}.Marshal(tt.input)
+		if err != nil {
+			t.Errorf("%s: Marshal() error: %v", tt.desc, err)
+			continue
+		}
+		if got := string(b); got != tt.want {
+			t.Errorf("%s: Marshal() = %s, want %s", tt.desc, got, tt.want)
+		}
+
+		got := tt.input.ProtoReflect().New().Interface()
+		if err := (protojson.UnmarshalOptions{Codecs: codecs}).Unmarshal([]byte(tt.want), got); err != nil {
+			t.Errorf("%s: Unmarshal() error: %v", tt.desc, err)
+			continue
+		}
+		want := proto.Clone(tt.input)
+		if n, ok := want.(*pb3.Nests); ok {
+			n.SNested.SNested = nil // not represented by the codec
+		}
+		if !proto.Equal(got, want) {
+			t.Errorf("%s: Unmarshal() = %v, want %v", tt.desc, got, want)
+		}
+	}
+
+	// Output from codecs is formatted consistently with other output.
+	b, err := protojson.MarshalOptions{Codecs: codecs, Indent: "\t"}.Marshal(timestamppb.New(time.Unix(1, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "{\n\t\"unix\": 1\n}"; got != want {
+		t.Errorf("Marshal() with indent = %q, want %q", got, want)
+	}
+
+	err = protojson.UnmarshalOptions{Codecs: codecs}.Unmarshal([]byte(`{"sNested": 5}`), &pb3.Nests{})
+	if err == nil || !strings.Contains(err.Error(), "(line 1:13): invalid value for pb3.Nested") {
+		t.Errorf("Unmarshal() error = %v, want invalid value error", err)
+	}
+
+	for _, out := range []string{`{`, `1 2`, ``} {
+		opts := protojson.MarshalOptions{Codecs: protojson.Codecs{"pb3.Nested": badCodec{out}}}
+		if _, err := opts.Marshal(&pb3.Nested{}); err == nil {
+			t.Errorf("Marshal() with codec output %q succeeded, want error", out)
+		}
+	}
+}
@@ -6,19 +6,24 @@ package protojson
 
 import (
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"math"
 	"strconv"
 	"strings"
+	"unicode"
 
+	"google.golang.org/protobuf/encoding/protoerror"
 	"google.golang.org/protobuf/internal/encoding/json"
 	"google.golang.org/protobuf/internal/encoding/messageset"
 	"google.golang.org/protobuf/internal/errors"
 	"google.golang.org/protobuf/internal/flags"
 	"google.golang.org/protobuf/internal/genid"
 	"google.golang.org/protobuf/internal/pragma"
+	"google.golang.org/protobuf/internal/required"
 	"google.golang.org/protobuf/internal/set"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protopath"
 	pref "google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/reflect/protoregistry"
 )
@@ -47,6 +52,45 @@ type UnmarshalOptions struct {
 		protoregistry.MessageTypeResolver
 		protoregistry.ExtensionTypeResolver
 	}
+
+	// Codecs specifies custom JSON representations for message types.
+	// It should be the same as the Codecs used to marshal the input.
+	Codecs Codecs
+
+	// BytesEncoding specifies how bytes values are expected to be encoded.
+	// Bytes values in standard or URL-safe base64, with or without padding,
+	// are always accepted. If BytesEncoding is HexBytes, strings that are
+	// valid hexadecimal are decoded as such rather than as base64.
+	//
+	// The other deviations from the protobuf JSON mapping permitted by
+	// MarshalOptions need no option to be unmarshaled: 64-bit integers are
+	// accepted both as JSON numbers and as JSON strings, and enum values
+	// both as numbers and as names.
+	BytesEncoding BytesEncoding
+
+	// If FuzzyFieldNames is set, a JSON field name which is neither the
+	// JSON name nor the proto name of a field is matched to a field
+	// disregarding case, underscores, and hyphens, so that for example
+	// "foo_bar", "FooBar", "foo-bar", and "FOOBAR" all match a field named
+	// foo_bar. It is an error if the name matches more than one field.
+	FuzzyFieldNames bool
+
+	// ResolveFieldName, if non-nil, is called for each JSON field name which
+	// is neither the JSON name nor the proto name of a field in the message
+	// described by md. If it returns a field of that message, the value is
+	// unmarshaled into that field; if it returns nil, the name is matched
+	// according to FuzzyFieldNames, or is otherwise an unknown field.
+	// It is not called for extension fields.
+	ResolveFieldName func(md pref.MessageDescriptor, name string) pref.FieldDescriptor
+
+	// If AllErrors is set, unmarshaling continues after an error in a field,
+	// skipping that field, so as to report as many errors as possible.
+	// Unless AllowPartial is set, every required field which is not
+	// populated is also reported. The error returned is then either nil or
+	// a protoerror.ErrorList, and the message is populated with the fields
+	// which could be unmarshaled. Errors in the syntax of the input still
+	// stop unmarshaling.
+	AllErrors bool
 }
 
 // Unmarshal reads the given []byte and populates the given proto.Message
@@ -55,35 +99,49 @@ type UnmarshalOptions struct {
 // If it returns an error, the given message may be partially set.
 // The provided message must be mutable (e.g., a non-nil pointer to a message).
 func (o UnmarshalOptions) Unmarshal(b []byte, m proto.Message) error {
-	return o.unmarshal(b, m)
+	return o.unmarshal(json.NewDecoder(b), m)
 }
 
 // unmarshal is a centralized function that all unmarshal operations go through.
 // For profiling purposes, avoid changing the name of this function or
 // introducing other code paths for unmarshal that do not go through this.
-func (o UnmarshalOptions) unmarshal(b []byte, m proto.Message) error {
+func (o UnmarshalOptions) unmarshal(in *json.Decoder, m proto.Message) error {
 	proto.Reset(m)
 
 	if o.Resolver == nil {
 		o.Resolver = protoregistry.GlobalTypes
 	}
 
-	dec := decoder{json.NewDecoder(b), o}
-	if err := dec.unmarshalMessage(m.ProtoReflect(), false); err != nil {
-		return err
+	dec := decoder{Decoder: in, opts: o}
+	if o.AllErrors {
+		dec.errs = new(protoerror.ErrorList)
+	}
+	root := protopath.Root(m.ProtoReflect().Descriptor())
+	if err := dec.at(root).unmarshalMessage(m.ProtoReflect(), false); err != nil {
+		return dec.errorList(dec.withPathStep(err, root, 0))
 	}
 
 	// Check for EOF.
 	tok, err := dec.Read()
 	if err != nil {
-		return err
+		return dec.errorList(dec.withPathStep(err, root, 0))
 	}
 	if tok.Kind() != json.EOF {
-		return dec.unexpectedTokenError(tok)
+		return dec.errorList(dec.withPathStep(dec.unexpectedTokenError(tok), root, 0))
 	}
 
 	if o.AllowPartial {
-		return nil
+		return dec.errorList(nil)
+	}
+	if o.AllErrors {
+		required.Range(m.ProtoReflect(), protopath.Path{root}, func(p protopath.Path, fd pref.FieldDescriptor) {
+			*dec.errs = append(*dec.errs, &protoerror.DecodeError{
+				Category: protoerror.RequiredField,
+				Path:     p,
+				Err:      errors.RequiredNotSet(string(fd.FullName())),
+			})
+		})
+		return dec.errorList(nil)
 	}
 	return proto.CheckInitialized(m)
 }
@@ -91,13 +149,24 @@ func (o UnmarshalOptions) unmarshal(b []byte, m proto.Message) error {
 type decoder struct {
 	*json.Decoder
 	opts UnmarshalOptions
+
+	// In AllErrors mode, errs is where errors are recorded,
+	// and path is the path to the value being unmarshaled.
+	errs *protoerror.ErrorList
+	path protopath.Path
 }
 
 // newError returns an error object with position info.
 func (d decoder) newError(pos int, f string, x ...interface{}) error {
-	line, column := d.Position(pos)
-	head := fmt.Sprintf("(line %d:%d): ", line, column)
-	return errors.New(head+f, x...)
+	return d.newCategoryError(protoerror.Other, pos, f, x...)
+}
+
+// newCategoryError returns an error object with position info
+// for an error of the given category.
+func (d decoder) newCategoryError(c protoerror.Category, pos int, f string, x ...interface{}) error {
+	p := d.errorPos(pos)
+	head := fmt.Sprintf("(line %d:%d): ", p.Line, p.Column)
+	return newDecodeError(c, p, errors.NewAt(p, head+f, x...))
 }
 
 // unexpectedTokenError returns a syntax error for the given unexpected token.
@@ -107,14 +176,108 @@ func (d decoder) unexpectedTokenError(tok json.Token) error {
 
 // syntaxError returns a syntax error for given position.
 func (d decoder) syntaxError(pos int, f string, x ...interface{}) error {
+	p := d.errorPos(pos)
+	head := fmt.Sprintf("syntax error (line %d:%d): ", p.Line, p.Column)
+	return newDecodeError(protoerror.Syntax, p, errors.NewAt(p, head+f, x...))
+}
+
+// errorPos returns the position in the input of the given index.
+func (d decoder) errorPos(pos int) errors.Pos {
 	line, column := d.Position(pos)
-	head := fmt.Sprintf("syntax error (line %d:%d): ", line, column)
-	return errors.New(head+f, x...)
+	return errors.Pos{Offset: d.Offset(pos), Line: line, Column: column}
+}
+
+// withPathStep returns err with the step s prepended to its path.
+// If err is not a *protoerror.DecodeError, it is first converted to one.
+func (d decoder) withPathStep(err error, s protopath.Step, pos int) error {
+	e := d.decodeError(err, pos)
+	e.Path = append(protopath.Path{s}, e.Path...)
+	return e
+}
+
+// decodeError converts err to a *protoerror.DecodeError if it is not one,
+// using the position recorded in err if any, and the given position otherwise.
+func (d decoder) decodeError(err error, pos int) *protoerror.DecodeError {
+	if e, ok := err.(*protoerror.DecodeError); ok {
+		return e
+	}
+	c := protoerror.Other
+	p, ok := errors.Position(err)
+	switch {
+	case ok && errors.IsInvalidUTF8(err):
+		c = protoerror.InvalidUTF8
+	case ok || err == json.ErrUnexpectedEOF:
+		c = protoerror.Syntax
+	}
+	if !ok {
+		p = d.errorPos(pos)
+	}
+	return newDecodeError(c, p, err)
+}
+
+func newDecodeError(c protoerror.Category, p errors.Pos, err error) *protoerror.DecodeError {
+	return &protoerror.DecodeError{
+		Category: c,
+		Offset:   p.Offset,
+		Line:     p.Line,
+		Column:   p.Column,
+		Err:      err,
+	}
+}
+
+// at returns a decoder for the value at the step s from the current value.
+// It records the path to the value only in AllErrors mode.
+func (d decoder) at(s protopath.Step) decoder {
+	if d.errs != nil {
+		d.path = append(d.path[:len(d.path):len(d.path)], s)
+	}
+	return d
+}
+
+// skipAfterError handles an error in the field whose value is next in the
+// input. In AllErrors mode, it records err and skips the value, returning nil
+// if unmarshaling can continue. Otherwise, it returns err, or the error in
+// the syntax of the value which prevents skipping it.
+func (d decoder) skipAfterError(err error) error {
+	if d.errs == nil {
+		return err
+	}
+	if serr := d.skipJSONValue(); serr != nil {
+		// The value is not valid syntax, which is then the error to report.
+		if e, ok := err.(*protoerror.DecodeError); ok && e.Category == protoerror.Syntax {
+			return err
+		}
+		return serr
+	}
+	d.recordError(err)
+	return nil
+}
+
+// recordError records err, which is relative to the current value.
+func (d decoder) recordError(err error) {
+	e := d.decodeError(err, 0)
+	e.Path = append(d.path[:len(d.path):len(d.path)], e.Path...)
+	*d.errs = append(*d.errs, e)
+}
+
+// errorList returns the errors recorded in AllErrors mode along with err,
+// which stopped unmarshaling. Otherwise, it returns err.
+func (d decoder) errorList(err error) error {
+	if d.errs == nil {
+		return err
+	}
+	if err != nil {
+		*d.errs = append(*d.errs, err.(*protoerror.DecodeError))
+	}
+	if len(*d.errs) == 0 {
+		return nil
+	}
+	return *d.errs
 }
 
 // unmarshalMessage unmarshals a message into the given protoreflect.Message.
 func (d decoder) unmarshalMessage(m pref.Message, skipTypeURL bool) error {
-	if unmarshal := wellKnownTypeUnmarshaler(m.Descriptor().FullName()); unmarshal != nil {
+	if unmarshal := d.typeUnmarshaler(m.Descriptor().FullName()); unmarshal != nil {
 		return unmarshal(d, m)
 	}
 
@@ -133,7 +296,6 @@ func (d decoder) unmarshalMessage(m pref.Message, skipTypeURL bool) error {
 
 	var seenNums set.Ints
 	var seenOneofs set.Ints
-	fieldDescs := messageDesc.Fields()
 	for {
 		// Read field name.
 		tok, err := d.Read()
@@ -159,31 +321,12 @@ func (d decoder) unmarshalMessage(m pref.Message, skipTypeURL bool) error {
 		}
 
 		// Get the FieldDescriptor.
-		var fd pref.FieldDescriptor
-		if strings.HasPrefix(name, "[") && strings.HasSuffix(name, "]") {
-			// Only extension names are in [name] format.
-			extName := pref.FullName(name[1 : len(name)-1])
-			extType, err := d.opts.Resolver.FindExtensionByName(extName)
-			if err != nil && err != protoregistry.NotFound {
-				return d.newError(tok.Pos(), "unable to resolve %s: %v", tok.RawString(), err)
-			}
-			if extType != nil {
-				fd = extType.TypeDescriptor()
-				if !messageDesc.ExtensionRanges().Has(fd.Number()) || fd.ContainingMessage().FullName() != messageDesc.FullName() {
-					return d.newError(tok.Pos(), "message %v cannot be extended by %v", messageDesc.FullName(), fd.FullName())
-				}
-			}
-		} else {
-			// The name can either be the JSON name or the proto field name.
-			fd = fieldDescs.ByJSONName(name)
-			if fd == nil {
-				fd = fieldDescs.ByTextName(name)
-			}
-		}
-		if flags.ProtoLegacy {
-			if fd != nil && fd.IsWeak() && fd.Message().IsPlaceholder() {
-				fd = nil // reset since the weak reference is not linked in
+		fd, err := d.findField(messageDesc, tok)
+		if err != nil {
+			if err := d.skipAfterError(err); err != nil {
+				return err
 			}
+			continue
 		}
 
 		if fd == nil {
@@ -194,50 +337,151 @@ func (d decoder) unmarshalMessage(m pref.Message, skipTypeURL bool) error {
 				}
 				continue
 			}
-			return d.newError(tok.Pos(), "unknown field %v", tok.RawString())
+			err := d.newCategoryError(protoerror.UnknownField, tok.Pos(), "unknown field %v", tok.RawString())
+			if err := d.skipAfterError(err); err != nil {
+				return err
+			}
+			continue
 		}
 
 		// Do not allow duplicate fields.
 		num := uint64(fd.Number())
 		if seenNums.Has(num) {
-			return d.newError(tok.Pos(), "duplicate field %v", tok.RawString())
+			err := d.newError(tok.Pos(), "duplicate field %v", tok.RawString())
+			if err := d.skipAfterError(err); err != nil {
+				return err
+			}
+			continue
 		}
 		seenNums.Set(num)
 
 		// No need to set values for JSON null unless the field type is
 		// google.protobuf.Value or google.protobuf.NullValue.
-		if tok, _ := d.Peek(); tok.Kind() == json.Null && !isKnownValue(fd) && !isNullValue(fd) {
-			d.Read()
-			continue
+		valuePos := tok.Pos()
+		if tok, err := d.Peek(); err == nil {
+			valuePos = tok.Pos()
+			if tok.Kind() == json.Null && !isKnownValue(fd) && !isNullValue(fd) {
+				d.Read()
+				continue
+			}
+		}
+
+		// If field is a oneof, check if it has already been set.
+		if od := fd.ContainingOneof(); od != nil {
+			idx := uint64(od.Index())
+			if seenOneofs.Has(idx) {
+				err := d.newError(tok.Pos(), "error parsing %s, oneof %v is already set", tok.RawString(), od.FullName())
+				if err := d.skipAfterError(err); err != nil {
+					return err
+				}
+				continue
+			}
+			seenOneofs.Set(idx)
+		}
+
+		// In AllErrors mode, keep the state before the value in order to
+		// skip it if it cannot be unmarshaled.
+		var saved *json.Decoder
+		if d.errs != nil {
+			saved = d.Clone()
 		}
 
+		fieldDec := d.at(protopath.FieldAccess(fd))
 		switch {
 		case fd.IsList():
-			list := m.Mutable(fd).List()
-			if err := d.unmarshalList(list, fd); err != nil {
-				return err
-			}
+			err = fieldDec.unmarshalList(m.Mutable(fd).List(), fd)
 		case fd.IsMap():
-			mmap := m.Mutable(fd).Map()
-			if err := d.unmarshalMap(mmap, fd); err != nil {
+			err = fieldDec.unmarshalMap(m.Mutable(fd).Map(), fd)
+		default:
+			// Required or optional fields.
+			err = fieldDec.unmarshalSingular(m, fd)
+		}
+		if err != nil {
+			err = d.withPathStep(err, protopath.FieldAccess(fd), valuePos)
+			if saved == nil {
 				return err
 			}
-		default:
-			// If field is a oneof, check if it has already been set.
-			if od := fd.ContainingOneof(); od != nil {
-				idx := uint64(od.Index())
-				if seenOneofs.Has(idx) {
-					return d.newError(tok.Pos(), "error parsing %s, oneof %v is already set", tok.RawString(), od.FullName())
-				}
-				seenOneofs.Set(idx)
+			*d.Decoder = *saved
+			if err := d.skipAfterError(err); err != nil {
+				return err
 			}
+		}
+	}
+}
 
-			// Required or optional fields.
-			if err := d.unmarshalSingular(m, fd); err != nil {
-				return err
+// findField returns the descriptor of the field of md named by the
+// given token of Name kind, or nil if there is no such field.
+func (d decoder) findField(md pref.MessageDescriptor, tok json.Token) (pref.FieldDescriptor, error) {
+	var fd pref.FieldDescriptor
+	name := tok.Name()
+	if strings.HasPrefix(name, "[") && strings.HasSuffix(name, "]") {
+		// Only extension names are in [name] format.
+		extName := pref.FullName(name[1 : len(name)-1])
+		extType, err := d.opts.Resolver.FindExtensionByName(extName)
+		if err != nil && err != protoregistry.NotFound {
+			return nil, d.newError(tok.Pos(), "unable to resolve %s: %v", tok.RawString(), err)
+		}
+		if extType != nil {
+			fd = extType.TypeDescriptor()
+			if !md.ExtensionRanges().Has(fd.Number()) || fd.ContainingMessage().FullName() != md.FullName() {
+				return nil, d.newError(tok.Pos(), "message %v cannot be extended by %v", md.FullName(), fd.FullName())
+			}
+		}
+	} else {
+		// The name can either be the JSON name or the proto field name.
+		fieldDescs := md.Fields()
+		fd = fieldDescs.ByJSONName(name)
+		if fd == nil {
+			fd = fieldDescs.ByTextName(name)
+		}
+		if fd == nil && d.opts.ResolveFieldName != nil {
+			fd = d.opts.ResolveFieldName(md, name)
+			if fd != nil && (fd.IsExtension() || fd.ContainingMessage().FullName() != md.FullName()) {
+				return nil, d.newError(tok.Pos(), "field name %v resolved to %v, which is not a field of %v", tok.RawString(), fd.FullName(), md.FullName())
+			}
+		}
+		if fd == nil && d.opts.FuzzyFieldNames {
+			var fds []pref.FieldDescriptor
+			fd, fds = fuzzyFieldByName(fieldDescs, name)
+			if len(fds) > 1 {
+				return nil, d.newError(tok.Pos(), "ambiguous field name %v matches fields %v and %v", tok.RawString(), fds[0].Name(), fds[1].Name())
 			}
 		}
 	}
+	if flags.ProtoLegacy {
+		if fd != nil && fd.IsWeak() && fd.Message().IsPlaceholder() {
+			fd = nil // reset since the weak reference is not linked in
+		}
+	}
+	return fd, nil
+}
+
+// fuzzyFieldByName returns the field whose JSON name or proto name matches
+// name disregarding case, underscores, and hyphens. If more than one field
+// matches, it returns nil and all the fields which match.
+func fuzzyFieldByName(fds pref.FieldDescriptors, name string) (pref.FieldDescriptor, []pref.FieldDescriptor) {
+	key := fuzzyFieldKey(name)
+	var matches []pref.FieldDescriptor
+	for i := 0; i < fds.Len(); i++ {
+		fd := fds.Get(i)
+		if fuzzyFieldKey(fd.JSONName()) == key || fuzzyFieldKey(fd.TextName()) == key {
+			matches = append(matches, fd)
+		}
+	}
+	if len(matches) != 1 {
+		return nil, matches
+	}
+	return matches[0], matches
+}
+
+// fuzzyFieldKey returns the form of a field name used for fuzzy matching.
+func fuzzyFieldKey(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, s)
 }
 
 func isKnownValue(fd pref.FieldDescriptor) bool {
@@ -324,7 +568,7 @@ func (d decoder) unmarshalScalar(fd pref.FieldDescriptor) (pref.Value, error) {
 		}
 
 	case pref.BytesKind:
-		if v, ok := unmarshalBytes(tok); ok {
+		if v, ok := unmarshalBytes(tok, d.opts.BytesEncoding); ok {
 			return v, nil
 		}
 
@@ -337,7 +581,29 @@ func (d decoder) unmarshalScalar(fd pref.FieldDescriptor) (pref.Value, error) {
 		panic(fmt.Sprintf("unmarshalScalar: invalid scalar kind %v", kind))
 	}
 
-	return pref.Value{}, d.newError(tok.Pos(), "invalid value for %v type: %v", kind, tok.RawString())
+	return pref.Value{}, d.newCategoryError(invalidScalarCategory(kind, tok), tok.Pos(), "invalid value for %v type: %v", kind, tok.RawString())
+}
+
+// invalidScalarCategory returns the category of the error for a token which
+// is not a valid value of the given kind: a number which is not representable
+// in a numeric kind is out of range, and any other value is a type mismatch.
+func invalidScalarCategory(kind pref.Kind, tok json.Token) protoerror.Category {
+	if tok.Kind() != json.Number {
+		return protoerror.TypeMismatch
+	}
+	switch kind {
+	case pref.FloatKind, pref.DoubleKind:
+		return protoerror.Range
+	case pref.Int32Kind, pref.Sint32Kind, pref.Sfixed32Kind,
+		pref.Int64Kind, pref.Sint64Kind, pref.Sfixed64Kind,
+		pref.Uint32Kind, pref.Fixed32Kind, pref.Uint64Kind, pref.Fixed64Kind,
+		pref.EnumKind:
+		// A number which overflows a float64 is also out of range.
+		if n, ok := tok.Float(64); !ok || n == math.Trunc(n) {
+			return protoerror.Range
+		}
+	}
+	return protoerror.TypeMismatch
 }
 
 func unmarshalInt(tok json.Token, bitSize int) (pref.Value, bool) {
@@ -454,12 +720,17 @@ func getFloat(tok json.Token, bitSize int) (pref.Value, bool) {
 	return pref.ValueOfFloat64(n), true
 }
 
-func unmarshalBytes(tok json.Token) (pref.Value, bool) {
+func unmarshalBytes(tok json.Token, be BytesEncoding) (pref.Value, bool) {
 	if tok.Kind() != json.String {
 		return pref.Value{}, false
 	}
 
 	s := tok.ParsedString()
+	if be == HexBytes {
+		if b, err := hex.DecodeString(s); err == nil {
+			return pref.ValueOfBytes(b), true
+		}
+	}
 	enc := base64.StdEncoding
 	if strings.ContainsAny(s, "-_") {
 		enc = base64.URLEncoding
@@ -521,8 +792,8 @@ func (d decoder) unmarshalList(list pref.List, fd pref.FieldDescriptor) error {
 			}
 
 			val := list.NewElement()
-			if err := d.unmarshalMessage(val.Message(), false); err != nil {
-				return err
+			if err := d.at(protopath.ListIndex(list.Len())).unmarshalMessage(val.Message(), false); err != nil {
+				return d.withPathStep(err, protopath.ListIndex(list.Len()), tok.Pos())
 			}
 			list.Append(val)
 		}
@@ -540,7 +811,7 @@ func (d decoder) unmarshalList(list pref.List, fd pref.FieldDescriptor) error {
 
 			val, err := d.unmarshalScalar(fd)
 			if err != nil {
-				return err
+				return d.withPathStep(err, protopath.ListIndex(list.Len()), tok.Pos())
 			}
 			list.Append(val)
 		}
@@ -561,10 +832,10 @@ func (d decoder) unmarshalMap(mmap pref.Map, fd pref.FieldDescriptor) error {
 	// Determine ahead whether map entry is a scalar type or a message type in
 	// order to call the appropriate unmarshalMapValue func inside the for loop
 	// below.
-	var unmarshalMapValue func() (pref.Value, error)
+	var unmarshalMapValue func(d decoder) (pref.Value, error)
 	switch fd.MapValue().Kind() {
 	case pref.MessageKind, pref.GroupKind:
-		unmarshalMapValue = func() (pref.Value, error) {
+		unmarshalMapValue = func(d decoder) (pref.Value, error) {
 			val := mmap.NewValue()
 			if err := d.unmarshalMessage(val.Message(), false); err != nil {
 				return pref.Value{}, err
@@ -572,7 +843,7 @@ func (d decoder) unmarshalMap(mmap pref.Map, fd pref.FieldDescriptor) error {
 			return val, nil
 		}
 	default:
-		unmarshalMapValue = func() (pref.Value, error) {
+		unmarshalMapValue = func(d decoder) (pref.Value, error) {
 			return d.unmarshalScalar(fd.MapValue())
 		}
 	}
@@ -605,9 +876,9 @@ Loop:
 		}
 
 		// Read and unmarshal field value.
-		pval, err := unmarshalMapValue()
+		pval, err := unmarshalMapValue(d.at(protopath.MapIndex(pkey)))
 		if err != nil {
-			return err
+			return d.withPathStep(err, protopath.MapIndex(pkey), tok.Pos())
 		}
 
 		mmap.Set(pkey, pval)
@@ -661,5 +932,5 @@ func (d decoder) unmarshalMapKey(tok json.Token, fd pref.FieldDescriptor) (pref.
 		panic(fmt.Sprintf("invalid kind for map key: %v", kind))
 	}
 
-	return pref.MapKey{}, d.newError(tok.Pos(), "invalid value for %v key: %s", kind, tok.RawString())
+	return pref.MapKey{}, d.newCategoryError(protoerror.TypeMismatch, tok.Pos(), "invalid value for %v key: %s", kind, tok.RawString())
 }
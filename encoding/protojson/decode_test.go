@@ -9,11 +9,17 @@ import (
 	"strings"
 	"testing"
 
+	"google.golang.org/protobuf/encoding/protoerror"
 	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
 	"google.golang.org/protobuf/internal/errors"
 	"google.golang.org/protobuf/internal/flags"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
 	preg "google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
 
 	testpb "google.golang.org/protobuf/internal/testprotos/test"
 	weakpb "google.golang.org/protobuf/internal/testprotos/test/weak1"
@@ -412,6 +418,130 @@ func TestUnmarshal(t *testing.T) {
 		wantMessage: &pb3.Scalars{
 			SBytes: []byte("hello world"),
 		},
+	}, {
+		desc:         "bytes URL-safe",
+		inputMessage: &pb3.Scalars{},
+		inputText:    `{"sBytes": "-_8="}`,
+		wantMessage: &pb3.Scalars{
+			SBytes: []byte{0xfb, 0xff},
+		},
+	}, {
+		desc:         "FuzzyFieldNames",
+		umo:          protojson.UnmarshalOptions{FuzzyFieldNames: true},
+		inputMessage: &pb3.Scalars{},
+		inputText: `{
+  "S_BOOL": true,
+  "sint32": 1,
+  "s-int64": 2,
+  "SUint32": 3,
+  "s_uint_64": 4
+}`,
+		wantMessage: &pb3.Scalars{
+			SBool:   true,
+			SInt32:  1,
+			SInt64:  2,
+			SUint32: 3,
+			SUint64: 4,
+		},
+	}, {
+		desc:         "FuzzyFieldNames with json_name",
+		umo:          protojson.UnmarshalOptions{FuzzyFieldNames: true},
+		inputMessage: &pb3.JSONNames{},
+		inputText: `{
+  "FOO-BAR": "hello"
+}`,
+		wantMessage: &pb3.JSONNames{
+			SString: "hello",
+		},
+	}, {
+		desc:         "FuzzyFieldNames matches proto name",
+		umo:          protojson.UnmarshalOptions{FuzzyFieldNames: true},
+		inputMessage: &pb3.JSONNames{},
+		inputText: `{
+  "sString": "hello"
+}`,
+		wantMessage: &pb3.JSONNames{
+			SString: "hello",
+		},
+	}, {
+		desc:         "FuzzyFieldNames duplicate field",
+		umo:          protojson.UnmarshalOptions{FuzzyFieldNames: true},
+		inputMessage: &pb3.Scalars{},
+		inputText: `{
+  "sInt32": 1,
+  "S_INT32": 2
+}`,
+		wantErr: `duplicate field "S_INT32"`,
+	}, {
+		desc:         "FuzzyFieldNames unknown field",
+		umo:          protojson.UnmarshalOptions{FuzzyFieldNames: true},
+		inputMessage: &pb3.Scalars{},
+		inputText: `{
+  "s_int": 1
+}`,
+		wantErr: `unknown field "s_int"`,
+	}, {
+		desc:         "wrong-case name without FuzzyFieldNames",
+		inputMessage: &pb3.Scalars{},
+		inputText: `{
+  "SInt32": 1
+}`,
+		wantErr: `unknown field "SInt32"`,
+	}, {
+		desc: "ResolveFieldName",
+		umo: protojson.UnmarshalOptions{
+			ResolveFieldName: func(md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+				if name == "count" {
+					return md.Fields().ByName("s_int32")
+				}
+				return nil
+			},
+			FuzzyFieldNames: true,
+		},
+		inputMessage: &pb3.Scalars{},
+		inputText: `{
+  "count": 1,
+  "S_STRING": "hello"
+}`,
+		wantMessage: &pb3.Scalars{
+			SInt32:  1,
+			SString: "hello",
+		},
+	}, {
+		desc: "ResolveFieldName returns field of another message",
+		umo: protojson.UnmarshalOptions{
+			ResolveFieldName: func(md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+				return (&pb3.Nested{}).ProtoReflect().Descriptor().Fields().ByName("s_string")
+			},
+		},
+		inputMessage: &pb3.Scalars{},
+		inputText: `{
+  "count": 1
+}`,
+		wantErr: `field name "count" resolved to pb3.Nested.s_string, which is not a field of pb3.Scalars`,
+	}, {
+		desc:         "bytes hex",
+		umo:          protojson.UnmarshalOptions{BytesEncoding: protojson.HexBytes},
+		inputMessage: &pb3.Scalars{},
+		inputText:    `{"sBytes": "fbff"}`,
+		wantMessage: &pb3.Scalars{
+			SBytes: []byte{0xfb, 0xff},
+		},
+	}, {
+		desc:         "bytes hex falls back to base64",
+		umo:          protojson.UnmarshalOptions{BytesEncoding: protojson.HexBytes},
+		inputMessage: &pb3.Scalars{},
+		inputText:    `{"sBytes": "aGVsbG8gd29ybGQ="}`,
+		wantMessage: &pb3.Scalars{
+			SBytes: []byte("hello world"),
+		},
+	}, {
+		desc:         "bytes hex without option",
+		inputMessage: &pb3.Scalars{},
+		inputText:    `{"sBytes": "fbff"}`,
+		wantMessage: &pb3.Scalars{
+			SBytes: []byte{0x7d, 0xb7, 0xdf},
+		},
 	}, {
 		desc:         "not bytes",
 		inputMessage: &pb3.Scalars{},
@@ -2478,3 +2608,276 @@ func TestUnmarshal(t *testing.T) {
 		})
 	}
 }
+
+func TestUnmarshalFuzzyFieldNamesAmbiguous(t *testing.T) {
+	fdpb := new(descriptorpb.FileDescriptorProto)
+	if err := prototext.Unmarshal([]byte(`
+		syntax: "proto2"
+		name:   "test.proto"
+		message_type: [{
+			name: "Message"
+			field: [
+				{name:"foo_bar" number:1 label:LABEL_OPTIONAL type:TYPE_STRING},
+				{name:"foobar"  number:2 label:LABEL_OPTIONAL type:TYPE_STRING}
+			]
+		}]
+	`), fdpb); err != nil {
+		t.Fatal(err)
+	}
+	fd, err := protodesc.NewFile(fdpb, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := fd.Messages().Get(0)
+	umo := protojson.UnmarshalOptions{FuzzyFieldNames: true}
+
+	m := dynamicpb.NewMessage(md)
+	if err := umo.Unmarshal([]byte(`{"fooBar":"a","foobar":"b"}`), m); err != nil {
+		t.Fatalf("Unmarshal() of exact names error: %v", err)
+	}
+	if got := m.Get(md.Fields().ByName("foo_bar")).String(); got != "a" {
+		t.Errorf("foo_bar = %q, want %q", got, "a")
+	}
+
+	err = umo.Unmarshal([]byte(`{"FOO-BAR":"a"}`), dynamicpb.NewMessage(md))
+	if err == nil || !strings.Contains(err.Error(), `ambiguous field name "FOO-BAR" matches fields foo_bar and foobar`) {
+		t.Errorf("Unmarshal() of ambiguous name error = %v, want ambiguous field name error", err)
+	}
+}
+
+func TestUnmarshalDecodeError(t *testing.T) {
+	tests := []struct {
+		desc         string
+		inputMessage proto.Message
+		inputText    string
+		category     protoerror.Category
+		offset       int
+		line, column int
+		path         string
+	}{{
+		desc:         "syntax error",
+		inputMessage: &pb3.Scalars{},
+		inputText:    "{\n  \"sInt32\": 1,\n}",
+		category:     protoerror.Syntax,
+		offset:       17,
+		line:         3,
+		column:       1,
+		path:         "(pb3.Scalars)",
+	}, {
+		desc:         "unexpected EOF in nested message",
+		inputMessage: &pb3.Nests{},
+		inputText:    `{"sNested": {"sString": "a"`,
+		category:     protoerror.Syntax,
+		offset:       12,
+		line:         1,
+		column:       13,
+		path:         "(pb3.Nests).s_nested",
+	}, {
+		desc:         "unknown field",
+		inputMessage: &pb3.Nests{},
+		inputText:    `{"sNested": {"sNested": {"unknown": 1}}}`,
+		category:     protoerror.UnknownField,
+		offset:       25,
+		line:         1,
+		column:       26,
+		path:         "(pb3.Nests).s_nested.s_nested",
+	}, {
+		desc:         "type mismatch in list",
+		inputMessage: &pb3.Repeats{},
+		inputText:    `{"rptBool": [true, "false"]}`,
+		category:     protoerror.TypeMismatch,
+		offset:       19,
+		line:         1,
+		column:       20,
+		path:         "(pb3.Repeats).rpt_bool[1]",
+	}, {
+		desc:         "type mismatch in map",
+		inputMessage: &pb3.Maps{},
+		inputText:    `{"strToNested": {"k": {"sNested": {"sString": 1}}}, "int32ToStr": {}}`,
+		category:     protoerror.TypeMismatch,
+		offset:       46,
+		line:         1,
+		column:       47,
+		path:         `(pb3.Maps).str_to_nested["k"].s_nested.s_string`,
+	}, {
+		desc:         "out of range",
+		inputMessage: &pb3.Scalars{},
+		inputText:    `{"sInt32": 1e20}`,
+		category:     protoerror.Range,
+		offset:       11,
+		line:         1,
+		column:       12,
+		path:         "(pb3.Scalars).s_int32",
+	}, {
+		desc:         "invalid UTF-8",
+		inputMessage: &pb3.Scalars{},
+		inputText:    "{\"sString\": \"abc\xff\"}",
+		category:     protoerror.InvalidUTF8,
+		offset:       12,
+		line:         1,
+		column:       13,
+		path:         "(pb3.Scalars).s_string",
+	}, {
+		desc:         "well-known type",
+		inputMessage: &anypb.Any{},
+		inputText: `{
+  "@type": "type.googleapis.com/google.protobuf.Duration",
+  "value": "315576000001s"
+}`,
+		category: protoerror.Range,
+		offset:   72,
+		line:     3,
+		column:   12,
+		path:     "(google.protobuf.Any).(google.protobuf.Duration)",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			err := protojson.Unmarshal([]byte(tt.inputText), tt.inputMessage)
+			e, ok := err.(*protoerror.DecodeError)
+			if !ok {
+				t.Fatalf("Unmarshal() error = %v (%T), want *protoerror.DecodeError", err, err)
+			}
+			if e.Category != tt.category {
+				t.Errorf("Category = %v, want %v", e.Category, tt.category)
+			}
+			if e.Offset != tt.offset || e.Line != tt.line || e.Column != tt.column {
+				t.Errorf("position = %d (%d:%d), want %d (%d:%d)", e.Offset, e.Line, e.Column, tt.offset, tt.line, tt.column)
+			}
+			if got := e.Path.String(); got != tt.path {
+				t.Errorf("Path = %v, want %v", got, tt.path)
+			}
+		})
+	}
+}
+
+func TestUnmarshalAllErrors(t *testing.T) {
+	type wantError struct {
+		category protoerror.Category
+		path     string
+	}
+	tests := []struct {
+		desc          string
+		umo           protojson.UnmarshalOptions
+		inputMessage  proto.Message
+		inputText     string
+		wantMessage   proto.Message
+		wantErrors    []wantError
+		wantErrorText string
+	}{{
+		desc:         "no errors",
+		inputMessage: &pb3.Scalars{},
+		inputText:    `{"sInt32": 5}`,
+		wantMessage:  &pb3.Scalars{SInt32: 5},
+	}, {
+		desc:         "field errors",
+		inputMessage: &pb3.Scalars{},
+		inputText:    `{"sBool": "x", "sInt32": 5, "unknown": {"a": [1]}, "sString": "a", "sInt32": 6, "sUint32": -1}`,
+		wantMessage:  &pb3.Scalars{SInt32: 5, SString: "a"},
+		wantErrors: []wantError{
+			{protoerror.TypeMismatch, "(pb3.Scalars).s_bool"},
+			{protoerror.UnknownField, "(pb3.Scalars)"},
+			{protoerror.Other, "(pb3.Scalars)"},
+			{protoerror.Range, "(pb3.Scalars).s_uint32"},
+		},
+		wantErrorText: "(and 3 more errors)",
+	}, {
+		desc:         "nested errors",
+		inputMessage: &pb3.Maps{},
+		inputText: `{
+  "strToNested": {
+    "a": {"sString": 1, "sNested": {"sString": "b"}},
+    "c": {"sNested": {"unknown": true}}
+  },
+  "uint64ToEnum": {"1": "BOGUS"},
+  "int32ToStr": {"1": "one"}
+}`,
+		wantMessage: &pb3.Maps{
+			StrToNested: map[string]*pb3.Nested{
+				"a": {SNested: &pb3.Nested{SString: "b"}},
+				"c": {SNested: &pb3.Nested{}},
+			},
+			Int32ToStr: map[int32]string{1: "one"},
+		},
+		wantErrors: []wantError{
+			{protoerror.TypeMismatch, `(pb3.Maps).str_to_nested["a"].s_string`},
+			{protoerror.UnknownField, `(pb3.Maps).str_to_nested["c"].s_nested`},
+			{protoerror.TypeMismatch, `(pb3.Maps).uint64_to_enum[1]`},
+		},
+	}, {
+		desc:         "syntax error stops unmarshaling",
+		inputMessage: &pb3.Scalars{},
+		inputText:    `{"sBool": "x", "sInt32": 5, "sString": }`,
+		wantMessage:  &pb3.Scalars{SInt32: 5},
+		wantErrors: []wantError{
+			{protoerror.TypeMismatch, "(pb3.Scalars).s_bool"},
+			{protoerror.Syntax, "(pb3.Scalars)"},
+		},
+	}, {
+		desc:         "missing required fields",
+		inputMessage: &pb2.IndirectRequired{},
+		inputText: `{
+  "optNested": {},
+  "rptNested": [{"reqString": "a"}, {"unknown": 1}],
+  "strToNested": {"b": {}, "a": {"reqString": "a"}}
+}`,
+		wantMessage: &pb2.IndirectRequired{
+			OptNested: &pb2.NestedWithRequired{},
+			RptNested: []*pb2.NestedWithRequired{
+				{ReqString: proto.String("a")},
+				{},
+			},
+			StrToNested: map[string]*pb2.NestedWithRequired{
+				"a": {ReqString: proto.String("a")},
+				"b": {},
+			},
+		},
+		wantErrors: []wantError{
+			{protoerror.UnknownField, "(pb2.IndirectRequired).rpt_nested[1]"},
+			{protoerror.RequiredField, "(pb2.IndirectRequired).opt_nested.req_string"},
+			{protoerror.RequiredField, "(pb2.IndirectRequired).rpt_nested[1].req_string"},
+			{protoerror.RequiredField, `(pb2.IndirectRequired).str_to_nested["b"].req_string`},
+		},
+	}, {
+		desc:         "missing required fields with AllowPartial",
+		umo:          protojson.UnmarshalOptions{AllowPartial: true},
+		inputMessage: &pb2.IndirectRequired{},
+		inputText:    `{"optNested": {}}`,
+		wantMessage:  &pb2.IndirectRequired{OptNested: &pb2.NestedWithRequired{}},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			tt.umo.AllErrors = true
+			err := tt.umo.Unmarshal([]byte(tt.inputText), tt.inputMessage)
+			if len(tt.wantErrors) == 0 {
+				if err != nil {
+					t.Fatalf("Unmarshal() error = %v, want nil", err)
+				}
+			} else {
+				errs, ok := err.(protoerror.ErrorList)
+				if !ok {
+					t.Fatalf("Unmarshal() error = %v (%T), want protoerror.ErrorList", err, err)
+				}
+				var got []wantError
+				for _, e := range errs {
+					got = append(got, wantError{e.Category, e.Path.String()})
+				}
+				if len(got) != len(tt.wantErrors) {
+					t.Fatalf("Unmarshal() errors = %v\ngot  %v\nwant %v", errs, got, tt.wantErrors)
+				}
+				for i := range got {
+					if got[i] != tt.wantErrors[i] {
+						t.Errorf("error %d = %v (%v), want %v", i, got[i], errs[i], tt.wantErrors[i])
+					}
+				}
+				if !strings.HasSuffix(err.Error(), tt.wantErrorText) {
+					t.Errorf("Error() = %q, want suffix %q", err.Error(), tt.wantErrorText)
+				}
+			}
+			if !proto.Equal(tt.inputMessage, tt.wantMessage) {
+				t.Errorf("Unmarshal()\n<got>\n%v\n<want>\n%v\n", tt.inputMessage, tt.wantMessage)
+			}
+		})
+	}
+}
@@ -6,7 +6,9 @@ package protojson
 
 import (
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"io"
 
 	"google.golang.org/protobuf/internal/encoding/json"
 	"google.golang.org/protobuf/internal/encoding/messageset"
@@ -24,6 +26,19 @@ import (
 
 const defaultIndent = "  "
 
+// BytesEncoding specifies how bytes values are encoded as JSON strings.
+type BytesEncoding int
+
+const (
+	// Base64Bytes is standard base64 encoding with padding,
+	// as specified by the protobuf JSON mapping.
+	Base64Bytes BytesEncoding = iota
+	// Base64URLBytes is URL-safe base64 encoding with padding.
+	Base64URLBytes
+	// HexBytes is lowercase hexadecimal encoding.
+	HexBytes
+)
+
 // Format formats the message as a multiline string.
 // This function is only intended for human consumption and ignores errors.
 // Do not depend on the output being stable. It may change over time across
@@ -66,6 +81,20 @@ type MarshalOptions struct {
 	// UseEnumNumbers emits enum values as numbers.
 	UseEnumNumbers bool
 
+	// UseEnumNumbersInMaps emits enum values as numbers only when they are
+	// the values of map fields.
+	UseEnumNumbersInMaps bool
+
+	// UseInt64Numbers emits the values of 64-bit integer fields as JSON
+	// numbers rather than as JSON strings. Note that many JSON parsers,
+	// including JavaScript's, lose precision for numbers beyond 2^53.
+	// Map keys are always emitted as strings.
+	UseInt64Numbers bool
+
+	// BytesEncoding specifies how bytes values are encoded as JSON strings.
+	// The default is standard base64 with padding.
+	BytesEncoding BytesEncoding
+
 	// EmitUnpopulated specifies whether to emit unpopulated fields. It does not
 	// emit unpopulated oneof fields or unpopulated extension fields.
 	// The JSON value emitted for unpopulated fields are as follows:
@@ -82,12 +111,28 @@ type MarshalOptions struct {
 	//  ╚═══════╧════════════════════════════╝
 	EmitUnpopulated bool
 
+	// EmitDefaultValues specifies whether to emit unpopulated fields which
+	// have implicit presence: proto3 scalar fields not declared as optional,
+	// list fields, and map fields. Unlike EmitUnpopulated, it does not emit
+	// null for unpopulated message fields or fields with explicit presence.
+	EmitDefaultValues bool
+
+	// EmitUnpopulatedIf, if non-nil, is called for each unpopulated field that
+	// is not emitted due to other options, and the field is emitted if it
+	// returns true. The value emitted is as described for EmitUnpopulated.
+	// It is not called for fields within a oneof, except for proto3 optional
+	// fields, nor for extension fields.
+	EmitUnpopulatedIf func(protoreflect.FieldDescriptor) bool
+
 	// Resolver is used for looking up types when expanding google.protobuf.Any
 	// messages. If nil, this defaults to using protoregistry.GlobalTypes.
 	Resolver interface {
 		protoregistry.ExtensionTypeResolver
 		protoregistry.MessageTypeResolver
 	}
+
+	// Codecs specifies custom JSON representations for message types.
+	Codecs Codecs
 }
 
 // Format formats the message as a string.
@@ -132,7 +177,7 @@ func (o MarshalOptions) marshal(m proto.Message) ([]byte, error) {
 		return []byte("{}"), nil
 	}
 
-	enc := encoder{internalEnc, o}
+	enc := encoder{internalEnc, o, nil}
 	if err := enc.marshalMessage(m.ProtoReflect(), ""); err != nil {
 		return nil, err
 	}
@@ -145,6 +190,9 @@ func (o MarshalOptions) marshal(m proto.Message) ([]byte, error) {
 type encoder struct {
 	*json.Encoder
 	opts MarshalOptions
+
+	// w, if non-nil, is the stream that output is incrementally written to.
+	w io.Writer
 }
 
 // typeFieldDesc is a synthetic field descriptor used for the "@type" field.
@@ -173,20 +221,24 @@ func (m typeURLFieldRanger) Range(f func(pref.FieldDescriptor, pref.Value) bool)
 
 // unpopulatedFieldRanger wraps a protoreflect.Message and modifies its Range
 // method to additionally iterate over unpopulated fields.
-type unpopulatedFieldRanger struct{ pref.Message }
+type unpopulatedFieldRanger struct {
+	pref.Message
+	opts MarshalOptions
+}
 
 func (m unpopulatedFieldRanger) Range(f func(pref.FieldDescriptor, pref.Value) bool) {
 	fds := m.Descriptor().Fields()
 	for i := 0; i < fds.Len(); i++ {
 		fd := fds.Get(i)
-		if m.Has(fd) || fd.ContainingOneof() != nil {
-			continue // ignore populated fields and fields within a oneofs
+		if m.Has(fd) || !m.opts.emitUnpopulated(fd) {
+			continue
 		}
 
 		v := m.Get(fd)
 		isProto2Scalar := fd.Syntax() == pref.Proto2 && fd.Default().IsValid()
+		isProto3Optional := fd.ContainingOneof() != nil // only synthetic oneofs
 		isSingularMessage := fd.Cardinality() != pref.Repeated && fd.Message() != nil
-		if isProto2Scalar || isSingularMessage {
+		if isProto2Scalar || isProto3Optional || isSingularMessage {
 			v = pref.Value{} // use invalid value to emit null
 		}
 		if !f(fd, v) {
@@ -196,6 +248,25 @@ func (m unpopulatedFieldRanger) Range(f func(pref.FieldDescriptor, pref.Value) b
 	m.Message.Range(f)
 }
 
+// emitUnpopulated reports whether the unpopulated field fd is emitted.
+func (o MarshalOptions) emitUnpopulated(fd pref.FieldDescriptor) bool {
+	if od := fd.ContainingOneof(); od != nil {
+		// Fields within a real oneof are never emitted, since at most one
+		// of them is populated. Proto3 optional fields are only emitted if
+		// selected by EmitUnpopulatedIf.
+		return od.IsSynthetic() && o.EmitUnpopulatedIf != nil && o.EmitUnpopulatedIf(fd)
+	}
+	switch {
+	case o.EmitUnpopulated:
+		return true
+	case o.EmitDefaultValues && !fd.HasPresence():
+		return true
+	case o.EmitUnpopulatedIf != nil:
+		return o.EmitUnpopulatedIf(fd)
+	}
+	return false
+}
+
 // marshalMessage marshals the fields in the given protoreflect.Message.
 // If the typeURL is non-empty, then a synthetic "@type" field is injected
 // containing the URL as the value.
@@ -204,7 +275,7 @@ func (e encoder) marshalMessage(m pref.Message, typeURL string) error {
 		return errors.New("no support for proto1 MessageSets")
 	}
 
-	if marshal := wellKnownTypeMarshaler(m.Descriptor().FullName()); marshal != nil {
+	if marshal := e.typeMarshaler(m.Descriptor().FullName()); marshal != nil {
 		return marshal(e, m)
 	}
 
@@ -212,8 +283,8 @@ func (e encoder) marshalMessage(m pref.Message, typeURL string) error {
 	defer e.EndObject()
 
 	var fields order.FieldRanger = m
-	if e.opts.EmitUnpopulated {
-		fields = unpopulatedFieldRanger{m}
+	if e.opts.EmitUnpopulated || e.opts.EmitDefaultValues || e.opts.EmitUnpopulatedIf != nil {
+		fields = unpopulatedFieldRanger{m, e.opts}
 	}
 	if typeURL != "" {
 		fields = typeURLFieldRanger{fields, typeURL}
@@ -232,7 +303,8 @@ func (e encoder) marshalMessage(m pref.Message, typeURL string) error {
 		if err = e.marshalValue(v, fd); err != nil {
 			return false
 		}
-		return true
+		err = e.maybeFlush()
+		return err == nil
 	})
 	return err
 }
@@ -272,10 +344,21 @@ func (e encoder) marshalSingular(val pref.Value, fd pref.FieldDescriptor) error
 	case pref.Uint32Kind, pref.Fixed32Kind:
 		e.WriteUint(val.Uint())
 
-	case pref.Int64Kind, pref.Sint64Kind, pref.Uint64Kind,
-		pref.Sfixed64Kind, pref.Fixed64Kind:
-		// 64-bit integers are written out as JSON string.
-		e.WriteString(val.String())
+	case pref.Int64Kind, pref.Sint64Kind, pref.Sfixed64Kind:
+		if e.opts.UseInt64Numbers {
+			e.WriteInt(val.Int())
+		} else {
+			// 64-bit integers are written out as JSON string.
+			e.WriteString(val.String())
+		}
+
+	case pref.Uint64Kind, pref.Fixed64Kind:
+		if e.opts.UseInt64Numbers {
+			e.WriteUint(val.Uint())
+		} else {
+			// 64-bit integers are written out as JSON string.
+			e.WriteString(val.String())
+		}
 
 	case pref.FloatKind:
 		// Encoder.WriteFloat handles the special numbers NaN and infinites.
@@ -286,14 +369,22 @@ func (e encoder) marshalSingular(val pref.Value, fd pref.FieldDescriptor) error
 		e.WriteFloat(val.Float(), 64)
 
 	case pref.BytesKind:
-		e.WriteString(base64.StdEncoding.EncodeToString(val.Bytes()))
+		switch e.opts.BytesEncoding {
+		case Base64URLBytes:
+			e.WriteString(base64.URLEncoding.EncodeToString(val.Bytes()))
+		case HexBytes:
+			e.WriteString(hex.EncodeToString(val.Bytes()))
+		default:
+			e.WriteString(base64.StdEncoding.EncodeToString(val.Bytes()))
+		}
 
 	case pref.EnumKind:
 		if fd.Enum().FullName() == genid.NullValue_enum_fullname {
 			e.WriteNull()
 		} else {
 			desc := fd.Enum().Values().ByNumber(val.Enum())
-			if e.opts.UseEnumNumbers || desc == nil {
+			inMap := fd.ContainingMessage() != nil && fd.ContainingMessage().IsMapEntry()
+			if e.opts.UseEnumNumbers || (e.opts.UseEnumNumbersInMaps && inMap) || desc == nil {
 				e.WriteInt(int64(val.Enum()))
 			} else {
 				e.WriteString(string(desc.Name()))
@@ -321,6 +412,9 @@ func (e encoder) marshalList(list pref.List, fd pref.FieldDescriptor) error {
 		if err := e.marshalSingular(item, fd); err != nil {
 			return err
 		}
+		if err := e.maybeFlush(); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -338,7 +432,8 @@ func (e encoder) marshalMap(mmap pref.Map, fd pref.FieldDescriptor) error {
 		if err = e.marshalSingular(v, fd.MapValue()); err != nil {
 			return false
 		}
-		return true
+		err = e.maybeFlush()
+		return err == nil
 	})
 	return err
 }
@@ -15,6 +15,7 @@ import (
 	"google.golang.org/protobuf/internal/detrand"
 	"google.golang.org/protobuf/internal/flags"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 	preg "google.golang.org/protobuf/reflect/protoregistry"
 	"google.golang.org/protobuf/testing/protopack"
 
@@ -2192,6 +2193,92 @@ func TestMarshal(t *testing.T) {
   "optDouble": null,
   "optBytes": "6LC35q2M",
   "optString": null
+}`,
+	}, {
+		desc: "EmitDefaultValues: proto3 scalars",
+		mo:   protojson.MarshalOptions{EmitDefaultValues: true},
+		input: &pb3.Scalars{
+			SInt32: 1,
+		},
+		want: `{
+  "sBool": false,
+  "sInt32": 1,
+  "sInt64": "0",
+  "sUint32": 0,
+  "sUint64": "0",
+  "sSint32": 0,
+  "sSint64": "0",
+  "sFixed32": 0,
+  "sFixed64": "0",
+  "sSfixed32": 0,
+  "sSfixed64": "0",
+  "sFloat": 0,
+  "sDouble": 0,
+  "sBytes": "",
+  "sString": ""
+}`,
+	}, {
+		desc:  "EmitDefaultValues: maps",
+		mo:    protojson.MarshalOptions{EmitDefaultValues: true},
+		input: &pb3.Maps{},
+		want: `{
+  "int32ToStr": {},
+  "boolToUint32": {},
+  "uint64ToEnum": {},
+  "strToNested": {},
+  "strToOneofs": {}
+}`,
+	}, {
+		desc:  "EmitDefaultValues: unpopulated message field",
+		mo:    protojson.MarshalOptions{EmitDefaultValues: true},
+		input: &pb3.Nests{},
+		want:  `{}`,
+	}, {
+		desc:  "EmitDefaultValues: proto3 optional fields",
+		mo:    protojson.MarshalOptions{EmitDefaultValues: true},
+		input: &pb3.Proto3Optional{},
+		want:  `{}`,
+	}, {
+		desc:  "EmitDefaultValues: proto2 fields",
+		mo:    protojson.MarshalOptions{EmitDefaultValues: true},
+		input: &pb2.Enums{},
+		want: `{
+  "rptEnum": [],
+  "rptNestedEnum": []
+}`,
+	}, {
+		desc:  "EmitDefaultValues: oneof fields",
+		mo:    protojson.MarshalOptions{EmitDefaultValues: true},
+		input: &pb3.Oneofs{},
+		want:  `{}`,
+	}, {
+		desc: "EmitUnpopulatedIf",
+		mo: protojson.MarshalOptions{EmitUnpopulatedIf: func(fd protoreflect.FieldDescriptor) bool {
+			return fd.Name() == "opt_int32" || fd.Name() == "opt_message"
+		}},
+		input: &pb3.Proto3Optional{
+			OptString: proto.String("hello"),
+		},
+		want: `{
+  "optInt32": null,
+  "optString": "hello",
+  "optMessage": null
+}`,
+	}, {
+		desc: "EmitUnpopulatedIf: oneof fields",
+		mo: protojson.MarshalOptions{EmitUnpopulatedIf: func(fd protoreflect.FieldDescriptor) bool {
+			return true
+		}},
+		input: &pb3.Oneofs{},
+		want:  `{}`,
+	}, {
+		desc: "EmitUnpopulatedIf with EmitDefaultValues",
+		mo: protojson.MarshalOptions{EmitDefaultValues: true, EmitUnpopulatedIf: func(fd protoreflect.FieldDescriptor) bool {
+			return fd.Message() != nil
+		}},
+		input: &pb3.Nests{},
+		want: `{
+  "sNested": null
 }`,
 	}, {
 		desc: "UseEnumNumbers in singular field",
@@ -2242,6 +2329,80 @@ func TestMarshal(t *testing.T) {
     "10": 10,
     "47": 47
   }
+}`,
+	}, {
+		desc: "UseEnumNumbersInMaps in map field",
+		mo:   protojson.MarshalOptions{UseEnumNumbersInMaps: true},
+		input: &pb3.Maps{
+			Uint64ToEnum: map[uint64]pb3.Enum{
+				1:  pb3.Enum_ONE,
+				47: 47,
+			},
+		},
+		want: `{
+  "uint64ToEnum": {
+    "1": 1,
+    "47": 47
+  }
+}`,
+	}, {
+		desc: "UseEnumNumbersInMaps in singular field",
+		mo:   protojson.MarshalOptions{UseEnumNumbersInMaps: true},
+		input: &pb3.Enums{
+			SEnum: pb3.Enum_ONE,
+		},
+		want: `{
+  "sEnum": "ONE"
+}`,
+	}, {
+		desc: "UseInt64Numbers",
+		mo:   protojson.MarshalOptions{UseInt64Numbers: true},
+		input: &pb3.Scalars{
+			SInt32:    -1,
+			SInt64:    -1234,
+			SUint64:   math.MaxUint64,
+			SSint64:   -5,
+			SFixed64:  6,
+			SSfixed64: math.MinInt64,
+		},
+		want: `{
+  "sInt32": -1,
+  "sInt64": -1234,
+  "sUint64": 18446744073709551615,
+  "sSint64": -5,
+  "sFixed64": 6,
+  "sSfixed64": -9223372036854775808
+}`,
+	}, {
+		desc: "UseInt64Numbers in map key",
+		mo:   protojson.MarshalOptions{UseInt64Numbers: true},
+		input: &pb3.Maps{
+			Uint64ToEnum: map[uint64]pb3.Enum{
+				1: pb3.Enum_ONE,
+			},
+		},
+		want: `{
+  "uint64ToEnum": {
+    "1": "ONE"
+  }
+}`,
+	}, {
+		desc: "BytesEncoding Base64URLBytes",
+		mo:   protojson.MarshalOptions{BytesEncoding: protojson.Base64URLBytes},
+		input: &pb3.Scalars{
+			SBytes: []byte{0xfb, 0xff},
+		},
+		want: `{
+  "sBytes": "-_8="
+}`,
+	}, {
+		desc: "BytesEncoding HexBytes",
+		mo:   protojson.MarshalOptions{BytesEncoding: protojson.HexBytes},
+		input: &pb3.Scalars{
+			SBytes: []byte{0xfb, 0xff},
+		},
+		want: `{
+  "sBytes": "fbff"
 }`,
 	}, {
 		desc: "UseProtoNames",
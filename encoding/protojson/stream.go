@@ -0,0 +1,417 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protojson
+
+import (
+	"io"
+
+	"google.golang.org/protobuf/encoding/protoerror"
+	"google.golang.org/protobuf/internal/encoding/json"
+	"google.golang.org/protobuf/internal/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// streamChunkSize is the amount of output that an Encoder buffers before
+// writing it to the underlying stream, and the minimum amount of input that
+// a Decoder requests from the underlying stream at a time.
+const streamChunkSize = 32 << 10
+
+// Encoder writes messages in JSON format to an output stream.
+type Encoder struct {
+	opts MarshalOptions
+	w    io.Writer
+}
+
+// NewEncoder returns an Encoder that writes to w using default options.
+func NewEncoder(w io.Writer) *Encoder {
+	return MarshalOptions{}.NewEncoder(w)
+}
+
+// NewEncoder returns an Encoder that writes to w using options in
+// MarshalOptions.
+func (o MarshalOptions) NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{opts: o, w: w}
+}
+
+// Encode writes the JSON encoding of m to the stream, followed by a newline,
+// such that a sequence of calls produces newline-delimited JSON.
+// Unless Multiline is set, each message is written on a line of its own,
+// as in the JSON Lines format.
+//
+// The output is written incrementally while m is being encoded, so that
+// large messages are never held in memory in their entirety. Consequently,
+// if an error occurs partway through, some output may already have been
+// written to the stream.
+func (e *Encoder) Encode(m proto.Message) error {
+	o := e.opts
+	if o.Multiline && o.Indent == "" {
+		o.Indent = defaultIndent
+	}
+	if o.Resolver == nil {
+		o.Resolver = protoregistry.GlobalTypes
+	}
+
+	internalEnc, err := json.NewEncoder(o.Indent)
+	if err != nil {
+		return err
+	}
+
+	// Treat nil message interface as an empty message,
+	// in which case the output in an empty JSON object.
+	if m == nil {
+		_, err := io.WriteString(e.w, "{}\n")
+		return err
+	}
+
+	// Required fields are checked up front since the output is not buffered.
+	if !o.AllowPartial {
+		if err := proto.CheckInitialized(m); err != nil {
+			return err
+		}
+	}
+
+	enc := encoder{internalEnc, o, e.w}
+	if err := enc.marshalMessage(m.ProtoReflect(), ""); err != nil {
+		return err
+	}
+	_, err = e.w.Write(append(enc.Bytes(), '\n'))
+	return err
+}
+
+// maybeFlush writes the output accumulated so far to the stream
+// once it has reached the chunk size.
+func (e encoder) maybeFlush() error {
+	if e.w == nil || len(e.Bytes()) < streamChunkSize {
+		return nil
+	}
+	_, err := e.w.Write(e.Bytes())
+	e.Truncate()
+	return err
+}
+
+// streamState is the position of a Decoder within the stream.
+type streamState uint8
+
+const (
+	streamStart     streamState = iota // nothing has been read yet
+	streamValues                       // reading a sequence of JSON values
+	streamArray                        // reading the elements of a JSON array
+	streamArrayNext                    // reading the elements of a JSON array after the first
+	streamEnd                          // no more messages are in the stream
+)
+
+// Decoder reads messages in JSON format from an input stream.
+//
+// The stream may either be a sequence of JSON values, such as
+// newline-delimited JSON or values concatenated without any separator,
+// or a single JSON array whose elements are the messages. A stream which
+// begins with '[' is always treated as an array of messages, unless
+// JSONLines is set.
+//
+// Input is read from the stream as needed, and only one message is
+// buffered in memory at a time. Each top-level value is buffered in its
+// entirety before it is unmarshaled, so decoding a message requires memory
+// proportional to the size of its JSON encoding.
+type Decoder struct {
+	// JSONLines, if set, reads the stream in the JSON Lines format,
+	// in which each line holds a single JSON value and blank lines are
+	// ignored. A malformed line is an invalid value like any other,
+	// rather than an error in the structure of the stream.
+	JSONLines bool
+
+	// OnInvalid, if non-nil, is called with the error for each value which
+	// cannot be unmarshaled, after which Decode skips the value and
+	// proceeds with the next one. Otherwise, Decode returns the error.
+	OnInvalid func(error)
+
+	opts  UnmarshalOptions
+	r     io.Reader
+	state streamState
+
+	buf []byte // buffered input, of which buf[off:] is unread
+	off int
+	err error // error returned by r, which is io.EOF at the end of input
+
+	// base is the offset in the input of buf[0], and line and column are
+	// the position in the input of buf[posOff].
+	base         int
+	line, column int
+	posOff       int
+
+	// valueLine and valueColumn are the position of the value most
+	// recently read by Decode.
+	valueLine, valueColumn int
+}
+
+// NewDecoder returns a Decoder that reads from r using default options.
+func NewDecoder(r io.Reader) *Decoder {
+	return UnmarshalOptions{}.NewDecoder(r)
+}
+
+// NewDecoder returns a Decoder that reads from r using options in
+// UnmarshalOptions.
+func (o UnmarshalOptions) NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{opts: o, r: r, line: 1, column: 1}
+}
+
+// Decode reads the next message from the stream and populates m with it,
+// clearing m first. It returns io.EOF when there are no more messages.
+// The line and column numbers reported by errors are relative to the start
+// of the stream.
+//
+// If the next JSON value is well-formed but cannot be unmarshaled into m,
+// the value is skipped, so that a subsequent call proceeds with the
+// following value, and the error is passed to OnInvalid if it is set or
+// returned otherwise. Errors in the overall structure of the stream,
+// such as an unterminated value, are permanent.
+func (d *Decoder) Decode(m proto.Message) error {
+	for {
+		b, err := d.next()
+		if err != nil {
+			return err
+		}
+		off := d.off - len(b)
+		d.valueLine, d.valueColumn = d.position(off)
+		in := json.NewDecoder(b)
+		in.SetStartPosition(d.base+off, d.valueLine, d.valueColumn)
+		err = d.opts.unmarshal(in, m)
+		if err == nil || d.OnInvalid == nil {
+			return err
+		}
+		d.OnInvalid(err)
+	}
+}
+
+// Position returns the line and column number within the stream of the
+// start of the value most recently read by Decode, such as for reporting
+// errors which are not specific to a position within the value.
+func (d *Decoder) Position() (line, column int) {
+	return d.valueLine, d.valueColumn
+}
+
+// next returns the next JSON value in the stream.
+func (d *Decoder) next() ([]byte, error) {
+	if d.JSONLines {
+		return d.nextLine()
+	}
+	if d.state == streamStart {
+		c, ok := d.peekByte()
+		switch {
+		case !ok:
+			d.state = streamEnd
+		case c == '[':
+			d.off++
+			d.state = streamArray
+		default:
+			d.state = streamValues
+		}
+	}
+
+	switch d.state {
+	case streamArray, streamArrayNext:
+		c, ok := d.peekByte()
+		switch {
+		case !ok:
+			return nil, d.setError(d.unexpectedEOF())
+		case c == ']':
+			d.off++
+			if _, ok := d.peekByte(); ok {
+				return nil, d.setError(d.syntaxError("unexpected data after array of messages"))
+			}
+			d.state = streamEnd
+			return d.next()
+		case d.state == streamArrayNext && c != ',':
+			return nil, d.setError(d.syntaxError("unexpected character %q, missing \",\" after array element", c))
+		case d.state == streamArrayNext:
+			d.off++
+			if _, ok := d.peekByte(); !ok {
+				return nil, d.setError(d.unexpectedEOF())
+			}
+		}
+		d.state = streamArrayNext
+	case streamValues:
+		if _, ok := d.peekByte(); !ok {
+			d.state = streamEnd
+			return d.next()
+		}
+	case streamEnd:
+		if d.err != nil && d.err != io.EOF {
+			return nil, d.err
+		}
+		return nil, io.EOF
+	}
+
+	n, err := d.scanValue()
+	if err != nil {
+		return nil, d.setError(err)
+	}
+	b := d.buf[d.off : d.off+n]
+	d.off += n
+	return b, nil
+}
+
+// nextLine returns the next line in the stream which is not blank,
+// without the line terminator.
+func (d *Decoder) nextLine() ([]byte, error) {
+	if _, ok := d.peekByte(); !ok {
+		d.state = streamEnd
+		if d.err != nil && d.err != io.EOF {
+			return nil, d.err
+		}
+		return nil, io.EOF
+	}
+	n := 0
+	for ; d.off+n < len(d.buf) || d.fill(); n++ {
+		if d.buf[d.off+n] == '\n' {
+			break
+		}
+	}
+	b := d.buf[d.off : d.off+n]
+	d.off += n
+	return b, nil
+}
+
+// setError records a permanent error and returns it.
+func (d *Decoder) setError(err error) error {
+	d.err = err
+	d.state = streamEnd
+	return err
+}
+
+// syntaxError returns a syntax error at the start of the unread input.
+func (d *Decoder) syntaxError(f string, x ...interface{}) error {
+	line, column := d.position(d.off)
+	p := errors.Pos{Offset: d.base + d.off, Line: line, Column: column}
+	e := errors.New(f, x...)
+	return newDecodeError(protoerror.Syntax, p, errors.NewAt(p, "syntax error (line %d:%d): %v", line, column, e))
+}
+
+// position returns the line and column number of buf[off] within the stream,
+// where off must not precede any offset previously passed to position.
+func (d *Decoder) position(off int) (line, column int) {
+	for _, c := range d.buf[d.posOff:off] {
+		switch {
+		case c == '\n':
+			d.line++
+			d.column = 1
+		case c&0xc0 != 0x80: // count runes rather than bytes
+			d.column++
+		}
+	}
+	d.posOff = off
+	return d.line, d.column
+}
+
+// unexpectedEOF returns the error for input which ends prematurely.
+func (d *Decoder) unexpectedEOF() error {
+	if d.err != nil && d.err != io.EOF {
+		return d.err
+	}
+	return json.ErrUnexpectedEOF
+}
+
+// peekByte skips any whitespace and returns the next byte of input.
+// It reports false if the end of the input has been reached.
+func (d *Decoder) peekByte() (byte, bool) {
+	for {
+		for ; d.off < len(d.buf); d.off++ {
+			switch c := d.buf[d.off]; c {
+			case ' ', '\n', '\r', '\t':
+			default:
+				return c, true
+			}
+		}
+		if !d.fill() {
+			return 0, false
+		}
+	}
+}
+
+// scanValue returns the length of the JSON value at the start of the unread
+// input, reading more input as necessary. It only determines the extent of
+// the value; the value itself is validated when it is unmarshaled.
+func (d *Decoder) scanValue() (int, error) {
+	var depth int
+	var inString, escaped bool
+	scalar := d.buf[d.off] != '{' && d.buf[d.off] != '[' && d.buf[d.off] != '"'
+	for i := 0; ; i++ {
+		if d.off+i == len(d.buf) && !d.fill() {
+			if scalar && i > 0 {
+				return i, nil
+			}
+			return 0, d.unexpectedEOF()
+		}
+		c := d.buf[d.off+i]
+		switch {
+		case inString:
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+				if depth == 0 {
+					return i + 1, nil
+				}
+			}
+		case scalar:
+			switch c {
+			case ' ', '\n', '\r', '\t', ',', ':', '{', '}', '[', ']', '"':
+				if i == 0 {
+					return 0, d.syntaxError("unexpected character %q", c)
+				}
+				return i, nil
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+			if depth == 0 {
+				return i + 1, nil
+			}
+		}
+	}
+}
+
+// fill reads more input into the buffer, discarding input that has already
+// been consumed. It reports whether any input was read.
+func (d *Decoder) fill() bool {
+	if d.err != nil {
+		return false
+	}
+	if d.off > 0 {
+		d.position(d.off)
+		d.posOff = 0
+		d.base += d.off
+		n := copy(d.buf, d.buf[d.off:])
+		d.buf = d.buf[:n]
+		d.off = 0
+	}
+	if cap(d.buf)-len(d.buf) < streamChunkSize {
+		buf := make([]byte, len(d.buf), 2*cap(d.buf)+streamChunkSize)
+		copy(buf, d.buf)
+		d.buf = buf
+	}
+	for i := 0; i < maxConsecutiveEmptyReads; i++ {
+		n, err := d.r.Read(d.buf[len(d.buf):cap(d.buf)])
+		d.buf = d.buf[:len(d.buf)+n]
+		if err != nil {
+			d.err = err
+		}
+		if n > 0 || err != nil {
+			return n > 0
+		}
+	}
+	d.err = io.ErrNoProgress
+	return false
+}
+
+// maxConsecutiveEmptyReads is the number of reads which return neither
+// data nor an error after which a Decoder gives up, as bufio.Reader does.
+const maxConsecutiveEmptyReads = 100
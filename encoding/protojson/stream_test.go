@@ -0,0 +1,332 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protojson_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"google.golang.org/protobuf/encoding/protoerror"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	pb2 "google.golang.org/protobuf/internal/testprotos/textpb2"
+	pb3 "google.golang.org/protobuf/internal/testprotos/textpb3"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestEncoder(t *testing.T) {
+	large := &pb3.Repeats{}
+	for i := 0; i < 10000; i++ {
+		large.RptString = append(large.RptString, strings.Repeat("x", i%20))
+	}
+	msgs := []proto.Message{
+		&pb3.Scalars{SInt32: 1, SString: "hello"},
+		&pb3.Scalars{},
+		large,
+		&pb3.Nests{SNested: &pb3.Nested{SString: "nested"}},
+	}
+
+	for _, mo := range []protojson.MarshalOptions{{}, {Multiline: true}, {UseProtoNames: true}} {
+		var got bytes.Buffer
+		var want []byte
+		enc := mo.NewEncoder(&got)
+		for _, m := range msgs {
+			if err := enc.Encode(m); err != nil {
+				t.Fatalf("Encode() error: %v", err)
+			}
+			b, err := mo.Marshal(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want = append(append(want, b...), '\n')
+		}
+		if !bytes.Equal(got.Bytes(), want) {
+			t.Errorf("%+v: Encode() output differs from Marshal()", mo)
+		}
+	}
+
+	if err := protojson.NewEncoder(ioutil.Discard).Encode(&pb2.Requireds{}); err == nil {
+		t.Errorf("Encode() of message with missing required fields succeeded, want error")
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	tests := []struct {
+		desc    string
+		input   string
+		newMsg  func() proto.Message
+		want    []proto.Message
+		wantErr string // error after the messages in want
+	}{{
+		desc:   "empty stream",
+		input:  " \n ",
+		newMsg: func() proto.Message { return &pb3.Scalars{} },
+	}, {
+		desc:   "newline-delimited",
+		input:  "{\"sInt32\":1}\n{\"sString\":\"a}b\\\"{\"}\n\n{}\n",
+		newMsg: func() proto.Message { return &pb3.Scalars{} },
+		want: []proto.Message{
+			&pb3.Scalars{SInt32: 1},
+			&pb3.Scalars{SString: "a}b\"{"},
+			&pb3.Scalars{},
+		},
+	}, {
+		desc:   "concatenated",
+		input:  `{"sInt32":1}{"sInt32":2}`,
+		newMsg: func() proto.Message { return &pb3.Scalars{} },
+		want: []proto.Message{
+			&pb3.Scalars{SInt32: 1},
+			&pb3.Scalars{SInt32: 2},
+		},
+	}, {
+		desc:   "array",
+		input:  ` [ {"sNested":{}} , {"sNested":{"sString":"]"}} ] `,
+		newMsg: func() proto.Message { return &pb3.Nests{} },
+		want: []proto.Message{
+			&pb3.Nests{SNested: &pb3.Nested{}},
+			&pb3.Nests{SNested: &pb3.Nested{SString: "]"}},
+		},
+	}, {
+		desc:   "empty array",
+		input:  `[]`,
+		newMsg: func() proto.Message { return &pb3.Scalars{} },
+	}, {
+		desc:   "scalar values",
+		input:  "1 2\n\"3\"4",
+		newMsg: func() proto.Message { return &wrapperspb.Int32Value{} },
+		want: []proto.Message{
+			wrapperspb.Int32(1),
+			wrapperspb.Int32(2),
+			wrapperspb.Int32(3),
+			wrapperspb.Int32(4),
+		},
+	}, {
+		desc:    "unterminated value",
+		input:   `{"sInt32":1}{"sInt32":`,
+		newMsg:  func() proto.Message { return &pb3.Scalars{} },
+		want:    []proto.Message{&pb3.Scalars{SInt32: 1}},
+		wantErr: "unexpected EOF",
+	}, {
+		desc:    "unterminated array",
+		input:   `[{"sInt32":1},`,
+		newMsg:  func() proto.Message { return &pb3.Scalars{} },
+		want:    []proto.Message{&pb3.Scalars{SInt32: 1}},
+		wantErr: "unexpected EOF",
+	}, {
+		desc:    "missing comma in array",
+		input:   `[{"sInt32":1} {"sInt32":2}]`,
+		newMsg:  func() proto.Message { return &pb3.Scalars{} },
+		want:    []proto.Message{&pb3.Scalars{SInt32: 1}},
+		wantErr: `missing ","`,
+	}, {
+		desc:    "data after array",
+		input:   `[{"sInt32":1}] {}`,
+		newMsg:  func() proto.Message { return &pb3.Scalars{} },
+		want:    []proto.Message{&pb3.Scalars{SInt32: 1}},
+		wantErr: "unexpected data after array",
+	}}
+
+	for _, tt := range tests {
+		for _, r := range []io.Reader{strings.NewReader(tt.input), iotest.OneByteReader(strings.NewReader(tt.input))} {
+			dec := protojson.NewDecoder(r)
+			var got []proto.Message
+			var err error
+			for {
+				m := tt.newMsg()
+				if err = dec.Decode(m); err != nil {
+					break
+				}
+				got = append(got, m)
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("%s: got %d messages, want %d; error: %v", tt.desc, len(got), len(tt.want), err)
+				continue
+			}
+			for i := range got {
+				if !proto.Equal(got[i], tt.want[i]) {
+					t.Errorf("%s: message %d = %v, want %v", tt.desc, i, got[i], tt.want[i])
+				}
+			}
+			switch {
+			case tt.wantErr == "" && err != io.EOF:
+				t.Errorf("%s: Decode() error = %v, want io.EOF", tt.desc, err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("%s: Decode() error = %v, want error containing %q", tt.desc, err, tt.wantErr)
+			}
+		}
+	}
+}
+
+func TestDecoderSkipsInvalidValue(t *testing.T) {
+	dec := protojson.NewDecoder(strings.NewReader(`{"sInt32":1} {"unknown":2} {"sInt32":3}`))
+	var got []int32
+	var errs int
+	for {
+		m := &pb3.Scalars{}
+		err := dec.Decode(m)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if e, ok := err.(*protoerror.DecodeError); !ok || e.Offset != 14 || e.Column != 15 {
+				t.Errorf("Decode() error = %#v, want *protoerror.DecodeError at offset 14, column 15", err)
+			}
+			errs++
+			continue
+		}
+		got = append(got, m.SInt32)
+	}
+	if errs != 1 || len(got) != 2 || got[0] != 1 || got[1] != 3 {
+		t.Errorf("Decode() returned messages %v and %d errors, want [1 3] and 1 error", got, errs)
+	}
+}
+
+// emptyReader returns neither data nor an error.
+type emptyReader struct{}
+
+func (emptyReader) Read([]byte) (int, error) { return 0, nil }
+
+func TestDecoderNoProgress(t *testing.T) {
+	for _, r := range []io.Reader{
+		emptyReader{},
+		io.MultiReader(strings.NewReader(`{"sInt32":`), emptyReader{}),
+	} {
+		if err := protojson.NewDecoder(r).Decode(&pb3.Scalars{}); err != io.ErrNoProgress {
+			t.Errorf("Decode() error = %v, want %v", err, io.ErrNoProgress)
+		}
+	}
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	var b bytes.Buffer
+	enc := protojson.MarshalOptions{Multiline: true}.NewEncoder(&b)
+	var want []*pb3.Repeats
+	for i := 0; i < 100; i++ {
+		m := &pb3.Repeats{RptInt32: []int32{int32(i)}, RptString: []string{strings.Repeat("{", i*100)}}
+		if err := enc.Encode(m); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, m)
+	}
+
+	dec := protojson.NewDecoder(&b)
+	for i := 0; ; i++ {
+		m := &pb3.Repeats{}
+		err := dec.Decode(m)
+		if err == io.EOF {
+			if i != len(want) {
+				t.Errorf("decoded %d messages, want %d", i, len(want))
+			}
+			break
+		}
+		if err != nil {
+			t.Fatalf("Decode() error: %v", err)
+		}
+		if i >= len(want) || !proto.Equal(m, want[i]) {
+			t.Fatalf("message %d mismatch", i)
+		}
+	}
+}
+
+func TestDecoderJSONLines(t *testing.T) {
+	const input = "{\"sInt32\":1}\r\n" +
+		"\n" +
+		"{\"sInt32\":2, \"sBool\":3}\n" +
+		"  {\"sString\":\"éé\", \"unknown\":4}\n" +
+		"{\"sInt32\":\n" +
+		"[{\"sInt32\":5}]\n" +
+		"{\"sInt32\":6}"
+
+	dec := protojson.NewDecoder(strings.NewReader(input))
+	dec.JSONLines = true
+	var got []int32
+	var errs []string
+	for {
+		m := &pb3.Scalars{}
+		err := dec.Decode(m)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		got = append(got, m.SInt32)
+	}
+	if len(got) != 2 || got[0] != 1 || got[1] != 6 {
+		t.Errorf("Decode() messages = %v, want [1 6]", got)
+	}
+	wantErrs := []string{
+		"(line 3:22): invalid value for bool type: 3",
+		`(line 4:20): unknown field "unknown"`,
+		"unexpected EOF",
+		"(line 6:1)",
+	}
+	if len(errs) != len(wantErrs) {
+		t.Fatalf("Decode() returned %d errors, want %d: %q", len(errs), len(wantErrs), errs)
+	}
+	for i, want := range wantErrs {
+		if !strings.Contains(errs[i], want) {
+			t.Errorf("error %d = %v, want error containing %q", i, errs[i], want)
+		}
+	}
+
+	// Invalid lines are reported to OnInvalid and skipped.
+	dec = protojson.NewDecoder(strings.NewReader(input))
+	dec.JSONLines = true
+	var skipped []int
+	dec.OnInvalid = func(error) {
+		line, _ := dec.Position()
+		skipped = append(skipped, line)
+	}
+	got = nil
+	for {
+		m := &pb3.Scalars{}
+		err := dec.Decode(m)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Decode() error: %v", err)
+		}
+		got = append(got, m.SInt32)
+	}
+	if len(got) != 2 || len(skipped) != 4 || skipped[0] != 3 || skipped[3] != 6 {
+		t.Errorf("Decode() with OnInvalid returned messages %v and skipped lines %v, want [1 6] and [3 4 5 6]", got, skipped)
+	}
+}
+
+func TestDecoderRequired(t *testing.T) {
+	dec := protojson.NewDecoder(strings.NewReader("{}\n  {}\n"))
+	dec.JSONLines = true
+	if err := dec.Decode(&pb3.Scalars{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.Decode(&pb2.Requireds{}); err == nil {
+		t.Errorf("Decode() of message with missing required fields succeeded, want error")
+	}
+	if line, column := dec.Position(); line != 2 || column != 3 {
+		t.Errorf("Position() = %d:%d, want 2:3", line, column)
+	}
+	if err := dec.Decode(&pb2.Requireds{}); err != io.EOF {
+		t.Errorf("Decode() at end of input = %v, want io.EOF", err)
+	}
+}
+
+func TestDecoderPosition(t *testing.T) {
+	dec := protojson.NewDecoder(strings.NewReader("{}\n\n  {\"sInt32\": \"x\"}"))
+	if err := dec.Decode(&pb3.Scalars{}); err != nil {
+		t.Fatal(err)
+	}
+	err := dec.Decode(&pb3.Scalars{})
+	if err == nil || !strings.Contains(err.Error(), "(line 3:14)") {
+		t.Errorf("Decode() error = %v, want error at line 3:14", err)
+	}
+}
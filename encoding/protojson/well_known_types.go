@@ -12,11 +12,13 @@ import (
 	"strings"
 	"time"
 
+	"google.golang.org/protobuf/encoding/protoerror"
 	"google.golang.org/protobuf/internal/encoding/json"
 	"google.golang.org/protobuf/internal/errors"
 	"google.golang.org/protobuf/internal/genid"
 	"google.golang.org/protobuf/internal/strs"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protopath"
 	pref "google.golang.org/protobuf/reflect/protoreflect"
 )
 
@@ -141,7 +143,7 @@ func (e encoder) marshalAny(m pref.Message) error {
 	// If type of value has custom JSON encoding, marshal out a field "value"
 	// with corresponding custom JSON encoding of the embedded message as a
 	// field.
-	if marshal := wellKnownTypeMarshaler(emt.Descriptor().FullName()); marshal != nil {
+	if marshal := e.typeMarshaler(emt.Descriptor().FullName()); marshal != nil {
 		e.StartObject()
 		defer e.EndObject()
 
@@ -176,7 +178,7 @@ func (d decoder) unmarshalAny(m pref.Message) error {
 	// Use another decoder to parse the unread bytes for @type field. This
 	// avoids advancing a read from current decoder because the current JSON
 	// object may contain the fields of the embedded type.
-	dec := decoder{d.Clone(), UnmarshalOptions{}}
+	dec := decoder{Decoder: d.Clone()}
 	tok, err := findTypeURL(dec)
 	switch err {
 	case errEmptyObject:
@@ -207,16 +209,16 @@ func (d decoder) unmarshalAny(m pref.Message) error {
 
 	// Create new message for the embedded message type and unmarshal into it.
 	em := emt.New()
-	if unmarshal := wellKnownTypeUnmarshaler(emt.Descriptor().FullName()); unmarshal != nil {
+	if unmarshal := d.typeUnmarshaler(emt.Descriptor().FullName()); unmarshal != nil {
 		// If embedded message is a custom type,
 		// unmarshal the JSON "value" field into it.
 		if err := d.unmarshalAnyValue(unmarshal, em); err != nil {
-			return err
+			return d.withPathStep(err, protopath.AnyExpand(emt.Descriptor()), start.Pos())
 		}
 	} else {
 		// Else unmarshal the current JSON object into it.
-		if err := d.unmarshalMessage(em, true); err != nil {
-			return err
+		if err := d.at(protopath.AnyExpand(emt.Descriptor())).unmarshalMessage(em, true); err != nil {
+			return d.withPathStep(err, protopath.AnyExpand(emt.Descriptor()), start.Pos())
 		}
 	}
 	// Serialize the embedded message and assign the resulting bytes to the
@@ -314,6 +316,8 @@ func (d decoder) skipJSONValue() error {
 	}
 	// Only need to continue reading for objects and arrays.
 	switch tok.Kind() {
+	case json.ObjectClose, json.ArrayClose:
+		return d.unexpectedTokenError(tok)
 	case json.ObjectOpen:
 		for {
 			tok, err := d.Read()
@@ -394,7 +398,7 @@ func (d decoder) unmarshalAnyValue(unmarshal unmarshalFunc, m pref.Message) erro
 					}
 					continue
 				}
-				return d.newError(tok.Pos(), "unknown field %v", tok.RawString())
+				return d.newCategoryError(protoerror.UnknownField, tok.Pos(), "unknown field %v", tok.RawString())
 			}
 		}
 	}
@@ -409,10 +413,14 @@ func (e encoder) marshalWrapperType(m pref.Message) error {
 }
 
 func (d decoder) unmarshalWrapperType(m pref.Message) error {
+	start, err := d.Peek()
+	if err != nil {
+		return err
+	}
 	fd := m.Descriptor().Fields().ByNumber(genid.WrapperValue_Value_field_number)
 	val, err := d.unmarshalScalar(fd)
 	if err != nil {
-		return err
+		return d.withPathStep(err, protopath.FieldAccess(fd), start.Pos())
 	}
 	m.Set(fd, val)
 	return nil
@@ -468,8 +476,15 @@ func (e encoder) marshalStruct(m pref.Message) error {
 }
 
 func (d decoder) unmarshalStruct(m pref.Message) error {
+	start, err := d.Peek()
+	if err != nil {
+		return err
+	}
 	fd := m.Descriptor().Fields().ByNumber(genid.Struct_Fields_field_number)
-	return d.unmarshalMap(m.Mutable(fd).Map(), fd)
+	if err := d.unmarshalMap(m.Mutable(fd).Map(), fd); err != nil {
+		return d.withPathStep(err, protopath.FieldAccess(fd), start.Pos())
+	}
+	return nil
 }
 
 // The JSON representation for ListValue is JSON array that contains the encoded
@@ -482,8 +497,15 @@ func (e encoder) marshalListValue(m pref.Message) error {
 }
 
 func (d decoder) unmarshalListValue(m pref.Message) error {
+	start, err := d.Peek()
+	if err != nil {
+		return err
+	}
 	fd := m.Descriptor().Fields().ByNumber(genid.ListValue_Values_field_number)
-	return d.unmarshalList(m.Mutable(fd).List(), fd)
+	if err := d.unmarshalList(m.Mutable(fd).List(), fd); err != nil {
+		return d.withPathStep(err, protopath.FieldAccess(fd), start.Pos())
+	}
+	return nil
 }
 
 // The JSON representation for a Value is dependent on the oneof field that is
@@ -535,7 +557,7 @@ func (d decoder) unmarshalKnownValue(m pref.Message) error {
 		var ok bool
 		val, ok = unmarshalFloat(tok, 64)
 		if !ok {
-			return d.newError(tok.Pos(), "invalid %v: %v", genid.Value_message_fullname, tok.RawString())
+			return d.newCategoryError(protoerror.TypeMismatch, tok.Pos(), "invalid %v: %v", genid.Value_message_fullname, tok.RawString())
 		}
 
 	case json.String:
@@ -556,18 +578,18 @@ func (d decoder) unmarshalKnownValue(m pref.Message) error {
 		fd = m.Descriptor().Fields().ByNumber(genid.Value_StructValue_field_number)
 		val = m.NewField(fd)
 		if err := d.unmarshalStruct(val.Message()); err != nil {
-			return err
+			return d.withPathStep(err, protopath.FieldAccess(fd), tok.Pos())
 		}
 
 	case json.ArrayOpen:
 		fd = m.Descriptor().Fields().ByNumber(genid.Value_ListValue_field_number)
 		val = m.NewField(fd)
 		if err := d.unmarshalListValue(val.Message()); err != nil {
-			return err
+			return d.withPathStep(err, protopath.FieldAccess(fd), tok.Pos())
 		}
 
 	default:
-		return d.newError(tok.Pos(), "invalid %v: %v", genid.Value_message_fullname, tok.RawString())
+		return d.newCategoryError(protoerror.TypeMismatch, tok.Pos(), "invalid %v: %v", genid.Value_message_fullname, tok.RawString())
 	}
 
 	m.Set(fd, val)
@@ -634,12 +656,12 @@ func (d decoder) unmarshalDuration(m pref.Message) error {
 
 	secs, nanos, ok := parseDuration(tok.ParsedString())
 	if !ok {
-		return d.newError(tok.Pos(), "invalid %v value %v", genid.Duration_message_fullname, tok.RawString())
+		return d.newCategoryError(protoerror.TypeMismatch, tok.Pos(), "invalid %v value %v", genid.Duration_message_fullname, tok.RawString())
 	}
 	// Validate seconds. No need to validate nanos because parseDuration would
 	// have covered that already.
 	if secs < -maxSecondsInDuration || secs > maxSecondsInDuration {
-		return d.newError(tok.Pos(), "%v value out of range: %v", genid.Duration_message_fullname, tok.RawString())
+		return d.newCategoryError(protoerror.Range, tok.Pos(), "%v value out of range: %v", genid.Duration_message_fullname, tok.RawString())
 	}
 
 	fds := m.Descriptor().Fields()
@@ -816,13 +838,13 @@ func (d decoder) unmarshalTimestamp(m pref.Message) error {
 
 	t, err := time.Parse(time.RFC3339Nano, tok.ParsedString())
 	if err != nil {
-		return d.newError(tok.Pos(), "invalid %v value %v", genid.Timestamp_message_fullname, tok.RawString())
+		return d.newCategoryError(protoerror.TypeMismatch, tok.Pos(), "invalid %v value %v", genid.Timestamp_message_fullname, tok.RawString())
 	}
 	// Validate seconds. No need to validate nanos because time.Parse would have
 	// covered that already.
 	secs := t.Unix()
 	if secs < minTimestampSeconds || secs > maxTimestampSeconds {
-		return d.newError(tok.Pos(), "%v value out of range: %v", genid.Timestamp_message_fullname, tok.RawString())
+		return d.newCategoryError(protoerror.Range, tok.Pos(), "%v value out of range: %v", genid.Timestamp_message_fullname, tok.RawString())
 	}
 
 	fds := m.Descriptor().Fields()
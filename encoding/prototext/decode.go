@@ -6,17 +6,21 @@ package prototext
 
 import (
 	"fmt"
+	"math"
 	"unicode/utf8"
 
+	"google.golang.org/protobuf/encoding/protoerror"
 	"google.golang.org/protobuf/internal/encoding/messageset"
 	"google.golang.org/protobuf/internal/encoding/text"
 	"google.golang.org/protobuf/internal/errors"
 	"google.golang.org/protobuf/internal/flags"
 	"google.golang.org/protobuf/internal/genid"
 	"google.golang.org/protobuf/internal/pragma"
+	"google.golang.org/protobuf/internal/required"
 	"google.golang.org/protobuf/internal/set"
 	"google.golang.org/protobuf/internal/strs"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protopath"
 	pref "google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/reflect/protoregistry"
 )
@@ -49,6 +53,15 @@ type UnmarshalOptions struct {
 		protoregistry.MessageTypeResolver
 		protoregistry.ExtensionTypeResolver
 	}
+
+	// If AllErrors is set, unmarshaling continues after an error in a field,
+	// skipping that field, so as to report as many errors as possible.
+	// Unless AllowPartial is set, every required field which is not
+	// populated is also reported. The error returned is then either nil or
+	// a protoerror.ErrorList, and the message is populated with the fields
+	// which could be unmarshaled. Errors in the syntax of the input still
+	// stop unmarshaling.
+	AllErrors bool
 }
 
 // Unmarshal reads the given []byte and populates the given proto.Message
@@ -68,12 +81,26 @@ func (o UnmarshalOptions) unmarshal(b []byte, m proto.Message) error {
 		o.Resolver = protoregistry.GlobalTypes
 	}
 
-	dec := decoder{text.NewDecoder(b), o}
-	if err := dec.unmarshalMessage(m.ProtoReflect(), false); err != nil {
-		return err
+	dec := decoder{Decoder: text.NewDecoder(b), opts: o}
+	if o.AllErrors {
+		dec.errs = new(protoerror.ErrorList)
+	}
+	root := protopath.Root(m.ProtoReflect().Descriptor())
+	if err := dec.at(root).unmarshalMessage(m.ProtoReflect(), false); err != nil {
+		return dec.errorList(dec.withPathStep(err, root, 0))
 	}
 	if o.AllowPartial {
-		return nil
+		return dec.errorList(nil)
+	}
+	if o.AllErrors {
+		required.Range(m.ProtoReflect(), protopath.Path{root}, func(p protopath.Path, fd pref.FieldDescriptor) {
+			*dec.errs = append(*dec.errs, &protoerror.DecodeError{
+				Category: protoerror.RequiredField,
+				Path:     p,
+				Err:      errors.RequiredNotSet(string(fd.FullName())),
+			})
+		})
+		return dec.errorList(nil)
 	}
 	return proto.CheckInitialized(m)
 }
@@ -81,13 +108,24 @@ func (o UnmarshalOptions) unmarshal(b []byte, m proto.Message) error {
 type decoder struct {
 	*text.Decoder
 	opts UnmarshalOptions
+
+	// In AllErrors mode, errs is where errors are recorded,
+	// and path is the path to the value being unmarshaled.
+	errs *protoerror.ErrorList
+	path protopath.Path
 }
 
 // newError returns an error object with position info.
 func (d decoder) newError(pos int, f string, x ...interface{}) error {
-	line, column := d.Position(pos)
-	head := fmt.Sprintf("(line %d:%d): ", line, column)
-	return errors.New(head+f, x...)
+	return d.newCategoryError(protoerror.Other, pos, f, x...)
+}
+
+// newCategoryError returns an error object with position info
+// for an error of the given category.
+func (d decoder) newCategoryError(c protoerror.Category, pos int, f string, x ...interface{}) error {
+	p := d.errorPos(pos)
+	head := fmt.Sprintf("(line %d:%d): ", p.Line, p.Column)
+	return newDecodeError(c, p, errors.NewAt(p, head+f, x...))
 }
 
 // unexpectedTokenError returns a syntax error for the given unexpected token.
@@ -97,9 +135,103 @@ func (d decoder) unexpectedTokenError(tok text.Token) error {
 
 // syntaxError returns a syntax error for given position.
 func (d decoder) syntaxError(pos int, f string, x ...interface{}) error {
+	p := d.errorPos(pos)
+	head := fmt.Sprintf("syntax error (line %d:%d): ", p.Line, p.Column)
+	return newDecodeError(protoerror.Syntax, p, errors.NewAt(p, head+f, x...))
+}
+
+// errorPos returns the position in the input of the given index.
+func (d decoder) errorPos(pos int) errors.Pos {
 	line, column := d.Position(pos)
-	head := fmt.Sprintf("syntax error (line %d:%d): ", line, column)
-	return errors.New(head+f, x...)
+	return errors.Pos{Offset: pos, Line: line, Column: column}
+}
+
+// withPathStep returns err with the step s prepended to its path.
+// If err is not a *protoerror.DecodeError, it is first converted to one.
+func (d decoder) withPathStep(err error, s protopath.Step, pos int) error {
+	e := d.decodeError(err, pos)
+	e.Path = append(protopath.Path{s}, e.Path...)
+	return e
+}
+
+// decodeError converts err to a *protoerror.DecodeError if it is not one,
+// using the position recorded in err if any, and the given position otherwise.
+func (d decoder) decodeError(err error, pos int) *protoerror.DecodeError {
+	if e, ok := err.(*protoerror.DecodeError); ok {
+		return e
+	}
+	c := protoerror.Other
+	p, ok := errors.Position(err)
+	switch {
+	case ok && errors.IsInvalidUTF8(err):
+		c = protoerror.InvalidUTF8
+	case ok || err == text.ErrUnexpectedEOF:
+		c = protoerror.Syntax
+	}
+	if !ok {
+		p = d.errorPos(pos)
+	}
+	return newDecodeError(c, p, err)
+}
+
+func newDecodeError(c protoerror.Category, p errors.Pos, err error) *protoerror.DecodeError {
+	return &protoerror.DecodeError{
+		Category: c,
+		Offset:   p.Offset,
+		Line:     p.Line,
+		Column:   p.Column,
+		Err:      err,
+	}
+}
+
+// at returns a decoder for the value at the step s from the current value.
+// It records the path to the value only in AllErrors mode.
+func (d decoder) at(s protopath.Step) decoder {
+	if d.errs != nil {
+		d.path = append(d.path[:len(d.path):len(d.path)], s)
+	}
+	return d
+}
+
+// skipAfterError handles an error in the field whose value is next in the
+// input. In AllErrors mode, it records err and skips the value, returning nil
+// if unmarshaling can continue. Otherwise, it returns err, or the error in
+// the syntax of the value which prevents skipping it.
+func (d decoder) skipAfterError(err error) error {
+	if d.errs == nil {
+		return err
+	}
+	if serr := d.skipValue(); serr != nil {
+		// The value is not valid syntax, which is then the error to report.
+		if e, ok := err.(*protoerror.DecodeError); ok && e.Category == protoerror.Syntax {
+			return err
+		}
+		return serr
+	}
+	d.recordError(err)
+	return nil
+}
+
+// recordError records err, which is relative to the current value.
+func (d decoder) recordError(err error) {
+	e := d.decodeError(err, 0)
+	e.Path = append(d.path[:len(d.path):len(d.path)], e.Path...)
+	*d.errs = append(*d.errs, e)
+}
+
+// errorList returns the errors recorded in AllErrors mode along with err,
+// which stopped unmarshaling. Otherwise, it returns err.
+func (d decoder) errorList(err error) error {
+	if d.errs == nil {
+		return err
+	}
+	if err != nil {
+		*d.errs = append(*d.errs, err.(*protoerror.DecodeError))
+	}
+	if len(*d.errs) == 0 {
+		return nil
+	}
+	return *d.errs
 }
 
 // unmarshalMessage unmarshals into the given protoreflect.Message.
@@ -126,7 +258,6 @@ func (d decoder) unmarshalMessage(m pref.Message, checkDelims bool) error {
 
 	var seenNums set.Ints
 	var seenOneofs set.Ints
-	fieldDescs := messageDesc.Fields()
 
 	for {
 		// Read field name.
@@ -150,113 +281,144 @@ func (d decoder) unmarshalMessage(m pref.Message, checkDelims bool) error {
 		}
 
 		// Resolve the field descriptor.
-		var name pref.Name
-		var fd pref.FieldDescriptor
-		var xt pref.ExtensionType
-		var xtErr error
-		var isFieldNumberName bool
-
-		switch tok.NameKind() {
-		case text.IdentName:
-			name = pref.Name(tok.IdentName())
-			fd = fieldDescs.ByTextName(string(name))
-
-		case text.TypeName:
-			// Handle extensions only. This code path is not for Any.
-			xt, xtErr = d.opts.Resolver.FindExtensionByName(pref.FullName(tok.TypeName()))
-
-		case text.FieldNumber:
-			isFieldNumberName = true
-			num := pref.FieldNumber(tok.FieldNumber())
-			if !num.IsValid() {
-				return d.newError(tok.Pos(), "invalid field number: %d", num)
-			}
-			fd = fieldDescs.ByNumber(num)
-			if fd == nil {
-				xt, xtErr = d.opts.Resolver.FindExtensionByNumber(messageDesc.FullName(), num)
-			}
-		}
-
-		if xt != nil {
-			fd = xt.TypeDescriptor()
-			if !messageDesc.ExtensionRanges().Has(fd.Number()) || fd.ContainingMessage().FullName() != messageDesc.FullName() {
-				return d.newError(tok.Pos(), "message %v cannot be extended by %v", messageDesc.FullName(), fd.FullName())
-			}
-		} else if xtErr != nil && xtErr != protoregistry.NotFound {
-			return d.newError(tok.Pos(), "unable to resolve [%s]: %v", tok.RawString(), xtErr)
+		fd, err := d.findField(messageDesc, tok)
+		if err == nil {
+			err = d.checkField(fd, tok, &seenNums, &seenOneofs)
 		}
-		if flags.ProtoLegacy {
-			if fd != nil && fd.IsWeak() && fd.Message().IsPlaceholder() {
-				fd = nil // reset since the weak reference is not linked in
+		if err != nil {
+			if err := d.skipAfterError(err); err != nil {
+				return err
 			}
+			continue
 		}
-
-		// Handle unknown fields.
 		if fd == nil {
-			if d.opts.DiscardUnknown || messageDesc.ReservedNames().Has(name) {
-				d.skipValue()
-				continue
-			}
-			return d.newError(tok.Pos(), "unknown field: %v", tok.RawString())
+			// Skip the discarded unknown field.
+			d.skipValue()
+			continue
 		}
 
-		// Handle fields identified by field number.
-		if isFieldNumberName {
-			// TODO: Add an option to permit parsing field numbers.
-			//
-			// This requires careful thought as the MarshalOptions.EmitUnknown
-			// option allows formatting unknown fields as the field number and the
-			// best-effort textual representation of the field value.  In that case,
-			// it may not be possible to unmarshal the value from a parser that does
-			// have information about the unknown field.
-			return d.newError(tok.Pos(), "cannot specify field by number: %v", tok.RawString())
+		// In AllErrors mode, keep the state before the value in order to
+		// skip it if it cannot be unmarshaled.
+		var saved *text.Decoder
+		if d.errs != nil {
+			saved = d.Clone()
 		}
 
+		fieldDec := d.at(protopath.FieldAccess(fd))
 		switch {
 		case fd.IsList():
-			kind := fd.Kind()
-			if kind != pref.MessageKind && kind != pref.GroupKind && !tok.HasSeparator() {
-				return d.syntaxError(tok.Pos(), "missing field separator :")
-			}
-
-			list := m.Mutable(fd).List()
-			if err := d.unmarshalList(fd, list); err != nil {
+			err = fieldDec.unmarshalList(fd, m.Mutable(fd).List())
+		case fd.IsMap():
+			err = fieldDec.unmarshalMap(fd, m.Mutable(fd).Map())
+		default:
+			err = fieldDec.unmarshalSingular(fd, m)
+		}
+		if err != nil {
+			err = d.withPathStep(err, protopath.FieldAccess(fd), tok.Pos())
+			if saved == nil {
 				return err
 			}
-
-		case fd.IsMap():
-			mmap := m.Mutable(fd).Map()
-			if err := d.unmarshalMap(fd, mmap); err != nil {
+			*d.Decoder = *saved
+			if err := d.skipAfterError(err); err != nil {
 				return err
 			}
+		}
+	}
+}
 
-		default:
-			kind := fd.Kind()
-			if kind != pref.MessageKind && kind != pref.GroupKind && !tok.HasSeparator() {
-				return d.syntaxError(tok.Pos(), "missing field separator :")
-			}
+// findField returns the descriptor of the field of md named by the given
+// token of Name kind. It returns nil for an unknown field which is discarded.
+func (d decoder) findField(md pref.MessageDescriptor, tok text.Token) (pref.FieldDescriptor, error) {
+	var name pref.Name
+	var fd pref.FieldDescriptor
+	var xt pref.ExtensionType
+	var xtErr error
+
+	switch tok.NameKind() {
+	case text.IdentName:
+		name = pref.Name(tok.IdentName())
+		fd = md.Fields().ByTextName(string(name))
+
+	case text.TypeName:
+		// Handle extensions only. This code path is not for Any.
+		xt, xtErr = d.opts.Resolver.FindExtensionByName(pref.FullName(tok.TypeName()))
+
+	case text.FieldNumber:
+		num := pref.FieldNumber(tok.FieldNumber())
+		if !num.IsValid() {
+			return nil, d.newError(tok.Pos(), "invalid field number: %d", num)
+		}
+		fd = md.Fields().ByNumber(num)
+		if fd == nil {
+			xt, xtErr = d.opts.Resolver.FindExtensionByNumber(md.FullName(), num)
+		}
+	}
 
-			// If field is a oneof, check if it has already been set.
-			if od := fd.ContainingOneof(); od != nil {
-				idx := uint64(od.Index())
-				if seenOneofs.Has(idx) {
-					return d.newError(tok.Pos(), "error parsing %q, oneof %v is already set", tok.RawString(), od.FullName())
-				}
-				seenOneofs.Set(idx)
-			}
+	if xt != nil {
+		fd = xt.TypeDescriptor()
+		if !md.ExtensionRanges().Has(fd.Number()) || fd.ContainingMessage().FullName() != md.FullName() {
+			return nil, d.newError(tok.Pos(), "message %v cannot be extended by %v", md.FullName(), fd.FullName())
+		}
+	} else if xtErr != nil && xtErr != protoregistry.NotFound {
+		return nil, d.newError(tok.Pos(), "unable to resolve [%s]: %v", tok.RawString(), xtErr)
+	}
+	if flags.ProtoLegacy {
+		if fd != nil && fd.IsWeak() && fd.Message().IsPlaceholder() {
+			fd = nil // reset since the weak reference is not linked in
+		}
+	}
 
-			num := uint64(fd.Number())
-			if seenNums.Has(num) {
-				return d.newError(tok.Pos(), "non-repeated field %q is repeated", tok.RawString())
-			}
+	// Handle unknown fields.
+	if fd == nil {
+		if d.opts.DiscardUnknown || md.ReservedNames().Has(name) {
+			return nil, nil
+		}
+		return nil, d.newCategoryError(protoerror.UnknownField, tok.Pos(), "unknown field: %v", tok.RawString())
+	}
 
-			if err := d.unmarshalSingular(fd, m); err != nil {
-				return err
-			}
-			seenNums.Set(num)
+	// Handle fields identified by field number.
+	if tok.NameKind() == text.FieldNumber {
+		// TODO: Add an option to permit parsing field numbers.
+		//
+		// This requires careful thought as the MarshalOptions.EmitUnknown
+		// option allows formatting unknown fields as the field number and the
+		// best-effort textual representation of the field value.  In that case,
+		// it may not be possible to unmarshal the value from a parser that does
+		// have information about the unknown field.
+		return nil, d.newError(tok.Pos(), "cannot specify field by number: %v", tok.RawString())
+	}
+	return fd, nil
+}
+
+// checkField checks that the field fd named by the given token may occur
+// at this point of the message, given the fields and oneofs seen so far,
+// and records it as seen.
+func (d decoder) checkField(fd pref.FieldDescriptor, tok text.Token, seenNums, seenOneofs *set.Ints) error {
+	if fd == nil || fd.IsMap() {
+		return nil
+	}
+	kind := fd.Kind()
+	if kind != pref.MessageKind && kind != pref.GroupKind && !tok.HasSeparator() {
+		return d.syntaxError(tok.Pos(), "missing field separator :")
+	}
+	if fd.IsList() {
+		return nil
+	}
+
+	// If field is a oneof, check if it has already been set.
+	if od := fd.ContainingOneof(); od != nil {
+		idx := uint64(od.Index())
+		if seenOneofs.Has(idx) {
+			return d.newError(tok.Pos(), "error parsing %q, oneof %v is already set", tok.RawString(), od.FullName())
 		}
+		seenOneofs.Set(idx)
 	}
 
+	num := uint64(fd.Number())
+	if seenNums.Has(num) {
+		return d.newError(tok.Pos(), "non-repeated field %q is repeated", tok.RawString())
+	}
+	seenNums.Set(num)
 	return nil
 }
 
@@ -330,7 +492,7 @@ func (d decoder) unmarshalScalar(fd pref.FieldDescriptor) (pref.Value, error) {
 	case pref.StringKind:
 		if s, ok := tok.String(); ok {
 			if strs.EnforceUTF8(fd) && !utf8.ValidString(s) {
-				return pref.Value{}, d.newError(tok.Pos(), "contains invalid UTF-8")
+				return pref.Value{}, d.newCategoryError(protoerror.InvalidUTF8, tok.Pos(), "contains invalid UTF-8")
 			}
 			return pref.ValueOfString(s), nil
 		}
@@ -355,7 +517,24 @@ func (d decoder) unmarshalScalar(fd pref.FieldDescriptor) (pref.Value, error) {
 		panic(fmt.Sprintf("invalid scalar kind %v", kind))
 	}
 
-	return pref.Value{}, d.newError(tok.Pos(), "invalid value for %v type: %v", kind, tok.RawString())
+	return pref.Value{}, d.newCategoryError(invalidScalarCategory(kind, tok), tok.Pos(), "invalid value for %v type: %v", kind, tok.RawString())
+}
+
+// invalidScalarCategory returns the category of the error for a token which
+// is not a valid value of the given kind: an integer which is not
+// representable in an integral kind is out of range, and any other value
+// is a type mismatch.
+func invalidScalarCategory(kind pref.Kind, tok text.Token) protoerror.Category {
+	switch kind {
+	case pref.Int32Kind, pref.Sint32Kind, pref.Sfixed32Kind,
+		pref.Int64Kind, pref.Sint64Kind, pref.Sfixed64Kind,
+		pref.Uint32Kind, pref.Fixed32Kind, pref.Uint64Kind, pref.Fixed64Kind,
+		pref.EnumKind:
+		if n, ok := tok.Float64(); ok && !math.IsInf(n, 0) && n == math.Trunc(n) {
+			return protoerror.Range
+		}
+	}
+	return protoerror.TypeMismatch
 }
 
 // unmarshalList unmarshals into given protoreflect.List. A list value can
@@ -383,8 +562,8 @@ func (d decoder) unmarshalList(fd pref.FieldDescriptor, list pref.List) error {
 					return nil
 				case text.MessageOpen:
 					pval := list.NewElement()
-					if err := d.unmarshalMessage(pval.Message(), true); err != nil {
-						return err
+					if err := d.at(protopath.ListIndex(list.Len())).unmarshalMessage(pval.Message(), true); err != nil {
+						return d.withPathStep(err, protopath.ListIndex(list.Len()), tok.Pos())
 					}
 					list.Append(pval)
 				default:
@@ -394,8 +573,8 @@ func (d decoder) unmarshalList(fd pref.FieldDescriptor, list pref.List) error {
 
 		case text.MessageOpen:
 			pval := list.NewElement()
-			if err := d.unmarshalMessage(pval.Message(), true); err != nil {
-				return err
+			if err := d.at(protopath.ListIndex(list.Len())).unmarshalMessage(pval.Message(), true); err != nil {
+				return d.withPathStep(err, protopath.ListIndex(list.Len()), tok.Pos())
 			}
 			list.Append(pval)
 			return nil
@@ -418,7 +597,7 @@ func (d decoder) unmarshalList(fd pref.FieldDescriptor, list pref.List) error {
 				case text.Scalar:
 					pval, err := d.unmarshalScalar(fd)
 					if err != nil {
-						return err
+						return d.withPathStep(err, protopath.ListIndex(list.Len()), tok.Pos())
 					}
 					list.Append(pval)
 				default:
@@ -429,7 +608,7 @@ func (d decoder) unmarshalList(fd pref.FieldDescriptor, list pref.List) error {
 		case text.Scalar:
 			pval, err := d.unmarshalScalar(fd)
 			if err != nil {
-				return err
+				return d.withPathStep(err, protopath.ListIndex(list.Len()), tok.Pos())
 			}
 			list.Append(pval)
 			return nil
@@ -445,10 +624,10 @@ func (d decoder) unmarshalMap(fd pref.FieldDescriptor, mmap pref.Map) error {
 	// Determine ahead whether map entry is a scalar type or a message type in
 	// order to call the appropriate unmarshalMapValue func inside
 	// unmarshalMapEntry.
-	var unmarshalMapValue func() (pref.Value, error)
+	var unmarshalMapValue func(d decoder) (pref.Value, error)
 	switch fd.MapValue().Kind() {
 	case pref.MessageKind, pref.GroupKind:
-		unmarshalMapValue = func() (pref.Value, error) {
+		unmarshalMapValue = func(d decoder) (pref.Value, error) {
 			pval := mmap.NewValue()
 			if err := d.unmarshalMessage(pval.Message(), true); err != nil {
 				return pref.Value{}, err
@@ -456,7 +635,7 @@ func (d decoder) unmarshalMap(fd pref.FieldDescriptor, mmap pref.Map) error {
 			return pval, nil
 		}
 	default:
-		unmarshalMapValue = func() (pref.Value, error) {
+		unmarshalMapValue = func(d decoder) (pref.Value, error) {
 			return d.unmarshalScalar(fd.MapValue())
 		}
 	}
@@ -494,7 +673,7 @@ func (d decoder) unmarshalMap(fd pref.FieldDescriptor, mmap pref.Map) error {
 
 // unmarshalMap unmarshals into given protoreflect.Map. A map value is a
 // textproto message containing {key: <kvalue>, value: <mvalue>}.
-func (d decoder) unmarshalMapEntry(fd pref.FieldDescriptor, mmap pref.Map, unmarshalMapValue func() (pref.Value, error)) error {
+func (d decoder) unmarshalMapEntry(fd pref.FieldDescriptor, mmap pref.Map, unmarshalMapValue func(decoder) (pref.Value, error)) error {
 	var key pref.MapKey
 	var pval pref.Value
 Loop:
@@ -508,7 +687,7 @@ Loop:
 		case text.Name:
 			if tok.NameKind() != text.IdentName {
 				if !d.opts.DiscardUnknown {
-					return d.newError(tok.Pos(), "unknown map entry field %q", tok.RawString())
+					return d.newCategoryError(protoerror.UnknownField, tok.Pos(), "unknown map entry field %q", tok.RawString())
 				}
 				d.skipValue()
 				continue Loop
@@ -543,14 +722,20 @@ Loop:
 			if pval.IsValid() {
 				return d.newError(tok.Pos(), "map entry %q cannot be repeated", name)
 			}
-			pval, err = unmarshalMapValue()
+			// The key is usually before the value, but otherwise the path
+			// has the default key.
+			pkey := key
+			if !pkey.IsValid() {
+				pkey = fd.MapKey().Default().MapKey()
+			}
+			pval, err = unmarshalMapValue(d.at(protopath.MapIndex(pkey)))
 			if err != nil {
-				return err
+				return d.withPathStep(err, protopath.MapIndex(pkey), tok.Pos())
 			}
 
 		default:
 			if !d.opts.DiscardUnknown {
-				return d.newError(tok.Pos(), "unknown map entry field %q", name)
+				return d.newCategoryError(protoerror.UnknownField, tok.Pos(), "unknown map entry field %q", name)
 			}
 			d.skipValue()
 		}
@@ -658,7 +843,7 @@ Loop:
 
 			default:
 				if !d.opts.DiscardUnknown {
-					return d.newError(tok.Pos(), "invalid field name %q in %v message", tok.RawString(), genid.Any_message_fullname)
+					return d.newCategoryError(protoerror.UnknownField, tok.Pos(), "invalid field name %q in %v message", tok.RawString(), genid.Any_message_fullname)
 				}
 			}
 
@@ -679,7 +864,7 @@ Loop:
 
 		default:
 			if !d.opts.DiscardUnknown {
-				return d.newError(tok.Pos(), "invalid field name %q in %v message", tok.RawString(), genid.Any_message_fullname)
+				return d.newCategoryError(protoerror.UnknownField, tok.Pos(), "invalid field name %q in %v message", tok.RawString(), genid.Any_message_fullname)
 			}
 		}
 	}
@@ -702,8 +887,8 @@ func (d decoder) unmarshalExpandedAny(typeURL string, pos int) ([]byte, error) {
 	// Create new message for the embedded message type and unmarshal the value
 	// field into it.
 	m := mt.New()
-	if err := d.unmarshalMessage(m, true); err != nil {
-		return nil, err
+	if err := d.at(protopath.AnyExpand(m.Descriptor())).unmarshalMessage(m, true); err != nil {
+		return nil, d.withPathStep(err, protopath.AnyExpand(m.Descriptor()), pos)
 	}
 	// Serialize the embedded message and return the resulting bytes.
 	b, err := proto.MarshalOptions{
@@ -739,7 +924,9 @@ func (d decoder) skipValue() error {
 			case text.ListClose:
 				return nil
 			case text.MessageOpen:
-				return d.skipMessageValue()
+				if err := d.skipMessageValue(); err != nil {
+					return err
+				}
 			default:
 				// Skip items. This will not validate whether skipped values are
 				// of the same type or not, same behavior as C++
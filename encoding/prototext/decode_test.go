@@ -9,6 +9,7 @@ import (
 	"strings"
 	"testing"
 
+	"google.golang.org/protobuf/encoding/protoerror"
 	"google.golang.org/protobuf/encoding/prototext"
 	"google.golang.org/protobuf/internal/flags"
 	"google.golang.org/protobuf/proto"
@@ -1698,3 +1699,250 @@ type_url: "pb2.Nested"
 		})
 	}
 }
+
+func TestUnmarshalDecodeError(t *testing.T) {
+	tests := []struct {
+		desc         string
+		inputMessage proto.Message
+		inputText    string
+		category     protoerror.Category
+		offset       int
+		line, column int
+		path         string
+	}{{
+		desc:         "syntax error",
+		inputMessage: &pb3.Scalars{},
+		inputText:    "s_int32: 1\ns_string \"a\"",
+		category:     protoerror.Syntax,
+		offset:       11,
+		line:         2,
+		column:       1,
+		path:         "(pb3.Scalars)",
+	}, {
+		desc:         "unexpected EOF in nested message",
+		inputMessage: &pb3.Nests{},
+		inputText:    `s_nested: { s_string: "a"`,
+		category:     protoerror.Syntax,
+		offset:       0,
+		line:         1,
+		column:       1,
+		path:         "(pb3.Nests).s_nested",
+	}, {
+		desc:         "unknown field",
+		inputMessage: &pb3.Nests{},
+		inputText:    "s_nested {\n  s_nested {\n    unknown: 1\n  }\n}",
+		category:     protoerror.UnknownField,
+		offset:       28,
+		line:         3,
+		column:       5,
+		path:         "(pb3.Nests).s_nested.s_nested",
+	}, {
+		desc:         "type mismatch in list",
+		inputMessage: &pb3.Repeats{},
+		inputText:    `rpt_bool: [true, 2]`,
+		category:     protoerror.TypeMismatch,
+		offset:       17,
+		line:         1,
+		column:       18,
+		path:         "(pb3.Repeats).rpt_bool[1]",
+	}, {
+		desc:         "type mismatch in map",
+		inputMessage: &pb3.Maps{},
+		inputText:    `str_to_nested { key: "k" value { s_nested { s_string: 1 } } }`,
+		category:     protoerror.TypeMismatch,
+		offset:       54,
+		line:         1,
+		column:       55,
+		path:         `(pb3.Maps).str_to_nested["k"].s_nested.s_string`,
+	}, {
+		desc:         "out of range",
+		inputMessage: &pb3.Scalars{},
+		inputText:    `s_uint32: -1`,
+		category:     protoerror.Range,
+		offset:       10,
+		line:         1,
+		column:       11,
+		path:         "(pb3.Scalars).s_uint32",
+	}, {
+		desc:         "invalid UTF-8",
+		inputMessage: &pb3.Scalars{},
+		inputText:    "s_string: \"abc\xff\"",
+		category:     protoerror.InvalidUTF8,
+		offset:       10,
+		line:         1,
+		column:       11,
+		path:         "(pb3.Scalars).s_string",
+	}, {
+		desc:         "expanded Any",
+		inputMessage: &anypb.Any{},
+		inputText:    `[type.googleapis.com/pb3.Nested] { s_string: 1 }`,
+		category:     protoerror.TypeMismatch,
+		offset:       45,
+		line:         1,
+		column:       46,
+		path:         "(google.protobuf.Any).(pb3.Nested).s_string",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			err := prototext.Unmarshal([]byte(tt.inputText), tt.inputMessage)
+			e, ok := err.(*protoerror.DecodeError)
+			if !ok {
+				t.Fatalf("Unmarshal() error = %v (%T), want *protoerror.DecodeError", err, err)
+			}
+			if e.Category != tt.category {
+				t.Errorf("Category = %v, want %v", e.Category, tt.category)
+			}
+			if e.Offset != tt.offset || e.Line != tt.line || e.Column != tt.column {
+				t.Errorf("position = %d (%d:%d), want %d (%d:%d)", e.Offset, e.Line, e.Column, tt.offset, tt.line, tt.column)
+			}
+			if got := e.Path.String(); got != tt.path {
+				t.Errorf("Path = %v, want %v", got, tt.path)
+			}
+		})
+	}
+}
+
+func TestUnmarshalAllErrors(t *testing.T) {
+	type wantError struct {
+		category protoerror.Category
+		path     string
+	}
+	tests := []struct {
+		desc          string
+		umo           prototext.UnmarshalOptions
+		inputMessage  proto.Message
+		inputText     string
+		wantMessage   proto.Message
+		wantErrors    []wantError
+		wantErrorText string
+	}{{
+		desc:         "no errors",
+		inputMessage: &pb3.Scalars{},
+		inputText:    `s_int32: 5`,
+		wantMessage:  &pb3.Scalars{SInt32: 5},
+	}, {
+		desc:         "field errors",
+		inputMessage: &pb3.Scalars{},
+		inputText: `
+s_bool: "x"
+s_int32: 5
+unknown: {a: [1, 2] b: [{}, {}]}
+s_string: "a"
+s_int32: 6
+s_uint32: -1
+s_int64 7
+`,
+		wantMessage: &pb3.Scalars{SInt32: 5, SString: "a"},
+		wantErrors: []wantError{
+			{protoerror.TypeMismatch, "(pb3.Scalars).s_bool"},
+			{protoerror.UnknownField, "(pb3.Scalars)"},
+			{protoerror.Other, "(pb3.Scalars)"},
+			{protoerror.Range, "(pb3.Scalars).s_uint32"},
+			{protoerror.Syntax, "(pb3.Scalars)"},
+		},
+		wantErrorText: "(and 4 more errors)",
+	}, {
+		desc:         "nested errors",
+		inputMessage: &pb3.Maps{},
+		inputText: `
+str_to_nested: {
+  key: "a"
+  value: {s_string: 1, s_nested: {s_string: "b"}}
+}
+str_to_nested: {
+  key: "c"
+  value: {s_nested: {unknown: true}}
+}
+uint64_to_enum: {key: 1, value: BOGUS}
+int32_to_str: {key: 1, value: "one"}
+`,
+		wantMessage: &pb3.Maps{
+			StrToNested: map[string]*pb3.Nested{
+				"a": {SNested: &pb3.Nested{SString: "b"}},
+				"c": {SNested: &pb3.Nested{}},
+			},
+			Int32ToStr: map[int32]string{1: "one"},
+		},
+		wantErrors: []wantError{
+			{protoerror.TypeMismatch, `(pb3.Maps).str_to_nested["a"].s_string`},
+			{protoerror.UnknownField, `(pb3.Maps).str_to_nested["c"].s_nested`},
+			{protoerror.TypeMismatch, `(pb3.Maps).uint64_to_enum[1]`},
+		},
+	}, {
+		desc:         "syntax error stops unmarshaling",
+		inputMessage: &pb3.Scalars{},
+		inputText:    `s_bool: "x" s_int32: 5 s_string: "a`,
+		wantMessage:  &pb3.Scalars{SInt32: 5},
+		wantErrors: []wantError{
+			{protoerror.TypeMismatch, "(pb3.Scalars).s_bool"},
+			{protoerror.Syntax, "(pb3.Scalars).s_string"},
+		},
+	}, {
+		desc:         "missing required fields",
+		inputMessage: &pb2.IndirectRequired{},
+		inputText: `
+opt_nested: {}
+rpt_nested: [{req_string: "a"}, {unknown: 1}]
+str_to_nested: [{key: "b", value: {}}, {key: "a", value: {req_string: "a"}}]
+`,
+		wantMessage: &pb2.IndirectRequired{
+			OptNested: &pb2.NestedWithRequired{},
+			RptNested: []*pb2.NestedWithRequired{
+				{ReqString: proto.String("a")},
+				{},
+			},
+			StrToNested: map[string]*pb2.NestedWithRequired{
+				"a": {ReqString: proto.String("a")},
+				"b": {},
+			},
+		},
+		wantErrors: []wantError{
+			{protoerror.UnknownField, "(pb2.IndirectRequired).rpt_nested[1]"},
+			{protoerror.RequiredField, "(pb2.IndirectRequired).opt_nested.req_string"},
+			{protoerror.RequiredField, "(pb2.IndirectRequired).rpt_nested[1].req_string"},
+			{protoerror.RequiredField, `(pb2.IndirectRequired).str_to_nested["b"].req_string`},
+		},
+	}, {
+		desc:         "missing required fields with AllowPartial",
+		umo:          prototext.UnmarshalOptions{AllowPartial: true},
+		inputMessage: &pb2.IndirectRequired{},
+		inputText:    `opt_nested: {}`,
+		wantMessage:  &pb2.IndirectRequired{OptNested: &pb2.NestedWithRequired{}},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			tt.umo.AllErrors = true
+			err := tt.umo.Unmarshal([]byte(tt.inputText), tt.inputMessage)
+			if len(tt.wantErrors) == 0 {
+				if err != nil {
+					t.Fatalf("Unmarshal() error = %v, want nil", err)
+				}
+			} else {
+				errs, ok := err.(protoerror.ErrorList)
+				if !ok {
+					t.Fatalf("Unmarshal() error = %v (%T), want protoerror.ErrorList", err, err)
+				}
+				var got []wantError
+				for _, e := range errs {
+					got = append(got, wantError{e.Category, e.Path.String()})
+				}
+				if len(got) != len(tt.wantErrors) {
+					t.Fatalf("Unmarshal() errors = %v\ngot  %v\nwant %v", errs, got, tt.wantErrors)
+				}
+				for i := range got {
+					if got[i] != tt.wantErrors[i] {
+						t.Errorf("error %d = %v (%v), want %v", i, got[i], errs[i], tt.wantErrors[i])
+					}
+				}
+				if !strings.HasSuffix(err.Error(), tt.wantErrorText) {
+					t.Errorf("Error() = %q, want suffix %q", err.Error(), tt.wantErrorText)
+				}
+			}
+			if !proto.Equal(tt.inputMessage, tt.wantMessage) {
+				t.Errorf("Unmarshal()\n<got>\n%v\n<want>\n%v\n", tt.inputMessage, tt.wantMessage)
+			}
+		})
+	}
+}
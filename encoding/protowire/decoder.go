@@ -0,0 +1,342 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protowire
+
+import (
+	"io"
+
+	"google.golang.org/protobuf/internal/errors"
+)
+
+// defaultBufferSize is the default size of the Decoder's read buffer.
+const defaultBufferSize = 4096
+
+// maxVarintLen is the maximum length of a varint-encoded uint64.
+const maxVarintLen = 10
+
+// Decoder reads wire-format tokens from an io.Reader.
+//
+// Unlike the Consume functions, which operate on a fully buffered input,
+// a Decoder holds at most a fixed-size window of the input in memory,
+// allowing arbitrarily large inputs to be scanned.
+// Length-delimited values may be skipped without being read into memory.
+//
+// The Decoder does not validate that the input is a well-formed message;
+// it only parses the tokens requested by the caller.
+type Decoder struct {
+	r   io.Reader
+	buf []byte // buffered input; unread bytes are buf[pos:]
+	pos int
+	off int64 // input offset of buf[0]
+	err error // sticky error from r
+}
+
+// NewDecoder returns a Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return NewDecoderSize(r, defaultBufferSize)
+}
+
+// NewDecoderSize returns a Decoder that reads from r and buffers
+// at most size bytes of the input at a time.
+// The buffer size is never less than the length of the longest varint.
+func NewDecoderSize(r io.Reader, size int) *Decoder {
+	if size < maxVarintLen {
+		size = maxVarintLen
+	}
+	return &Decoder{r: r, buf: make([]byte, 0, size)}
+}
+
+// InputOffset returns the offset in the input of the next unread byte.
+func (d *Decoder) InputOffset() int64 {
+	return d.off + int64(d.pos)
+}
+
+// fill attempts to buffer at least n unread bytes, where n must not exceed
+// the buffer capacity. It reports the number of unread bytes buffered,
+// which is less than n only if the underlying reader reached EOF or failed.
+func (d *Decoder) fill(n int) int {
+	if len(d.buf)-d.pos >= n {
+		return len(d.buf) - d.pos
+	}
+	// Shift unread bytes to the front of the buffer.
+	m := copy(d.buf[:cap(d.buf)], d.buf[d.pos:])
+	d.off += int64(d.pos)
+	d.buf = d.buf[:m]
+	d.pos = 0
+	for len(d.buf) < n && d.err == nil {
+		k, err := d.r.Read(d.buf[len(d.buf):cap(d.buf)])
+		d.buf = d.buf[:len(d.buf)+k]
+		if err != nil {
+			d.err = err
+		} else if k == 0 {
+			d.err = io.ErrNoProgress
+		}
+	}
+	return len(d.buf)
+}
+
+// truncated returns the error to report when the input ended prematurely.
+func (d *Decoder) truncated() error {
+	if d.err != nil && d.err != io.EOF {
+		return d.err
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// ReadTag reads a varint-encoded tag.
+// It returns io.EOF only if the input ended before any byte of the tag was read,
+// which is the normal way for a message to end.
+func (d *Decoder) ReadTag() (Number, Type, error) {
+	if d.fill(1) == 0 {
+		if d.err == nil || d.err == io.EOF {
+			return 0, 0, io.EOF
+		}
+		return 0, 0, d.err
+	}
+	v, err := d.ReadVarint()
+	if err != nil {
+		return 0, 0, err
+	}
+	num, typ := DecodeTag(v)
+	if num < MinValidNumber {
+		return 0, 0, errFieldNumber
+	}
+	return num, typ, nil
+}
+
+// ReadVarint reads a varint-encoded uint64.
+func (d *Decoder) ReadVarint() (uint64, error) {
+	n := d.fill(maxVarintLen)
+	v, m := ConsumeVarint(d.buf[d.pos : d.pos+n])
+	if m < 0 {
+		if m == errCodeTruncated {
+			return 0, d.truncated()
+		}
+		return 0, ParseError(m)
+	}
+	d.pos += m
+	return v, nil
+}
+
+// ReadFixed32 reads a 32-bit little-endian uint32.
+func (d *Decoder) ReadFixed32() (uint32, error) {
+	if d.fill(4) < 4 {
+		return 0, d.truncated()
+	}
+	v, m := ConsumeFixed32(d.buf[d.pos:])
+	d.pos += m
+	return v, nil
+}
+
+// ReadFixed64 reads a 64-bit little-endian uint64.
+func (d *Decoder) ReadFixed64() (uint64, error) {
+	if d.fill(8) < 8 {
+		return 0, d.truncated()
+	}
+	v, m := ConsumeFixed64(d.buf[d.pos:])
+	d.pos += m
+	return v, nil
+}
+
+// ReadLength reads the varint-encoded length prefix of a length-delimited value.
+// The caller is responsible for consuming exactly that many bytes afterwards,
+// either with Read, with Skip, or by decoding the value as a nested message.
+func (d *Decoder) ReadLength() (int64, error) {
+	v, err := d.ReadVarint()
+	if err != nil {
+		return 0, err
+	}
+	if int64(v) < 0 {
+		return 0, errors.New("length-delimited value too large")
+	}
+	return int64(v), nil
+}
+
+// ReadBytes reads a length-prefixed bytes value into a newly allocated slice.
+// It returns an error without allocating if the length exceeds max.
+// If max is negative, no limit is applied; memory is then allocated only
+// as the bytes of the value are read, so a length prefix larger than
+// the input results in io.ErrUnexpectedEOF.
+func (d *Decoder) ReadBytes(max int) ([]byte, error) {
+	n, err := d.ReadLength()
+	if err != nil {
+		return nil, err
+	}
+	if max >= 0 && n > int64(max) {
+		return nil, errors.New("length-delimited value of %d bytes exceeds limit of %d bytes", n, max)
+	}
+	// The length comes from the input, so the slice is grown as the bytes
+	// are read rather than allocated up front. A hostile length then fails
+	// with a truncation error instead of a huge allocation.
+	b := make([]byte, 0, minLength(n, readBytesChunkSize))
+	for int64(len(b)) < n {
+		if len(b) == cap(b) {
+			b = append(b, make([]byte, minLength(n-int64(len(b)), int64(cap(b))))...)[:len(b)]
+		}
+		k, err := d.Read(b[len(b):minLength(n, int64(cap(b)))])
+		b = b[:len(b)+k]
+		if err != nil && int64(len(b)) < n {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// readBytesChunkSize is the initial capacity of the slice read by ReadBytes.
+const readBytesChunkSize = 64 << 10
+
+// minLength returns the lesser of a length and a limit, as an int.
+func minLength(n, limit int64) int {
+	if n < limit {
+		return int(n)
+	}
+	return int(limit)
+}
+
+// Read reads raw bytes from the input, implementing io.Reader.
+// It is typically used to stream the contents of a length-delimited value
+// after calling ReadLength, for example through an io.LimitedReader.
+func (d *Decoder) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if d.pos == len(d.buf) {
+		if d.err != nil {
+			return 0, d.err
+		}
+		if len(p) >= cap(d.buf) {
+			// Large reads bypass the buffer.
+			d.off += int64(len(d.buf))
+			d.buf = d.buf[:0]
+			d.pos = 0
+			n, err := d.r.Read(p)
+			d.off += int64(n)
+			d.err = err
+			return n, err
+		}
+		if d.fill(1) == 0 {
+			return 0, d.err
+		}
+	}
+	n := copy(p, d.buf[d.pos:])
+	d.pos += n
+	return n, nil
+}
+
+// Skip discards the next n bytes of the input.
+// If the underlying reader implements io.Seeker, Skip seeks past
+// any bytes that are not already buffered.
+func (d *Decoder) Skip(n int64) error {
+	if n < 0 {
+		return errors.New("invalid skip length %d", n)
+	}
+	if k := int64(len(d.buf) - d.pos); n <= k {
+		d.pos += int(n)
+		return nil
+	}
+	n -= int64(len(d.buf) - d.pos)
+	d.off += int64(len(d.buf))
+	d.buf = d.buf[:0]
+	d.pos = 0
+	if s, ok := d.r.(io.Seeker); ok && d.err == nil {
+		if ok, err := d.seek(s, n); ok {
+			return err
+		}
+	}
+	for n > 0 {
+		k := d.fill(cap(d.buf))
+		if k == 0 {
+			return d.truncated()
+		}
+		if int64(k) > n {
+			d.pos = int(n)
+			return nil
+		}
+		n -= int64(k)
+		d.off += int64(k)
+		d.buf = d.buf[:0]
+	}
+	return nil
+}
+
+// seek advances s by n bytes. It reports false if s cannot seek,
+// in which case the position of s is unchanged.
+func (d *Decoder) seek(s io.Seeker, n int64) (bool, error) {
+	cur, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return false, nil
+	}
+	end, err := s.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false, nil
+	}
+	if end-cur < n {
+		d.off += end - cur
+		d.err = io.EOF
+		return true, io.ErrUnexpectedEOF
+	}
+	if _, err := s.Seek(cur+n, io.SeekStart); err != nil {
+		d.err = err
+		return true, err
+	}
+	d.off += n
+	return true, nil
+}
+
+// SkipFieldValue discards a field value of the given wire type.
+// This assumes that the field Number and wire Type have already been read.
+//
+// When skipping a group, the end group marker is consumed and
+// verified to match the starting field number.
+func (d *Decoder) SkipFieldValue(num Number, typ Type) error {
+	return d.skipFieldValueD(num, typ, DefaultRecursionLimit)
+}
+
+func (d *Decoder) skipFieldValueD(num Number, typ Type, depth int) error {
+	switch typ {
+	case VarintType:
+		_, err := d.ReadVarint()
+		return err
+	case Fixed32Type:
+		return d.Skip(4)
+	case Fixed64Type:
+		return d.Skip(8)
+	case BytesType:
+		n, err := d.ReadLength()
+		if err != nil {
+			return err
+		}
+		return d.Skip(n)
+	case StartGroupType:
+		if depth < 0 {
+			return errors.New("exceeded max recursion depth")
+		}
+		for {
+			num2, typ2, err := d.ReadTag()
+			if err == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return err
+			}
+			if typ2 == EndGroupType {
+				if num != num2 {
+					return errEndGroup
+				}
+				return nil
+			}
+			if err := d.skipFieldValueD(num2, typ2, depth-1); err != nil {
+				return err
+			}
+		}
+	case EndGroupType:
+		return errEndGroup
+	default:
+		return errReserved
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protowire
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecoder(t *testing.T) {
+	var b []byte
+	b = AppendTag(b, 1, VarintType)
+	b = AppendVarint(b, 150)
+	b = AppendTag(b, 2, Fixed32Type)
+	b = AppendFixed32(b, 0xdeadbeef)
+	b = AppendTag(b, 3, Fixed64Type)
+	b = AppendFixed64(b, 0x0123456789abcdef)
+	b = AppendTag(b, 4, BytesType)
+	b = AppendBytes(b, []byte("hello"))
+	b = AppendTag(b, 5, BytesType)
+	b = AppendBytes(b, bytes.Repeat([]byte{'x'}, 1000))
+	b = AppendTag(b, 6, StartGroupType)
+	b = AppendTag(b, 1, BytesType)
+	b = AppendBytes(b, []byte("nested"))
+	b = AppendTag(b, 6, EndGroupType)
+	b = AppendTag(b, 7, VarintType)
+	b = AppendVarint(b, 7)
+
+	readers := map[string]func() io.Reader{
+		"bytes.Reader":   func() io.Reader { return bytes.NewReader(b) },
+		"one byte":       func() io.Reader { return iotest.OneByteReader(bytes.NewReader(b)) },
+		"half reader":    func() io.Reader { return iotest.HalfReader(bytes.NewReader(b)) },
+		"strings.Reader": func() io.Reader { return strings.NewReader(string(b)) },
+	}
+	for name, newReader := range readers {
+		t.Run(name, func(t *testing.T) {
+			d := NewDecoderSize(newReader(), 16)
+			wantTag := func(num Number, typ Type) {
+				t.Helper()
+				gotNum, gotTyp, err := d.ReadTag()
+				if err != nil || gotNum != num || gotTyp != typ {
+					t.Fatalf("ReadTag() = (%v, %v, %v), want (%v, %v, nil)", gotNum, gotTyp, err, num, typ)
+				}
+			}
+
+			wantTag(1, VarintType)
+			if v, err := d.ReadVarint(); err != nil || v != 150 {
+				t.Fatalf("ReadVarint() = (%v, %v), want (150, nil)", v, err)
+			}
+			wantTag(2, Fixed32Type)
+			if v, err := d.ReadFixed32(); err != nil || v != 0xdeadbeef {
+				t.Fatalf("ReadFixed32() = (%#x, %v), want (0xdeadbeef, nil)", v, err)
+			}
+			wantTag(3, Fixed64Type)
+			if v, err := d.ReadFixed64(); err != nil || v != 0x0123456789abcdef {
+				t.Fatalf("ReadFixed64() = (%#x, %v), want (0x0123456789abcdef, nil)", v, err)
+			}
+			wantTag(4, BytesType)
+			if v, err := d.ReadBytes(-1); err != nil || string(v) != "hello" {
+				t.Fatalf("ReadBytes() = (%q, %v), want (hello, nil)", v, err)
+			}
+			wantTag(5, BytesType)
+			start := d.InputOffset()
+			if err := d.SkipFieldValue(5, BytesType); err != nil {
+				t.Fatalf("SkipFieldValue() error: %v", err)
+			}
+			if got, want := d.InputOffset()-start, int64(SizeBytes(1000)); got != want {
+				t.Fatalf("SkipFieldValue() skipped %d bytes, want %d", got, want)
+			}
+			wantTag(6, StartGroupType)
+			if err := d.SkipFieldValue(6, StartGroupType); err != nil {
+				t.Fatalf("SkipFieldValue(group) error: %v", err)
+			}
+			wantTag(7, VarintType)
+			if v, err := d.ReadVarint(); err != nil || v != 7 {
+				t.Fatalf("ReadVarint() = (%v, %v), want (7, nil)", v, err)
+			}
+			if got, want := d.InputOffset(), int64(len(b)); got != want {
+				t.Fatalf("InputOffset() = %d, want %d", got, want)
+			}
+			if _, _, err := d.ReadTag(); err != io.EOF {
+				t.Fatalf("ReadTag() at end = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestDecoderStreamBytes(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789"), 100)
+	b := AppendBytes(nil, payload)
+	b = AppendVarint(b, 42)
+
+	d := NewDecoderSize(bytes.NewReader(b), 16)
+	n, err := d.ReadLength()
+	if err != nil || n != int64(len(payload)) {
+		t.Fatalf("ReadLength() = (%v, %v), want (%v, nil)", n, err, len(payload))
+	}
+	var got bytes.Buffer
+	if _, err := io.Copy(&got, io.LimitReader(d, n)); err != nil {
+		t.Fatalf("io.Copy() error: %v", err)
+	}
+	if !bytes.Equal(got.Bytes(), payload) {
+		t.Fatalf("streamed payload mismatch")
+	}
+	if v, err := d.ReadVarint(); err != nil || v != 42 {
+		t.Fatalf("ReadVarint() = (%v, %v), want (42, nil)", v, err)
+	}
+	if got, want := d.InputOffset(), int64(len(b)); got != want {
+		t.Fatalf("InputOffset() = %d, want %d", got, want)
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		in      []byte
+		read    func(d *Decoder) error
+		wantErr error
+	}{{
+		desc:    "truncated varint",
+		in:      []byte{0x80},
+		read:    func(d *Decoder) error { _, err := d.ReadVarint(); return err },
+		wantErr: io.ErrUnexpectedEOF,
+	}, {
+		desc:    "overflow varint",
+		in:      bytes.Repeat([]byte{0xff}, 11),
+		read:    func(d *Decoder) error { _, err := d.ReadVarint(); return err },
+		wantErr: errOverflow,
+	}, {
+		desc:    "truncated fixed32",
+		in:      []byte{1, 2, 3},
+		read:    func(d *Decoder) error { _, err := d.ReadFixed32(); return err },
+		wantErr: io.ErrUnexpectedEOF,
+	}, {
+		desc:    "truncated fixed64",
+		in:      []byte{1, 2, 3, 4, 5, 6, 7},
+		read:    func(d *Decoder) error { _, err := d.ReadFixed64(); return err },
+		wantErr: io.ErrUnexpectedEOF,
+	}, {
+		desc:    "truncated bytes",
+		in:      AppendVarint(nil, 100),
+		read:    func(d *Decoder) error { _, err := d.ReadBytes(-1); return err },
+		wantErr: io.ErrUnexpectedEOF,
+	}, {
+		desc:    "truncated bytes with huge length",
+		in:      append(AppendVarint(nil, 1<<62), "short"...),
+		read:    func(d *Decoder) error { _, err := d.ReadBytes(-1); return err },
+		wantErr: io.ErrUnexpectedEOF,
+	}, {
+		desc:    "truncated skip",
+		in:      append(AppendTag(nil, 1, BytesType), AppendVarint(nil, 100)...),
+		read:    skipField,
+		wantErr: io.ErrUnexpectedEOF,
+	}, {
+		desc:    "invalid field number",
+		in:      AppendVarint(nil, EncodeTag(0, VarintType)),
+		read:    func(d *Decoder) error { _, _, err := d.ReadTag(); return err },
+		wantErr: errFieldNumber,
+	}, {
+		desc: "mismatched end group",
+		in: func() []byte {
+			b := AppendTag(nil, 1, StartGroupType)
+			return AppendTag(b, 2, EndGroupType)
+		}(),
+		read:    skipField,
+		wantErr: errEndGroup,
+	}, {
+		desc:    "unterminated group",
+		in:      AppendTag(nil, 1, StartGroupType),
+		read:    skipField,
+		wantErr: io.ErrUnexpectedEOF,
+	}, {
+		desc:    "reserved wire type",
+		in:      AppendVarint(nil, EncodeTag(1, 6)),
+		read:    skipField,
+		wantErr: errReserved,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			// Hide any io.Seeker implementation to exercise the buffered path.
+			d := NewDecoder(struct{ io.Reader }{bytes.NewReader(tt.in)})
+			if err := tt.read(d); err != tt.wantErr {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+			d = NewDecoder(bytes.NewReader(tt.in))
+			if err := tt.read(d); err != tt.wantErr {
+				t.Errorf("with io.Seeker: got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	d := NewDecoder(bytes.NewReader(AppendVarint(nil, 10)))
+	if _, err := d.ReadBytes(5); err == nil {
+		t.Errorf("ReadBytes() over limit: got nil error")
+	}
+
+	// Values larger than the initial allocation are read in full.
+	large := bytes.Repeat([]byte("x"), 3*readBytesChunkSize+1)
+	d = NewDecoder(bytes.NewReader(AppendBytes(nil, large)))
+	if v, err := d.ReadBytes(-1); err != nil || !bytes.Equal(v, large) {
+		t.Errorf("ReadBytes() of %d bytes = (%d bytes, %v), want (%d bytes, nil)", len(large), len(v), err, len(large))
+	}
+}
+
+func skipField(d *Decoder) error {
+	num, typ, err := d.ReadTag()
+	if err != nil {
+		return err
+	}
+	return d.SkipFieldValue(num, typ)
+}
@@ -0,0 +1,265 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package textast
+
+import (
+	"strings"
+
+	"google.golang.org/protobuf/internal/encoding/text"
+	"google.golang.org/protobuf/internal/errors"
+)
+
+// Lookup returns the field named by path, or nil if there is none.
+//
+// A path consists of field names separated by '.', each naming a field of
+// the message which is the value of the field named by the previous name.
+// The names are written as in the text format, such as "server.port" or
+// "[pkg.ext].port". At every step, the path goes through the first field
+// with the name.
+func (d *Document) Lookup(path string) *Field {
+	names, err := splitPath(path)
+	if err != nil {
+		return nil
+	}
+	fields := d.Fields
+	for i, name := range names {
+		f := findField(fields, name)
+		if f == nil || i == len(names)-1 {
+			return f
+		}
+		if f.Value.Kind != Message {
+			return nil
+		}
+		fields = f.Value.Fields
+	}
+	return nil
+}
+
+// Set sets the value of the field named by path to the scalar value written
+// as s, such as "8080", "true" or a string quoted by Quote. If there is no
+// such field, Set adds it, along with any message fields leading to it,
+// after the last field of the message which contains it.
+func (d *Document) Set(path, s string) error {
+	names, err := splitPath(path)
+	if err != nil {
+		return err
+	}
+	if !isScalar(s) {
+		return errors.New("invalid scalar value: %q", s)
+	}
+
+	c := d.container()
+	for i, name := range names {
+		f := findField(*c.fields, name)
+		if i == len(names)-1 {
+			if f == nil {
+				f = c.add(name)
+			}
+			if !strings.Contains(f.Sep, ":") {
+				f.Sep = ": "
+			}
+			f.Value = &Value{Kind: Scalar, Text: s}
+			return nil
+		}
+
+		if f == nil {
+			f = c.add(name)
+			f.Sep = " "
+			f.Value = &Value{Kind: Message, Text: "{", Start: " "}
+			if c.multiline {
+				f.Value.Start, f.Value.End = "\n", c.indent
+			}
+		}
+		if f.Value.Kind != Message {
+			return errors.New("field %v is not a message", strings.Join(names[:i+1], "."))
+		}
+		c = f.Value.container(indentOf(f))
+	}
+	return nil
+}
+
+// Delete deletes the fields named by path, and reports whether there were any.
+// Only the last name of the path may name more than one field.
+// The comments above a deleted field are deleted with it, except for those
+// separated from it by a blank line.
+func (d *Document) Delete(path string) bool {
+	names, err := splitPath(path)
+	if err != nil {
+		return false
+	}
+	c := d.container()
+	for _, name := range names[:len(names)-1] {
+		f := findField(*c.fields, name)
+		if f == nil || f.Value.Kind != Message {
+			return false
+		}
+		c = f.Value.container(indentOf(f))
+	}
+
+	var deleted bool
+	fields := *c.fields
+	for i := 0; i < len(fields); {
+		f := fields[i]
+		if fieldKey(f.Name) != names[len(names)-1] {
+			i++
+			continue
+		}
+		deleted = true
+		// The text before a field starts at the start of a line,
+		// unless it is the first field of a message.
+		if j := strings.LastIndex("\n"+f.Before, "\n\n"); j >= 0 {
+			kept := f.Before[:j+1]
+			if i+1 < len(fields) {
+				fields[i+1].Before = kept + fields[i+1].Before
+			} else {
+				*c.end = kept + *c.end
+			}
+		}
+		fields = append(fields[:i], fields[i+1:]...)
+	}
+	*c.fields = fields
+	return deleted
+}
+
+// Quote returns s quoted as a string value in the text format.
+func Quote(s string) string {
+	return string(text.AppendString(nil, s))
+}
+
+// container is a list of fields of the document or of a message value,
+// to which fields can be added.
+type container struct {
+	fields *[]*Field
+	start  *string // nil for the document
+	end    *string
+
+	// multiline reports whether the fields are on separate lines,
+	// and indent is the indentation of fields on separate lines.
+	multiline bool
+	indent    string
+}
+
+func (d *Document) container() container {
+	return container{fields: &d.Fields, end: &d.End, multiline: true}
+}
+
+// container returns the fields of v, which is the value of a field
+// with the given indentation.
+func (v *Value) container(indent string) container {
+	c := container{fields: &v.Fields, start: &v.Start, end: &v.End}
+	c.multiline = strings.Contains(v.Start, "\n") || strings.Contains(v.End, "\n")
+	for _, f := range v.Fields {
+		c.multiline = c.multiline || strings.Contains(f.Before, "\n") || strings.Contains(f.After, "\n")
+	}
+	c.indent = indent + "  "
+	if n := len(v.Fields); n > 0 {
+		c.indent = indentOf(v.Fields[n-1])
+	}
+	return c
+}
+
+// add adds a field with the given name after the last field,
+// and returns it for the caller to set its separator and value.
+func (c container) add(name string) *Field {
+	f := &Field{Name: name}
+	fields := *c.fields
+	n := len(fields)
+	if c.multiline {
+		f.Before, f.After = c.indent, "\n"
+		switch {
+		case n > 0 && !strings.HasSuffix(fields[n-1].After, "\n"):
+			fields[n-1].After += "\n"
+		case n == 0 && c.start == nil:
+			// Keep the comments in a document without fields above the field.
+			f.Before, *c.end = *c.end+f.Before, ""
+		}
+	} else {
+		f.After = " "
+		switch {
+		case n > 0:
+			// Keep the text before the end of the message after the field.
+			f.After = fields[n-1].After
+			if strings.TrimSpace(f.After) == "" {
+				fields[n-1].After = " "
+			}
+		case *c.start == "":
+			*c.start = " "
+		}
+	}
+	*c.fields = append(fields, f)
+	return f
+}
+
+// indentOf returns the indentation of the field f, which is the whitespace
+// before its name on its line.
+func indentOf(f *Field) string {
+	s := f.Before[strings.LastIndexByte(f.Before, '\n')+1:]
+	if strings.Trim(s, " \t") != "" {
+		return ""
+	}
+	return s
+}
+
+// findField returns the first of fields with the given name.
+func findField(fields []*Field, name string) *Field {
+	for _, f := range fields {
+		if fieldKey(f.Name) == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// fieldKey returns the name of a field as used in paths, in which extension
+// and type names have no whitespace within the brackets.
+func fieldKey(name string) string {
+	if !strings.HasPrefix(name, "[") {
+		return name
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\n', '\r', '\t':
+			return -1
+		}
+		return r
+	}, name)
+}
+
+// splitPath splits path into field names.
+func splitPath(path string) ([]string, error) {
+	var names []string
+	for s := path; s != ""; {
+		i := strings.IndexByte(s, '.')
+		if strings.HasPrefix(s, "[") {
+			i = strings.IndexByte(s, ']') + 1
+			if i == 0 || (i < len(s) && s[i] != '.') {
+				return nil, errors.New("invalid field path: %q", path)
+			}
+		}
+		if i < 0 || i == len(s) {
+			return append(names, fieldKey(s)), nil
+		}
+		if i == 0 || i == len(s)-1 {
+			return nil, errors.New("invalid field path: %q", path)
+		}
+		names = append(names, fieldKey(s[:i]))
+		s = s[i+1:]
+	}
+	return nil, errors.New("invalid field path: %q", path)
+}
+
+// isScalar reports whether s is a single scalar value.
+func isScalar(s string) bool {
+	dec := text.NewDecoder([]byte("x:" + s))
+	if _, err := dec.Read(); err != nil {
+		return false
+	}
+	tok, err := dec.Read()
+	if err != nil || tok.Kind() != text.Scalar || tok.RawString() != s {
+		return false
+	}
+	tok, err = dec.Read()
+	return err == nil && tok.Kind() == text.EOF
+}
@@ -0,0 +1,324 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package textast parses documents in the protocol buffer text format into
+// a syntax tree which preserves comments and layout, so that programs can
+// edit a document and write it back with minimal changes.
+//
+// The syntax tree does not depend on the schema of the message:
+// field names and values are kept as they are written.
+// Use the prototext package to unmarshal a document into a message.
+//
+// For example, to change the port of a server in a configuration file:
+//
+//	doc, err := textast.Parse(b)
+//	if err != nil {
+//		return err
+//	}
+//	if err := doc.Set("server.port", "8080"); err != nil {
+//		return err
+//	}
+//	b = doc.Format()
+package textast
+
+import (
+	"google.golang.org/protobuf/internal/encoding/text"
+)
+
+// Document is a document in the text format.
+//
+// Formatting a document which was parsed and not modified reproduces the
+// original text exactly.
+type Document struct {
+	// Fields are the fields of the top-level message.
+	Fields []*Field
+
+	// End is the text after the last field, such as comments at the end
+	// of the document.
+	End string
+}
+
+// Field is a field of a message, with its name and value.
+type Field struct {
+	// Before is the text before the name, such as indentation and comments
+	// on the lines above the field.
+	Before string
+
+	// Name is the name of the field as written, such as "port", "[pkg.ext]",
+	// "[type.googleapis.com/pkg.Message]" or "1".
+	Name string
+
+	// Sep is the text between the name and the value,
+	// such as ": ", or " " before a message value.
+	Sep string
+
+	// Value is the value of the field.
+	Value *Value
+
+	// After is the text after the value up to the end of its line,
+	// including any delimiter, such as ",", and comment.
+	After string
+}
+
+// Element is an element of a list.
+type Element struct {
+	// Before is the text before the value, such as indentation and comments
+	// on the lines above the element.
+	Before string
+
+	// Value is the value of the element.
+	Value *Value
+
+	// After is the text after the value up to the end of its line,
+	// including any delimiter, such as ",", and comment.
+	After string
+}
+
+// Kind is the kind of a value.
+type Kind int
+
+const (
+	// Scalar is the kind of scalar values, such as numbers, strings and
+	// enum value names.
+	Scalar Kind = iota
+	// Message is the kind of message values, delimited by '{' and '}'
+	// or '<' and '>'.
+	Message
+	// List is the kind of list values, delimited by '[' and ']'.
+	List
+)
+
+// String returns the name of k.
+func (k Kind) String() string {
+	switch k {
+	case Scalar:
+		return "scalar"
+	case Message:
+		return "message"
+	case List:
+		return "list"
+	default:
+		return "<unknown>"
+	}
+}
+
+// Value is the value of a field or of a list element.
+type Value struct {
+	// Kind is the kind of value.
+	Kind Kind
+
+	// Text is the text of a scalar value as written, such as `8080` or
+	// `"a" "b"`, or the opening delimiter of a message or list value.
+	Text string
+
+	// Start is the text after the opening delimiter of a message or list
+	// up to the end of its line.
+	Start string
+
+	// Fields are the fields of a message value.
+	Fields []*Field
+
+	// Elements are the elements of a list value.
+	Elements []*Element
+
+	// End is the text before the closing delimiter of a message or list,
+	// after the end of the line of its last field or element.
+	End string
+}
+
+// Parse parses the document in b.
+func Parse(b []byte) (*Document, error) {
+	p := parser{in: b, dec: text.NewDecoder(b)}
+	fields, end, err := p.parseFields(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Document{Fields: fields, End: end}, nil
+}
+
+type parser struct {
+	in  []byte
+	dec *text.Decoder
+
+	// last is the offset of the end of the previous token.
+	last int
+}
+
+// gap returns the text between the previous token and tok,
+// and advances past tok.
+func (p *parser) gap(tok text.Token) string {
+	s := string(p.in[p.last:tok.Pos()])
+	p.last = tok.Pos() + len(tok.RawString())
+	return s
+}
+
+// parseFields parses fields up to the end of the document or of the message
+// and returns them along with the text before the end. For a message,
+// start is set to the text after its opening delimiter.
+func (p *parser) parseFields(start *string) ([]*Field, string, error) {
+	var fields []*Field
+	for {
+		tok, err := p.dec.Read()
+		if err != nil {
+			return nil, "", err
+		}
+		before := p.gap(tok)
+		if n := len(fields); n > 0 {
+			fields[n-1].After, before = splitLine(before)
+		} else if start != nil {
+			*start, before = splitLine(before)
+		}
+		if tok.Kind() != text.Name {
+			// The decoder only permits the end of the document or message.
+			return fields, before, nil
+		}
+
+		f := &Field{Before: before, Name: tok.RawString()}
+		tok, err = p.dec.Read()
+		if err != nil {
+			return nil, "", err
+		}
+		f.Sep = p.gap(tok)
+		if f.Value, err = p.parseValue(tok); err != nil {
+			return nil, "", err
+		}
+		fields = append(fields, f)
+	}
+}
+
+// parseValue parses the value starting with tok.
+func (p *parser) parseValue(tok text.Token) (*Value, error) {
+	v := &Value{Text: tok.RawString()}
+	switch tok.Kind() {
+	case text.MessageOpen:
+		v.Kind = Message
+		var err error
+		if v.Fields, v.End, err = p.parseFields(&v.Start); err != nil {
+			return nil, err
+		}
+	case text.ListOpen:
+		v.Kind = List
+		for {
+			tok, err := p.dec.Read()
+			if err != nil {
+				return nil, err
+			}
+			before := p.gap(tok)
+			if n := len(v.Elements); n > 0 {
+				v.Elements[n-1].After, before = splitLine(before)
+			} else {
+				v.Start, before = splitLine(before)
+			}
+			if tok.Kind() == text.ListClose {
+				v.End = before
+				break
+			}
+			e := &Element{Before: before}
+			if e.Value, err = p.parseValue(tok); err != nil {
+				return nil, err
+			}
+			v.Elements = append(v.Elements, e)
+		}
+	}
+	return v, nil
+}
+
+// splitLine splits the text s between two tokens after the end of the line
+// of the first token. A delimiter after the first token is always part of
+// its line, even if it is not on the same line.
+func splitLine(s string) (line, rest string) {
+	i := indexDelim(s)
+	if i < 0 {
+		i = 0
+	}
+	for ; i < len(s); i++ {
+		if s[i] == '\n' {
+			return s[:i+1], s[i+1:]
+		}
+	}
+	return s, ""
+}
+
+// indexDelim returns the index of the first delimiter, ',' or ';', in the
+// text s between two tokens, or -1 if there is none.
+func indexDelim(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case ',', ';':
+			return i
+		case '#':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		}
+	}
+	return -1
+}
+
+// Format returns the text of the document.
+func (d *Document) Format() []byte {
+	b := appendFields(nil, d.Fields)
+	return append(b, d.End...)
+}
+
+func appendFields(b []byte, fields []*Field) []byte {
+	for _, f := range fields {
+		b = append(b, f.Before...)
+		b = append(b, f.Name...)
+		b = append(b, f.Sep...)
+		b = f.Value.appendText(b)
+		b = append(b, f.After...)
+	}
+	return b
+}
+
+func (v *Value) appendText(b []byte) []byte {
+	b = append(b, v.Text...)
+	switch v.Kind {
+	case Message:
+		b = append(b, v.Start...)
+		b = appendFields(b, v.Fields)
+		b = append(b, v.End...)
+		if v.Text == "<" {
+			return append(b, '>')
+		}
+		return append(b, '}')
+	case List:
+		b = append(b, v.Start...)
+		for _, e := range v.Elements {
+			b = append(b, e.Before...)
+			b = e.Value.appendText(b)
+			// Elements must be separated by commas, and the last element
+			// must not be followed by one, which may no longer hold after
+			// editing the list.
+			after := e.After
+			switch i := indexDelim(after); {
+			case i < 0 && e != v.Elements[len(v.Elements)-1]:
+				b = append(b, ',')
+				if after == "" {
+					after = " "
+				}
+			case i >= 0 && e == v.Elements[len(v.Elements)-1]:
+				after = after[:i] + trimSpace(after[i+1:])
+			}
+			b = append(b, after...)
+		}
+		b = append(b, v.End...)
+		return append(b, ']')
+	}
+	return b
+}
+
+// trimSpace removes leading spaces and tabs from s, keeping one before
+// a comment.
+func trimSpace(s string) string {
+	i := 0
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	if i > 0 && i < len(s) && s[i] == '#' {
+		i--
+	}
+	return s[i:]
+}
@@ -0,0 +1,278 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package textast_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"google.golang.org/protobuf/encoding/textast"
+)
+
+const config = `# Server configuration.
+
+server {
+  host: "localhost"  # The host name.
+  port: 80
+  tags: ["a", "b",
+         "c"]  # Tags.
+
+  # The backends.
+  backend <name: 'x' weight: 1>, backend { name: "y" "z" };
+  [pkg.ext]: 1
+}
+
+# Trailing comment.
+`
+
+func TestRoundTrip(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"\n\n",
+		"# Only a comment",
+		config,
+		"a:1 b :2;c: 3,d{}e<>f:[]g [{},< >]",
+		"a: [1 , 2 ,\n# c\n3]\n",
+		"[ type.googleapis.com / pkg.Message ] { x: -inf }",
+		"1: 2\n",
+	} {
+		doc, err := textast.Parse([]byte(in))
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", in, err)
+			continue
+		}
+		if got := string(doc.Format()); got != in {
+			t.Errorf("Parse(%q).Format() = %q", in, got)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, in := range []string{
+		"a: 1 }",
+		"a { b: 1",
+		"a: [1,]",
+		`a: "abc`,
+	} {
+		if _, err := textast.Parse([]byte(in)); err == nil {
+			t.Errorf("Parse(%q) got nil error, want error", in)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	doc, err := textast.Parse([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := doc.Lookup("server")
+	if server == nil {
+		t.Fatal(`Lookup("server") = nil`)
+	}
+	if got, want := server.Before, "# Server configuration.\n\n"; got != want {
+		t.Errorf("server.Before = %q, want %q", got, want)
+	}
+	for _, tt := range []struct {
+		path, before, text, after string
+	}{
+		{"server.host", "  ", `"localhost"`, "  # The host name.\n"},
+		{"server.port", "  ", "80", "\n"},
+		{"server.backend.name", "", "'x'", " "},
+		{"server.[ pkg.ext ]", "  ", "1", "\n"},
+	} {
+		f := doc.Lookup(tt.path)
+		if f == nil {
+			t.Errorf("Lookup(%q) = nil", tt.path)
+			continue
+		}
+		if f.Before != tt.before || f.Value.Text != tt.text || f.After != tt.after {
+			t.Errorf("Lookup(%q) = (%q, %q, %q), want (%q, %q, %q)", tt.path, f.Before, f.Value.Text, f.After, tt.before, tt.text, tt.after)
+		}
+	}
+	tags := doc.Lookup("server.tags").Value
+	if tags.Kind != textast.List || len(tags.Elements) != 3 {
+		t.Fatalf("server.tags = %v value with %d elements, want list with 3", tags.Kind, len(tags.Elements))
+	}
+	if got, want := tags.Elements[1].After, ",\n"; got != want {
+		t.Errorf("server.tags[1].After = %q, want %q", got, want)
+	}
+	if f := doc.Lookup("server.missing"); f != nil {
+		t.Errorf(`Lookup("server.missing") = %v, want nil`, f)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	tests := []struct {
+		desc string
+		in   string
+		edit func(*textast.Document) error
+		want string
+	}{{
+		desc: "set existing field",
+		in:   config,
+		edit: func(d *textast.Document) error {
+			return d.Set("server.port", "8080")
+		},
+		want: `# Server configuration.
+
+server {
+  host: "localhost"  # The host name.
+  port: 8080
+  tags: ["a", "b",
+         "c"]  # Tags.
+
+  # The backends.
+  backend <name: 'x' weight: 1>, backend { name: "y" "z" };
+  [pkg.ext]: 1
+}
+
+# Trailing comment.
+`,
+	}, {
+		desc: "add fields",
+		in:   config,
+		edit: func(d *textast.Document) error {
+			if err := d.Set("server.backend.port", "81"); err != nil {
+				return err
+			}
+			if err := d.Set("server.limits.max_conns", "10"); err != nil {
+				return err
+			}
+			return d.Set("client.name", textast.Quote("c\n"))
+		},
+		want: `# Server configuration.
+
+server {
+  host: "localhost"  # The host name.
+  port: 80
+  tags: ["a", "b",
+         "c"]  # Tags.
+
+  # The backends.
+  backend <name: 'x' weight: 1 port: 81>, backend { name: "y" "z" };
+  [pkg.ext]: 1
+  limits {
+    max_conns: 10
+  }
+}
+client {
+  name: "c\n"
+}
+
+# Trailing comment.
+`,
+	}, {
+		desc: "add to single-line messages",
+		in:   "a {} b { c: 1}",
+		edit: func(d *textast.Document) error {
+			if err := d.Set("a.x", "1"); err != nil {
+				return err
+			}
+			return d.Set("b.d.e", "2")
+		},
+		want: "a { x: 1 } b { c: 1 d { e: 2 }}",
+	}, {
+		desc: "add to document without fields",
+		in:   "# Comment.\n",
+		edit: func(d *textast.Document) error {
+			return d.Set("a", "1")
+		},
+		want: "# Comment.\na: 1\n",
+	}, {
+		desc: "replace message value",
+		in:   "a {\n  b: 1\n}\n",
+		edit: func(d *textast.Document) error {
+			return d.Set("a", "ENUM")
+		},
+		want: "a: ENUM\n",
+	}, {
+		desc: "delete fields",
+		in:   config,
+		edit: func(d *textast.Document) error {
+			d.Delete("server.backend")
+			d.Delete("server.host")
+			return nil
+		},
+		want: `# Server configuration.
+
+server {
+  port: 80
+  tags: ["a", "b",
+         "c"]  # Tags.
+
+  [pkg.ext]: 1
+}
+
+# Trailing comment.
+`,
+	}, {
+		desc: "delete list elements",
+		in:   "a: [1, 2, 3]\nb: [\n  1,\n  2  # two\n]\n",
+		edit: func(d *textast.Document) error {
+			a := d.Lookup("a").Value
+			a.Elements = a.Elements[:2]
+			b := d.Lookup("b").Value
+			b.Elements = b.Elements[1:]
+			return nil
+		},
+		want: "a: [1, 2]\nb: [\n  2  # two\n]\n",
+	}, {
+		desc: "append list element",
+		in:   "a: [1, 2]\n",
+		edit: func(d *textast.Document) error {
+			a := d.Lookup("a").Value
+			a.Elements = append(a.Elements, &textast.Element{
+				Value: &textast.Value{Kind: textast.Scalar, Text: "3"},
+			})
+			return nil
+		},
+		want: "a: [1, 2, 3]\n",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			doc, err := textast.Parse([]byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.edit(doc); err != nil {
+				t.Fatal(err)
+			}
+			got := string(doc.Format())
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Format() mismatch (-want +got):\n%s", diff)
+			}
+			if _, err := textast.Parse([]byte(got)); err != nil {
+				t.Errorf("Parse(Format()) error: %v", err)
+			}
+		})
+	}
+}
+
+func TestEditError(t *testing.T) {
+	doc, err := textast.Parse([]byte("a: 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		path, value string
+	}{
+		{"a.b", "1"},
+		{"b", "1 2"},
+		{"b", "{}"},
+		{"b", "1 # comment"},
+		{"", "1"},
+		{"b.", "1"},
+		{"[b", "1"},
+	} {
+		if err := doc.Set(tt.path, tt.value); err == nil {
+			t.Errorf("Set(%q, %q) got nil error, want error", tt.path, tt.value)
+		}
+	}
+	if got, want := string(doc.Format()), "a: 1\n"; got != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,499 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package textfmt formats documents in the protocol buffer text format
+// in a canonical layout, keeping their comments.
+//
+// Formatting uses the schema of the message if it is available, and works
+// from the syntax of the document alone otherwise. The schema is found by
+// the full name of the message, which is either given in the options or in
+// a comment at the start of the document of the form:
+//
+//	# proto-message: pkg.Message
+package textfmt
+
+import (
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/textast"
+	"google.golang.org/protobuf/internal/errors"
+	"google.golang.org/protobuf/internal/pragma"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Format formats the text format document b with default options.
+func Format(b []byte) ([]byte, error) {
+	return Options{}.Format(b)
+}
+
+// Options configures the formatting of text format documents.
+type Options struct {
+	pragma.NoUnkeyedLiterals
+
+	// Indent is the indentation of each level of nesting.
+	// If empty, it is two spaces.
+	Indent string
+
+	// SortFields sorts the fields of every message. Fields in the schema are
+	// sorted by field number, before the other fields, which are sorted by
+	// name. Fields with the same name keep their order.
+	SortFields bool
+
+	// MessageColon writes a ':' between the name of a field and a message
+	// value, which is optional in the text format.
+	MessageColon bool
+
+	// ListSyntax writes the values of a repeated scalar field as a single
+	// list, such as "f: [1, 2]", rather than as repeated fields.
+	// Without a schema, a field is repeated if it occurs more than once.
+	ListSyntax bool
+
+	// Message is the full name of the message of the document.
+	// If empty, it is taken from a "proto-message" comment at the start of
+	// the document, if any.
+	Message protoreflect.FullName
+
+	// Resolver is used for looking up the descriptors of messages and
+	// extensions, such as a protoregistry.Files.
+	// If nil, formatting does not use any schema.
+	Resolver interface {
+		FindDescriptorByName(protoreflect.FullName) (protoreflect.Descriptor, error)
+	}
+}
+
+// Format formats the text format document b.
+func (o Options) Format(b []byte) ([]byte, error) {
+	doc, err := textast.Parse(b)
+	if err != nil {
+		return nil, err
+	}
+	if o.Indent == "" {
+		o.Indent = "  "
+	}
+
+	var md protoreflect.MessageDescriptor
+	if o.Resolver != nil {
+		name := o.Message
+		if name == "" {
+			name = messageComment(doc)
+		}
+		if name != "" {
+			d, err := o.Resolver.FindDescriptorByName(name)
+			if err != nil {
+				return nil, errors.Wrap(err, "unable to resolve message %v", name)
+			}
+			var ok bool
+			if md, ok = d.(protoreflect.MessageDescriptor); !ok {
+				return nil, errors.New("%v is not a message", name)
+			}
+		}
+	}
+
+	f := formatter{opts: o}
+	var header []string
+	fields := f.convertFields(doc.Fields, md, &header)
+	f.writeComments(header, 0)
+	f.writeFields(fields, 0)
+	f.writeComments(commentLines(doc.End, len(fields) == 0, true), 0)
+	return f.out, nil
+}
+
+// messageComment returns the message name in a "proto-message" comment
+// before the first field of doc.
+func messageComment(doc *textast.Document) protoreflect.FullName {
+	s := doc.End
+	if len(doc.Fields) > 0 {
+		s = doc.Fields[0].Before
+	}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		if strings.HasPrefix(line, "proto-message:") {
+			return protoreflect.FullName(strings.TrimSpace(strings.TrimPrefix(line, "proto-message:")))
+		}
+	}
+	return ""
+}
+
+// field is a field or list element, with its comments.
+type field struct {
+	// comments are the lines of comments before the field,
+	// where "" is a blank line.
+	comments []string
+	name     string
+	fd       protoreflect.FieldDescriptor // nil if unknown
+	value    *value
+	// trailing is the comment at the end of the line of the field.
+	trailing string
+}
+
+type value struct {
+	kind     textast.Kind
+	text     string   // for scalars
+	fields   []*field // for messages
+	elements []*field // for lists
+	// start is the comment at the end of the line of the opening delimiter,
+	// and end are the lines of comments before the closing delimiter.
+	start string
+	end   []string
+}
+
+type formatter struct {
+	opts Options
+	out  []byte
+}
+
+// convertFields converts the fields of a message of type md,
+// which is nil if it is unknown. For the top-level message, header is set
+// to the comments at the start of the document, which stay there even if
+// the first field is moved.
+func (f *formatter) convertFields(in []*textast.Field, md protoreflect.MessageDescriptor, header *[]string) []*field {
+	var fields []*field
+	for i, tf := range in {
+		x := &field{
+			comments: commentLines(tf.Before, i == 0, false),
+			name:     fieldName(tf.Name),
+			trailing: comment(tf.After),
+		}
+		x.fd = f.findField(md, x.name)
+		var vmd protoreflect.MessageDescriptor
+		switch {
+		case x.fd != nil:
+			vmd = x.fd.Message()
+		case md != nil && md.FullName() == "google.protobuf.Any" && strings.HasPrefix(x.name, "["):
+			// The expanded value of a google.protobuf.Any message.
+			name := x.name[1 : len(x.name)-1]
+			vmd = f.findMessage(protoreflect.FullName(name[strings.LastIndexByte(name, '/')+1:]))
+		}
+		x.value = f.convertValue(tf.Value, vmd)
+		fields = append(fields, x)
+	}
+	if header != nil && len(fields) > 0 {
+		*header, fields[0].comments = splitHeader(fields[0].comments)
+	}
+	if f.opts.ListSyntax {
+		fields = mergeLists(fields)
+	}
+	if f.opts.SortFields {
+		sort.SliceStable(fields, func(i, j int) bool {
+			return lessField(fields[i], fields[j])
+		})
+	}
+	return fields
+}
+
+func (f *formatter) convertValue(in *textast.Value, md protoreflect.MessageDescriptor) *value {
+	v := &value{kind: in.Kind, text: in.Text, start: comment(in.Start)}
+	switch in.Kind {
+	case textast.Message:
+		v.fields = f.convertFields(in.Fields, md, nil)
+		v.end = commentLines(in.End, len(in.Fields) == 0, true)
+	case textast.List:
+		for i, e := range in.Elements {
+			v.elements = append(v.elements, &field{
+				comments: commentLines(e.Before, i == 0, false),
+				value:    f.convertValue(e.Value, md),
+				trailing: comment(e.After),
+			})
+		}
+		v.end = commentLines(in.End, len(in.Elements) == 0, true)
+	}
+	return v
+}
+
+// findField returns the field of md with the given name as written, or nil.
+func (f *formatter) findField(md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	if md == nil {
+		return nil
+	}
+	if strings.HasPrefix(name, "[") {
+		d, err := f.opts.Resolver.FindDescriptorByName(protoreflect.FullName(name[1 : len(name)-1]))
+		if err != nil {
+			return nil
+		}
+		if xd, ok := d.(protoreflect.ExtensionDescriptor); ok && xd.ContainingMessage().FullName() == md.FullName() {
+			return xd
+		}
+		return nil
+	}
+	if fd := md.Fields().ByTextName(name); fd != nil {
+		return fd
+	}
+	if n := protoreflect.FieldNumber(parseNumber(name)); n > 0 {
+		return md.Fields().ByNumber(n)
+	}
+	return nil
+}
+
+// findMessage returns the message with the given name, or nil.
+func (f *formatter) findMessage(name protoreflect.FullName) protoreflect.MessageDescriptor {
+	d, err := f.opts.Resolver.FindDescriptorByName(name)
+	if err != nil {
+		return nil
+	}
+	md, _ := d.(protoreflect.MessageDescriptor)
+	return md
+}
+
+// parseNumber returns the decimal number s, or -1 if s is not one.
+func parseNumber(s string) int {
+	n := 0
+	for _, c := range s {
+		if c < '0' || c > '9' || n > 1<<29 {
+			return -1
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n
+}
+
+// mergeLists merges the values of each repeated scalar field into a single
+// list value, in place of the first occurrence of the field.
+func mergeLists(fields []*field) []*field {
+	count := make(map[string]int)
+	for _, x := range fields {
+		if isScalarValue(x.value) {
+			count[x.name]++
+		} else {
+			count[x.name] = -len(fields) // Never merge fields with message values.
+		}
+	}
+	lists := make(map[string]*field)
+	var out []*field
+	for _, x := range fields {
+		repeated := count[x.name] > 1
+		if x.fd != nil {
+			repeated = x.fd.IsList() && x.fd.Message() == nil && count[x.name] > 0
+		}
+		if !repeated {
+			out = append(out, x)
+			continue
+		}
+
+		y := lists[x.name]
+		if y == nil {
+			// The first occurrence becomes the list, keeping its comments.
+			y = &field{comments: x.comments, name: x.name, fd: x.fd, value: &value{kind: textast.List}}
+			if x.value.kind == textast.List {
+				y.trailing = x.trailing
+				y.value.start, y.value.end = x.value.start, x.value.end
+				y.value.elements = x.value.elements
+			} else {
+				y.value.elements = []*field{{value: x.value, trailing: x.trailing}}
+			}
+			lists[x.name] = y
+			out = append(out, y)
+			continue
+		}
+
+		// The comments of other occurrences go with their values.
+		if x.value.kind == textast.List {
+			elems := x.value.elements
+			if len(elems) > 0 {
+				elems[0].comments = append(append([]string(nil), x.comments...), elems[0].comments...)
+			}
+			y.value.elements = append(y.value.elements, elems...)
+		} else {
+			y.value.elements = append(y.value.elements, &field{comments: x.comments, value: x.value, trailing: x.trailing})
+		}
+	}
+	return out
+}
+
+// isScalarValue reports whether v is a scalar or a list of scalars.
+func isScalarValue(v *value) bool {
+	if v.kind == textast.List {
+		for _, e := range v.elements {
+			if e.value.kind != textast.Scalar {
+				return false
+			}
+		}
+		return true
+	}
+	return v.kind == textast.Scalar
+}
+
+// lessField reports whether x sorts before y.
+func lessField(x, y *field) bool {
+	switch {
+	case x.fd != nil && y.fd != nil:
+		return x.fd.Number() < y.fd.Number()
+	case x.fd != nil || y.fd != nil:
+		return x.fd != nil
+	}
+	// Extension and type names sort after field names.
+	if xe, ye := strings.HasPrefix(x.name, "["), strings.HasPrefix(y.name, "["); xe != ye {
+		return ye
+	}
+	return x.name < y.name
+}
+
+// fieldName returns the normalized form of a field name.
+func fieldName(name string) string {
+	if !strings.HasPrefix(name, "[") {
+		return name
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\n', '\r', '\t':
+			return -1
+		}
+		return r
+	}, name)
+}
+
+// commentLines returns the lines of comments in the text s before a field
+// or the end of a message, with "" for blank lines between them. Blank lines
+// at the start or the end are dropped if trimStart or trimEnd is set.
+func commentLines(s string, trimStart, trimEnd bool) []string {
+	lines := strings.Split(s, "\n")
+	var out []string
+	blank := false
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "#") {
+			// The last line is the indentation before a field or the end,
+			// rather than a blank line.
+			blank = blank || i < len(lines)-1
+			continue
+		}
+		if blank && (len(out) > 0 || !trimStart) {
+			out = append(out, "")
+		}
+		blank = false
+		out = append(out, line)
+	}
+	if blank && !trimEnd && (len(out) > 0 || !trimStart) {
+		out = append(out, "")
+	}
+	return out
+}
+
+// splitHeader splits the comments before the first field of a document into
+// those at the start of the document and those of the field. The comments at
+// the start are those up to the last blank line or the last line of the form
+// "# proto-message: ...", or another "# proto-" directive.
+func splitHeader(comments []string) (header, rest []string) {
+	n := 0
+	for i, line := range comments {
+		if line == "" || strings.HasPrefix(strings.TrimSpace(strings.TrimPrefix(line, "#")), "proto-") {
+			n = i + 1
+		}
+	}
+	return comments[:n], comments[n:]
+}
+
+// comment returns the comment in the text s after a value, if any.
+func comment(s string) string {
+	i := strings.IndexByte(s, '#')
+	if i < 0 {
+		return ""
+	}
+	s = s[i:]
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+func (f *formatter) writeIndent(depth int) {
+	for i := 0; i < depth; i++ {
+		f.out = append(f.out, f.opts.Indent...)
+	}
+}
+
+func (f *formatter) writeComments(lines []string, depth int) {
+	for _, line := range lines {
+		if line != "" {
+			f.writeIndent(depth)
+			f.out = append(f.out, line...)
+		}
+		f.out = append(f.out, '\n')
+	}
+}
+
+// writeEnd ends the line after a value, with its trailing comment if any.
+func (f *formatter) writeEnd(trailing string) {
+	if trailing != "" {
+		f.out = append(f.out, "  "...)
+		f.out = append(f.out, trailing...)
+	}
+	f.out = append(f.out, '\n')
+}
+
+func (f *formatter) writeFields(fields []*field, depth int) {
+	for _, x := range fields {
+		f.writeComments(x.comments, depth)
+		f.writeIndent(depth)
+		f.out = append(f.out, x.name...)
+		if x.value.kind != textast.Message || f.opts.MessageColon {
+			f.out = append(f.out, ':')
+		}
+		f.out = append(f.out, ' ')
+		f.writeValue(x.value, depth)
+		f.writeEnd(x.trailing)
+	}
+}
+
+// writeValue writes v, which starts on a line with the given depth.
+func (f *formatter) writeValue(v *value, depth int) {
+	switch v.kind {
+	case textast.Scalar:
+		f.out = append(f.out, v.text...)
+	case textast.Message:
+		if len(v.fields) == 0 && len(v.end) == 0 && v.start == "" {
+			f.out = append(f.out, "{}"...)
+			return
+		}
+		f.out = append(f.out, '{')
+		f.writeEnd(v.start)
+		f.writeFields(v.fields, depth+1)
+		f.writeComments(v.end, depth+1)
+		f.writeIndent(depth)
+		f.out = append(f.out, '}')
+	case textast.List:
+		f.out = append(f.out, '[')
+		if !v.isMultiline() {
+			for i, e := range v.elements {
+				if i > 0 {
+					f.out = append(f.out, ", "...)
+				}
+				f.out = append(f.out, e.value.text...)
+			}
+			f.out = append(f.out, ']')
+			return
+		}
+		f.writeEnd(v.start)
+		for i, e := range v.elements {
+			f.writeComments(e.comments, depth+1)
+			f.writeIndent(depth + 1)
+			f.writeValue(e.value, depth+1)
+			if i < len(v.elements)-1 {
+				f.out = append(f.out, ',')
+			}
+			f.writeEnd(e.trailing)
+		}
+		f.writeComments(v.end, depth+1)
+		f.writeIndent(depth)
+		f.out = append(f.out, ']')
+	}
+}
+
+// isMultiline reports whether the list v is written on multiple lines,
+// which it is unless it only has scalars without comments.
+func (v *value) isMultiline() bool {
+	if v.start != "" || len(v.end) > 0 {
+		return true
+	}
+	for _, e := range v.elements {
+		if e.value.kind != textast.Scalar || len(e.comments) > 0 || e.trailing != "" || strings.Contains(e.value.text, "\n") {
+			return true
+		}
+	}
+	return false
+}
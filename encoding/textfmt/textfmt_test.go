@@ -0,0 +1,174 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package textfmt_test
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/encoding/textfmt"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	_ "google.golang.org/protobuf/internal/testprotos/textpb3"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		desc  string
+		opts  textfmt.Options
+		input string
+		want  string
+	}{{
+		desc:  "empty",
+		input: "",
+		want:  "",
+	}, {
+		desc:  "comments only",
+		input: "\n\n# a\n\n\n# b\n\n",
+		want:  "# a\n\n# b\n",
+	}, {
+		desc:  "indentation and separators",
+		input: "a:1 b  :  \"x\" ,c{d:2;e<f:3>} g [1,2 ,3]",
+		want: `a: 1
+b: "x"
+c {
+  d: 2
+  e {
+    f: 3
+  }
+}
+g: [1, 2, 3]
+`,
+	}, {
+		desc: "comments",
+		input: `# header
+
+  # a comment
+  a: 1   # trailing
+
+
+b {  # start
+# inside
+c: 2
+  # end
+}
+# footer
+`,
+		want: `# header
+
+# a comment
+a: 1  # trailing
+
+b {  # start
+  # inside
+  c: 2
+  # end
+}
+# footer
+`,
+	}, {
+		desc:  "empty message",
+		input: "a {\n}\nb <>",
+		want:  "a {}\nb {}\n",
+	}, {
+		desc:  "custom indent",
+		opts:  textfmt.Options{Indent: "\t"},
+		input: "a { b { c: 1 } }",
+		want:  "a {\n\tb {\n\t\tc: 1\n\t}\n}\n",
+	}, {
+		desc:  "message colon",
+		opts:  textfmt.Options{MessageColon: true},
+		input: "a { b: 1 }\nc: {}",
+		want:  "a: {\n  b: 1\n}\nc: {}\n",
+	}, {
+		desc:  "multiline list",
+		input: "a: [\n1, # one\n2]\nb: [{c: 1}]",
+		want:  "a: [\n  1,  # one\n  2\n]\nb: [\n  {\n    c: 1\n  }\n]\n",
+	}, {
+		desc:  "sort fields without schema",
+		opts:  textfmt.Options{SortFields: true},
+		input: "c: 1\n[ext]: 2\na: 3\nb { z: 1 y: 2 }\na: 4",
+		want:  "a: 3\na: 4\nb {\n  y: 2\n  z: 1\n}\nc: 1\n[ext]: 2\n",
+	}, {
+		desc:  "sort fields with schema",
+		opts:  textfmt.Options{SortFields: true, Resolver: protoregistry.GlobalFiles},
+		input: "# proto-message: pb3.Scalars\ns_string: \"a\"\nunknown: 1\ns_int32: 2\ns_bool: true",
+		want:  "# proto-message: pb3.Scalars\ns_bool: true\ns_int32: 2\ns_string: \"a\"\nunknown: 1\n",
+	}, {
+		desc:  "sort fields with header",
+		opts:  textfmt.Options{SortFields: true},
+		input: "# header\n\n# b\nb: 1\n# a\na: 2",
+		want:  "# header\n\n# a\na: 2\n# b\nb: 1\n",
+	}, {
+		desc: "sort nested fields with schema",
+		opts: textfmt.Options{
+			SortFields: true,
+			Message:    "pb3.Nests",
+			Resolver:   protoregistry.GlobalFiles,
+		},
+		input: "s_nested { s_string: \"a\" s_nested { s_string: \"b\" } }",
+		want:  "s_nested {\n  s_string: \"a\"\n  s_nested {\n    s_string: \"b\"\n  }\n}\n",
+	}, {
+		desc:  "list syntax without schema",
+		opts:  textfmt.Options{ListSyntax: true},
+		input: "a: 1\nb: 2\na: [3, 4]\n# five\na: 5\nc {}\nc {}",
+		want:  "a: [\n  1,\n  3,\n  4,\n  # five\n  5\n]\nb: 2\nc {}\nc {}\n",
+	}, {
+		desc: "list syntax with schema",
+		opts: textfmt.Options{
+			ListSyntax: true,
+			Message:    "pb3.Repeats",
+			Resolver:   protoregistry.GlobalFiles,
+		},
+		input: "rpt_bool: true\nrpt_string: \"a\"\nrpt_bool: false",
+		want:  "rpt_bool: [true, false]\nrpt_string: [\"a\"]\n",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := tt.opts.Format([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Format() error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Format() output:\n%s\nwant:\n%s", got, tt.want)
+			}
+			// Formatting must be idempotent.
+			again, err := tt.opts.Format(got)
+			if err != nil {
+				t.Fatalf("Format() of output error: %v", err)
+			}
+			if string(again) != string(got) {
+				t.Errorf("Format() of output:\n%s\nwant:\n%s", again, got)
+			}
+		})
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	tests := []struct {
+		desc  string
+		opts  textfmt.Options
+		input string
+	}{{
+		desc:  "syntax error",
+		input: "a: {",
+	}, {
+		desc:  "unknown message",
+		opts:  textfmt.Options{Message: "pb3.Unknown", Resolver: protoregistry.GlobalFiles},
+		input: "a: 1",
+	}, {
+		desc:  "not a message",
+		opts:  textfmt.Options{Message: "pb3.Enum", Resolver: protoregistry.GlobalFiles},
+		input: "a: 1",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			if _, err := tt.opts.Format([]byte(tt.input)); err == nil {
+				t.Error("Format() got nil error, want error")
+			}
+		})
+	}
+}
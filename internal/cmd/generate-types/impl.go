@@ -86,6 +86,22 @@ v, n := protowire.Consume{{.WireType}}(b)
 {{- end -}}
 {{- end -}}
 
+{{- define "Count" -}}
+{{- if eq .WireType "Varint" -}}
+countVarints(b)
+{{- else -}}
+len(b) / protowire.Size{{.WireType}}()
+{{- end -}}
+{{- end -}}
+
+{{- define "ToValue" -}}
+{{- if or (eq .Name "Bytes") (eq .Name "String") -}}
+protoreflect.ValueOf{{.Name}}({{.ToGoType}})
+{{- else -}}
+{{.ToValue}}
+{{- end -}}
+{{- end -}}
+
 {{- range .}}
 
 {{- if .FromGoType }}
@@ -367,11 +383,14 @@ func consume{{.Name}}Slice(b []byte, p pointer, wtyp protowire.Type, f *coderFie
 	sp := p.{{.GoType.PointerMethod}}Slice()
 	{{- if .WireType.Packable}}
 	if wtyp == protowire.BytesType {
-		s := *sp
 		b, n := protowire.ConsumeBytes(b)
 		if n < 0 {
 			return out, errDecode
 		}
+		if opts.allocator != nil {
+			opts.reserveSlice(p, f.ft, {{template "Count" .}})
+		}
+		s := *sp
 		for len(b) > 0 {
 			{{template "Consume" .}}
 			if n < 0 {
@@ -517,7 +536,7 @@ func consume{{.Name}}Value(b []byte, _ protoreflect.Value, _ protowire.Number, w
 		return protoreflect.Value{}, out, errDecode
 	}
 	out.n = n
-	return {{.ToValue}}, out, nil
+	return {{template "ToValue" .}}, out, nil
 }
 
 var coder{{.Name}}Value = valueCoderFuncs{
@@ -555,7 +574,7 @@ func consume{{.Name}}ValueValidateUTF8(b []byte, _ protoreflect.Value, _ protowi
 		return protoreflect.Value{}, out, errInvalidUTF8{}
 	}
 	out.n = n
-	return {{.ToValue}}, out, nil
+	return {{template "ToValue" .}}, out, nil
 }
 
 var coder{{.Name}}ValueValidateUTF8 = valueCoderFuncs{
@@ -605,7 +624,7 @@ func consume{{.Name}}SliceValue(b []byte, listv protoreflect.Value, _ protowire.
 			if n < 0 {
 				return protoreflect.Value{}, out, errDecode
 			}
-			list.Append({{.ToValue}})
+			list.Append({{template "ToValue" .}})
 			b = b[n:]
 		}
 		out.n = n
@@ -619,7 +638,7 @@ func consume{{.Name}}SliceValue(b []byte, listv protoreflect.Value, _ protowire.
 	if n < 0 {
 		return protoreflect.Value{}, out, errDecode
 	}
-	list.Append({{.ToValue}})
+	list.Append({{template "ToValue" .}})
 	out.n = n
 	return listv, out, nil
 }
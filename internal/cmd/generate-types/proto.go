@@ -239,7 +239,7 @@ var ProtoKinds = []ProtoKind{
 		ToValue:    "protoreflect.ValueOfString(string(v))",
 		FromValue:  "v.String()",
 		GoType:     GoString,
-		ToGoType:   "string(v)",
+		ToGoType:   "opts.decodeString(v)",
 		FromGoType: "v",
 	},
 	{
@@ -248,8 +248,8 @@ var ProtoKinds = []ProtoKind{
 		ToValue:        "protoreflect.ValueOfBytes(append(emptyBuf[:], v...))",
 		FromValue:      "v.Bytes()",
 		GoType:         GoBytes,
-		ToGoType:       "append(emptyBuf[:], v...)",
-		ToGoTypeNoZero: "append(([]byte)(nil), v...)",
+		ToGoType:       "opts.decodeBytes(v)",
+		ToGoTypeNoZero: "opts.decodeBytesNoZero(v)",
 		FromGoType:     "v",
 		NoPointer:      true,
 	},
@@ -46,6 +46,10 @@ type Decoder struct {
 
 	// orig is used in reporting line and column.
 	orig []byte
+	// startOffset, startLine, and startColumn are the position of the start
+	// of orig, relative to the start of the overall input.
+	startOffset            int
+	startLine, startColumn int
 	// in contains the unconsumed input.
 	in []byte
 }
@@ -216,8 +220,34 @@ func (d *Decoder) parseNext() (Token, error) {
 // syntax errors.
 func (d *Decoder) newSyntaxError(pos int, f string, x ...interface{}) error {
 	e := errors.New(f, x...)
+	p := d.errorPos(pos)
+	return errors.NewAt(p, "syntax error (line %d:%d): %v", p.Line, p.Column, e)
+}
+
+// newInvalidUTF8Error is like newSyntaxError, but for invalid UTF-8.
+func (d *Decoder) newInvalidUTF8Error(pos int, f string, x ...interface{}) error {
+	e := errors.New(f, x...)
+	p := d.errorPos(pos)
+	return errors.NewInvalidUTF8At(p, "syntax error (line %d:%d): %v", p.Line, p.Column, e)
+}
+
+func (d *Decoder) errorPos(pos int) errors.Pos {
 	line, column := d.Position(pos)
-	return errors.New("syntax error (line %d:%d): %v", line, column, e)
+	return errors.Pos{Offset: d.Offset(pos), Line: line, Column: column}
+}
+
+// SetStartPosition sets the byte offset, line, and column number of the start
+// of the input, which are otherwise 0, 1, and 1, for use when the input is
+// part of a larger text.
+func (d *Decoder) SetStartPosition(offset, line, column int) {
+	d.startOffset = offset
+	d.startLine, d.startColumn = line-1, column-1
+}
+
+// Offset returns the byte offset of given index of the original input,
+// relative to the start position.
+func (d *Decoder) Offset(idx int) int {
+	return d.startOffset + idx
 }
 
 // Position returns line and column number of given index of the original input.
@@ -227,9 +257,11 @@ func (d *Decoder) Position(idx int) (line int, column int) {
 	line = bytes.Count(b, []byte("\n")) + 1
 	if i := bytes.LastIndexByte(b, '\n'); i >= 0 {
 		b = b[i+1:]
+	} else {
+		column = d.startColumn
 	}
-	column = utf8.RuneCount(b) + 1 // ignore multi-rune characters
-	return line, column
+	column += utf8.RuneCount(b) + 1 // ignore multi-rune characters
+	return line + d.startLine, column
 }
 
 // currPos returns the current index position of d.in from d.orig.
@@ -27,7 +27,7 @@ func (d *Decoder) parseString(in []byte) (string, int, error) {
 	for len(in) > 0 {
 		switch r, n := utf8.DecodeRune(in); {
 		case r == utf8.RuneError && n == 1:
-			return "", 0, d.newSyntaxError(d.currPos(), "invalid UTF-8 in string")
+			return "", 0, d.newInvalidUTF8Error(d.currPos(), "invalid UTF-8 in string")
 		case r < ' ':
 			return "", 0, d.newSyntaxError(d.currPos(), "invalid character %q in string", r)
 		case r == '"':
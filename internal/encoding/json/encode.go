@@ -57,6 +57,13 @@ func (e *Encoder) Bytes() []byte {
 	return e.out
 }
 
+// Truncate discards the written bytes while retaining the state needed to
+// continue writing the same JSON text, such as pending separators and the
+// current indentation.
+func (e *Encoder) Truncate() {
+	e.out = e.out[:0]
+}
+
 // WriteNull writes out the null value.
 func (e *Encoder) WriteNull() {
 	e.prepareNext(scalar)
@@ -185,6 +192,13 @@ func (e *Encoder) WriteUint(n uint64) {
 	e.out = append(e.out, strconv.FormatUint(n, 10)...)
 }
 
+// WriteNumber writes out the given JSON number, which must be valid,
+// such as the raw form of a Number token.
+func (e *Encoder) WriteNumber(s string) {
+	e.prepareNext(scalar)
+	e.out = append(e.out, s...)
+}
+
 // StartObject writes out the '{' symbol.
 func (e *Encoder) StartObject() {
 	e.prepareNext(objectOpen)
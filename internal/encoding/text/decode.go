@@ -364,6 +364,14 @@ var otherCloseChar = map[byte]byte{
 	'>': '}',
 }
 
+// Clone returns a copy of the Decoder for use in reading ahead the next
+// values without affecting the current Decoder.
+func (d *Decoder) Clone() *Decoder {
+	ret := *d
+	ret.openStack = append([]byte(nil), ret.openStack...)
+	return &ret
+}
+
 // currentOpenKind indicates whether current position is inside a message, list
 // or top-level message by returning MessageOpen, ListOpen or bof respectively.
 // If the returned kind is either a MessageOpen or ListOpen, it also returns the
@@ -603,8 +611,21 @@ func (d *Decoder) consumeToken(kind Kind, size int, attrs uint8) Token {
 // current position.
 func (d *Decoder) newSyntaxError(f string, x ...interface{}) error {
 	e := errors.New(f, x...)
-	line, column := d.Position(len(d.orig) - len(d.in))
-	return errors.New("syntax error (line %d:%d): %v", line, column, e)
+	p := d.currErrorPos()
+	return errors.NewAt(p, "syntax error (line %d:%d): %v", p.Line, p.Column, e)
+}
+
+// newInvalidUTF8Error is like newSyntaxError, but for invalid UTF-8.
+func (d *Decoder) newInvalidUTF8Error(f string, x ...interface{}) error {
+	e := errors.New(f, x...)
+	p := d.currErrorPos()
+	return errors.NewInvalidUTF8At(p, "syntax error (line %d:%d): %v", p.Line, p.Column, e)
+}
+
+func (d *Decoder) currErrorPos() errors.Pos {
+	pos := len(d.orig) - len(d.in)
+	line, column := d.Position(pos)
+	return errors.Pos{Offset: pos, Line: line, Column: column}
 }
 
 // Position returns line and column number of given index of the original input.
@@ -29,19 +29,23 @@ func (d *Decoder) parseStringValue() (Token, error) {
 	//	`"foo"'bar'"baz"` => "foobarbaz"
 	in0 := d.in
 	var ss []string
+	var size int
 	for len(d.in) > 0 && (d.in[0] == '"' || d.in[0] == '\'') {
 		s, err := d.parseString()
 		if err != nil {
 			return Token{}, err
 		}
 		ss = append(ss, s)
+		// The raw value excludes any whitespace or comments after it.
+		size = len(in0) - len(d.in)
+		d.consume(0)
 	}
 	// d.in already points to the end of the value at this point.
 	return Token{
 		kind:  Scalar,
 		attrs: stringValue,
 		pos:   len(d.orig) - len(in0),
-		raw:   in0[:len(in0)-len(d.in)],
+		raw:   in0[:size],
 		str:   strings.Join(ss, ""),
 	}, nil
 }
@@ -59,12 +63,11 @@ func (d *Decoder) parseString() (string, error) {
 	for len(in) > 0 {
 		switch r, n := utf8.DecodeRune(in); {
 		case r == utf8.RuneError && n == 1:
-			return "", d.newSyntaxError("invalid UTF-8 detected")
+			return "", d.newInvalidUTF8Error("invalid UTF-8 detected")
 		case r == 0 || r == '\n':
 			return "", d.newSyntaxError("invalid character %q in string", r)
 		case r == rune(quote):
-			in = in[1:]
-			d.consume(len(d.in) - len(in))
+			d.in = in[1:]
 			return string(out), nil
 		case r == '\\':
 			if len(in) < 2 {
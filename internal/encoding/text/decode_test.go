@@ -580,7 +580,7 @@ func TestDecoder(t *testing.T) {
 			in: space + `name` + space + `"hello"` + space,
 			want: []R{
 				{K: text.Name},
-				{K: text.Scalar, T: ST{ok: Str{"hello"}}},
+				{K: text.Scalar, T: ST{ok: Str{"hello"}}, RS: `"hello"`},
 			},
 		},
 		{
@@ -594,7 +594,7 @@ func TestDecoder(t *testing.T) {
 			in: `name: ` + space + `"hello"` + space + `,`,
 			want: []R{
 				{K: text.Name},
-				{K: text.Scalar, T: ST{ok: Str{"hello"}}},
+				{K: text.Scalar, T: ST{ok: Str{"hello"}}, RS: `"hello"`},
 				{K: text.EOF},
 			},
 		},
@@ -21,7 +21,64 @@ func New(f string, x ...interface{}) error {
 	return &prefixError{s: format(f, x...)}
 }
 
-type prefixError struct{ s string }
+// Pos is a position within the input being parsed.
+type Pos struct {
+	Offset int // byte offset, starting at 0
+	Line   int // line number, starting at 1
+	Column int // column number, starting at 1
+}
+
+// NewAt is like New, but additionally records that the error occurred at the
+// given position of the input being parsed. See Position.
+func NewAt(p Pos, f string, x ...interface{}) error {
+	return &prefixError{s: format(f, x...), pos: p}
+}
+
+// NewInvalidUTF8At is like NewAt, but additionally records that the error
+// is due to invalid UTF-8 in the input. See IsInvalidUTF8.
+func NewInvalidUTF8At(p Pos, f string, x ...interface{}) error {
+	return &prefixError{s: format(f, x...), pos: p, invalidUTF8: true}
+}
+
+// Position returns the position recorded by NewAt for err or
+// an error that it wraps. It reports false if there is none.
+func Position(err error) (Pos, bool) {
+	if e := findPrefixError(err); e != nil {
+		return e.pos, true
+	}
+	return Pos{}, false
+}
+
+// IsInvalidUTF8 reports whether err or an error that it wraps was
+// created by NewInvalidUTF8At.
+func IsInvalidUTF8(err error) bool {
+	e := findPrefixError(err)
+	return e != nil && e.invalidUTF8
+}
+
+// findPrefixError returns the first error in the chain of err that has a
+// recorded position.
+func findPrefixError(err error) *prefixError {
+	for err != nil {
+		if e, ok := err.(*prefixError); ok && e.pos.Line > 0 {
+			return e
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			break
+		}
+		err = u.Unwrap()
+	}
+	return nil
+}
+
+type prefixError struct {
+	s string
+
+	// pos is the position in the input, if pos.Line is non-zero.
+	pos         Pos
+	invalidUTF8 bool
+}
 
 var prefix = func() string {
 	// Deliberately introduce instability into the error message string to
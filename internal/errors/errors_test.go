@@ -65,3 +65,50 @@ func TestErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestPosition(t *testing.T) {
+	pos := Pos{Offset: 5, Line: 2, Column: 3}
+	for _, test := range []struct {
+		what        string
+		err         error
+		pos         Pos
+		ok          bool
+		invalidUTF8 bool
+	}{{
+		what: `New("abc")`,
+		err:  New("abc"),
+	}, {
+		what: `NewAt(pos, "abc")`,
+		err:  NewAt(pos, "abc"),
+		pos:  pos,
+		ok:   true,
+	}, {
+		what: `Wrap(NewAt(pos, "abc"), "text")`,
+		err:  Wrap(NewAt(pos, "abc"), "text"),
+		pos:  pos,
+		ok:   true,
+	}, {
+		what: `New("%v", NewAt(pos, "abc"))`,
+		err:  New("%v", NewAt(pos, "abc")),
+	}, {
+		what:        `NewInvalidUTF8At(pos, "abc")`,
+		err:         NewInvalidUTF8At(pos, "abc"),
+		pos:         pos,
+		ok:          true,
+		invalidUTF8: true,
+	}, {
+		what:        `Wrap(NewInvalidUTF8At(pos, "abc"), "text")`,
+		err:         Wrap(NewInvalidUTF8At(pos, "abc"), "text"),
+		pos:         pos,
+		ok:          true,
+		invalidUTF8: true,
+	}} {
+		got, ok := Position(test.err)
+		if got != test.pos || ok != test.ok {
+			t.Errorf("Position(%v) = (%+v, %v), want (%+v, %v)", test.what, got, ok, test.pos, test.ok)
+		}
+		if got := IsInvalidUTF8(test.err); got != test.invalidUTF8 {
+			t.Errorf("IsInvalidUTF8(%v) = %v, want %v", test.what, got, test.invalidUTF8)
+		}
+	}
+}
@@ -21,5 +21,8 @@ const (
 	ExtensionFieldsA_goname = "XXX_InternalExtensions"
 	ExtensionFieldsB_goname = "XXX_extensions"
 
+	LazyFields_goname = "lazyFields"
+
 	WeakFieldPrefix_goname = "XXX_weak_"
+	LazyFieldPrefix_goname = "xxx_lazy_"
 )
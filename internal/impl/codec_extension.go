@@ -208,6 +208,9 @@ func IsLazy(m pref.Message, fd pref.FieldDescriptor) bool {
 	}
 	xd, ok := fd.(pref.ExtensionTypeDescriptor)
 	if !ok {
+		if c := mi.lazyCoders[fd.Number()]; c != nil && !p.IsNil() {
+			return c.raw(p) != nil
+		}
 		return false
 	}
 	xt := xd.Type()
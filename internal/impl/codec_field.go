@@ -58,6 +58,8 @@ func (mi *MessageInfo) initOneofFieldCoders(od pref.OneofDescriptor, si structIn
 			vi := p.AsValueOf(ft).Elem() // oneof field value of interface kind
 			if !vi.IsNil() && !vi.Elem().IsNil() && vi.Elem().Elem().Type() == ot {
 				vw = vi.Elem()
+			} else if w, ok := opts.reusedOneof(p, ft, ot); ok {
+				vw = w
 			} else {
 				vw = reflect.New(ot)
 			}
@@ -247,7 +249,11 @@ func consumeMessageInfo(b []byte, p pointer, wtyp protowire.Type, f *coderFieldI
 		return out, errDecode
 	}
 	if p.Elem().IsNil() {
-		p.SetPointer(pointerOfValue(reflect.New(f.mi.GoReflectType.Elem())))
+		if mp, ok := opts.reusedMessage(p, f); ok {
+			p.SetPointer(mp)
+		} else {
+			p.SetPointer(opts.newMessage(f.mi))
+		}
 	}
 	o, err := f.mi.unmarshalPointer(v, p.Elem(), 0, opts)
 	if err != nil {
@@ -400,7 +406,11 @@ func consumeGroupType(b []byte, p pointer, wtyp protowire.Type, f *coderFieldInf
 		return out, errUnknown
 	}
 	if p.Elem().IsNil() {
-		p.SetPointer(pointerOfValue(reflect.New(f.mi.GoReflectType.Elem())))
+		if mp, ok := opts.reusedMessage(p, f); ok {
+			p.SetPointer(mp)
+		} else {
+			p.SetPointer(opts.newMessage(f.mi))
+		}
 	}
 	return f.mi.unmarshalPointer(b, p.Elem(), f.num, opts)
 }
@@ -498,12 +508,17 @@ func consumeMessageSliceInfo(b []byte, p pointer, wtyp protowire.Type, f *coderF
 	if n < 0 {
 		return out, errDecode
 	}
-	m := reflect.New(f.mi.GoReflectType.Elem()).Interface()
-	mp := pointerOfIface(m)
+	mp, ok := opts.spareMessage(p, f)
+	if !ok {
+		mp = opts.newMessage(f.mi)
+	}
 	o, err := f.mi.unmarshalPointer(v, mp, 0, opts)
 	if err != nil {
 		return out, err
 	}
+	if opts.allocator != nil {
+		opts.reserveSlice(p, f.ft, 1)
+	}
 	p.AppendPointerSlice(mp)
 	out.n = n
 	out.initialized = o.initialized
@@ -812,12 +827,17 @@ func consumeGroupSliceInfo(b []byte, p pointer, wtyp protowire.Type, f *coderFie
 	if wtyp != protowire.StartGroupType {
 		return unmarshalOutput{}, errUnknown
 	}
-	m := reflect.New(f.mi.GoReflectType.Elem()).Interface()
-	mp := pointerOfIface(m)
+	mp, ok := opts.spareMessage(p, f)
+	if !ok {
+		mp = opts.newMessage(f.mi)
+	}
 	out, err := f.mi.unmarshalPointer(b, mp, f.num, opts)
 	if err != nil {
 		return out, err
 	}
+	if opts.allocator != nil {
+		opts.reserveSlice(p, f.ft, 1)
+	}
 	p.AppendPointerSlice(mp)
 	return out, nil
 }
@@ -162,11 +162,14 @@ func appendBoolSlice(b []byte, p pointer, f *coderFieldInfo, opts marshalOptions
 func consumeBoolSlice(b []byte, p pointer, wtyp protowire.Type, f *coderFieldInfo, opts unmarshalOptions) (out unmarshalOutput, err error) {
 	sp := p.BoolSlice()
 	if wtyp == protowire.BytesType {
-		s := *sp
 		b, n := protowire.ConsumeBytes(b)
 		if n < 0 {
 			return out, errDecode
 		}
+		if opts.allocator != nil {
+			opts.reserveSlice(p, f.ft, countVarints(b))
+		}
+		s := *sp
 		for len(b) > 0 {
 			var v uint64
 			var n int
@@ -732,11 +735,14 @@ func appendInt32Slice(b []byte, p pointer, f *coderFieldInfo, opts marshalOption
 func consumeInt32Slice(b []byte, p pointer, wtyp protowire.Type, f *coderFieldInfo, opts unmarshalOptions) (out unmarshalOutput, err error) {
 	sp := p.Int32Slice()
 	if wtyp == protowire.BytesType {
-		s := *sp
 		b, n := protowire.ConsumeBytes(b)
 		if n < 0 {
 			return out, errDecode
 		}
+		if opts.allocator != nil {
+			opts.reserveSlice(p, f.ft, countVarints(b))
+		}
+		s := *sp
 		for len(b) > 0 {
 			var v uint64
 			var n int
@@ -1138,11 +1144,14 @@ func appendSint32Slice(b []byte, p pointer, f *coderFieldInfo, opts marshalOptio
 func consumeSint32Slice(b []byte, p pointer, wtyp protowire.Type, f *coderFieldInfo, opts unmarshalOptions) (out unmarshalOutput, err error) {
 	sp := p.Int32Slice()
 	if wtyp == protowire.BytesType {
-		s := *sp
 		b, n := protowire.ConsumeBytes(b)
 		if n < 0 {
 			return out, errDecode
 		}
+		if opts.allocator != nil {
+			opts.reserveSlice(p, f.ft, countVarints(b))
+		}
+		s := *sp
 		for len(b) > 0 {
 			var v uint64
 			var n int
@@ -1544,11 +1553,14 @@ func appendUint32Slice(b []byte, p pointer, f *coderFieldInfo, opts marshalOptio
 func consumeUint32Slice(b []byte, p pointer, wtyp protowire.Type, f *coderFieldInfo, opts unmarshalOptions) (out unmarshalOutput, err error) {
 	sp := p.Uint32Slice()
 	if wtyp == protowire.BytesType {
-		s := *sp
 		b, n := protowire.ConsumeBytes(b)
 		if n < 0 {
 			return out, errDecode
 		}
+		if opts.allocator != nil {
+			opts.reserveSlice(p, f.ft, countVarints(b))
+		}
+		s := *sp
 		for len(b) > 0 {
 			var v uint64
 			var n int
@@ -1950,11 +1962,14 @@ func appendInt64Slice(b []byte, p pointer, f *coderFieldInfo, opts marshalOption
 func consumeInt64Slice(b []byte, p pointer, wtyp protowire.Type, f *coderFieldInfo, opts unmarshalOptions) (out unmarshalOutput, err error) {
 	sp := p.Int64Slice()
 	if wtyp == protowire.BytesType {
-		s := *sp
 		b, n := protowire.ConsumeBytes(b)
 		if n < 0 {
 			return out, errDecode
 		}
+		if opts.allocator != nil {
+			opts.reserveSlice(p, f.ft, countVarints(b))
+		}
+		s := *sp
 		for len(b) > 0 {
 			var v uint64
 			var n int
@@ -2356,11 +2371,14 @@ func appendSint64Slice(b []byte, p pointer, f *coderFieldInfo, opts marshalOptio
 func consumeSint64Slice(b []byte, p pointer, wtyp protowire.Type, f *coderFieldInfo, opts unmarshalOptions) (out unmarshalOutput, err error) {
 	sp := p.Int64Slice()
 	if wtyp == protowire.BytesType {
-		s := *sp
 		b, n := protowire.ConsumeBytes(b)
 		if n < 0 {
 			return out, errDecode
 		}
+		if opts.allocator != nil {
+			opts.reserveSlice(p, f.ft, countVarints(b))
+		}
+		s := *sp
 		for len(b) > 0 {
 			var v uint64
 			var n int
@@ -2762,11 +2780,14 @@ func appendUint64Slice(b []byte, p pointer, f *coderFieldInfo, opts marshalOptio
 func consumeUint64Slice(b []byte, p pointer, wtyp protowire.Type, f *coderFieldInfo, opts unmarshalOptions) (out unmarshalOutput, err error) {
 	sp := p.Uint64Slice()
 	if wtyp == protowire.BytesType {
-		s := *sp
 		b, n := protowire.ConsumeBytes(b)
 		if n < 0 {
 			return out, errDecode
 		}
+		if opts.allocator != nil {
+			opts.reserveSlice(p, f.ft, countVarints(b))
+		}
+		s := *sp
 		for len(b) > 0 {
 			var v uint64
 			var n int
@@ -3145,11 +3166,14 @@ func appendSfixed32Slice(b []byte, p pointer, f *coderFieldInfo, opts marshalOpt
 func consumeSfixed32Slice(b []byte, p pointer, wtyp protowire.Type, f *coderFieldInfo, opts unmarshalOptions) (out unmarshalOutput, err error) {
 	sp := p.Int32Slice()
 	if wtyp == protowire.BytesType {
-		s := *sp
 		b, n := protowire.ConsumeBytes(b)
 		if n < 0 {
 			return out, errDecode
 		}
+		if opts.allocator != nil {
+			opts.reserveSlice(p, f.ft, len(b)/protowire.SizeFixed32())
+		}
+		s := *sp
 		for len(b) > 0 {
 			v, n := protowire.ConsumeFixed32(b)
 			if n < 0 {
@@ -3461,11 +3485,14 @@ func appendFixed32Slice(b []byte, p pointer, f *coderFieldInfo, opts marshalOpti
 func consumeFixed32Slice(b []byte, p pointer, wtyp protowire.Type, f *coderFieldInfo, opts unmarshalOptions) (out unmarshalOutput, err error) {
 	sp := p.Uint32Slice()
 	if wtyp == protowire.BytesType {
-		s := *sp
 		b, n := protowire.ConsumeBytes(b)
 		if n < 0 {
 			return out, errDecode
 		}
+		if opts.allocator != nil {
+			opts.reserveSlice(p, f.ft, len(b)/protowire.SizeFixed32())
+		}
+		s := *sp
 		for len(b) > 0 {
 			v, n := protowire.ConsumeFixed32(b)
 			if n < 0 {
@@ -3777,11 +3804,14 @@ func appendFloatSlice(b []byte, p pointer, f *coderFieldInfo, opts marshalOption
 func consumeFloatSlice(b []byte, p pointer, wtyp protowire.Type, f *coderFieldInfo, opts unmarshalOptions) (out unmarshalOutput, err error) {
 	sp := p.Float32Slice()
 	if wtyp == protowire.BytesType {
-		s := *sp
 		b, n := protowire.ConsumeBytes(b)
 		if n < 0 {
 			return out, errDecode
 		}
+		if opts.allocator != nil {
+			opts.reserveSlice(p, f.ft, len(b)/protowire.SizeFixed32())
+		}
+		s := *sp
 		for len(b) > 0 {
 			v, n := protowire.ConsumeFixed32(b)
 			if n < 0 {
@@ -4093,11 +4123,14 @@ func appendSfixed64Slice(b []byte, p pointer, f *coderFieldInfo, opts marshalOpt
 func consumeSfixed64Slice(b []byte, p pointer, wtyp protowire.Type, f *coderFieldInfo, opts unmarshalOptions) (out unmarshalOutput, err error) {
 	sp := p.Int64Slice()
 	if wtyp == protowire.BytesType {
-		s := *sp
 		b, n := protowire.ConsumeBytes(b)
 		if n < 0 {
 			return out, errDecode
 		}
+		if opts.allocator != nil {
+			opts.reserveSlice(p, f.ft, len(b)/protowire.SizeFixed64())
+		}
+		s := *sp
 		for len(b) > 0 {
 			v, n := protowire.ConsumeFixed64(b)
 			if n < 0 {
@@ -4409,11 +4442,14 @@ func appendFixed64Slice(b []byte, p pointer, f *coderFieldInfo, opts marshalOpti
 func consumeFixed64Slice(b []byte, p pointer, wtyp protowire.Type, f *coderFieldInfo, opts unmarshalOptions) (out unmarshalOutput, err error) {
 	sp := p.Uint64Slice()
 	if wtyp == protowire.BytesType {
-		s := *sp
 		b, n := protowire.ConsumeBytes(b)
 		if n < 0 {
 			return out, errDecode
 		}
+		if opts.allocator != nil {
+			opts.reserveSlice(p, f.ft, len(b)/protowire.SizeFixed64())
+		}
+		s := *sp
 		for len(b) > 0 {
 			v, n := protowire.ConsumeFixed64(b)
 			if n < 0 {
@@ -4725,11 +4761,14 @@ func appendDoubleSlice(b []byte, p pointer, f *coderFieldInfo, opts marshalOptio
 func consumeDoubleSlice(b []byte, p pointer, wtyp protowire.Type, f *coderFieldInfo, opts unmarshalOptions) (out unmarshalOutput, err error) {
 	sp := p.Float64Slice()
 	if wtyp == protowire.BytesType {
-		s := *sp
 		b, n := protowire.ConsumeBytes(b)
 		if n < 0 {
 			return out, errDecode
 		}
+		if opts.allocator != nil {
+			opts.reserveSlice(p, f.ft, len(b)/protowire.SizeFixed64())
+		}
+		s := *sp
 		for len(b) > 0 {
 			v, n := protowire.ConsumeFixed64(b)
 			if n < 0 {
@@ -4939,7 +4978,7 @@ func consumeString(b []byte, p pointer, wtyp protowire.Type, f *coderFieldInfo,
 	if n < 0 {
 		return out, errDecode
 	}
-	*p.String() = string(v)
+	*p.String() = opts.decodeString(v)
 	out.n = n
 	return out, nil
 }
@@ -4974,7 +5013,7 @@ func consumeStringValidateUTF8(b []byte, p pointer, wtyp protowire.Type, f *code
 	if !utf8.Valid(v) {
 		return out, errInvalidUTF8{}
 	}
-	*p.String() = string(v)
+	*p.String() = opts.decodeString(v)
 	out.n = n
 	return out, nil
 }
@@ -5066,7 +5105,7 @@ func consumeStringPtr(b []byte, p pointer, wtyp protowire.Type, f *coderFieldInf
 	if *vp == nil {
 		*vp = new(string)
 	}
-	**vp = string(v)
+	**vp = opts.decodeString(v)
 	out.n = n
 	return out, nil
 }
@@ -5106,7 +5145,7 @@ func consumeStringPtrValidateUTF8(b []byte, p pointer, wtyp protowire.Type, f *c
 	if *vp == nil {
 		*vp = new(string)
 	}
-	**vp = string(v)
+	**vp = opts.decodeString(v)
 	out.n = n
 	return out, nil
 }
@@ -5147,7 +5186,7 @@ func consumeStringSlice(b []byte, p pointer, wtyp protowire.Type, f *coderFieldI
 	if n < 0 {
 		return out, errDecode
 	}
-	*sp = append(*sp, string(v))
+	*sp = append(*sp, opts.decodeString(v))
 	out.n = n
 	return out, nil
 }
@@ -5185,7 +5224,7 @@ func consumeStringSliceValidateUTF8(b []byte, p pointer, wtyp protowire.Type, f
 		return out, errInvalidUTF8{}
 	}
 	sp := p.StringSlice()
-	*sp = append(*sp, string(v))
+	*sp = append(*sp, opts.decodeString(v))
 	out.n = n
 	return out, nil
 }
@@ -5219,7 +5258,7 @@ func consumeStringValue(b []byte, _ protoreflect.Value, _ protowire.Number, wtyp
 		return protoreflect.Value{}, out, errDecode
 	}
 	out.n = n
-	return protoreflect.ValueOfString(string(v)), out, nil
+	return protoreflect.ValueOfString(opts.decodeString(v)), out, nil
 }
 
 var coderStringValue = valueCoderFuncs{
@@ -5252,7 +5291,7 @@ func consumeStringValueValidateUTF8(b []byte, _ protoreflect.Value, _ protowire.
 		return protoreflect.Value{}, out, errInvalidUTF8{}
 	}
 	out.n = n
-	return protoreflect.ValueOfString(string(v)), out, nil
+	return protoreflect.ValueOfString(opts.decodeString(v)), out, nil
 }
 
 var coderStringValueValidateUTF8 = valueCoderFuncs{
@@ -5293,7 +5332,7 @@ func consumeStringSliceValue(b []byte, listv protoreflect.Value, _ protowire.Num
 	if n < 0 {
 		return protoreflect.Value{}, out, errDecode
 	}
-	list.Append(protoreflect.ValueOfString(string(v)))
+	list.Append(protoreflect.ValueOfString(opts.decodeString(v)))
 	out.n = n
 	return listv, out, nil
 }
@@ -5328,7 +5367,7 @@ func consumeBytes(b []byte, p pointer, wtyp protowire.Type, f *coderFieldInfo, o
 	if n < 0 {
 		return out, errDecode
 	}
-	*p.Bytes() = append(emptyBuf[:], v...)
+	*p.Bytes() = opts.decodeBytes(v)
 	out.n = n
 	return out, nil
 }
@@ -5363,7 +5402,7 @@ func consumeBytesValidateUTF8(b []byte, p pointer, wtyp protowire.Type, f *coder
 	if !utf8.Valid(v) {
 		return out, errInvalidUTF8{}
 	}
-	*p.Bytes() = append(emptyBuf[:], v...)
+	*p.Bytes() = opts.decodeBytes(v)
 	out.n = n
 	return out, nil
 }
@@ -5407,7 +5446,7 @@ func consumeBytesNoZero(b []byte, p pointer, wtyp protowire.Type, f *coderFieldI
 	if n < 0 {
 		return out, errDecode
 	}
-	*p.Bytes() = append(([]byte)(nil), v...)
+	*p.Bytes() = opts.decodeBytesNoZero(v)
 	out.n = n
 	return out, nil
 }
@@ -5446,7 +5485,7 @@ func consumeBytesNoZeroValidateUTF8(b []byte, p pointer, wtyp protowire.Type, f
 	if !utf8.Valid(v) {
 		return out, errInvalidUTF8{}
 	}
-	*p.Bytes() = append(([]byte)(nil), v...)
+	*p.Bytes() = opts.decodeBytesNoZero(v)
 	out.n = n
 	return out, nil
 }
@@ -5487,7 +5526,7 @@ func consumeBytesSlice(b []byte, p pointer, wtyp protowire.Type, f *coderFieldIn
 	if n < 0 {
 		return out, errDecode
 	}
-	*sp = append(*sp, append(emptyBuf[:], v...))
+	*sp = append(*sp, opts.decodeBytes(v))
 	out.n = n
 	return out, nil
 }
@@ -5525,7 +5564,7 @@ func consumeBytesSliceValidateUTF8(b []byte, p pointer, wtyp protowire.Type, f *
 		return out, errInvalidUTF8{}
 	}
 	sp := p.BytesSlice()
-	*sp = append(*sp, append(emptyBuf[:], v...))
+	*sp = append(*sp, opts.decodeBytes(v))
 	out.n = n
 	return out, nil
 }
@@ -5559,7 +5598,7 @@ func consumeBytesValue(b []byte, _ protoreflect.Value, _ protowire.Number, wtyp
 		return protoreflect.Value{}, out, errDecode
 	}
 	out.n = n
-	return protoreflect.ValueOfBytes(append(emptyBuf[:], v...)), out, nil
+	return protoreflect.ValueOfBytes(opts.decodeBytes(v)), out, nil
 }
 
 var coderBytesValue = valueCoderFuncs{
@@ -5600,7 +5639,7 @@ func consumeBytesSliceValue(b []byte, listv protoreflect.Value, _ protowire.Numb
 	if n < 0 {
 		return protoreflect.Value{}, out, errDecode
 	}
-	list.Append(protoreflect.ValueOfBytes(append(emptyBuf[:], v...)))
+	list.Append(protoreflect.ValueOfBytes(opts.decodeBytes(v)))
 	out.n = n
 	return listv, out, nil
 }
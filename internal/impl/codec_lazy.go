@@ -0,0 +1,344 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/internal/errors"
+	pref "google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// lazyFields holds the undecoded wire data for message fields
+// declared with the [lazy=true] option.
+//
+// A field with an entry in raw always has an empty Go struct field.
+// The entry is decoded into the struct field the first time the field
+// is accessed through reflection or a generated getter.
+type lazyFields struct {
+	atomicPending uint32 // number of entries in raw
+	mu            sync.Mutex
+	raw           map[pref.FieldNumber][]byte // tag and value of each record
+}
+
+// isLazyField reports whether the field fd, stored in the struct field fs
+// of a message with struct info si, may be decoded lazily.
+//
+// Only unexported struct fields are decoded lazily. An exported field may be
+// read or written directly, bypassing its undecoded data, so it is always
+// decoded eagerly.
+func isLazyField(fd pref.FieldDescriptor, fs reflect.StructField, si structInfo) bool {
+	if !si.lazyOffset.IsValid() || fs.Type == nil || fs.PkgPath == "" {
+		return false
+	}
+	if fd.Kind() != pref.MessageKind || fd.IsMap() || fd.IsWeak() {
+		return false
+	}
+	if od := fd.ContainingOneof(); od != nil && !od.IsSynthetic() {
+		return false
+	}
+	ft := fs.Type
+	if fd.IsList() {
+		ft = ft.Elem()
+	}
+	if getMessageInfo(ft) == nil {
+		return false
+	}
+	lfd, ok := fd.(interface{ IsLazy() bool })
+	return ok && lfd.IsLazy()
+}
+
+// lazyFieldCoder implements the fast-path functions for a lazy field.
+//
+// Its functions are called with a pointer to the message struct rather
+// than to the field, since they need access to both the field and the
+// message's lazyFields. The eager functions in funcs operate on the field.
+type lazyFieldCoder struct {
+	fd          pref.FieldDescriptor
+	fieldOffset offset
+	lazyOffset  offset
+	funcs       pointerCoderFuncs
+	f           *coderFieldInfo
+}
+
+func makeLazyFieldCoder(fd pref.FieldDescriptor, fieldOffset, lazyOffset offset, funcs pointerCoderFuncs) (*lazyFieldCoder, pointerCoderFuncs) {
+	c := &lazyFieldCoder{
+		fd:          fd,
+		fieldOffset: fieldOffset,
+		lazyOffset:  lazyOffset,
+		funcs:       funcs,
+	}
+	return c, pointerCoderFuncs{
+		size:      c.size,
+		marshal:   c.marshal,
+		unmarshal: c.unmarshal,
+		isInit:    c.isInit,
+		merge:     c.merge,
+	}
+}
+
+// isEmpty reports whether the Go struct field is unset.
+func (c *lazyFieldCoder) isEmpty(p pointer) bool {
+	return p.Apply(c.fieldOffset).Elem().IsNil()
+}
+
+// appendRaw records undecoded field data b, including the tag.
+// It must not be called concurrently with any other access to p.
+func (c *lazyFieldCoder) appendRaw(p pointer, b []byte) {
+	lp := p.Apply(c.lazyOffset).LazyFields()
+	if *lp == nil {
+		*lp = &lazyFields{}
+	}
+	lf := *lp
+	if lf.raw == nil {
+		lf.raw = make(map[pref.FieldNumber][]byte)
+	}
+	num := c.fd.Number()
+	if _, ok := lf.raw[num]; !ok {
+		atomic.AddUint32(&lf.atomicPending, 1)
+	}
+	lf.raw[num] = append(lf.raw[num], b...)
+}
+
+// pending returns the lazyFields of p if it has undecoded data for any field.
+func (c *lazyFieldCoder) pending(p pointer) *lazyFields {
+	lf := *p.Apply(c.lazyOffset).LazyFields()
+	if lf == nil || atomic.LoadUint32(&lf.atomicPending) == 0 {
+		return nil
+	}
+	return lf
+}
+
+// raw returns the undecoded data for the field, or nil if there is none.
+// If the Go struct field has also been populated, any undecoded data
+// is merged into it and nil is returned.
+func (c *lazyFieldCoder) raw(p pointer) []byte {
+	lf := c.pending(p)
+	if lf == nil {
+		return nil
+	}
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	b, ok := lf.raw[c.fd.Number()]
+	if !ok {
+		return nil
+	}
+	if !c.isEmpty(p) {
+		c.resolveLocked(p, lf)
+		return nil
+	}
+	return b
+}
+
+// resolve decodes any undecoded data for the field.
+// It may be called concurrently.
+func (c *lazyFieldCoder) resolve(p pointer) {
+	lf := c.pending(p)
+	if lf == nil {
+		return
+	}
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	c.resolveLocked(p, lf)
+}
+
+func (c *lazyFieldCoder) resolveLocked(p pointer, lf *lazyFields) {
+	num := c.fd.Number()
+	b, ok := lf.raw[num]
+	if !ok {
+		return
+	}
+	fp := p.Apply(c.fieldOffset)
+	for len(b) > 0 {
+		_, wtyp, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			panic(errors.New("bad tag in lazy field decoding"))
+		}
+		b = b[n:]
+		out, err := c.funcs.unmarshal(b, fp, wtyp, c.f, lazyUnmarshalOptions)
+		if err != nil {
+			panic(errors.New("decode failure in lazy field decoding: %v", err))
+		}
+		b = b[out.n:]
+	}
+	delete(lf.raw, num)
+	atomic.AddUint32(&lf.atomicPending, ^uint32(0))
+}
+
+// discard drops any undecoded data for the field.
+// It must not be called concurrently with any other access to p.
+func (c *lazyFieldCoder) discard(p pointer) {
+	lf := c.pending(p)
+	if lf == nil {
+		return
+	}
+	num := c.fd.Number()
+	if _, ok := lf.raw[num]; ok {
+		delete(lf.raw, num)
+		atomic.AddUint32(&lf.atomicPending, ^uint32(0))
+	}
+}
+
+func (c *lazyFieldCoder) size(p pointer, f *coderFieldInfo, opts marshalOptions) int {
+	if opts.Deterministic() {
+		// Undecoded data is emitted as it was received,
+		// which is not necessarily in deterministic order.
+		c.resolve(p)
+	} else if b := c.raw(p); b != nil {
+		return len(b)
+	}
+	if c.isEmpty(p) {
+		return 0
+	}
+	return c.funcs.size(p.Apply(c.fieldOffset), f, opts)
+}
+
+func (c *lazyFieldCoder) marshal(b []byte, p pointer, f *coderFieldInfo, opts marshalOptions) ([]byte, error) {
+	if opts.Deterministic() {
+		c.resolve(p)
+	} else if raw := c.raw(p); raw != nil {
+		return append(b, raw...), nil
+	}
+	if c.isEmpty(p) {
+		return b, nil
+	}
+	return c.funcs.marshal(b, p.Apply(c.fieldOffset), f, opts)
+}
+
+func (c *lazyFieldCoder) unmarshal(b []byte, p pointer, wtyp protowire.Type, f *coderFieldInfo, opts unmarshalOptions) (out unmarshalOutput, err error) {
+	if wtyp == protowire.BytesType && opts.IsDefault() && c.isEmpty(p) {
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return out, errDecode
+		}
+		// The data is decoded with lazyUnmarshalOptions when it is resolved,
+		// so it must be valid within that recursion limit as well.
+		vopts := opts
+		if vopts.depth > lazyUnmarshalOptions.depth {
+			vopts.depth = lazyUnmarshalOptions.depth
+		}
+		out, valid := f.mi.validate(v, 0, vopts)
+		switch valid {
+		case ValidationValid:
+			if out.initialized {
+				c.appendRaw(p, append(protowire.AppendTag(nil, c.fd.Number(), wtyp), b[:n]...))
+				out.n = n
+				return out, nil
+			}
+		case ValidationInvalid:
+			return out, errDecode
+		case ValidationUnknown:
+		}
+	}
+	c.resolve(p)
+	return c.funcs.unmarshal(b, p.Apply(c.fieldOffset), wtyp, f, opts)
+}
+
+func (c *lazyFieldCoder) isInit(p pointer, f *coderFieldInfo) error {
+	if c.raw(p) != nil {
+		// Undecoded data was verified to be initialized when it was read.
+		return nil
+	}
+	if c.isEmpty(p) {
+		if f.isRequired {
+			return errors.RequiredNotSet(string(c.fd.FullName()))
+		}
+		return nil
+	}
+	if c.funcs.isInit == nil {
+		return nil
+	}
+	return c.funcs.isInit(p.Apply(c.fieldOffset), f)
+}
+
+func (c *lazyFieldCoder) merge(dst, src pointer, f *coderFieldInfo, opts mergeOptions) {
+	if b := c.raw(src); b != nil {
+		if c.isEmpty(dst) {
+			// Appending records is equivalent to merging their decoded values.
+			c.appendRaw(dst, b)
+			return
+		}
+		c.resolve(src)
+	}
+	c.resolve(dst)
+	if c.isEmpty(src) {
+		return
+	}
+	c.funcs.merge(dst.Apply(c.fieldOffset), src.Apply(c.fieldOffset), f, opts)
+}
+
+// fieldInfoForLazy wraps the reflection functions of a lazy field
+// so that undecoded data is decoded before the field is read.
+func (mi *MessageInfo) fieldInfoForLazy(fi fieldInfo) fieldInfo {
+	num := fi.fieldDesc.Number()
+	base := fi
+	fi.has = func(p pointer) bool {
+		if !p.IsNil() && mi.lazyCoders[num].raw(p) != nil {
+			return true
+		}
+		return base.has(p)
+	}
+	fi.clear = func(p pointer) {
+		mi.lazyCoders[num].discard(p)
+		base.clear(p)
+	}
+	fi.get = func(p pointer) pref.Value {
+		if !p.IsNil() {
+			mi.lazyCoders[num].resolve(p)
+		}
+		return base.get(p)
+	}
+	fi.set = func(p pointer, v pref.Value) {
+		mi.lazyCoders[num].discard(p)
+		base.set(p, v)
+	}
+	fi.mutable = func(p pointer) pref.Value {
+		mi.lazyCoders[num].resolve(p)
+		return base.mutable(p)
+	}
+	return fi
+}
+
+// UnmarshalLazyField decodes any undecoded data for field num of m.
+// It is called by the generated getters of lazy fields.
+func (e Export) UnmarshalLazyField(m message, num pref.FieldNumber) {
+	if c, p := lazyFieldCoderOf(e.ProtoMessageV2Of(m), num); c != nil {
+		c.resolve(p)
+	}
+}
+
+// DiscardLazyField drops any undecoded data for field num of m.
+// It is called by the generated setters of lazy fields.
+func (e Export) DiscardLazyField(m message, num pref.FieldNumber) {
+	if c, p := lazyFieldCoderOf(e.ProtoMessageV2Of(m), num); c != nil {
+		c.discard(p)
+	}
+}
+
+// lazyFieldCoderOf returns the coder for the lazy field num of m,
+// and a pointer to m, or nil if m is nil or the field is not lazy.
+func lazyFieldCoderOf(m pref.ProtoMessage, num pref.FieldNumber) (*lazyFieldCoder, pointer) {
+	var mi *MessageInfo
+	var p pointer
+	switch m := m.ProtoReflect().(type) {
+	case *messageState:
+		mi = m.messageInfo()
+		p = m.pointer()
+	case *messageReflectWrapper:
+		mi = m.messageInfo()
+		p = m.pointer()
+	default:
+		return nil, pointer{}
+	}
+	mi.init()
+	c := mi.lazyCoders[num]
+	if c == nil || p.IsNil() {
+		return nil, pointer{}
+	}
+	return c, p
+}
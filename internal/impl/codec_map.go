@@ -178,8 +178,13 @@ func consumeMapOfMessage(b []byte, mapv reflect.Value, wtyp protowire.Type, mapi
 	}
 	var (
 		key = mapi.keyZero
-		val = reflect.New(f.mi.GoReflectType.Elem())
+		val reflect.Value
 	)
+	if mp, ok := opts.reusedMapValue(mapv); ok {
+		val = mp.AsValueOf(f.mi.GoReflectType.Elem())
+	} else {
+		val = opts.newMessage(f.mi).AsValueOf(f.mi.GoReflectType.Elem())
+	}
 	for len(b) > 0 {
 		num, wtyp, n := protowire.ConsumeTag(b)
 		if n < 0 {
@@ -32,6 +32,9 @@ type coderMessageInfo struct {
 	needsInitCheck     bool
 	isMessageSet       bool
 	numRequiredFields  uint8
+	lazyCoders         map[pref.FieldNumber]*lazyFieldCoder
+	lazyOffset         offset
+	resetFields        []resetFieldInfo
 }
 
 type coderFieldInfo struct {
@@ -135,6 +138,17 @@ func (mi *MessageInfo) makeCoderMethods(t reflect.Type, si structInfo) {
 			fieldOffset = offsetOf(fs, mi.Exporter)
 			// 当前字段的操作函数，如果当前 field 是 message 类型，那么 childMessage 会返回
 			childMessage, funcs = fieldCoder(fd, ft)
+			if isLazyField(fd, fs, si) {
+				// Lazy field functions operate on the message struct,
+				// since they need access to the lazyFields as well.
+				var lc *lazyFieldCoder
+				lc, funcs = makeLazyFieldCoder(fd, fieldOffset, si.lazyOffset, funcs)
+				if mi.lazyCoders == nil {
+					mi.lazyCoders = make(map[pref.FieldNumber]*lazyFieldCoder)
+				}
+				mi.lazyCoders[fd.Number()] = lc
+				fieldOffset = selfOffset
+			}
 		}
 
 		// 保存
@@ -151,6 +165,10 @@ func (mi *MessageInfo) makeCoderMethods(t reflect.Type, si structInfo) {
 			isPointer:  fd.Cardinality() == pref.Repeated || fd.HasPresence(),
 			isRequired: fd.Cardinality() == pref.Required,
 		}
+		if lc := mi.lazyCoders[cf.num]; lc != nil {
+			lc.f = cf
+			cf.isPointer = false
+		}
 
 		mi.orderedCoderFields = append(mi.orderedCoderFields, cf)
 		mi.coderFields[cf.num] = cf
@@ -207,7 +225,7 @@ func (mi *MessageInfo) makeCoderMethods(t reflect.Type, si structInfo) {
 	}
 
 	if mi.methods.Unmarshal == nil {
-		mi.methods.Flags |= piface.SupportUnmarshalDiscardUnknown
+		mi.methods.Flags |= piface.SupportUnmarshalDiscardUnknown | piface.SupportUnmarshalReuseMemory
 		mi.methods.Unmarshal = mi.unmarshal
 	}
 
@@ -218,6 +236,11 @@ func (mi *MessageInfo) makeCoderMethods(t reflect.Type, si structInfo) {
 	if mi.methods.Merge == nil {
 		mi.methods.Merge = mi.merge
 	}
+
+	mi.makeResetFields(si)
+	if mi.methods.Reset == nil {
+		mi.methods.Reset = mi.reset
+	}
 }
 
 // getUnknownBytes returns a *[]byte for the unknown fields.
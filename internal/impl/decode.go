@@ -6,10 +6,12 @@ package impl
 
 import (
 	"math/bits"
+	"reflect"
 
 	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/internal/errors"
 	"google.golang.org/protobuf/internal/flags"
+	"google.golang.org/protobuf/internal/strs"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
 	preg "google.golang.org/protobuf/reflect/protoregistry"
@@ -26,7 +28,9 @@ type unmarshalOptions struct {
 		FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error)
 		FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error)
 	}
-	depth int
+	depth     int
+	allocator piface.Allocator
+	reuse     *reuseTable // messages retained for reuse, when ReuseMemory is set
 }
 
 func (o unmarshalOptions) Options() proto.UnmarshalOptions {
@@ -34,14 +38,111 @@ func (o unmarshalOptions) Options() proto.UnmarshalOptions {
 		Merge:          true,
 		AllowPartial:   true,
 		DiscardUnknown: o.DiscardUnknown(),
+		AliasBuffer:    o.AliasBuffer(),
+		AliasStrings:   o.AliasStrings(),
+		ReuseMemory:    o.ReuseMemory(),
 		Resolver:       o.resolver,
+		Allocator:      o.allocator,
 	}
 }
 
 func (o unmarshalOptions) DiscardUnknown() bool { return o.flags&piface.UnmarshalDiscardUnknown != 0 }
+func (o unmarshalOptions) AliasBuffer() bool    { return o.flags&piface.UnmarshalAliasBuffer != 0 }
+func (o unmarshalOptions) ReuseMemory() bool    { return o.flags&piface.UnmarshalReuseMemory != 0 }
+func (o unmarshalOptions) AliasStrings() bool   { return o.flags&piface.UnmarshalAliasStrings != 0 }
 
 func (o unmarshalOptions) IsDefault() bool {
-	return o.flags == 0 && o.resolver == preg.GlobalTypes
+	return o.flags == 0 && o.resolver == preg.GlobalTypes && o.allocator == nil
+}
+
+// decodeString returns the string value of the field contents v.
+func (o unmarshalOptions) decodeString(v []byte) string {
+	if o.AliasStrings() {
+		return strs.UnsafeString(v)
+	}
+	return string(v)
+}
+
+// decodeBytes returns the bytes value of the field contents v.
+// The result is never nil.
+func (o unmarshalOptions) decodeBytes(v []byte) []byte {
+	if o.AliasBuffer() {
+		return v[:len(v):len(v)]
+	}
+	return append(emptyBuf[:], v...)
+}
+
+// decodeBytesNoZero returns the bytes value of the field contents v,
+// or nil if v is empty.
+func (o unmarshalOptions) decodeBytesNoZero(v []byte) []byte {
+	if len(v) == 0 {
+		return nil
+	}
+	return o.decodeBytes(v)
+}
+
+// newMessage returns a pointer to a new empty message of type mi.
+func (o unmarshalOptions) newMessage(mi *MessageInfo) pointer {
+	t := mi.GoReflectType.Elem()
+	if o.allocator != nil {
+		return pointerOfValue(o.allocator.New(t))
+	}
+	return pointerOfValue(reflect.New(t))
+}
+
+// reserveSlice ensures that the slice of type t pointed to by p
+// has capacity for at least n more elements.
+// It must only be called when an allocator is set.
+func (o unmarshalOptions) reserveSlice(p pointer, t reflect.Type, n int) {
+	sp := p.AsValueOf(t)
+	s := sp.Elem()
+	if s.Cap()-s.Len() >= n {
+		return
+	}
+	c := s.Len() + n
+	if c < 2*s.Cap() {
+		c = 2 * s.Cap()
+	}
+	sp.Elem().Set(reflect.AppendSlice(o.allocator.MakeSlice(t, c), s))
+}
+
+// reusedMessage returns the message retained from the singular message
+// field pointed to by p, if memory is being reused.
+func (o unmarshalOptions) reusedMessage(p pointer, f *coderFieldInfo) (pointer, bool) {
+	if o.reuse == nil {
+		return pointer{}, false
+	}
+	return o.reuse.message(p, f.ft)
+}
+
+// reusedOneof returns the oneof wrapper of type ot retained from the oneof
+// field of type ft pointed to by p, if memory is being reused.
+func (o unmarshalOptions) reusedOneof(p pointer, ft, ot reflect.Type) (reflect.Value, bool) {
+	if o.reuse == nil {
+		return reflect.Value{}, false
+	}
+	return o.reuse.oneof(p, ft, ot)
+}
+
+// reusedMapValue returns a message retained from the values of the map m,
+// if memory is being reused.
+func (o unmarshalOptions) reusedMapValue(m reflect.Value) (pointer, bool) {
+	if o.reuse == nil {
+		return pointer{}, false
+	}
+	return o.reuse.mapValue(m)
+}
+
+// spareMessage returns the message stored just beyond the end of the []*T
+// slice pointed to by p, if it was retained there by resetKeepCapacityPointer
+// and may be reused. It reports false if memory is not being reused or there
+// is no such message. Messages left in the spare capacity by the caller
+// truncating the slice are never reused.
+func (o unmarshalOptions) spareMessage(p pointer, f *coderFieldInfo) (pointer, bool) {
+	if o.reuse == nil {
+		return pointer{}, false
+	}
+	return o.reuse.listMessage(p, f.ft)
 }
 
 var lazyUnmarshalOptions = unmarshalOptions{
@@ -62,11 +163,17 @@ func (mi *MessageInfo) unmarshal(in piface.UnmarshalInput) (piface.UnmarshalOutp
 	} else {
 		p = in.Message.(*messageReflectWrapper).pointer()
 	}
-	out, err := mi.unmarshalPointer(in.Buf, p, 0, unmarshalOptions{
-		flags:    in.Flags,
-		resolver: in.Resolver,
-		depth:    in.Depth,
-	})
+	opts := unmarshalOptions{
+		flags:     in.Flags,
+		resolver:  in.Resolver,
+		depth:     in.Depth,
+		allocator: in.Allocator,
+	}
+	if opts.ReuseMemory() {
+		opts.reuse = mi.retainMessages(p)
+		defer opts.reuse.release()
+	}
+	out, err := mi.unmarshalPointer(in.Buf, p, 0, opts)
 	var flags piface.UnmarshalOutputFlags
 	if out.initialized {
 		flags |= piface.UnmarshalInitialized
@@ -290,3 +397,14 @@ func skipExtension(b []byte, xi *extensionFieldInfo, num protowire.Number, wtyp
 		return out, ValidationUnknown
 	}
 }
+
+// countVarints returns the number of varints in b,
+// assuming that b contains only well-formed varints.
+func countVarints(b []byte) (n int) {
+	for _, c := range b {
+		if c < 0x80 {
+			n++
+		}
+	}
+	return n
+}
@@ -5,13 +5,20 @@
 package impl_test
 
 import (
+	"bytes"
+	"reflect"
 	"testing"
 
+	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/internal/flags"
 	"google.golang.org/protobuf/internal/impl"
 	"google.golang.org/protobuf/internal/protobuild"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/testing/protopack"
 
+	lazypb "google.golang.org/protobuf/cmd/protoc-gen-go/testdata/lazy"
 	testpb "google.golang.org/protobuf/internal/testprotos/test"
 )
 
@@ -50,3 +57,227 @@ func TestLazyExtensions(t *testing.T) {
 	}
 	checkLazy("after unmarshal", m, flags.LazyUnmarshalExtensions)
 }
+
+type LazyFieldsMessage struct {
+	child    *testpb.TestAllTypes   `protobuf:"bytes,1,opt,name=child"`
+	children []*testpb.TestAllTypes `protobuf:"bytes,2,rep,name=children"`
+	required *testpb.TestRequired   `protobuf:"bytes,3,opt,name=required"`
+	Exported *testpb.TestAllTypes   `protobuf:"bytes,4,opt,name=exported"`
+
+	lazyFields impl.LazyFields
+}
+
+var lazyFieldsMessageType = impl.MessageInfo{
+	GoReflectType: reflect.TypeOf(new(LazyFieldsMessage)),
+	Desc: mustMakeMessageDesc("lazy.proto", protoreflect.Proto2, `
+		dependency: "internal/testprotos/test/test.proto"
+	`, `
+		name: "LazyFieldsMessage"
+		field: [
+			{name:"child"    number:1 label:LABEL_OPTIONAL type:TYPE_MESSAGE type_name:".goproto.proto.test.TestAllTypes" options:{lazy:true}},
+			{name:"children" number:2 label:LABEL_REPEATED type:TYPE_MESSAGE type_name:".goproto.proto.test.TestAllTypes" options:{lazy:true}},
+			{name:"required" number:3 label:LABEL_OPTIONAL type:TYPE_MESSAGE type_name:".goproto.proto.test.TestRequired" options:{lazy:true}},
+			{name:"exported" number:4 label:LABEL_OPTIONAL type:TYPE_MESSAGE type_name:".goproto.proto.test.TestAllTypes" options:{lazy:true}}
+		]
+	`, protoregistry.GlobalFiles),
+	Exporter: func(v interface{}, i int) interface{} {
+		m := v.(*LazyFieldsMessage)
+		switch i {
+		case 0:
+			return &m.child
+		case 1:
+			return &m.children
+		case 2:
+			return &m.required
+		case 4:
+			return &m.lazyFields
+		}
+		return nil
+	},
+}
+
+func (m *LazyFieldsMessage) ProtoReflect() protoreflect.Message {
+	return lazyFieldsMessageType.MessageOf(m)
+}
+
+func TestLazyFields(t *testing.T) {
+	fds := lazyFieldsMessageType.Desc.Fields()
+	checkLazy := func(when string, m *LazyFieldsMessage, name protoreflect.Name, want bool) {
+		t.Helper()
+		if got := impl.IsLazy(m.ProtoReflect(), fds.ByName(name)); got != want {
+			t.Errorf("%v: %v lazy=%v, want %v", when, name, got, want)
+		}
+	}
+
+	want := &LazyFieldsMessage{
+		child: &testpb.TestAllTypes{
+			OptionalInt32:         proto.Int32(1),
+			OptionalNestedMessage: &testpb.TestAllTypes_NestedMessage{A: proto.Int32(2)},
+		},
+		children: []*testpb.TestAllTypes{
+			{OptionalString: proto.String("a")},
+			{OptionalString: proto.String("b")},
+		},
+		required: &testpb.TestRequired{},
+		Exported: &testpb.TestAllTypes{OptionalInt32: proto.Int32(3)},
+	}
+	b, err := proto.MarshalOptions{AllowPartial: true}.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &LazyFieldsMessage{}
+	if err := (proto.UnmarshalOptions{AllowPartial: true}).Unmarshal(b, m); err != nil {
+		t.Fatal(err)
+	}
+	checkLazy("after unmarshal", m, "child", true)
+	checkLazy("after unmarshal", m, "children", true)
+	checkLazy("after unmarshal", m, "required", false) // not initialized
+	checkLazy("after unmarshal", m, "exported", false) // exported field
+	if m.child != nil || m.children != nil {
+		t.Errorf("after unmarshal: lazy fields decoded eagerly")
+	}
+	if !proto.Equal(m.Exported, want.Exported) {
+		t.Errorf("after unmarshal: exported field = %v, want %v", m.Exported, want.Exported)
+	}
+	if !m.ProtoReflect().Has(fds.ByName("child")) {
+		t.Errorf("after unmarshal: Has(child) = false, want true")
+	}
+
+	if got, err := (proto.MarshalOptions{AllowPartial: true}).Marshal(m); err != nil {
+		t.Errorf("Marshal error: %v", err)
+	} else if !bytes.Equal(got, b) {
+		t.Errorf("Marshal of undecoded fields mismatch:\ngot  %x\nwant %x", got, b)
+	}
+	if got := proto.Size(m); got != len(b) {
+		t.Errorf("Size = %v, want %v", got, len(b))
+	}
+
+	c := proto.Clone(m).(*LazyFieldsMessage)
+	checkLazy("after clone", c, "child", true)
+	if !proto.Equal(c, want) {
+		t.Errorf("Clone mismatch:\ngot  %v\nwant %v", c, want)
+	}
+	checkLazy("after Equal", c, "child", false)
+	checkLazy("after Equal", c, "children", false)
+
+	impl.Export{}.UnmarshalLazyField(m, 1)
+	checkLazy("after UnmarshalLazyField", m, "child", false)
+	checkLazy("after UnmarshalLazyField", m, "children", true)
+	if !proto.Equal(m.child, want.child) {
+		t.Errorf("UnmarshalLazyField: got %v, want %v", m.child, want.child)
+	}
+
+	m.ProtoReflect().Clear(fds.ByName("children"))
+	checkLazy("after Clear", m, "children", false)
+	if m.ProtoReflect().Has(fds.ByName("children")) {
+		t.Errorf("after Clear: Has(children) = true, want false")
+	}
+
+	m = &LazyFieldsMessage{}
+	if err := (proto.UnmarshalOptions{AllowPartial: true, DiscardUnknown: true}).Unmarshal(b, m); err != nil {
+		t.Fatal(err)
+	}
+	checkLazy("after unmarshal with options", m, "child", false)
+	if !proto.Equal(m, want) {
+		t.Errorf("Unmarshal with options mismatch:\ngot  %v\nwant %v", m, want)
+	}
+
+	// Setting a field drops its undecoded data.
+	if err := (proto.UnmarshalOptions{AllowPartial: true}).Unmarshal(b, m); err != nil {
+		t.Fatal(err)
+	}
+	impl.Export{}.DiscardLazyField(m, 1)
+	m.child = &testpb.TestAllTypes{OptionalInt32: proto.Int32(4)}
+	checkLazy("after DiscardLazyField", m, "child", false)
+	checkLazy("after DiscardLazyField", m, "children", true)
+	if got := m.ProtoReflect().Get(fds.ByName("child")).Message().Interface(); !proto.Equal(got, m.child) {
+		t.Errorf("after DiscardLazyField: Get(child) = %v, want %v", got, m.child)
+	}
+	want.child = m.child
+	if !proto.Equal(m, want) {
+		t.Errorf("after DiscardLazyField mismatch:\ngot  %v\nwant %v", m, want)
+	}
+
+	// Invalid data is reported by Unmarshal, even for lazy fields.
+	bad := protopack.Message{
+		protopack.Tag{1, protopack.BytesType}, protopack.LengthPrefix{
+			protopack.Tag{1, protopack.VarintType}, // missing value
+		},
+	}.Marshal()
+	if err := proto.Unmarshal(bad, &LazyFieldsMessage{}); err == nil {
+		t.Errorf("Unmarshal of invalid lazy field: got nil error")
+	}
+}
+
+func TestLazyFieldsRecursionLimit(t *testing.T) {
+	// Nest messages beyond the recursion limit used to decode lazy fields.
+	var inner []byte
+	for i := 0; i < protowire.DefaultRecursionLimit/2+1; i++ {
+		inner = protopack.Message{
+			protopack.Tag{18, protopack.BytesType}, protopack.LengthPrefix{
+				protopack.Tag{2, protopack.BytesType}, protopack.Bytes(inner),
+			},
+		}.Marshal()
+	}
+	b := protopack.Message{
+		protopack.Tag{1, protopack.BytesType}, protopack.Bytes(inner),
+	}.Marshal()
+
+	m := &LazyFieldsMessage{}
+	opts := proto.UnmarshalOptions{RecursionLimit: 2 * protowire.DefaultRecursionLimit}
+	if err := opts.Unmarshal(b, m); err != nil {
+		t.Fatal(err)
+	}
+	if impl.IsLazy(m.ProtoReflect(), lazyFieldsMessageType.Desc.Fields().ByNumber(1)) {
+		t.Errorf("field nested beyond the recursion limit is lazy")
+	}
+	if m.child == nil {
+		t.Errorf("field nested beyond the recursion limit was not decoded")
+	}
+	if err := proto.Unmarshal(b, &LazyFieldsMessage{}); err == nil {
+		t.Errorf("Unmarshal beyond the recursion limit: got nil error")
+	}
+}
+
+func TestLazyFieldsGenerated(t *testing.T) {
+	src := &lazypb.Message{EagerMessage: &lazypb.Message{}}
+	src.SetLazyMessage(&lazypb.Message{EagerMessage: &lazypb.Message{}})
+	src.SetLazyRepeated([]*lazypb.Message{{}, {EagerMessage: &lazypb.Message{}}})
+	b, err := proto.Marshal(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &lazypb.Message{}
+	if err := proto.Unmarshal(b, m); err != nil {
+		t.Fatal(err)
+	}
+	if m.EagerMessage == nil {
+		t.Errorf("eager_message is not set after Unmarshal")
+	}
+	if got := m.GetLazyMessage(); got == nil || got.EagerMessage == nil {
+		t.Errorf("GetLazyMessage() = %v, want message with eager_message set", got)
+	}
+	if got := len(m.GetLazyRepeated()); got != 2 {
+		t.Errorf("len(GetLazyRepeated()) = %v, want 2", got)
+	}
+	if !proto.Equal(m, src) {
+		t.Errorf("Unmarshal mismatch:\ngot  %v\nwant %v", m, src)
+	}
+
+	// A setter replaces any undecoded data.
+	m = &lazypb.Message{}
+	if err := proto.Unmarshal(b, m); err != nil {
+		t.Fatal(err)
+	}
+	m.SetLazyMessage(nil)
+	if got := m.GetLazyMessage(); got != nil {
+		t.Errorf("GetLazyMessage() after SetLazyMessage(nil) = %v, want nil", got)
+	}
+	if b2, err := proto.Marshal(m); err != nil {
+		t.Fatal(err)
+	} else if len(b2) >= len(b) {
+		t.Errorf("Marshal after SetLazyMessage(nil) still includes lazy_message")
+	}
+}
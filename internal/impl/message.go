@@ -134,6 +134,7 @@ type (
 	unknownFieldsA  = []byte
 	unknownFieldsB  = *[]byte
 	ExtensionFields = map[int32]ExtensionField
+	LazyFields      = *lazyFields
 )
 
 var (
@@ -142,6 +143,7 @@ var (
 	unknownFieldsAType  = reflect.TypeOf(unknownFieldsA(nil))
 	unknownFieldsBType  = reflect.TypeOf(unknownFieldsB(nil))
 	extensionFieldsType = reflect.TypeOf(ExtensionFields(nil))
+	lazyFieldsType      = reflect.TypeOf(LazyFields(nil))
 )
 
 type structInfo struct {
@@ -153,6 +155,7 @@ type structInfo struct {
 	unknownType     reflect.Type
 	extensionOffset offset
 	extensionType   reflect.Type
+	lazyOffset      offset
 
 	// 保存字段 Number 与 reflect.StructField 的映射。
 	fieldsByNumber        map[pref.FieldNumber]reflect.StructField
@@ -171,6 +174,7 @@ func (mi *MessageInfo) makeStructInfo(t reflect.Type) structInfo {
 		weakOffset:      invalidOffset,
 		unknownOffset:   invalidOffset,
 		extensionOffset: invalidOffset,
+		lazyOffset:      invalidOffset,
 
 		fieldsByNumber:        map[pref.FieldNumber]reflect.StructField{},
 		oneofsByName:          map[pref.Name]reflect.StructField{},
@@ -208,6 +212,10 @@ fieldLoop:
 				si.extensionOffset = offsetOf(f, mi.Exporter)
 				si.extensionType = f.Type
 			}
+		case genid.LazyFields_goname:
+			if f.Type == lazyFieldsType {
+				si.lazyOffset = offsetOf(f, mi.Exporter)
+			}
 		default:
 			// eg.
 			//
@@ -92,6 +92,9 @@ func (mi *MessageInfo) makeKnownFieldsFunc(si structInfo) {
 		default:
 			fi = fieldInfoForScalar(fd, fs, mi.Exporter)
 		}
+		if isLazyField(fd, fs, si) {
+			fi = mi.fieldInfoForLazy(fi)
+		}
 
 		// 将 fi 和字段 Number 关联起来
 		mi.fields[fd.Number()] = &fi
@@ -48,6 +48,9 @@ var invalidOffset = offset{index: -1}
 // zeroOffset is a noop when calling pointer.Apply.
 var zeroOffset = offset{index: 0}
 
+// selfOffset is an offset which, when applied, yields the struct pointer itself.
+var selfOffset = offset{index: 0, export: func(v interface{}, _ int) interface{} { return v }}
+
 // pointer is an abstract representation of a pointer to a struct or field.
 type pointer struct{ v reflect.Value }
 
@@ -128,6 +131,7 @@ func (p pointer) WeakFields() *weakFields  { return (*weakFields)(p.v.Interface(
 func (p pointer) Extensions() *map[int32]ExtensionField {
 	return p.v.Interface().(*map[int32]ExtensionField)
 }
+func (p pointer) LazyFields() *LazyFields { return p.v.Interface().(*LazyFields) }
 
 func (p pointer) Elem() pointer {
 	return pointer{v: p.v.Elem()}
@@ -37,6 +37,9 @@ var invalidOffset = ^offset(0)
 // zeroOffset is a noop when calling pointer.Apply.
 var zeroOffset = offset(0)
 
+// selfOffset is an offset which, when applied, yields the struct pointer itself.
+var selfOffset = offset(0)
+
 // pointer is a pointer to a message struct or field.
 type pointer struct{
 	p unsafe.Pointer
@@ -119,6 +122,7 @@ func (p pointer) BytesPtr() **[]byte                    { return (**[]byte)(p.p)
 func (p pointer) BytesSlice() *[][]byte                 { return (*[][]byte)(p.p) }
 func (p pointer) WeakFields() *weakFields               { return (*weakFields)(p.p) }
 func (p pointer) Extensions() *map[int32]ExtensionField { return (*map[int32]ExtensionField)(p.p) }
+func (p pointer) LazyFields() *LazyFields               { return (*LazyFields)(p.p) }
 
 func (p pointer) Elem() pointer {
 	return pointer{p: *(*unsafe.Pointer)(p.p)}
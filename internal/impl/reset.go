@@ -0,0 +1,347 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	piface "google.golang.org/protobuf/runtime/protoiface"
+)
+
+// resetKind is the operation used to reset a struct field.
+type resetKind uint8
+
+const (
+	resetZero        resetKind = iota // set the field to its zero value
+	resetList                         // truncate the slice
+	resetMessage                      // retain the message and clear the field
+	resetMessageList                  // retain each message and truncate the slice
+	resetOneof                        // retain the oneof wrapper and clear the field
+	resetMap                          // delete every entry
+	resetMessageMap                   // retain each value and delete every entry
+)
+
+// resetFieldInfo describes how to reset a struct field
+// while retaining the memory allocated for its contents.
+type resetFieldInfo struct {
+	kind   resetKind
+	offset offset
+	ft     reflect.Type
+	zero   reflect.Value
+	mi     *MessageInfo // message type of the field, list elements, or map values
+
+	// oneofMessages are the message types of the fields of the oneof
+	// wrappers, by wrapper type, for wrappers of message fields.
+	oneofMessages map[reflect.Type]*MessageInfo
+}
+
+func (mi *MessageInfo) makeResetFields(si structInfo) {
+	add := func(kind resetKind, fs reflect.StructField, childMessage *MessageInfo) *resetFieldInfo {
+		mi.resetFields = append(mi.resetFields, resetFieldInfo{
+			kind:   kind,
+			offset: offsetOf(fs, mi.Exporter),
+			ft:     fs.Type,
+			zero:   reflect.Zero(fs.Type),
+			mi:     childMessage,
+		})
+		return &mi.resetFields[len(mi.resetFields)-1]
+	}
+	fields := mi.Desc.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if od := fd.ContainingOneof(); od != nil && !od.IsSynthetic() {
+			continue
+		}
+		if fd.IsWeak() {
+			continue
+		}
+		fs, ok := si.fieldsByNumber[fd.Number()]
+		if !ok {
+			continue
+		}
+		switch {
+		case fd.IsMap():
+			if childMessage := getMessageInfo(fs.Type.Elem()); fd.MapValue().Message() != nil && childMessage != nil {
+				add(resetMessageMap, fs, childMessage)
+			} else {
+				add(resetMap, fs, nil)
+			}
+		case fd.IsList():
+			if childMessage := getMessageInfo(fs.Type.Elem()); fd.Message() != nil && childMessage != nil {
+				add(resetMessageList, fs, childMessage)
+			} else {
+				add(resetList, fs, nil)
+			}
+		case fd.Message() != nil:
+			if childMessage := getMessageInfo(fs.Type); childMessage != nil {
+				add(resetMessage, fs, childMessage)
+			} else {
+				add(resetZero, fs, nil)
+			}
+		default:
+			add(resetZero, fs, nil)
+		}
+	}
+	for i := 0; i < mi.Desc.Oneofs().Len(); i++ {
+		od := mi.Desc.Oneofs().Get(i)
+		fs, ok := si.oneofsByName[od.Name()]
+		if !ok || od.IsSynthetic() {
+			continue
+		}
+		rf := add(resetOneof, fs, nil)
+		rf.oneofMessages = make(map[reflect.Type]*MessageInfo)
+		for j := 0; j < od.Fields().Len(); j++ {
+			fd := od.Fields().Get(j)
+			ot, ok := si.oneofWrappersByNumber[fd.Number()]
+			if !ok {
+				continue
+			}
+			if fd.Message() != nil && !fd.IsWeak() {
+				rf.oneofMessages[ot] = getMessageInfo(ot.Field(0).Type)
+			}
+		}
+	}
+	if si.weakOffset.IsValid() {
+		mi.resetFields = append(mi.resetFields, resetFieldInfo{
+			kind:   resetZero,
+			offset: si.weakOffset,
+			ft:     si.weakType,
+			zero:   reflect.Zero(si.weakType),
+		})
+	}
+	mi.lazyOffset = si.lazyOffset
+}
+
+// reset is protoreflect.Methods.Reset.
+func (mi *MessageInfo) reset(in piface.ResetInput) piface.ResetOutput {
+	if in.Flags&piface.ResetKeepCapacity == 0 {
+		return piface.ResetOutput{}
+	}
+	p, ok := mi.getPointer(in.Message)
+	if !ok {
+		return piface.ResetOutput{}
+	}
+	if p.IsNil() {
+		panic("invalid value: resetting nil message")
+	}
+	mi.resetKeepCapacityPointer(p, nil)
+	return piface.ResetOutput{Flags: piface.ResetComplete}
+}
+
+// resetKeepCapacityPointer clears the message pointed to by p, retaining
+// the storage of repeated fields and maps in place.
+//
+// If r is non-nil, the messages in the fields of p are reset in turn and
+// retained in r, for reuse by the unmarshaling into p which follows.
+// Otherwise they are dropped, since the message would be left holding
+// messages it does not contain.
+func (mi *MessageInfo) resetKeepCapacityPointer(p pointer, r *reuseTable) {
+	mi.init()
+	for i := range mi.resetFields {
+		rf := &mi.resetFields[i]
+		fp := p.Apply(rf.offset)
+		v := fp.AsValueOf(rf.ft).Elem()
+		switch rf.kind {
+		case resetZero:
+			v.Set(rf.zero)
+		case resetList:
+			v.SetLen(0)
+		case resetMessage:
+			if r != nil && !v.IsNil() {
+				// Copy the pointer out of the field, which is cleared below.
+				if e := pointerOfIface(v.Interface()); r.retain(e, rf.mi) {
+					r.messages[v.UnsafeAddr()] = e
+				}
+			}
+			v.Set(rf.zero)
+		case resetMessageList:
+			s := fp.PointerSlice()
+			for j, e := range s {
+				if r == nil || e.IsNil() || !r.retain(e, rf.mi) {
+					v.Index(j).Set(reflect.Zero(rf.ft.Elem()))
+				}
+			}
+			if r != nil && len(s) > 0 {
+				r.lists[v.UnsafeAddr()] = len(s)
+			}
+			v.SetLen(0)
+		case resetOneof:
+			if r != nil && !v.IsNil() && !v.Elem().IsNil() && r.retainOneof(v.Elem(), rf.oneofMessages) {
+				r.oneofs[v.UnsafeAddr()] = v.Elem()
+			}
+			v.Set(rf.zero)
+		case resetMap, resetMessageMap:
+			if v.Len() == 0 {
+				continue
+			}
+			for iter := mapRange(v); iter.Next(); {
+				if rf.kind == resetMessageMap && r != nil {
+					if e := pointerOfValue(iter.Value()); !e.IsNil() && r.retain(e, rf.mi) {
+						r.maps[v.Pointer()] = append(r.maps[v.Pointer()], e)
+					}
+				}
+				v.SetMapIndex(iter.Key(), reflect.Value{})
+			}
+		}
+	}
+	if mi.lazyOffset.IsValid() {
+		*p.Apply(mi.lazyOffset).LazyFields() = nil
+	}
+	if mi.unknownOffset.IsValid() {
+		if u := mi.getUnknownBytes(p); u != nil {
+			*u = (*u)[:0]
+		}
+	}
+	if mi.extensionOffset.IsValid() {
+		ext := *p.Apply(mi.extensionOffset).Extensions()
+		for num := range ext {
+			delete(ext, num)
+		}
+	}
+	if mi.sizecacheOffset.IsValid() {
+		atomic.StoreInt32(p.Apply(mi.sizecacheOffset).Int32(), 0)
+	}
+}
+
+// reuseTable holds the messages retained by resetKeepCapacityPointer while
+// unmarshaling into a message with the UnmarshalReuseMemory flag, which is
+// reset at the start of the call. The messages are keyed by the address of
+// the field which held them, or by the map for map values, and each is
+// reused at most once. A table is only used for the duration of the call.
+type reuseTable struct {
+	seen     map[uintptr]bool          // messages and oneof wrappers which have been retained
+	messages map[uintptr]pointer       // messages of singular fields
+	oneofs   map[uintptr]reflect.Value // oneof wrappers
+	lists    map[uintptr]int           // length of lists, whose spare capacity holds their messages
+	maps     map[uintptr][]pointer     // message values of maps
+}
+
+var reuseTablePool = sync.Pool{
+	New: func() interface{} {
+		return &reuseTable{
+			seen:     make(map[uintptr]bool),
+			messages: make(map[uintptr]pointer),
+			oneofs:   make(map[uintptr]reflect.Value),
+			lists:    make(map[uintptr]int),
+			maps:     make(map[uintptr][]pointer),
+		}
+	},
+}
+
+// retainMessages resets the message pointed to by p, retaining its
+// messages in a new table, which must be released once unmarshaling
+// into p is done.
+func (mi *MessageInfo) retainMessages(p pointer) *reuseTable {
+	r := reuseTablePool.Get().(*reuseTable)
+	if !r.retain(p, mi) {
+		panic("invalid reuse table")
+	}
+	return r
+}
+
+// release clears the table and returns it to the pool.
+func (r *reuseTable) release() {
+	for k := range r.seen {
+		delete(r.seen, k)
+	}
+	for k := range r.messages {
+		delete(r.messages, k)
+	}
+	for k := range r.oneofs {
+		delete(r.oneofs, k)
+	}
+	for k := range r.lists {
+		delete(r.lists, k)
+	}
+	for k := range r.maps {
+		delete(r.maps, k)
+	}
+	reuseTablePool.Put(r)
+}
+
+// retain resets the message pointed to by p, of type mi, retaining its
+// messages in turn. It reports false if the message has already been
+// retained, as when it is reachable from several fields, since it must
+// only be reused once.
+func (r *reuseTable) retain(p pointer, mi *MessageInfo) bool {
+	k := p.AsValueOf(mi.GoReflectType.Elem()).Pointer()
+	if r.seen[k] {
+		return false
+	}
+	r.seen[k] = true
+	mi.resetKeepCapacityPointer(p, r)
+	return true
+}
+
+// retainOneof resets the field of the oneof wrapper w, which is a pointer
+// to a wrapper struct, retaining the message in it if its type is in ms.
+// It reports false if the wrapper has already been retained.
+func (r *reuseTable) retainOneof(w reflect.Value, ms map[reflect.Type]*MessageInfo) bool {
+	k := w.Pointer()
+	if r.seen[k] {
+		return false
+	}
+	r.seen[k] = true
+	f := w.Elem().Field(0)
+	if mi := ms[w.Elem().Type()]; mi != nil && !f.IsNil() && r.retain(pointerOfValue(f), mi) {
+		return true
+	}
+	f.Set(reflect.Zero(f.Type()))
+	return true
+}
+
+// message returns the message retained from the singular message field
+// of type ft pointed to by p.
+func (r *reuseTable) message(p pointer, ft reflect.Type) (pointer, bool) {
+	k := p.AsValueOf(ft).Pointer()
+	mp, ok := r.messages[k]
+	if ok {
+		delete(r.messages, k)
+	}
+	return mp, ok
+}
+
+// oneof returns the wrapper of type ot retained from the oneof field
+// of type ft pointed to by p.
+func (r *reuseTable) oneof(p pointer, ft, ot reflect.Type) (reflect.Value, bool) {
+	k := p.AsValueOf(ft).Pointer()
+	w, ok := r.oneofs[k]
+	if !ok || w.Elem().Type() != ot {
+		return reflect.Value{}, false
+	}
+	delete(r.oneofs, k)
+	return w, true
+}
+
+// listMessage returns the message retained in the spare capacity of the
+// []*T field of type ft pointed to by p, just beyond the end of the slice.
+func (r *reuseTable) listMessage(p pointer, ft reflect.Type) (pointer, bool) {
+	sp := p.AsValueOf(ft)
+	s := sp.Elem()
+	n := s.Len()
+	if n >= r.lists[sp.Pointer()] || n == s.Cap() {
+		return pointer{}, false
+	}
+	s.SetLen(n + 1)
+	v := s.Index(n)
+	s.SetLen(n)
+	if v.IsNil() {
+		return pointer{}, false
+	}
+	return pointerOfValue(v), true
+}
+
+// mapValue returns a message retained from the values of the map m.
+func (r *reuseTable) mapValue(m reflect.Value) (pointer, bool) {
+	k := m.Pointer()
+	vs := r.maps[k]
+	if len(vs) == 0 {
+		return pointer{}, false
+	}
+	r.maps[k] = vs[:len(vs)-1]
+	return vs[len(vs)-1], true
+}
@@ -64,9 +64,13 @@ func Validate(mt pref.MessageType, in piface.UnmarshalInput) (out piface.Unmarsh
 	if in.Resolver == nil {
 		in.Resolver = preg.GlobalTypes
 	}
+	if in.Depth == 0 {
+		in.Depth = protowire.DefaultRecursionLimit
+	}
 	o, st := mi.validate(in.Buf, 0, unmarshalOptions{
 		flags:    in.Flags,
 		resolver: in.Resolver,
+		depth:    in.Depth,
 	})
 	if o.initialized {
 		out.Flags |= piface.UnmarshalInitialized
@@ -247,6 +251,10 @@ func (mi *MessageInfo) validate(b []byte, groupTag protowire.Number, opts unmars
 	start := len(b)
 State:
 	for len(states) > 0 {
+		if len(states) > opts.depth {
+			// Unmarshal reports the recursion limit being exceeded.
+			return out, ValidationUnknown
+		}
 		st := &states[len(states)-1]
 		for len(b) > 0 {
 			// Parse the tag (field number and wire type).
@@ -0,0 +1,59 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package required finds the required fields which are not populated
+// in a message.
+package required
+
+import (
+	"google.golang.org/protobuf/internal/order"
+	"google.golang.org/protobuf/reflect/protopath"
+	pref "google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Range calls f for every required field which is not populated in m or in
+// any message within m, in a deterministic order. The path passed to f is the
+// given path to m followed by the path from m to the field.
+func Range(m pref.Message, p protopath.Path, f func(protopath.Path, pref.FieldDescriptor)) {
+	md := m.Descriptor()
+	fds := md.Fields()
+	for i := 0; i < fds.Len(); i++ {
+		fd := fds.Get(i)
+		if fd.Cardinality() == pref.Required && !m.Has(fd) {
+			f(appendStep(p, protopath.FieldAccess(fd)), fd)
+		}
+	}
+	order.RangeFields(m, order.IndexNameFieldOrder, func(fd pref.FieldDescriptor, v pref.Value) bool {
+		switch {
+		case fd.IsList():
+			if fd.Message() == nil {
+				return true
+			}
+			fp := appendStep(p, protopath.FieldAccess(fd))
+			for i, list := 0, v.List(); i < list.Len(); i++ {
+				Range(list.Get(i).Message(), appendStep(fp, protopath.ListIndex(i)), f)
+			}
+		case fd.IsMap():
+			if fd.MapValue().Message() == nil {
+				return true
+			}
+			fp := appendStep(p, protopath.FieldAccess(fd))
+			order.RangeEntries(v.Map(), order.GenericKeyOrder, func(k pref.MapKey, v pref.Value) bool {
+				Range(v.Message(), appendStep(fp, protopath.MapIndex(k)), f)
+				return true
+			})
+		default:
+			if fd.Message() == nil {
+				return true
+			}
+			Range(v.Message(), appendStep(p, protopath.FieldAccess(fd)), f)
+		}
+		return true
+	})
+}
+
+// appendStep returns a new path consisting of p followed by s.
+func appendStep(p protopath.Path, s protopath.Step) protopath.Path {
+	return append(p[:len(p):len(p)], s)
+}
@@ -0,0 +1,51 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package required_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"google.golang.org/protobuf/internal/required"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protopath"
+	pref "google.golang.org/protobuf/reflect/protoreflect"
+
+	pb2 "google.golang.org/protobuf/internal/testprotos/textpb2"
+)
+
+func TestRange(t *testing.T) {
+	m := &pb2.IndirectRequired{
+		OptNested: &pb2.NestedWithRequired{},
+		RptNested: []*pb2.NestedWithRequired{
+			{ReqString: proto.String("a")},
+			{},
+		},
+		StrToNested: map[string]*pb2.NestedWithRequired{
+			"c": {},
+			"a": {},
+			"b": {ReqString: proto.String("b")},
+		},
+		Union: &pb2.IndirectRequired_OneofNested{
+			OneofNested: &pb2.NestedWithRequired{},
+		},
+	}
+	root := protopath.Path{protopath.Root(m.ProtoReflect().Descriptor())}
+	var got []string
+	required.Range(m.ProtoReflect(), root, func(p protopath.Path, fd pref.FieldDescriptor) {
+		got = append(got, p.String())
+	})
+	want := []string{
+		"(pb2.IndirectRequired).opt_nested.req_string",
+		"(pb2.IndirectRequired).rpt_nested[1].req_string",
+		`(pb2.IndirectRequired).str_to_nested["a"].req_string`,
+		`(pb2.IndirectRequired).str_to_nested["c"].req_string`,
+		"(pb2.IndirectRequired).oneof_nested.req_string",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Range() paths mismatch (-want +got):\n%s", diff)
+	}
+}
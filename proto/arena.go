@@ -0,0 +1,106 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proto
+
+import (
+	"reflect"
+)
+
+// arenaSlabSize is the approximate size in bytes of each block of memory
+// allocated by an Arena.
+const arenaSlabSize = 16 << 10
+
+// Arena is a protoiface.Allocator which carves the messages and repeated
+// field storage created by Unmarshal out of larger blocks of memory,
+// reducing the number of individual allocations needed to decode a tree
+// of messages.
+//
+// Release releases every value allocated from the Arena at once, and the
+// blocks are reused by later allocations. The values are owned by the Arena:
+// the caller must not use any message or repeated field allocated from it,
+// nor any message which refers to one, after calling Release. Such values
+// are zeroed by Release and then overwritten as the blocks are reused,
+// so a retained value silently changes contents, as a message which
+// refers to a released sub-message appears to lose or change its fields.
+// Values which must outlive Release should be copied with Clone first.
+// Values larger than half a block are allocated individually and are
+// neither zeroed nor reused.
+//
+// Until Release is called, the values are ordinary Go values managed by the
+// garbage collector; a block is reclaimed once the Arena and all of its
+// values are unreachable. An Arena retains its blocks across calls to
+// Release, so its memory use is that of the largest tree decoded between
+// two calls to Release.
+//
+// The zero value is ready for use. An Arena is not safe for concurrent use.
+type Arena struct {
+	slabs map[reflect.Type]*arenaSlabs
+}
+
+// arenaSlabs are the blocks of values of a single type.
+type arenaSlabs struct {
+	list []reflect.Value // of [N]T, in the order they were allocated
+	cur  int             // index in list of the block being allocated from
+	next int             // index of the next unused element of list[cur]
+}
+
+// New returns a pointer to a new zero value of type t.
+func (a *Arena) New(t reflect.Type) reflect.Value {
+	if t.Size() == 0 || t.Size() > arenaSlabSize/2 {
+		return reflect.New(t)
+	}
+	s := a.slab(t, 1)
+	v := s.list[s.cur].Index(s.next).Addr()
+	s.next++
+	return v
+}
+
+// MakeSlice returns a new slice of type t with zero length
+// and a capacity of at least n.
+func (a *Arena) MakeSlice(t reflect.Type, n int) reflect.Value {
+	et := t.Elem()
+	if n <= 0 || et.Size() == 0 || uintptr(n)*et.Size() > arenaSlabSize/2 {
+		return reflect.MakeSlice(t, 0, n)
+	}
+	s := a.slab(et, n)
+	v := s.list[s.cur].Slice3(s.next, s.next, s.next+n).Convert(t)
+	s.next += n
+	return v
+}
+
+// slab returns the blocks of type t, where the current block has room
+// for at least n values.
+func (a *Arena) slab(t reflect.Type, n int) *arenaSlabs {
+	s := a.slabs[t]
+	if s == nil {
+		if a.slabs == nil {
+			a.slabs = make(map[reflect.Type]*arenaSlabs)
+		}
+		s = &arenaSlabs{}
+		a.slabs[t] = s
+	}
+	for ; s.cur < len(s.list); s.cur, s.next = s.cur+1, 0 {
+		if s.list[s.cur].Len()-s.next >= n {
+			return s
+		}
+	}
+	size := int(arenaSlabSize / t.Size())
+	if size < n {
+		size = n
+	}
+	s.list = append(s.list, reflect.New(reflect.ArrayOf(size, t)).Elem())
+	return s
+}
+
+// Release releases all values allocated from the Arena, which must no
+// longer be used, and zeroes their blocks for reuse by later allocations.
+func (a *Arena) Release() {
+	for _, s := range a.slabs {
+		for i := 0; i < len(s.list) && i <= s.cur; i++ {
+			s.list[i].Set(reflect.Zero(s.list[i].Type()))
+		}
+		s.cur, s.next = 0, 0
+	}
+}
@@ -36,6 +36,26 @@ type UnmarshalOptions struct {
 	// If DiscardUnknown is set, unknown fields are ignored.
 	DiscardUnknown bool
 
+	// AliasBuffer permits Unmarshal to avoid copying the contents of bytes
+	// fields out of the input buffer. Decoded []byte values may share memory
+	// with the input. The caller must not modify or reuse the input buffer
+	// for as long as the message is in use.
+	//
+	// Aliasing is an optimization; messages which do not use the fast-path
+	// unmarshal implementation may copy the input regardless.
+	AliasBuffer bool
+
+	// AliasStrings permits Unmarshal to avoid copying the contents of string
+	// fields out of the input buffer, unless built with the purego tag.
+	// Go strings are immutable, so the input buffer must never be modified
+	// again while any decoded string may still be in use, including strings
+	// copied out of the message. Modifying it changes the contents of such
+	// strings, which may break maps and other code relying on immutability.
+	//
+	// Like AliasBuffer, aliasing is an optimization which messages that do
+	// not use the fast-path unmarshal implementation may ignore.
+	AliasStrings bool
+
 	// Resolver is used for looking up types when unmarshaling extension fields.
 	// If nil, this defaults to using protoregistry.GlobalTypes.
 	Resolver interface {
@@ -46,6 +66,44 @@ type UnmarshalOptions struct {
 	// RecursionLimit limits how deeply messages may be nested.
 	// If zero, a default limit is applied.
 	RecursionLimit int
+
+	// Mask, if set, restricts unmarshaling to the fields selected by
+	// the PathSet. The contents of other fields are skipped without being
+	// decoded. For example, to decode only the fields in a FieldMask:
+	//
+	//	mask, err := proto.NewPathSet(m.ProtoReflect().Descriptor(), fm.GetPaths()...)
+	//
+	// Since required fields may be skipped, Mask is usually used together
+	// with AllowPartial. Messages along the selected paths are decoded using
+	// the slower reflection-based implementation; fields selected entirely
+	// are decoded as usual.
+	Mask *PathSet
+
+	// If KeepMasked is set, fields skipped because of Mask are retained
+	// as unknown fields rather than discarded.
+	KeepMasked bool
+
+	// Allocator, if set, provides memory for the messages and repeated
+	// fields created by Unmarshal. See Arena for an implementation which
+	// allocates in bulk. Messages which do not use the fast-path unmarshal
+	// implementation allocate values individually.
+	//
+	// The Allocator determines how long its values may be used; values from
+	// an Arena must not be used after the Arena is released.
+	Allocator protoiface.Allocator
+
+	// ReuseMemory permits Unmarshal to reuse memory held by a message
+	// for its previous contents. When Merge is false, the message is reset
+	// keeping the backing arrays of repeated fields and maps, as by
+	// ResetKeepCapacity, and the messages in its singular, oneof, repeated,
+	// and map fields are themselves reset and reused in place of new
+	// allocations for the same fields. It has no effect when Merge is true.
+	// Sub-messages which were reachable from the destination message
+	// must not be used after it has been unmarshaled into.
+	//
+	// Messages which do not use the fast-path unmarshal implementation
+	// only reuse the backing arrays of repeated fields and maps.
+	ReuseMemory bool
 }
 
 // Unmarshal parses the wire-format message in b and places the result in m.
@@ -83,24 +141,45 @@ func (o UnmarshalOptions) unmarshal(b []byte, m protoreflect.Message) (out proto
 	if o.Resolver == nil {
 		o.Resolver = protoregistry.GlobalTypes
 	}
-	if !o.Merge {
-		Reset(m.Interface())
+	if o.Mask != nil && o.Mask.md.FullName() != m.Descriptor().FullName() {
+		return out, errors.New("field mask for %v used to unmarshal %v", o.Mask.md.FullName(), m.Descriptor().FullName())
+	}
+	methods := protoMethods(m)
+	fast := o.Mask == nil && methods != nil && methods.Unmarshal != nil &&
+		!(o.DiscardUnknown && methods.Flags&protoiface.SupportUnmarshalDiscardUnknown == 0)
+	// With UnmarshalReuseMemory, the fast-path unmarshaler resets the message
+	// itself so that it may reuse the messages it contains.
+	reuse := o.ReuseMemory && !o.Merge && fast && methods.Flags&protoiface.SupportUnmarshalReuseMemory != 0
+	if !o.Merge && !reuse {
+		if o.ReuseMemory {
+			resetKeepCapacity(m)
+		} else {
+			Reset(m.Interface())
+		}
 	}
 	allowPartial := o.AllowPartial
 	o.Merge = true
 	o.AllowPartial = true
-	methods := protoMethods(m)
-	if methods != nil && methods.Unmarshal != nil &&
-		!(o.DiscardUnknown && methods.Flags&protoiface.SupportUnmarshalDiscardUnknown == 0) {
+	if fast {
 		in := protoiface.UnmarshalInput{
-			Message:  m,
-			Buf:      b,
-			Resolver: o.Resolver,
-			Depth:    o.RecursionLimit,
+			Message:   m,
+			Buf:       b,
+			Resolver:  o.Resolver,
+			Depth:     o.RecursionLimit,
+			Allocator: o.Allocator,
 		}
 		if o.DiscardUnknown {
 			in.Flags |= protoiface.UnmarshalDiscardUnknown
 		}
+		if o.AliasBuffer {
+			in.Flags |= protoiface.UnmarshalAliasBuffer
+		}
+		if o.AliasStrings {
+			in.Flags |= protoiface.UnmarshalAliasStrings
+		}
+		if reuse {
+			in.Flags |= protoiface.UnmarshalReuseMemory
+		}
 		out, err = methods.Unmarshal(in)
 	} else {
 		o.RecursionLimit--
@@ -143,6 +222,24 @@ func (o UnmarshalOptions) unmarshalMessageSlow(b []byte, m protoreflect.Message)
 			return errDecode
 		}
 
+		// Skip fields excluded by the mask.
+		fo := o
+		if o.Mask != nil {
+			sub, ok := o.Mask.lookup(num)
+			if !ok {
+				valLen := protowire.ConsumeFieldValue(num, wtyp, b[tagLen:])
+				if valLen < 0 {
+					return errDecode
+				}
+				if o.KeepMasked {
+					m.SetUnknown(append(m.GetUnknown(), b[:tagLen+valLen]...))
+				}
+				b = b[tagLen+valLen:]
+				continue
+			}
+			fo.Mask = sub
+		}
+
 		// Find the field descriptor for this field number.
 		fd := fields.ByNumber(num)
 		if fd == nil && md.ExtensionRanges().Has(num) {
@@ -169,11 +266,11 @@ func (o UnmarshalOptions) unmarshalMessageSlow(b []byte, m protoreflect.Message)
 		switch {
 		case err != nil:
 		case fd.IsList():
-			valLen, err = o.unmarshalList(b[tagLen:], wtyp, m.Mutable(fd).List(), fd)
+			valLen, err = fo.unmarshalList(b[tagLen:], wtyp, m.Mutable(fd).List(), fd)
 		case fd.IsMap():
-			valLen, err = o.unmarshalMap(b[tagLen:], wtyp, m.Mutable(fd).Map(), fd)
+			valLen, err = fo.unmarshalMap(b[tagLen:], wtyp, m.Mutable(fd).Map(), fd)
 		default:
-			valLen, err = o.unmarshalSingular(b[tagLen:], wtyp, m, fd)
+			valLen, err = fo.unmarshalSingular(b[tagLen:], wtyp, m, fd)
 		}
 
 		if err != nil {
@@ -53,6 +53,271 @@ func TestDecode(t *testing.T) {
 	}
 }
 
+func TestDecodeAliasBuffer(t *testing.T) {
+	for _, test := range testValidMessages {
+		for _, want := range test.decodeTo {
+			t.Run(fmt.Sprintf("%s (%T)", test.desc, want), func(t *testing.T) {
+				opts := test.unmarshalOptions
+				opts.AllowPartial = test.partial
+				opts.AliasBuffer = true
+				wire := append(([]byte)(nil), test.wire...)
+				got := reflect.New(reflect.TypeOf(want).Elem()).Interface().(proto.Message)
+				if err := opts.Unmarshal(wire, got); err != nil {
+					t.Errorf("Unmarshal error: %v\nMessage:\n%v", err, prototext.Format(want))
+					return
+				}
+				if !bytes.Equal(test.wire, wire) {
+					t.Errorf("Unmarshal unexpectedly modified its input")
+				}
+				if !proto.Equal(got, want) && got.ProtoReflect().IsValid() && want.ProtoReflect().IsValid() {
+					t.Errorf("Unmarshal returned unexpected result; got:\n%v\nwant:\n%v", prototext.Format(got), prototext.Format(want))
+				}
+			})
+		}
+	}
+
+	wire := protopack.Message{
+		protopack.Tag{95, protopack.BytesType}, protopack.Bytes("singular"),
+		protopack.Tag{45, protopack.BytesType}, protopack.Bytes("repeated"),
+		protopack.Tag{45, protopack.BytesType}, protopack.Bytes(nil),
+	}.Marshal()
+	m := &test3pb.TestAllTypes{}
+	if err := (proto.UnmarshalOptions{AliasBuffer: true}).Unmarshal(wire, m); err != nil {
+		t.Fatal(err)
+	}
+	if m.RepeatedBytes[1] == nil {
+		t.Errorf("unmarshaling zero-length repeated bytes: got nil bytes, want non-nil")
+	}
+	for i := range wire {
+		wire[i] = 'x'
+	}
+	if got, want := string(m.SingularBytes), "xxxxxxxx"; got != want {
+		t.Errorf("after modifying input: singular_bytes = %q, want %q", got, want)
+	}
+	if got, want := string(m.RepeatedBytes[0]), "xxxxxxxx"; got != want {
+		t.Errorf("after modifying input: repeated_bytes[0] = %q, want %q", got, want)
+	}
+
+	// Appending to an aliased value must not overwrite the input.
+	m.SingularBytes = append(m.SingularBytes, 'y')
+	if bytes.IndexByte(wire, 'y') >= 0 {
+		t.Errorf("append to aliased bytes value modified the input")
+	}
+
+	// Strings are only aliased with AliasStrings.
+	wire = protopack.Message{
+		protopack.Tag{94, protopack.BytesType}, protopack.String("singular"),
+	}.Marshal()
+	for _, opts := range []proto.UnmarshalOptions{{AliasBuffer: true}, {AliasStrings: true}} {
+		m := &test3pb.TestAllTypes{}
+		b := append([]byte(nil), wire...)
+		if err := opts.Unmarshal(b, m); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := m.SingularString, "singular"; got != want {
+			t.Errorf("%+v: singular_string = %q, want %q", opts, got, want)
+		}
+		for i := range b {
+			b[i] = 'x'
+		}
+		if got, want := m.SingularString, "singular"; got != want && !opts.AliasStrings {
+			t.Errorf("%+v: after modifying input: singular_string = %q, want %q", opts, got, want)
+		}
+	}
+}
+
+func TestDecodeArena(t *testing.T) {
+	arena := &proto.Arena{}
+	for _, test := range testValidMessages {
+		for _, want := range test.decodeTo {
+			t.Run(fmt.Sprintf("%s (%T)", test.desc, want), func(t *testing.T) {
+				opts := test.unmarshalOptions
+				opts.AllowPartial = test.partial
+				opts.Allocator = arena
+				got := reflect.New(reflect.TypeOf(want).Elem()).Interface().(proto.Message)
+				if err := opts.Unmarshal(test.wire, got); err != nil {
+					t.Errorf("Unmarshal error: %v\nMessage:\n%v", err, prototext.Format(want))
+					return
+				}
+				if !proto.Equal(got, want) && got.ProtoReflect().IsValid() && want.ProtoReflect().IsValid() {
+					t.Errorf("Unmarshal returned unexpected result; got:\n%v\nwant:\n%v", prototext.Format(got), prototext.Format(want))
+				}
+			})
+		}
+	}
+
+	arena.Release()
+	wire := protopack.Message{
+		protopack.Tag{48, protopack.BytesType}, protopack.LengthPrefix{protopack.Tag{1, protopack.VarintType}, protopack.Varint(1)},
+		protopack.Tag{48, protopack.BytesType}, protopack.LengthPrefix{protopack.Tag{1, protopack.VarintType}, protopack.Varint(2)},
+		protopack.Tag{31, protopack.BytesType}, protopack.LengthPrefix{protopack.Varint(1), protopack.Varint(2), protopack.Varint(3)},
+	}.Marshal()
+	m := &test3pb.TestAllTypes{}
+	if err := (proto.UnmarshalOptions{Allocator: arena}).Unmarshal(wire, m); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(m.RepeatedNestedMessage), 2; got != want {
+		t.Fatalf("len(repeated_nested_message) = %v, want %v", got, want)
+	}
+	// Messages of the same type are carved out of a single block.
+	p0 := reflect.ValueOf(m.RepeatedNestedMessage[0]).Pointer()
+	p1 := reflect.ValueOf(m.RepeatedNestedMessage[1]).Pointer()
+	if size := reflect.TypeOf(m.RepeatedNestedMessage[0]).Elem().Size(); p1-p0 != size {
+		t.Errorf("repeated_nested_message elements are %v bytes apart, want %v", p1-p0, size)
+	}
+	if got, want := cap(m.RepeatedInt32), 3; got != want {
+		t.Errorf("cap(repeated_int32) = %v, want %v", got, want)
+	}
+
+	// Release zeroes the values allocated from the arena,
+	// and later allocations reuse their memory.
+	n0 := m.RepeatedNestedMessage[0]
+	arena.Release()
+	if m.RepeatedNestedMessage[0] != nil || n0.A != 0 || m.RepeatedInt32[0] != 0 {
+		t.Errorf("values allocated from the arena are not zeroed by Release")
+	}
+	m2 := &test3pb.TestAllTypes{}
+	if err := (proto.UnmarshalOptions{Allocator: arena}).Unmarshal(wire, m2); err != nil {
+		t.Fatal(err)
+	}
+	if m2.RepeatedNestedMessage[0] != n0 {
+		t.Errorf("repeated_nested_message[0] is not allocated from the released block")
+	}
+	if got, want := m2.RepeatedNestedMessage[1].A, int32(2); got != want {
+		t.Errorf("repeated_nested_message[1].a = %v, want %v", got, want)
+	}
+}
+
+func TestDecodeReuseMemory(t *testing.T) {
+	for _, test := range testValidMessages {
+		for _, want := range test.decodeTo {
+			t.Run(fmt.Sprintf("%s (%T)", test.desc, want), func(t *testing.T) {
+				opts := test.unmarshalOptions
+				opts.AllowPartial = test.partial
+				opts.ReuseMemory = true
+				got := reflect.New(reflect.TypeOf(want).Elem()).Interface().(proto.Message)
+				// Unmarshal twice, so that the second pass reuses the
+				// memory retained from the first.
+				for i := 0; i < 2; i++ {
+					if err := opts.Unmarshal(test.wire, got); err != nil {
+						t.Errorf("Unmarshal error: %v\nMessage:\n%v", err, prototext.Format(want))
+						return
+					}
+				}
+				if !proto.Equal(got, want) && got.ProtoReflect().IsValid() && want.ProtoReflect().IsValid() {
+					t.Errorf("Unmarshal returned unexpected result; got:\n%v\nwant:\n%v", prototext.Format(got), prototext.Format(want))
+				}
+				proto.ResetKeepCapacity(got)
+				if empty := got.ProtoReflect().Type().New().Interface(); !proto.Equal(got, empty) {
+					t.Errorf("ResetKeepCapacity left fields set:\n%v", prototext.Format(got))
+				}
+			})
+		}
+	}
+
+	wire := protopack.Message{
+		protopack.Tag{18, protopack.BytesType}, protopack.LengthPrefix{protopack.Tag{1, protopack.VarintType}, protopack.Varint(1)},
+		protopack.Tag{48, protopack.BytesType}, protopack.LengthPrefix{protopack.Tag{1, protopack.VarintType}, protopack.Varint(2)},
+		protopack.Tag{48, protopack.BytesType}, protopack.LengthPrefix{protopack.Tag{1, protopack.VarintType}, protopack.Varint(3)},
+		protopack.Tag{31, protopack.BytesType}, protopack.LengthPrefix{protopack.Varint(1), protopack.Varint(2), protopack.Varint(3)},
+		protopack.Tag{71, protopack.BytesType}, protopack.LengthPrefix{
+			protopack.Tag{1, protopack.BytesType}, protopack.String("k"),
+			protopack.Tag{2, protopack.BytesType}, protopack.LengthPrefix{protopack.Tag{1, protopack.VarintType}, protopack.Varint(4)},
+		},
+		protopack.Tag{112, protopack.BytesType}, protopack.LengthPrefix{protopack.Tag{1, protopack.VarintType}, protopack.Varint(5)},
+	}.Marshal()
+	m := &test3pb.TestAllTypes{}
+	if err := proto.Unmarshal(wire, m); err != nil {
+		t.Fatal(err)
+	}
+	want := proto.Clone(m)
+	optional := m.OptionalNestedMessage
+	elem := m.RepeatedNestedMessage[0]
+	mapValue := m.MapStringNestedMessage["k"]
+	oneof := m.GetOneofNestedMessage()
+	opts := proto.UnmarshalOptions{ReuseMemory: true}
+	if err := opts.Unmarshal(wire, m); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(m, want) {
+		t.Errorf("Unmarshal with ReuseMemory returned unexpected result; got:\n%v\nwant:\n%v", prototext.Format(m), prototext.Format(want))
+	}
+	if m.OptionalNestedMessage != optional {
+		t.Errorf("optional_nested_message was not reused")
+	}
+	if m.RepeatedNestedMessage[0] != elem {
+		t.Errorf("repeated_nested_message element was not reused")
+	}
+	if m.MapStringNestedMessage["k"] != mapValue {
+		t.Errorf("map_string_nested_message value was not reused")
+	}
+	if m.GetOneofNestedMessage() != oneof {
+		t.Errorf("oneof_nested_message was not reused")
+	}
+
+	// ResetKeepCapacity keeps the backing arrays of repeated fields,
+	// but drops messages.
+	wantCap := cap(m.RepeatedInt32)
+	proto.ResetKeepCapacity(m)
+	if got, want := cap(m.RepeatedInt32), wantCap; got != want {
+		t.Errorf("after ResetKeepCapacity: cap(repeated_int32) = %v, want %v", got, want)
+	}
+	if !proto.Equal(m, &test3pb.TestAllTypes{}) {
+		t.Errorf("ResetKeepCapacity left fields set:\n%v", prototext.Format(m))
+	}
+	if got, want := elem.GetA(), int32(2); got != want {
+		t.Errorf("after ResetKeepCapacity: repeated_nested_message[0].a = %v, want %v", got, want)
+	}
+	if err := opts.Unmarshal(wire, m); err != nil {
+		t.Fatal(err)
+	}
+	if m.RepeatedNestedMessage[0] == elem {
+		t.Errorf("repeated_nested_message element dropped by ResetKeepCapacity was reused")
+	}
+
+	// Messages left in spare capacity by the caller are not reused.
+	held := m.RepeatedNestedMessage[1]
+	m.RepeatedNestedMessage = m.RepeatedNestedMessage[:1]
+	if err := opts.Unmarshal(wire, m); err != nil {
+		t.Fatal(err)
+	}
+	if m.RepeatedNestedMessage[1] == held {
+		t.Errorf("repeated_nested_message element truncated by the caller was reused")
+	}
+	if got, want := held.GetA(), int32(3); got != want {
+		t.Errorf("held repeated_nested_message element: a = %v, want %v", got, want)
+	}
+
+	// A message reachable from several fields is reused at most once.
+	shared := &test3pb.TestAllTypes_NestedMessage{}
+	m = &test3pb.TestAllTypes{
+		OptionalNestedMessage: shared,
+		RepeatedNestedMessage: []*test3pb.TestAllTypes_NestedMessage{shared, shared},
+	}
+	if err := opts.Unmarshal(wire, m); err != nil {
+		t.Fatal(err)
+	}
+	if m.RepeatedNestedMessage[0] == m.RepeatedNestedMessage[1] || m.OptionalNestedMessage == m.RepeatedNestedMessage[0] || m.OptionalNestedMessage == m.RepeatedNestedMessage[1] {
+		t.Errorf("fields share a message after reuse")
+	}
+	if !proto.Equal(m, want) {
+		t.Errorf("Unmarshal with ReuseMemory of shared messages returned unexpected result; got:\n%v\nwant:\n%v", prototext.Format(m), prototext.Format(want))
+	}
+
+	// Steady-state unmarshaling should allocate less when reusing memory.
+	const count = 100
+	allocs := func(opts proto.UnmarshalOptions) float64 {
+		return testing.AllocsPerRun(count, func() {
+			if err := opts.Unmarshal(wire, m); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+	if reuse, plain := allocs(opts), allocs(proto.UnmarshalOptions{}); reuse >= plain {
+		t.Errorf("Unmarshal with ReuseMemory made %v allocations, want fewer than %v", reuse, plain)
+	}
+}
+
 func TestDecodeRequiredFieldChecks(t *testing.T) {
 	for _, test := range testValidMessages {
 		if !test.partial {
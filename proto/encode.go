@@ -7,6 +7,7 @@ package proto
 import (
 	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/internal/encoding/messageset"
+	"google.golang.org/protobuf/internal/errors"
 	"google.golang.org/protobuf/internal/order"
 	"google.golang.org/protobuf/internal/pragma"
 	"google.golang.org/protobuf/reflect/protoreflect"
@@ -121,6 +122,14 @@ type MarshalOptions struct {
 	//
 	// 使用缓存的 Size
 	UseCachedSize bool
+
+	// Mask, if set, restricts marshaling to the fields selected by the
+	// PathSet. Unknown fields are not marshaled. Messages along the selected
+	// paths are marshaled using the slower reflection-based implementation;
+	// fields selected entirely are marshaled as usual. MarshalTo still
+	// streams its output, but computes the sizes of messages along the
+	// selected paths again as it writes them.
+	Mask *PathSet
 }
 
 // Marshal returns the wire-format encoding of m.
@@ -202,8 +211,12 @@ func (o MarshalOptions) marshal(b []byte, m protoreflect.Message) (out protoifac
 	allowPartial := o.AllowPartial
 	o.AllowPartial = true
 
+	if o.Mask != nil && o.Mask.md.FullName() != m.Descriptor().FullName() {
+		return out, errors.New("field mask for %v used to marshal %v", o.Mask.md.FullName(), m.Descriptor().FullName())
+	}
+
 	// 如果 m 提供了合法的 Marshal() 函数，就直接调用它。
-	if methods := protoMethods(m); methods != nil && methods.Marshal != nil &&
+	if methods := protoMethods(m); o.Mask == nil && methods != nil && methods.Marshal != nil &&
 		!( o.Deterministic && methods.Flags&protoiface.SupportMarshalDeterministic == 0 ){
 
 		// 构造输入
@@ -311,8 +324,16 @@ func (o MarshalOptions) marshalMessageSlow(b []byte, m protoreflect.Message) ([]
 	//
 	// 如果不要求 fields 有序，则简单等价于 m.Range(fn) 。
 	order.RangeFields(m, fieldOrder, func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		fo := o
+		if o.Mask != nil {
+			sub, ok := o.Mask.lookup(fd.Number())
+			if !ok {
+				return true
+			}
+			fo.Mask = sub
+		}
 		// 将 fd 类型的 field 值 v 编码后存入 b 中。
-		b, err = o.marshalField(b, fd, v)
+		b, err = fo.marshalField(b, fd, v)
 		return err == nil
 	})
 	if err != nil {
@@ -320,7 +341,9 @@ func (o MarshalOptions) marshalMessageSlow(b []byte, m protoreflect.Message) ([]
 	}
 
 	// 追加未知字段
-	b = append(b, m.GetUnknown()...)
+	if o.Mask == nil {
+		b = append(b, m.GetUnknown()...)
+	}
 	return b, nil
 }
 
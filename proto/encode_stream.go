@@ -0,0 +1,288 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proto
+
+import (
+	"io"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/internal/encoding/messageset"
+	"google.golang.org/protobuf/internal/errors"
+	"google.golang.org/protobuf/internal/order"
+	"google.golang.org/protobuf/internal/strs"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/runtime/protoiface"
+)
+
+// streamChunkSize is the amount of encoded output that MarshalTo buffers
+// before writing it out. Values smaller than this are encoded whole.
+const streamChunkSize = 32 << 10
+
+// MarshalTo writes the wire-format encoding of m to w.
+// It returns the number of bytes written.
+//
+// Unlike MarshalAppend, MarshalTo does not materialize the entire encoding
+// in memory. The size of m and its sub-messages is computed once up front
+// so that length prefixes can be emitted before the values they describe;
+// nested messages, repeated fields, and large bytes values are then written
+// out in bounded chunks. The message must not be modified while MarshalTo
+// is running.
+//
+// If o.Mask is set, only the selected fields are written. The sizes of
+// messages along the selected paths cannot be cached and are computed again
+// when each such message is written.
+//
+// If w returns an error, MarshalTo returns it unchanged.
+func (o MarshalOptions) MarshalTo(w io.Writer, m Message) (int64, error) {
+	// Treat nil message interface as an empty message; nothing to output.
+	if m == nil {
+		return 0, nil
+	}
+	mr := m.ProtoReflect()
+	if !o.AllowPartial {
+		if err := checkInitialized(mr); err != nil {
+			return 0, err
+		}
+	}
+	o.AllowPartial = true
+
+	if o.Mask != nil && o.Mask.md.FullName() != mr.Descriptor().FullName() {
+		return 0, errors.New("field mask for %v used to marshal %v", o.Mask.md.FullName(), mr.Descriptor().FullName())
+	}
+
+	// Populate the size caches of m and all of its sub-messages.
+	if !o.UseCachedSize {
+		o.size(mr)
+	}
+	o.UseCachedSize = true
+
+	sw := &streamWriter{w: w, buf: make([]byte, 0, streamChunkSize)}
+	err := o.streamMessage(sw, mr)
+	if err == nil {
+		err = sw.flush()
+	}
+	return sw.n, err
+}
+
+// streamWriter buffers output for MarshalTo.
+type streamWriter struct {
+	w   io.Writer
+	buf []byte
+	n   int64 // number of bytes written to w
+}
+
+// maybeFlush writes out the buffer once it has reached the chunk size.
+func (sw *streamWriter) maybeFlush() error {
+	if len(sw.buf) < streamChunkSize {
+		return nil
+	}
+	return sw.flush()
+}
+
+func (sw *streamWriter) flush() error {
+	if len(sw.buf) == 0 {
+		return nil
+	}
+	n, err := sw.w.Write(sw.buf)
+	sw.n += int64(n)
+	sw.buf = sw.buf[:0]
+	return err
+}
+
+// writeLarge prepares to write a large length-delimited value of n bytes
+// directly to the underlying writer, bypassing the buffer.
+func (sw *streamWriter) writeLarge(n int) error {
+	sw.buf = protowire.AppendVarint(sw.buf, uint64(n))
+	return sw.flush()
+}
+
+// written reports the total number of bytes produced so far,
+// including those that are still buffered.
+func (sw *streamWriter) written() int64 {
+	return sw.n + int64(len(sw.buf))
+}
+
+// cachedSize returns the size of m, reusing the size computed by MarshalTo.
+// Only messages selected entirely by the mask have a cached size.
+func (o MarshalOptions) cachedSize(m protoreflect.Message) int {
+	if o.Mask != nil {
+		return o.size(m)
+	}
+	if methods := protoMethods(m); methods != nil && methods.Size != nil {
+		return methods.Size(protoiface.SizeInput{
+			Message: m,
+			Flags:   protoiface.MarshalUseCachedSize,
+		}).Size
+	}
+	return o.size(m)
+}
+
+func (o MarshalOptions) streamMessage(sw *streamWriter, m protoreflect.Message) error {
+	if messageset.IsMessageSet(m.Descriptor()) {
+		var err error
+		sw.buf, err = o.marshalMessage(sw.buf, m)
+		if err != nil {
+			return err
+		}
+		return sw.maybeFlush()
+	}
+
+	fieldOrder := order.AnyFieldOrder
+	if o.Deterministic {
+		fieldOrder = order.LegacyFieldOrder
+	}
+	var err error
+	order.RangeFields(m, fieldOrder, func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		fo := o
+		if o.Mask != nil {
+			sub, ok := o.Mask.lookup(fd.Number())
+			if !ok {
+				return true
+			}
+			fo.Mask = sub
+		}
+		err = fo.streamField(sw, fd, v)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	if o.Mask == nil {
+		sw.buf = append(sw.buf, m.GetUnknown()...)
+	}
+	return sw.maybeFlush()
+}
+
+func (o MarshalOptions) streamField(sw *streamWriter, fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+	switch {
+	case fd.IsList():
+		return o.streamList(sw, fd, v.List())
+	case fd.IsMap():
+		return o.streamMap(sw, fd, v.Map())
+	default:
+		sw.buf = protowire.AppendTag(sw.buf, fd.Number(), wireTypes[fd.Kind()])
+		return o.streamSingular(sw, fd, v)
+	}
+}
+
+func (o MarshalOptions) streamList(sw *streamWriter, fd protoreflect.FieldDescriptor, list protoreflect.List) error {
+	if fd.IsPacked() && list.Len() > 0 {
+		sw.buf = protowire.AppendTag(sw.buf, fd.Number(), protowire.BytesType)
+		content := 0
+		for i, llen := 0, list.Len(); i < llen; i++ {
+			content += o.sizeSingular(fd.Number(), fd.Kind(), list.Get(i))
+		}
+		sw.buf = protowire.AppendVarint(sw.buf, uint64(content))
+		for i, llen := 0, list.Len(); i < llen; i++ {
+			var err error
+			sw.buf, err = o.marshalSingular(sw.buf, fd, list.Get(i))
+			if err != nil {
+				return err
+			}
+			if err := sw.maybeFlush(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i, llen := 0, list.Len(); i < llen; i++ {
+		sw.buf = protowire.AppendTag(sw.buf, fd.Number(), wireTypes[fd.Kind()])
+		if err := o.streamSingular(sw, fd, list.Get(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o MarshalOptions) streamMap(sw *streamWriter, fd protoreflect.FieldDescriptor, mapv protoreflect.Map) error {
+	keyf := fd.MapKey()
+	valf := fd.MapValue()
+
+	keyOrder := order.AnyKeyOrder
+	if o.Deterministic {
+		keyOrder = order.GenericKeyOrder
+	}
+	var err error
+	order.RangeEntries(mapv, keyOrder, func(key protoreflect.MapKey, value protoreflect.Value) bool {
+		sw.buf = protowire.AppendTag(sw.buf, fd.Number(), protowire.BytesType)
+		size := o.sizeField(keyf, key.Value())
+		if valf.Message() != nil {
+			size += protowire.SizeTag(valf.Number()) + protowire.SizeBytes(o.cachedSize(value.Message()))
+		} else {
+			size += o.sizeField(valf, value)
+		}
+		sw.buf = protowire.AppendVarint(sw.buf, uint64(size))
+		if err = o.streamField(sw, keyf, key.Value()); err != nil {
+			return false
+		}
+		err = o.streamField(sw, valf, value)
+		return err == nil
+	})
+	return err
+}
+
+func (o MarshalOptions) streamSingular(sw *streamWriter, fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+	switch fd.Kind() {
+	case protoreflect.MessageKind:
+		m := v.Message()
+		size := o.cachedSize(m)
+		sw.buf = protowire.AppendVarint(sw.buf, uint64(size))
+		if size < streamChunkSize {
+			var err error
+			sw.buf, err = o.marshalMessage(sw.buf, m)
+			if err != nil {
+				return err
+			}
+			return sw.maybeFlush()
+		}
+		start := sw.written()
+		if err := o.streamMessage(sw, m); err != nil {
+			return err
+		}
+		if got := sw.written() - start; got != int64(size) {
+			return errors.New("size mismatch (got %d, want %d) while streaming %v", got, size, fd.FullName())
+		}
+		return nil
+	case protoreflect.GroupKind:
+		if err := o.streamMessage(sw, v.Message()); err != nil {
+			return err
+		}
+		sw.buf = protowire.AppendVarint(sw.buf, protowire.EncodeTag(fd.Number(), protowire.EndGroupType))
+		return sw.maybeFlush()
+	case protoreflect.StringKind:
+		s := v.String()
+		if len(s) < streamChunkSize {
+			break
+		}
+		if strs.EnforceUTF8(fd) && !utf8.ValidString(s) {
+			return errors.InvalidUTF8(string(fd.FullName()))
+		}
+		if err := sw.writeLarge(len(s)); err != nil {
+			return err
+		}
+		n, err := io.WriteString(sw.w, s)
+		sw.n += int64(n)
+		return err
+	case protoreflect.BytesKind:
+		b := v.Bytes()
+		if len(b) < streamChunkSize {
+			break
+		}
+		if err := sw.writeLarge(len(b)); err != nil {
+			return err
+		}
+		n, err := sw.w.Write(b)
+		sw.n += int64(n)
+		return err
+	}
+	var err error
+	sw.buf, err = o.marshalSingular(sw.buf, fd, v)
+	if err != nil {
+		return err
+	}
+	return sw.maybeFlush()
+}
@@ -0,0 +1,168 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proto_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+
+	testpb "google.golang.org/protobuf/internal/testprotos/test"
+	test3pb "google.golang.org/protobuf/internal/testprotos/test3"
+)
+
+func TestMarshalTo(t *testing.T) {
+	for _, test := range testValidMessages {
+		for _, want := range test.decodeTo {
+			t.Run(fmt.Sprintf("%s (%T)", test.desc, want), func(t *testing.T) {
+				opts := proto.MarshalOptions{
+					AllowPartial: test.partial,
+				}
+				var buf bytes.Buffer
+				n, err := opts.MarshalTo(&buf, want)
+				if err != nil {
+					t.Fatalf("MarshalTo error: %v\nMessage:\n%v", err, prototext.Format(want))
+				}
+				if n != int64(buf.Len()) {
+					t.Errorf("MarshalTo returned %d, wrote %d bytes", n, buf.Len())
+				}
+				if size := proto.Size(want); size != buf.Len() {
+					t.Errorf("Size and MarshalTo disagree: Size(m)=%v; MarshalTo wrote %v bytes", size, buf.Len())
+				}
+
+				got := want.ProtoReflect().New().Interface()
+				uopts := proto.UnmarshalOptions{
+					AllowPartial: test.partial,
+				}
+				if err := uopts.Unmarshal(buf.Bytes(), got); err != nil {
+					t.Errorf("Unmarshal error: %v\nMessage:\n%v", err, prototext.Format(want))
+					return
+				}
+				if !proto.Equal(got, want) && got.ProtoReflect().IsValid() && want.ProtoReflect().IsValid() {
+					t.Errorf("Unmarshal returned unexpected result; got:\n%v\nwant:\n%v", prototext.Format(got), prototext.Format(want))
+				}
+			})
+		}
+	}
+}
+
+func TestMarshalToRequiredFields(t *testing.T) {
+	m := &testpb.TestRequiredForeign{
+		OptionalMessage: &testpb.TestRequired{},
+	}
+	var buf bytes.Buffer
+	if _, err := (proto.MarshalOptions{}).MarshalTo(&buf, m); err == nil {
+		t.Errorf("MarshalTo succeeded with missing required field")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("MarshalTo wrote %d bytes before reporting missing required field", buf.Len())
+	}
+}
+
+// maxWriter records the largest single write it receives.
+type maxWriter struct {
+	bytes.Buffer
+	max int
+}
+
+func (w *maxWriter) Write(b []byte) (int, error) {
+	if len(b) > w.max {
+		w.max = len(b)
+	}
+	return w.Buffer.Write(b)
+}
+
+func TestMarshalToLarge(t *testing.T) {
+	const elems = 5000
+	want := &test3pb.TestAllTypes{
+		MapStringNestedMessage: map[string]*test3pb.TestAllTypes_NestedMessage{},
+	}
+	for i := 0; i < elems; i++ {
+		want.RepeatedNestedMessage = append(want.RepeatedNestedMessage, &test3pb.TestAllTypes_NestedMessage{
+			A: int32(i),
+			Corecursive: &test3pb.TestAllTypes{
+				SingularString: fmt.Sprintf("element %d", i),
+				RepeatedInt64:  []int64{int64(i), int64(-i)},
+			},
+		})
+		want.RepeatedInt32 = append(want.RepeatedInt32, int32(i))
+		want.MapStringNestedMessage[fmt.Sprint(i)] = &test3pb.TestAllTypes_NestedMessage{A: int32(i)}
+	}
+	want.SingularNestedMessage = &test3pb.TestAllTypes_NestedMessage{
+		Corecursive: &test3pb.TestAllTypes{
+			RepeatedBytes: [][]byte{bytes.Repeat([]byte{'x'}, 1<<20)},
+		},
+	}
+
+	w := &maxWriter{}
+	if _, err := (proto.MarshalOptions{Deterministic: true}).MarshalTo(w, want); err != nil {
+		t.Fatalf("MarshalTo error: %v", err)
+	}
+	if size := proto.Size(want); w.Len() != size {
+		t.Fatalf("MarshalTo wrote %d bytes, want %d", w.Len(), size)
+	}
+	// Apart from the large bytes value, which is written as is,
+	// no single write should hold more than a couple of chunks.
+	if w.max > 1<<20 {
+		t.Errorf("largest write was %d bytes, want at most %d", w.max, 1<<20)
+	}
+	got := &test3pb.TestAllTypes{}
+	if err := proto.Unmarshal(w.Bytes(), got); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if !proto.Equal(got, want) {
+		t.Errorf("Unmarshal returned unexpected result")
+	}
+}
+
+func TestMarshalToMask(t *testing.T) {
+	const elems = 5000
+	src := &test3pb.TestAllTypes{
+		SingularNestedMessage: &test3pb.TestAllTypes_NestedMessage{
+			A:           1,
+			Corecursive: &test3pb.TestAllTypes{SingularString: "nested"},
+		},
+	}
+	for i := 0; i < elems; i++ {
+		src.RepeatedInt32 = append(src.RepeatedInt32, int32(i))
+		src.SingularNestedMessage.Corecursive.RepeatedNestedMessage = append(src.SingularNestedMessage.Corecursive.RepeatedNestedMessage, &test3pb.TestAllTypes_NestedMessage{
+			A: int32(i),
+			Corecursive: &test3pb.TestAllTypes{
+				SingularString: fmt.Sprintf("element %d", i),
+			},
+		})
+	}
+	mask, err := proto.NewPathSet(src.ProtoReflect().Descriptor(), "singular_nested_message.corecursive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := proto.MarshalOptions{Mask: mask, Deterministic: true}
+	want, err := opts.Marshal(src)
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+
+	w := &maxWriter{}
+	n, err := opts.MarshalTo(w, src)
+	if err != nil {
+		t.Fatalf("MarshalTo error: %v", err)
+	}
+	if n != int64(w.Len()) {
+		t.Errorf("MarshalTo returned %d, wrote %d bytes", n, w.Len())
+	}
+	if !bytes.Equal(w.Bytes(), want) {
+		t.Errorf("MarshalTo and Marshal with Mask disagree")
+	}
+	if w.max >= w.Len() {
+		t.Errorf("MarshalTo with Mask wrote %d bytes in a single write, want the output streamed", w.max)
+	}
+
+	if _, err := opts.MarshalTo(&bytes.Buffer{}, &test3pb.ForeignMessage{}); err == nil {
+		t.Errorf("MarshalTo with Mask for another message type succeeded, want error")
+	}
+}
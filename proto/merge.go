@@ -47,6 +47,32 @@ func Merge(dst, src Message) {
 	mergeOptions{}.mergeMessage(dstMsg, srcMsg)
 }
 
+// MergeWithMask merges the fields of src selected by mask into dst,
+// which must be messages with the same descriptor as the mask.
+// It follows the update rules of google.protobuf.FieldMask:
+//
+// A selected scalar field is copied from src to dst, or cleared in dst
+// if it is not populated in src. A selected singular message field that is
+// populated in src is merged into dst by recursively calling Merge.
+// The elements of a selected list field in src are appended to the list in dst,
+// and the entries of a selected map field in src are copied into the map in
+// dst, possibly replacing existing entries. Paths which continue past
+// a singular message field apply the same rules within that message.
+// Unknown fields are not merged.
+func MergeWithMask(dst, src Message, mask *PathSet) {
+	dstMsg, srcMsg := dst.ProtoReflect(), src.ProtoReflect()
+	if dstMsg.Descriptor() != srcMsg.Descriptor() {
+		if got, want := dstMsg.Descriptor().FullName(), srcMsg.Descriptor().FullName(); got != want {
+			panic(fmt.Sprintf("descriptor mismatch: %v != %v", got, want))
+		}
+		panic("descriptor mismatch")
+	}
+	if got, want := mask.md.FullName(), dstMsg.Descriptor().FullName(); got != want {
+		panic(fmt.Sprintf("field mask descriptor mismatch: %v != %v", got, want))
+	}
+	mergeOptions{}.mergeMessageWithMask(dstMsg, srcMsg, mask)
+}
+
 // Clone returns a deep copy of m.
 // If the top-level message is invalid, it returns an invalid message as well.
 func Clone(m Message) Message {
@@ -124,6 +150,36 @@ func (o mergeOptions) mergeMessage(dst, src protoreflect.Message) {
 
 }
 
+func (o mergeOptions) mergeMessageWithMask(dst, src protoreflect.Message, mask *PathSet) {
+	if !dst.IsValid() {
+		panic(fmt.Sprintf("cannot merge into invalid %v message", dst.Descriptor().FullName()))
+	}
+	for num, sub := range mask.fields {
+		fd := mask.field(num)
+		switch {
+		case sub != nil:
+			if !src.Has(fd) && !dst.Has(fd) {
+				continue
+			}
+			o.mergeMessageWithMask(dst.Mutable(fd).Message(), src.Get(fd).Message(), sub)
+		case !src.Has(fd):
+			if !fd.IsList() && !fd.IsMap() && fd.Message() == nil {
+				dst.Clear(fd)
+			}
+		case fd.IsList():
+			o.mergeList(dst.Mutable(fd).List(), src.Get(fd).List(), fd)
+		case fd.IsMap():
+			o.mergeMap(dst.Mutable(fd).Map(), src.Get(fd).Map(), fd.MapValue())
+		case fd.Message() != nil:
+			o.mergeMessage(dst.Mutable(fd).Message(), src.Get(fd).Message())
+		case fd.Kind() == protoreflect.BytesKind:
+			dst.Set(fd, o.cloneBytes(src.Get(fd)))
+		default:
+			dst.Set(fd, src.Get(fd))
+		}
+	}
+}
+
 func (o mergeOptions) mergeList(dst, src protoreflect.List, fd protoreflect.FieldDescriptor) {
 	// Merge semantics appends to the end of the existing list.
 	for i, n := 0, src.Len(); i < n; i++ {
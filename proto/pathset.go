@@ -0,0 +1,150 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proto
+
+import (
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/internal/errors"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// PathSet is a set of field paths rooted at a message type,
+// such as the paths of a google.protobuf.FieldMask.
+// It is used to restrict the fields processed by operations like Unmarshal.
+//
+// A path selects a field along with everything it contains.
+// Every field in a path other than the last must be a singular message field.
+//
+// The zero value is not usable; use NewPathSet to create a PathSet.
+// A PathSet must not be modified while it is in use by another operation.
+type PathSet struct {
+	md protoreflect.MessageDescriptor
+
+	// fields maps the number of each selected field to the set of
+	// paths selected within it. A nil set selects the entire field.
+	fields map[protoreflect.FieldNumber]*PathSet
+
+	// extensions records the descriptors of selected extension fields.
+	extensions map[protoreflect.FieldNumber]protoreflect.FieldDescriptor
+}
+
+// NewPathSet returns a PathSet for messages of type md containing
+// the provided paths. Each path is a sequence of field names separated
+// by dots, in the format used by google.protobuf.FieldMask.
+func NewPathSet(md protoreflect.MessageDescriptor, paths ...string) (*PathSet, error) {
+	s := &PathSet{md: md}
+	for _, path := range paths {
+		var fds []protoreflect.FieldDescriptor
+		cur := md
+		for _, name := range strings.Split(path, ".") {
+			if cur == nil {
+				return nil, errors.New("invalid path %q for %v: %v is not a message field", path, md.FullName(), fds[len(fds)-1].FullName())
+			}
+			fd := cur.Fields().ByName(protoreflect.Name(name))
+			if fd == nil {
+				return nil, errors.New("invalid path %q for %v: no field %q in %v", path, md.FullName(), name, cur.FullName())
+			}
+			fds = append(fds, fd)
+			cur = fd.Message()
+		}
+		if err := s.AddFields(fds...); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Descriptor returns the type of message the paths are rooted at.
+func (s *PathSet) Descriptor() protoreflect.MessageDescriptor {
+	return s.md
+}
+
+// AddFields adds the path consisting of the provided sequence of fields.
+// The first field must belong to the root message type, and each subsequent
+// field must belong to the message type of the preceding field.
+// Extension fields must be provided as protoreflect.ExtensionTypeDescriptors.
+func (s *PathSet) AddFields(fds ...protoreflect.FieldDescriptor) error {
+	if len(fds) == 0 {
+		return errors.New("empty path for %v", s.md.FullName())
+	}
+	md := s.md
+	for i, fd := range fds {
+		if fd.ContainingMessage().FullName() != md.FullName() {
+			return errors.New("invalid path for %v: %v is not a field of %v", s.md.FullName(), fd.FullName(), md.FullName())
+		}
+		if i < len(fds)-1 && (fd.Message() == nil || fd.IsList() || fd.IsMap()) {
+			return errors.New("invalid path for %v: %v is not a singular message field", s.md.FullName(), fd.FullName())
+		}
+		md = fd.Message()
+	}
+
+	for i, fd := range fds {
+		sub, ok := s.fields[fd.Number()]
+		if ok && sub == nil {
+			return nil // already contains the entire field
+		}
+		if s.fields == nil {
+			s.fields = make(map[protoreflect.FieldNumber]*PathSet)
+		}
+		if fd.IsExtension() {
+			if s.extensions == nil {
+				s.extensions = make(map[protoreflect.FieldNumber]protoreflect.FieldDescriptor)
+			}
+			s.extensions[fd.Number()] = fd
+		}
+		if i == len(fds)-1 {
+			s.fields[fd.Number()] = nil
+			return nil
+		}
+		if sub == nil {
+			sub = &PathSet{md: fd.Message()}
+			s.fields[fd.Number()] = sub
+		}
+		s = sub
+	}
+	return nil
+}
+
+// Paths returns the paths in the set, sorted and in the format used by
+// google.protobuf.FieldMask. Paths through extension fields are formatted
+// with the extension's full name in brackets.
+func (s *PathSet) Paths() []string {
+	var paths []string
+	s.appendPaths(&paths, "")
+	sort.Strings(paths)
+	return paths
+}
+
+func (s *PathSet) appendPaths(paths *[]string, prefix string) {
+	for num, sub := range s.fields {
+		fd := s.field(num)
+		name := prefix + string(fd.Name())
+		if fd.IsExtension() {
+			name = prefix + "[" + string(fd.FullName()) + "]"
+		}
+		if sub == nil {
+			*paths = append(*paths, name)
+		} else {
+			sub.appendPaths(paths, name+".")
+		}
+	}
+}
+
+// field returns the descriptor of the selected field numbered num.
+func (s *PathSet) field(num protoreflect.FieldNumber) protoreflect.FieldDescriptor {
+	if xd, ok := s.extensions[num]; ok {
+		return xd
+	}
+	return s.md.Fields().ByNumber(num)
+}
+
+// lookup reports whether the field numbered num is selected
+// and returns the paths selected within it, or nil if it is selected entirely.
+func (s *PathSet) lookup(num protoreflect.FieldNumber) (*PathSet, bool) {
+	sub, ok := s.fields[num]
+	return sub, ok
+}
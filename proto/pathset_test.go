@@ -0,0 +1,255 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proto_test
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+
+	test3pb "google.golang.org/protobuf/internal/testprotos/test3"
+)
+
+func TestPathSet(t *testing.T) {
+	md := (&test3pb.TestAllTypes{}).ProtoReflect().Descriptor()
+	tests := []struct {
+		paths   []string
+		want    []string
+		wantErr bool
+	}{{
+		paths: nil,
+		want:  nil,
+	}, {
+		paths: []string{"singular_int32", "repeated_int32"},
+		want:  []string{"repeated_int32", "singular_int32"},
+	}, {
+		paths: []string{"singular_nested_message.a", "singular_nested_message.corecursive.singular_string"},
+		want:  []string{"singular_nested_message.a", "singular_nested_message.corecursive.singular_string"},
+	}, {
+		paths: []string{"singular_nested_message.a", "singular_nested_message"},
+		want:  []string{"singular_nested_message"},
+	}, {
+		paths: []string{"singular_nested_message", "singular_nested_message.a"},
+		want:  []string{"singular_nested_message"},
+	}, {
+		paths:   []string{"no_such_field"},
+		wantErr: true,
+	}, {
+		paths:   []string{"singular_int32.a"},
+		wantErr: true,
+	}, {
+		paths:   []string{"repeated_nested_message.a"},
+		wantErr: true,
+	}, {
+		paths:   []string{"map_string_nested_message.a"},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		s, err := proto.NewPathSet(md, tt.paths...)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewPathSet(%q) error = %v, want error %v", tt.paths, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got := s.Paths(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NewPathSet(%q).Paths() = %q, want %q", tt.paths, got, tt.want)
+		}
+	}
+
+	s, err := proto.NewPathSet(md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nmd := (&test3pb.TestAllTypes_NestedMessage{}).ProtoReflect().Descriptor()
+	if err := s.AddFields(nmd.Fields().ByName("a")); err == nil {
+		t.Errorf("AddFields with a field of another message succeeded, want error")
+	}
+}
+
+func TestUnmarshalMask(t *testing.T) {
+	src := &test3pb.TestAllTypes{
+		SingularInt32:  1,
+		SingularString: "string",
+		RepeatedInt32:  []int32{1, 2, 3},
+		SingularNestedMessage: &test3pb.TestAllTypes_NestedMessage{
+			A: 2,
+			Corecursive: &test3pb.TestAllTypes{
+				SingularInt64:  3,
+				SingularString: "nested",
+			},
+		},
+		MapStringString: map[string]string{"k": "v"},
+	}
+	b, err := proto.Marshal(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mask, err := proto.NewPathSet(src.ProtoReflect().Descriptor(),
+		"singular_int32",
+		"repeated_int32",
+		"singular_nested_message.corecursive.singular_string",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &test3pb.TestAllTypes{
+		SingularInt32: 1,
+		RepeatedInt32: []int32{1, 2, 3},
+		SingularNestedMessage: &test3pb.TestAllTypes_NestedMessage{
+			Corecursive: &test3pb.TestAllTypes{
+				SingularString: "nested",
+			},
+		},
+	}
+
+	got := &test3pb.TestAllTypes{SingularBool: true}
+	if err := (proto.UnmarshalOptions{Mask: mask}).Unmarshal(b, got); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if !proto.Equal(got, want) {
+		t.Errorf("Unmarshal with Mask:\ngot:  %v\nwant: %v", prototext.Format(got), prototext.Format(want))
+	}
+
+	// Retaining skipped fields as unknown fields preserves the original content.
+	got = &test3pb.TestAllTypes{}
+	if err := (proto.UnmarshalOptions{Mask: mask, KeepMasked: true}).Unmarshal(b, got); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if len(got.ProtoReflect().GetUnknown()) == 0 {
+		t.Errorf("Unmarshal with KeepMasked: no unknown fields retained")
+	}
+	b2, err := proto.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip := &test3pb.TestAllTypes{}
+	if err := proto.Unmarshal(b2, roundTrip); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(roundTrip, src) {
+		t.Errorf("round trip with KeepMasked:\ngot:  %v\nwant: %v", prototext.Format(roundTrip), prototext.Format(src))
+	}
+
+	if err := (proto.UnmarshalOptions{Mask: mask}).Unmarshal(b, &test3pb.ForeignMessage{}); err == nil {
+		t.Errorf("Unmarshal with Mask for another message type succeeded, want error")
+	}
+}
+
+func TestMarshalMask(t *testing.T) {
+	src := &test3pb.TestAllTypes{
+		SingularInt32:  1,
+		SingularString: "string",
+		RepeatedInt32:  []int32{1, 2, 3},
+		SingularNestedMessage: &test3pb.TestAllTypes_NestedMessage{
+			A: 2,
+			Corecursive: &test3pb.TestAllTypes{
+				SingularInt64:  3,
+				SingularString: "nested",
+			},
+		},
+		MapStringString: map[string]string{"k": "v"},
+	}
+	src.ProtoReflect().SetUnknown([]byte{0xf8, 0x07, 0x01}) // field 127, varint 1
+
+	mask, err := proto.NewPathSet(src.ProtoReflect().Descriptor(),
+		"singular_string",
+		"map_string_string",
+		"singular_nested_message.corecursive",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &test3pb.TestAllTypes{
+		SingularString: "string",
+		SingularNestedMessage: &test3pb.TestAllTypes_NestedMessage{
+			Corecursive: &test3pb.TestAllTypes{
+				SingularInt64:  3,
+				SingularString: "nested",
+			},
+		},
+		MapStringString: map[string]string{"k": "v"},
+	}
+
+	opts := proto.MarshalOptions{Mask: mask}
+	b, err := opts.Marshal(src)
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+	if got, want := opts.Size(src), len(b); got != want {
+		t.Errorf("Size() = %v, want %v", got, want)
+	}
+	got := &test3pb.TestAllTypes{}
+	if err := proto.Unmarshal(b, got); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(got, want) {
+		t.Errorf("Marshal with Mask:\ngot:  %v\nwant: %v", prototext.Format(got), prototext.Format(want))
+	}
+
+	if _, err := opts.Marshal(&test3pb.ForeignMessage{}); err == nil {
+		t.Errorf("Marshal with Mask for another message type succeeded, want error")
+	}
+}
+
+func TestMergeWithMask(t *testing.T) {
+	dst := &test3pb.TestAllTypes{
+		SingularInt32:  1,
+		SingularInt64:  2,
+		SingularString: "dst",
+		RepeatedInt32:  []int32{1},
+		SingularNestedMessage: &test3pb.TestAllTypes_NestedMessage{
+			A: 1,
+		},
+		SingularForeignMessage: &test3pb.ForeignMessage{C: 1, D: 2},
+		MapStringString:        map[string]string{"a": "dst", "b": "dst"},
+	}
+	src := &test3pb.TestAllTypes{
+		SingularInt64:  20,
+		SingularString: "src",
+		RepeatedInt32:  []int32{2, 3},
+		SingularNestedMessage: &test3pb.TestAllTypes_NestedMessage{
+			A:           2,
+			Corecursive: &test3pb.TestAllTypes{SingularInt32: 3},
+		},
+		SingularForeignMessage: &test3pb.ForeignMessage{D: 4},
+		MapStringString:        map[string]string{"b": "src", "c": "src"},
+	}
+	mask, err := proto.NewPathSet(dst.ProtoReflect().Descriptor(),
+		"singular_int32",
+		"singular_int64",
+		"repeated_int32",
+		"map_string_string",
+		"singular_nested_message.corecursive",
+		"singular_foreign_message",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &test3pb.TestAllTypes{
+		SingularInt64:  20,
+		SingularString: "dst",
+		RepeatedInt32:  []int32{1, 2, 3},
+		SingularNestedMessage: &test3pb.TestAllTypes_NestedMessage{
+			A:           1,
+			Corecursive: &test3pb.TestAllTypes{SingularInt32: 3},
+		},
+		SingularForeignMessage: &test3pb.ForeignMessage{C: 1, D: 4},
+		MapStringString:        map[string]string{"a": "dst", "b": "src", "c": "src"},
+	}
+
+	proto.MergeWithMask(dst, src, mask)
+	if !proto.Equal(dst, want) {
+		t.Errorf("MergeWithMask:\ngot:  %v\nwant: %v", prototext.Format(dst), prototext.Format(want))
+	}
+	src.SingularNestedMessage.Corecursive.SingularInt32 = 4
+	if dst.SingularNestedMessage.Corecursive.SingularInt32 != 3 {
+		t.Errorf("MergeWithMask result shares memory with the source")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 
 	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/runtime/protoiface"
 )
 
 // Reset clears every field in the message.
@@ -43,3 +44,52 @@ func resetMessage(m protoreflect.Message) {
 	// Clear unknown fields.
 	m.SetUnknown(nil)
 }
+
+// ResetKeepCapacity clears every field in the message, like Reset,
+// but retains memory allocated for the message's contents.
+//
+// Repeated fields are truncated to zero length, keeping their backing arrays,
+// and maps are emptied in place. Messages which m contained are dropped;
+// to reuse them as well, unmarshal into m with UnmarshalOptions.ReuseMemory,
+// which resets m itself.
+//
+// Unlike with Reset, the resulting message may share memory with its
+// previous state: the contents of repeated fields that were reachable
+// from m must not be used after calling ResetKeepCapacity.
+func ResetKeepCapacity(m Message) {
+	resetKeepCapacity(m.ProtoReflect())
+}
+
+func resetKeepCapacity(m protoreflect.Message) {
+	if !m.IsValid() {
+		panic(fmt.Sprintf("cannot reset invalid %v message", m.Descriptor().FullName()))
+	}
+	if methods := protoMethods(m); methods != nil && methods.Reset != nil {
+		out := methods.Reset(protoiface.ResetInput{
+			Message: m,
+			Flags:   protoiface.ResetKeepCapacity,
+		})
+		if out.Flags&protoiface.ResetComplete != 0 {
+			return
+		}
+	}
+
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.IsList() {
+			list := v.List()
+			if fd.Message() != nil {
+				for i, llen := 0, list.Len(); i < llen; i++ {
+					resetKeepCapacity(list.Get(i).Message())
+				}
+			}
+			list.Truncate(0)
+		} else {
+			m.Clear(fd)
+		}
+		return true
+	})
+
+	if u := m.GetUnknown(); u != nil {
+		m.SetUnknown(u[:0])
+	}
+}
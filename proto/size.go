@@ -32,6 +32,9 @@ func (o MarshalOptions) Size(m Message) int {
 func (o MarshalOptions) size(m protoreflect.Message) (size int) {
 
 	methods := protoMethods(m)
+	if o.Mask != nil {
+		methods = nil
+	}
 
 	// 已实现 size 方法，直接调用
 	if methods != nil && methods.Size != nil {
@@ -63,11 +66,21 @@ func (o MarshalOptions) sizeMessageSlow(m protoreflect.Message) (size int) {
 
 	// 遍历 fields ，逐个计算 size 并汇总
 	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
-		size += o.sizeField(fd, v)
+		fo := o
+		if o.Mask != nil {
+			sub, ok := o.Mask.lookup(fd.Number())
+			if !ok {
+				return true
+			}
+			fo.Mask = sub
+		}
+		size += fo.sizeField(fd, v)
 		return true
 	})
 
-	size += len(m.GetUnknown())
+	if o.Mask == nil {
+		size += len(m.GetUnknown())
+	}
 	return size
 }
 
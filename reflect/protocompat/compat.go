@@ -0,0 +1,531 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package protocompat reports the changes between two versions of a set of
+// .proto files which break compatibility.
+//
+// A change may break the compatibility of messages serialized in the binary
+// wire format, in the JSON format, or of code generated from the files.
+// For example, changing the type of a field from int32 to string breaks the
+// wire format, renaming a field breaks the JSON format and generated code,
+// and moving a message to another file only breaks generated code.
+package protocompat
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// Severity is a kind of compatibility which a change may break,
+// in increasing order of severity.
+type Severity int8
+
+const (
+	// Source is the compatibility of code with the code generated from
+	// the files, such as the names of generated types and fields.
+	Source Severity = 1 + iota
+	// JSON is the compatibility of messages serialized in the JSON format.
+	JSON
+	// Wire is the compatibility of messages serialized in the binary wire
+	// format, and of RPCs.
+	Wire
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Source:
+		return "source"
+	case JSON:
+		return "JSON"
+	case Wire:
+		return "wire"
+	default:
+		return fmt.Sprintf("<unknown:%d>", s)
+	}
+}
+
+// breaks is a set of severities.
+type breaks uint8
+
+const (
+	breaksSource breaks = 1 << uint(Source)
+	breaksJSON   breaks = 1 << uint(JSON)
+	breaksWire   breaks = 1 << uint(Wire)
+)
+
+// Change is a kind of change between two versions of a file set.
+type Change int
+
+const (
+	FileRemoved Change = iota + 1
+	PackageChanged
+	DeclarationMoved
+	MessageRemoved
+	EnumRemoved
+	ServiceRemoved
+	ExtensionRemoved
+	FieldRemoved
+	FieldNumberChanged
+	FieldNameChanged
+	FieldJSONNameChanged
+	FieldTypeChanged
+	FieldCardinalityChanged
+	FieldPresenceChanged
+	FieldOneofChanged
+	FieldDefaultChanged
+	EnumValueRemoved
+	EnumValueNameChanged
+	EnumValueNumberChanged
+	MethodRemoved
+	MethodTypeChanged
+	MethodStreamingChanged
+)
+
+var changeNames = map[Change]string{
+	FileRemoved:             "FileRemoved",
+	PackageChanged:          "PackageChanged",
+	DeclarationMoved:        "DeclarationMoved",
+	MessageRemoved:          "MessageRemoved",
+	EnumRemoved:             "EnumRemoved",
+	ServiceRemoved:          "ServiceRemoved",
+	ExtensionRemoved:        "ExtensionRemoved",
+	FieldRemoved:            "FieldRemoved",
+	FieldNumberChanged:      "FieldNumberChanged",
+	FieldNameChanged:        "FieldNameChanged",
+	FieldJSONNameChanged:    "FieldJSONNameChanged",
+	FieldTypeChanged:        "FieldTypeChanged",
+	FieldCardinalityChanged: "FieldCardinalityChanged",
+	FieldPresenceChanged:    "FieldPresenceChanged",
+	FieldOneofChanged:       "FieldOneofChanged",
+	FieldDefaultChanged:     "FieldDefaultChanged",
+	EnumValueRemoved:        "EnumValueRemoved",
+	EnumValueNameChanged:    "EnumValueNameChanged",
+	EnumValueNumberChanged:  "EnumValueNumberChanged",
+	MethodRemoved:           "MethodRemoved",
+	MethodTypeChanged:       "MethodTypeChanged",
+	MethodStreamingChanged:  "MethodStreamingChanged",
+}
+
+func (c Change) String() string {
+	if s, ok := changeNames[c]; ok {
+		return s
+	}
+	return fmt.Sprintf("<unknown:%d>", int(c))
+}
+
+// Finding is a change which breaks compatibility.
+type Finding struct {
+	// Change is the kind of change.
+	Change Change
+
+	// Severity is the most severe kind of compatibility which the change
+	// breaks. Use Breaks to check for the others.
+	Severity Severity
+
+	// Old is the changed declaration in the old version, such as a file,
+	// message, or field, and New is the declaration in the new version.
+	// New is nil if the declaration was removed.
+	Old, New protoreflect.Descriptor
+
+	// Message describes the change.
+	Message string
+
+	breaks breaks
+}
+
+// Breaks reports whether the change breaks the given kind of compatibility.
+func (f Finding) Breaks(s Severity) bool {
+	return f.breaks&(1<<uint(s)) != 0
+}
+
+// String returns the file of the declaration in the old version,
+// followed by the message and the kinds of compatibility it breaks.
+func (f Finding) String() string {
+	var ss []string
+	for s := Wire; s >= Source; s-- {
+		if f.Breaks(s) {
+			ss = append(ss, s.String())
+		}
+	}
+	return fmt.Sprintf("%v: %v (breaks %v)", f.Old.ParentFile().Path(), f.Message, strings.Join(ss, ", "))
+}
+
+// Compare returns the changes between the old and new versions of a set of
+// files which break compatibility, in the order of the paths of the files
+// and then of the declarations in the files.
+//
+// Declarations are identified by their full names, except in files whose
+// package has changed, in which declarations are identified by their names
+// relative to the package. Fields are identified by their numbers,
+// and enum values by their names.
+func Compare(old, new *protoregistry.Files) []Finding {
+	c := &comparer{new: new}
+	var paths []string
+	old.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		paths = append(paths, fd.Path())
+		return true
+	})
+	sort.Strings(paths)
+	for _, path := range paths {
+		fd, _ := old.FindFileByPath(path)
+		c.file(fd)
+	}
+	return c.findings
+}
+
+type comparer struct {
+	new      *protoregistry.Files
+	findings []Finding
+}
+
+func (c *comparer) report(change Change, b breaks, old, new protoreflect.Descriptor, format string, args ...interface{}) {
+	f := Finding{Change: change, Old: old, New: new, Message: fmt.Sprintf(format, args...), breaks: b}
+	for s := Source; s <= Wire; s++ {
+		if f.Breaks(s) {
+			f.Severity = s
+		}
+	}
+	c.findings = append(c.findings, f)
+}
+
+func (c *comparer) file(of protoreflect.FileDescriptor) {
+	nf, _ := c.new.FindFileByPath(of.Path())
+	moved := nf != nil && nf.Package() != of.Package()
+	switch {
+	case nf == nil:
+		c.report(FileRemoved, breaksSource, of, nil, "file %q was removed", of.Path())
+	case moved:
+		// The full names of the declarations change, which are used in
+		// google.protobuf.Any messages and the paths of RPCs.
+		c.report(PackageChanged, breaksSource|breaksJSON|breaksWire, of, nf, "package of file %q changed from %q to %q", of.Path(), of.Package(), nf.Package())
+	}
+
+	// lookup returns the declaration in the new version of the top-level
+	// declaration d, if there is one.
+	lookup := func(d protoreflect.Descriptor) protoreflect.Descriptor {
+		if moved {
+			name := d.Name()
+			if d := nf.Messages().ByName(name); d != nil {
+				return d
+			}
+			if d := nf.Enums().ByName(name); d != nil {
+				return d
+			}
+			if d := nf.Services().ByName(name); d != nil {
+				return d
+			}
+			if d := nf.Extensions().ByName(name); d != nil {
+				return d
+			}
+			return nil
+		}
+		d, _ = c.new.FindDescriptorByName(d.FullName())
+		return d
+	}
+	checkMoved := func(od, nd protoreflect.Descriptor) {
+		if path := nd.ParentFile().Path(); path != of.Path() {
+			c.report(DeclarationMoved, breaksSource, od, nd, "%v moved from file %q to %q", od.FullName(), of.Path(), path)
+		}
+	}
+
+	for i := 0; i < of.Messages().Len(); i++ {
+		om := of.Messages().Get(i)
+		nm, ok := lookup(om).(protoreflect.MessageDescriptor)
+		if !ok {
+			c.report(MessageRemoved, breaksSource, om, nil, "message %v was removed", om.FullName())
+			continue
+		}
+		checkMoved(om, nm)
+		c.message(om, nm)
+	}
+	for i := 0; i < of.Enums().Len(); i++ {
+		oe := of.Enums().Get(i)
+		ne, ok := lookup(oe).(protoreflect.EnumDescriptor)
+		if !ok {
+			c.report(EnumRemoved, breaksSource, oe, nil, "enum %v was removed", oe.FullName())
+			continue
+		}
+		checkMoved(oe, ne)
+		c.enum(oe, ne)
+	}
+	for i := 0; i < of.Extensions().Len(); i++ {
+		ox := of.Extensions().Get(i)
+		nx, ok := lookup(ox).(protoreflect.ExtensionDescriptor)
+		if !ok || !nx.IsExtension() {
+			c.extensionRemoved(ox)
+			continue
+		}
+		checkMoved(ox, nx)
+		c.field(ox, nx)
+	}
+	for i := 0; i < of.Services().Len(); i++ {
+		os := of.Services().Get(i)
+		ns, ok := lookup(os).(protoreflect.ServiceDescriptor)
+		if !ok {
+			c.report(ServiceRemoved, breaksSource|breaksWire, os, nil, "service %v was removed", os.FullName())
+			continue
+		}
+		checkMoved(os, ns)
+		c.service(os, ns)
+	}
+}
+
+func (c *comparer) message(om, nm protoreflect.MessageDescriptor) {
+	for i := 0; i < om.Fields().Len(); i++ {
+		of := om.Fields().Get(i)
+		nf := nm.Fields().ByNumber(of.Number())
+		if nf == nil {
+			if nf := nm.Fields().ByName(of.Name()); nf != nil {
+				c.report(FieldNumberChanged, breaksWire, of, nf, "number of field %v changed from %d to %d", of.FullName(), of.Number(), nf.Number())
+				c.field(of, nf)
+				continue
+			}
+			b := breaksSource | breaksJSON
+			msg := fmt.Sprintf("field %v was removed", of.FullName())
+			if !nm.ReservedRanges().Has(of.Number()) {
+				b |= breaksWire
+				msg += " without reserving its number"
+			}
+			c.report(FieldRemoved, b, of, nil, "%s", msg)
+			continue
+		}
+		c.field(of, nf)
+	}
+
+	for i := 0; i < om.Messages().Len(); i++ {
+		// Changes to map entries are reported as changes to the map fields.
+		if om := om.Messages().Get(i); !om.IsMapEntry() {
+			if nm := nm.Messages().ByName(om.Name()); nm != nil {
+				c.message(om, nm)
+			} else {
+				c.report(MessageRemoved, breaksSource, om, nil, "message %v was removed", om.FullName())
+			}
+		}
+	}
+	for i := 0; i < om.Enums().Len(); i++ {
+		oe := om.Enums().Get(i)
+		if ne := nm.Enums().ByName(oe.Name()); ne != nil {
+			c.enum(oe, ne)
+		} else {
+			c.report(EnumRemoved, breaksSource, oe, nil, "enum %v was removed", oe.FullName())
+		}
+	}
+	for i := 0; i < om.Extensions().Len(); i++ {
+		ox := om.Extensions().Get(i)
+		if nx := nm.Extensions().ByName(ox.Name()); nx != nil {
+			c.field(ox, nx)
+		} else {
+			c.extensionRemoved(ox)
+		}
+	}
+}
+
+func (c *comparer) extensionRemoved(xd protoreflect.ExtensionDescriptor) {
+	c.report(ExtensionRemoved, breaksSource|breaksJSON, xd, nil, "extension %v was removed", xd.FullName())
+}
+
+// field compares a field or an extension.
+func (c *comparer) field(of, nf protoreflect.FieldDescriptor) {
+	if of.Name() != nf.Name() {
+		c.report(FieldNameChanged, breaksSource, of, nf, "field %v was renamed to %v", of.FullName(), nf.Name())
+	}
+	if of.IsExtension() && of.Number() != nf.Number() {
+		c.report(FieldNumberChanged, breaksWire, of, nf, "number of extension %v changed from %d to %d", of.FullName(), of.Number(), nf.Number())
+	}
+	if !of.IsExtension() && of.JSONName() != nf.JSONName() {
+		c.report(FieldJSONNameChanged, breaksJSON, of, nf, "JSON name of field %v changed from %q to %q", of.FullName(), of.JSONName(), nf.JSONName())
+	}
+
+	sameType := c.sameType(of, nf)
+	if !sameType {
+		b := breaksSource | breaksJSON
+		if wireType(of) != wireType(nf) {
+			b |= breaksWire
+		}
+		c.report(FieldTypeChanged, b, of, nf, "type of field %v changed from %v to %v", of.FullName(), typeString(of), typeString(nf))
+	}
+
+	switch oc, nc := of.Cardinality(), nf.Cardinality(); {
+	case (oc == protoreflect.Repeated) != (nc == protoreflect.Repeated):
+		c.report(FieldCardinalityChanged, breaksSource|breaksJSON|breaksWire, of, nf, "field %v changed from %v to %v", of.FullName(), oc, nc)
+	case oc != nc:
+		// A message without a required field fails to be unmarshaled.
+		c.report(FieldCardinalityChanged, breaksSource|breaksWire, of, nf, "field %v changed from %v to %v", of.FullName(), oc, nc)
+	case oc != protoreflect.Repeated && of.HasPresence() != nf.HasPresence():
+		c.report(FieldPresenceChanged, breaksSource, of, nf, "presence of field %v changed", of.FullName())
+	}
+
+	if oo, no := oneofName(of), oneofName(nf); oo != no {
+		switch {
+		case oo == "" && nf.ContainingOneof().Fields().Len() == 1:
+			// A oneof with a single member has the same encoding
+			// as a field with explicit presence.
+			c.report(FieldOneofChanged, breaksSource, of, nf, "field %v moved into new oneof %v", of.FullName(), no)
+		case oo == "":
+			c.report(FieldOneofChanged, breaksSource|breaksWire, of, nf, "field %v moved into oneof %v", of.FullName(), no)
+		case no == "":
+			c.report(FieldOneofChanged, breaksSource|breaksWire, of, nf, "field %v moved out of oneof %v", of.FullName(), oo)
+		default:
+			c.report(FieldOneofChanged, breaksSource|breaksWire, of, nf, "field %v moved from oneof %v to %v", of.FullName(), oo, no)
+		}
+	}
+
+	if sameType && !of.IsList() && !equalValues(of.Default(), nf.Default()) {
+		// The default is not encoded, but changes the value which
+		// generated code returns for an unset field.
+		c.report(FieldDefaultChanged, breaksSource, of, nf, "default value of field %v changed from %v to %v", of.FullName(), valueString(of), valueString(nf))
+	}
+}
+
+// sameType reports whether two fields have the same type. Messages and enums
+// are the same if they have the same full name, or the same name relative to
+// the package of the same file.
+func (c *comparer) sameType(of, nf protoreflect.FieldDescriptor) bool {
+	if of.IsMap() != nf.IsMap() {
+		return false
+	}
+	if of.IsMap() {
+		return c.sameType(of.MapKey(), nf.MapKey()) && c.sameType(of.MapValue(), nf.MapValue())
+	}
+	if of.Kind() != nf.Kind() {
+		return false
+	}
+	switch of.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return sameDecl(of.Message(), nf.Message())
+	case protoreflect.EnumKind:
+		return sameDecl(of.Enum(), nf.Enum())
+	}
+	return true
+}
+
+func sameDecl(od, nd protoreflect.Descriptor) bool {
+	return od.FullName() == nd.FullName() ||
+		(od.ParentFile().Path() == nd.ParentFile().Path() && relativeName(od) == relativeName(nd))
+}
+
+// relativeName returns the name of d relative to its package.
+func relativeName(d protoreflect.Descriptor) string {
+	if pkg := d.ParentFile().Package(); pkg != "" {
+		return strings.TrimPrefix(string(d.FullName()), string(pkg)+".")
+	}
+	return string(d.FullName())
+}
+
+// wireType returns the class of types of fields which have compatible
+// encodings in the wire format.
+func wireType(fd protoreflect.FieldDescriptor) string {
+	switch fd.Kind() {
+	case protoreflect.BoolKind, protoreflect.EnumKind,
+		protoreflect.Int32Kind, protoreflect.Int64Kind, protoreflect.Uint32Kind, protoreflect.Uint64Kind:
+		return "varint"
+	case protoreflect.Sint32Kind, protoreflect.Sint64Kind:
+		return "zigzag"
+	case protoreflect.Fixed32Kind, protoreflect.Sfixed32Kind:
+		return "fixed32"
+	case protoreflect.Fixed64Kind, protoreflect.Sfixed64Kind:
+		return "fixed64"
+	case protoreflect.StringKind, protoreflect.BytesKind, protoreflect.MessageKind:
+		return "bytes"
+	}
+	return fd.Kind().String()
+}
+
+func typeString(fd protoreflect.FieldDescriptor) string {
+	switch {
+	case fd.IsMap():
+		return "map<" + typeString(fd.MapKey()) + ", " + typeString(fd.MapValue()) + ">"
+	case fd.Message() != nil:
+		return string(fd.Message().FullName())
+	case fd.Enum() != nil:
+		return string(fd.Enum().FullName())
+	}
+	return fd.Kind().String()
+}
+
+func oneofName(fd protoreflect.FieldDescriptor) protoreflect.Name {
+	if od := fd.ContainingOneof(); od != nil && !od.IsSynthetic() {
+		return od.Name()
+	}
+	return ""
+}
+
+func equalValues(x, y protoreflect.Value) bool {
+	if !x.IsValid() || !y.IsValid() {
+		return x.IsValid() == y.IsValid()
+	}
+	if b, ok := x.Interface().([]byte); ok {
+		return string(b) == string(y.Bytes())
+	}
+	if f, ok := x.Interface().(float64); ok {
+		// NaN is equal to itself, as a default value.
+		return f == y.Float() || (f != f && y.Float() != y.Float())
+	}
+	if f, ok := x.Interface().(float32); ok {
+		return f == float32(y.Float()) || (f != f && y.Float() != y.Float())
+	}
+	return x.Interface() == y.Interface()
+}
+
+func valueString(fd protoreflect.FieldDescriptor) string {
+	if fd.Enum() != nil {
+		if ev := fd.DefaultEnumValue(); ev != nil {
+			return string(ev.Name())
+		}
+	}
+	if b, ok := fd.Default().Interface().([]byte); ok {
+		return fmt.Sprintf("%q", b)
+	}
+	if s, ok := fd.Default().Interface().(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprint(fd.Default().Interface())
+}
+
+func (c *comparer) enum(oe, ne protoreflect.EnumDescriptor) {
+	for i := 0; i < oe.Values().Len(); i++ {
+		ov := oe.Values().Get(i)
+		byName := ne.Values().ByName(ov.Name())
+		byNumber := ne.Values().ByNumber(ov.Number())
+		switch {
+		case byName != nil && byName.Number() == ov.Number():
+		case byName != nil:
+			c.report(EnumValueNumberChanged, breaksWire, ov, byName, "number of enum value %v changed from %d to %d", ov.FullName(), ov.Number(), byName.Number())
+		case byNumber != nil:
+			c.report(EnumValueNameChanged, breaksSource|breaksJSON, ov, byNumber, "enum value %v was renamed to %v", ov.FullName(), byNumber.Name())
+		default:
+			b := breaksSource | breaksJSON
+			msg := fmt.Sprintf("enum value %v was removed", ov.FullName())
+			if !ne.ReservedRanges().Has(ov.Number()) {
+				b |= breaksWire
+				msg += " without reserving its number"
+			}
+			c.report(EnumValueRemoved, b, ov, nil, "%s", msg)
+		}
+	}
+}
+
+func (c *comparer) service(os, ns protoreflect.ServiceDescriptor) {
+	for i := 0; i < os.Methods().Len(); i++ {
+		om := os.Methods().Get(i)
+		nm := ns.Methods().ByName(om.Name())
+		if nm == nil {
+			c.report(MethodRemoved, breaksSource|breaksWire, om, nil, "method %v was removed", om.FullName())
+			continue
+		}
+		if !sameDecl(om.Input(), nm.Input()) {
+			c.report(MethodTypeChanged, breaksSource|breaksWire, om, nm, "request type of method %v changed from %v to %v", om.FullName(), om.Input().FullName(), nm.Input().FullName())
+		}
+		if !sameDecl(om.Output(), nm.Output()) {
+			c.report(MethodTypeChanged, breaksSource|breaksWire, om, nm, "response type of method %v changed from %v to %v", om.FullName(), om.Output().FullName(), nm.Output().FullName())
+		}
+		if om.IsStreamingClient() != nm.IsStreamingClient() || om.IsStreamingServer() != nm.IsStreamingServer() {
+			c.report(MethodStreamingChanged, breaksSource|breaksWire, om, nm, "streaming of method %v changed", om.FullName())
+		}
+	}
+}
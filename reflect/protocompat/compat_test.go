@@ -0,0 +1,272 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protocompat_test
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"google.golang.org/protobuf/compiler/protoparse"
+	"google.golang.org/protobuf/reflect/protocompat"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// files compiles the .proto sources of files into a registry.
+func files(t *testing.T, srcs map[string]string) *protoregistry.Files {
+	t.Helper()
+	var filenames []string
+	for filename := range srcs {
+		filenames = append(filenames, filename)
+	}
+	fdps, err := protoparse.Parser{
+		Accessor: func(filename string) (io.ReadCloser, error) {
+			s, ok := srcs[filename]
+			if !ok {
+				return nil, os.ErrNotExist
+			}
+			return ioutil.NopCloser(strings.NewReader(s)), nil
+		},
+	}.ParseFiles(filenames...)
+	if err != nil {
+		t.Fatalf("ParseFiles() error: %v", err)
+	}
+	r := new(protoregistry.Files)
+	for _, fdp := range fdps {
+		fd, err := protodesc.NewFile(fdp, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.RegisterFile(fd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
+}
+
+// finding is the part of a finding checked by the tests.
+type finding struct {
+	Change   protocompat.Change
+	Severity protocompat.Severity
+	Source   bool
+	JSON     bool
+	Wire     bool
+}
+
+func TestCompare(t *testing.T) {
+	const base = `
+		syntax = "proto2";
+		package test;
+		message M {
+			optional int32 a = 1;
+			optional string b = 2 [default = "x"];
+			repeated int64 c = 3;
+			optional E e = 4;
+			map<string, int32> m = 5;
+			oneof o {
+				int32 d = 6;
+			}
+			optional int32 j = 7 [json_name = "jay"];
+			required int32 r = 8;
+			message N {
+				optional int32 x = 1;
+			}
+		}
+		enum E {
+			E_ZERO = 0;
+			E_ONE = 1;
+		}
+		service S {
+			rpc Call(M) returns (M);
+		}
+	`
+	tests := []struct {
+		desc string
+		old  map[string]string
+		new  map[string]string
+		want []finding
+	}{{
+		desc: "unchanged",
+		old:  map[string]string{"test.proto": base},
+		new:  map[string]string{"test.proto": base},
+	}, {
+		desc: "field removed",
+		old:  map[string]string{"test.proto": base},
+		new:  map[string]string{"test.proto": strings.Replace(base, "optional int32 a = 1;", "", 1)},
+		want: []finding{{protocompat.FieldRemoved, protocompat.Wire, true, true, true}},
+	}, {
+		desc: "field removed and reserved",
+		old:  map[string]string{"test.proto": base},
+		new:  map[string]string{"test.proto": strings.Replace(base, "optional int32 a = 1;", "reserved 1;", 1)},
+		want: []finding{{protocompat.FieldRemoved, protocompat.JSON, true, true, false}},
+	}, {
+		desc: "field number changed",
+		old:  map[string]string{"test.proto": base},
+		new:  map[string]string{"test.proto": strings.Replace(base, "int32 a = 1;", "int32 a = 10;", 1)},
+		want: []finding{{protocompat.FieldNumberChanged, protocompat.Wire, false, false, true}},
+	}, {
+		desc: "field renamed",
+		old:  map[string]string{"test.proto": base},
+		new:  map[string]string{"test.proto": strings.Replace(base, "int32 a = 1;", "int32 aa = 1;", 1)},
+		want: []finding{
+			{protocompat.FieldNameChanged, protocompat.Source, true, false, false},
+			{protocompat.FieldJSONNameChanged, protocompat.JSON, false, true, false},
+		},
+	}, {
+		desc: "json_name changed",
+		old:  map[string]string{"test.proto": base},
+		new:  map[string]string{"test.proto": strings.Replace(base, `json_name = "jay"`, `json_name = "jj"`, 1)},
+		want: []finding{{protocompat.FieldJSONNameChanged, protocompat.JSON, false, true, false}},
+	}, {
+		desc: "wire compatible type change",
+		old:  map[string]string{"test.proto": base},
+		new:  map[string]string{"test.proto": strings.Replace(base, "int32 a = 1;", "int64 a = 1;", 1)},
+		want: []finding{{protocompat.FieldTypeChanged, protocompat.JSON, true, true, false}},
+	}, {
+		desc: "wire incompatible type change",
+		old:  map[string]string{"test.proto": base},
+		new:  map[string]string{"test.proto": strings.Replace(base, "int32 a = 1;", "fixed32 a = 1;", 1)},
+		want: []finding{{protocompat.FieldTypeChanged, protocompat.Wire, true, true, true}},
+	}, {
+		desc: "map value type change",
+		old:  map[string]string{"test.proto": base},
+		new:  map[string]string{"test.proto": strings.Replace(base, "map<string, int32>", "map<string, string>", 1)},
+		want: []finding{{protocompat.FieldTypeChanged, protocompat.JSON, true, true, false}},
+	}, {
+		desc: "repeated to optional",
+		old:  map[string]string{"test.proto": base},
+		new:  map[string]string{"test.proto": strings.Replace(base, "repeated int64 c", "optional int64 c", 1)},
+		want: []finding{{protocompat.FieldCardinalityChanged, protocompat.Wire, true, true, true}},
+	}, {
+		desc: "optional to required",
+		old:  map[string]string{"test.proto": base},
+		new:  map[string]string{"test.proto": strings.Replace(base, "optional int32 a", "required int32 a", 1)},
+		want: []finding{{protocompat.FieldCardinalityChanged, protocompat.Wire, true, false, true}},
+	}, {
+		desc: "moved out of oneof",
+		old:  map[string]string{"test.proto": base},
+		new: map[string]string{"test.proto": strings.Replace(base, `oneof o {
+				int32 d = 6;
+			}`, "optional int32 d = 6;", 1)},
+		want: []finding{{protocompat.FieldOneofChanged, protocompat.Wire, true, false, true}},
+	}, {
+		desc: "moved into new oneof",
+		old:  map[string]string{"test.proto": base},
+		new:  map[string]string{"test.proto": strings.Replace(base, "optional int32 a = 1;", "oneof p { int32 a = 1; }", 1)},
+		want: []finding{{protocompat.FieldOneofChanged, protocompat.Source, true, false, false}},
+	}, {
+		desc: "moved into existing oneof",
+		old:  map[string]string{"test.proto": base},
+		new:  map[string]string{"test.proto": strings.Replace(strings.Replace(base, "optional int32 a = 1;", "", 1), "int32 d = 6;", "int32 d = 6; int32 a = 1;", 1)},
+		want: []finding{{protocompat.FieldOneofChanged, protocompat.Wire, true, false, true}},
+	}, {
+		desc: "default changed",
+		old:  map[string]string{"test.proto": base},
+		new:  map[string]string{"test.proto": strings.Replace(base, `default = "x"`, `default = "y"`, 1)},
+		want: []finding{{protocompat.FieldDefaultChanged, protocompat.Source, true, false, false}},
+	}, {
+		desc: "enum value removed",
+		old:  map[string]string{"test.proto": base},
+		new:  map[string]string{"test.proto": strings.Replace(base, "E_ONE = 1;", "", 1)},
+		want: []finding{{protocompat.EnumValueRemoved, protocompat.Wire, true, true, true}},
+	}, {
+		desc: "enum value removed and reserved",
+		old:  map[string]string{"test.proto": base},
+		new:  map[string]string{"test.proto": strings.Replace(base, "E_ONE = 1;", "reserved 1;", 1)},
+		want: []finding{{protocompat.EnumValueRemoved, protocompat.JSON, true, true, false}},
+	}, {
+		desc: "enum value renamed",
+		old:  map[string]string{"test.proto": base},
+		new:  map[string]string{"test.proto": strings.Replace(base, "E_ONE = 1;", "E_UNO = 1;", 1)},
+		want: []finding{{protocompat.EnumValueNameChanged, protocompat.JSON, true, true, false}},
+	}, {
+		desc: "enum value number changed",
+		old:  map[string]string{"test.proto": base},
+		new:  map[string]string{"test.proto": strings.Replace(base, "E_ONE = 1;", "E_ONE = 2;", 1)},
+		want: []finding{{protocompat.EnumValueNumberChanged, protocompat.Wire, false, false, true}},
+	}, {
+		desc: "nested message removed",
+		old:  map[string]string{"test.proto": base},
+		new: map[string]string{"test.proto": strings.Replace(base, `message N {
+				optional int32 x = 1;
+			}`, "", 1)},
+		want: []finding{{protocompat.MessageRemoved, protocompat.Source, true, false, false}},
+	}, {
+		desc: "method streaming changed",
+		old:  map[string]string{"test.proto": base},
+		new:  map[string]string{"test.proto": strings.Replace(base, "returns (M)", "returns (stream M)", 1)},
+		want: []finding{{protocompat.MethodStreamingChanged, protocompat.Wire, true, false, true}},
+	}, {
+		desc: "package changed",
+		old:  map[string]string{"test.proto": base},
+		new:  map[string]string{"test.proto": strings.Replace(base, "package test;", "package test2;", 1)},
+		want: []finding{{protocompat.PackageChanged, protocompat.Wire, true, true, true}},
+	}, {
+		desc: "declaration moved",
+		old: map[string]string{
+			"a.proto": `syntax = "proto3"; package test; message A { int32 x = 1; }`,
+			"b.proto": `syntax = "proto3"; package test;`,
+		},
+		new: map[string]string{
+			"a.proto": `syntax = "proto3"; package test;`,
+			"b.proto": `syntax = "proto3"; package test; message A { int32 x = 1; }`,
+		},
+		want: []finding{{protocompat.DeclarationMoved, protocompat.Source, true, false, false}},
+	}, {
+		desc: "file removed",
+		old: map[string]string{
+			"a.proto": `syntax = "proto3"; package test; message A { int32 x = 1; }`,
+		},
+		new: map[string]string{
+			"b.proto": `syntax = "proto3"; package test;`,
+		},
+		want: []finding{
+			{protocompat.FileRemoved, protocompat.Source, true, false, false},
+			{protocompat.MessageRemoved, protocompat.Source, true, false, false},
+		},
+	}, {
+		desc: "proto3 optional",
+		old:  map[string]string{"a.proto": `syntax = "proto3"; message A { int32 x = 1; }`},
+		new:  map[string]string{"a.proto": `syntax = "proto3"; message A { optional int32 x = 1; }`},
+		want: []finding{{protocompat.FieldPresenceChanged, protocompat.Source, true, false, false}},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			var got []finding
+			for _, f := range protocompat.Compare(files(t, tt.old), files(t, tt.new)) {
+				got = append(got, finding{
+					Change:   f.Change,
+					Severity: f.Severity,
+					Source:   f.Breaks(protocompat.Source),
+					JSON:     f.Breaks(protocompat.JSON),
+					Wire:     f.Breaks(protocompat.Wire),
+				})
+				t.Log(f)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Compare() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFindingString(t *testing.T) {
+	old := files(t, map[string]string{"a.proto": `syntax = "proto3"; package test; message A { int32 x = 1; }`})
+	new := files(t, map[string]string{"a.proto": `syntax = "proto3"; package test; message A {}`})
+	got := protocompat.Compare(old, new)
+	if len(got) != 1 {
+		t.Fatalf("Compare() = %v, want one finding", got)
+	}
+	want := "a.proto: field test.A.x was removed without reserving its number (breaks wire, JSON, source)"
+	if s := got[0].String(); s != want {
+		t.Errorf("String() = %q, want %q", s, want)
+	}
+}
@@ -64,6 +64,34 @@ type FileOptions struct {
 	// then the placeholder will contain an invalid FullName with a "*." prefix,
 	// indicating that the starting prefix of the full name is unknown.
 	AllowUnresolvable bool
+
+	// AllowInvalid configures New to permissively allow declarations which
+	// violate the semantics of protobuf once their dependencies are resolved,
+	// such as conflicting field numbers or invalid reserved ranges,
+	// as found in legacy descriptors. Descriptors with such declarations
+	// may not be usable with every API.
+	//
+	// Violations which leave the descriptor unusable by the implementations
+	// of protoreflect.Message are never allowed:
+	//	• Fields of a message with conflicting numbers.
+	//	• Map fields whose entry message is not of the form produced by protoc.
+	//	• Oneofs without fields, or with fields which are not optional.
+	//	• Enums without values.
+	//
+	// The violations are reported by NewWithDiagnostics as diagnostics
+	// with SeverityError.
+	AllowInvalid bool
+
+	// Lint configures NewWithDiagnostics to report diagnostics with
+	// SeverityWarning for problems which are not violations of the semantics
+	// of protobuf, but which are likely to be mistakes:
+	//	• Fields of a message with conflicting JSON names.
+	//	• Names which do not follow the style guide, such as messages which
+	//	are not in UpperCamelCase, or fields which are not in lower_snake_case.
+	//	• Imports which are unused.
+	//	• Custom options with numbers outside of the range 50000 to 99999.
+	//	• Zero values of proto3 enums without the suffix "_UNSPECIFIED".
+	Lint bool
 }
 
 // NewFile creates a new protoreflect.FileDescriptor from the provided
@@ -96,6 +124,20 @@ func NewFiles(fd *descriptorpb.FileDescriptorSet) (*protoregistry.Files, error)
 // 当查询导入文件的路径时，路径必须是唯一的。新创建的文件描述符不会被注册到所提供的文件注册表中。
 //
 func (o FileOptions) New(fd *descriptorpb.FileDescriptorProto, r Resolver) (protoreflect.FileDescriptor, error) {
+	f, _, err := o.NewWithDiagnostics(fd, r)
+	return f, err
+}
+
+// NewWithDiagnostics is like New, but also returns the diagnostics of the
+// file, in the order of the declarations, instead of only the first error.
+//
+// If the file violates the semantics of protobuf, every violation is
+// reported, and the first one is also returned as the error unless
+// AllowInvalid is set. Problems found by the checks enabled by Lint are
+// reported as warnings, which are never returned as the error.
+// If the file cannot be constructed, such as when a dependency cannot be
+// resolved, only the error is returned.
+func (o FileOptions) NewWithDiagnostics(fd *descriptorpb.FileDescriptorProto, r Resolver) (protoreflect.FileDescriptor, []Diagnostic, error) {
 	if r == nil {
 		r = (*protoregistry.Files)(nil) // empty resolver
 	}
@@ -113,19 +155,19 @@ func (o FileOptions) New(fd *descriptorpb.FileDescriptorProto, r Resolver) (prot
 	case "proto3":
 		f.L1.Syntax = protoreflect.Proto3
 	default:
-		return nil, errors.New("invalid syntax: %q", fd.GetSyntax())
+		return nil, nil, errors.New("invalid syntax: %q", fd.GetSyntax())
 	}
 
 	// file name, relative to root of source tree
 	f.L1.Path = fd.GetName()
 	if f.L1.Path == "" {
-		return nil, errors.New("file path must be populated")
+		return nil, nil, errors.New("file path must be populated")
 	}
 
 	// package name, e.g. "foo", "foo.bar", etc.
 	f.L1.Package = protoreflect.FullName(fd.GetPackage())
 	if !f.L1.Package.IsValid() && f.L1.Package != "" {
-		return nil, errors.New("invalid package: %q", f.L1.Package)
+		return nil, nil, errors.New("invalid package: %q", f.L1.Package)
 	}
 
 	// 选项
@@ -141,7 +183,7 @@ func (o FileOptions) New(fd *descriptorpb.FileDescriptorProto, r Resolver) (prot
 	for _, i := range fd.GetPublicDependency() {
 		// 数组越界或者重复 Import，报错
 		if !(0 <= i && int(i) < len(f.L2.Imports)) || f.L2.Imports[i].IsPublic {
-			return nil, errors.New("invalid or duplicate public import index: %d", i)
+			return nil, nil, errors.New("invalid or duplicate public import index: %d", i)
 		}
 		// 保存 Import
 		f.L2.Imports[i].IsPublic = true
@@ -150,7 +192,7 @@ func (o FileOptions) New(fd *descriptorpb.FileDescriptorProto, r Resolver) (prot
 	// 弱依赖，忽略～
 	for _, i := range fd.GetWeakDependency() {
 		if !(0 <= i && int(i) < len(f.L2.Imports)) || f.L2.Imports[i].IsWeak {
-			return nil, errors.New("invalid or duplicate weak import index: %d", i)
+			return nil, nil, errors.New("invalid or duplicate weak import index: %d", i)
 		}
 		f.L2.Imports[i].IsWeak = true
 	}
@@ -173,7 +215,7 @@ func (o FileOptions) New(fd *descriptorpb.FileDescriptorProto, r Resolver) (prot
 			impFd = filedesc.PlaceholderFile(path)
 		// 否则，未找到直接报错
 		} else if err != nil {
-			return nil, errors.New("could not resolve import %q: %v", path, err)
+			return nil, nil, errors.New("could not resolve import %q: %v", path, err)
 		}
 
 		// 找到依赖文件，则把 fd 保存到当前依赖项上
@@ -181,7 +223,7 @@ func (o FileOptions) New(fd *descriptorpb.FileDescriptorProto, r Resolver) (prot
 
 		// 检查是否重复导入
 		if imps[imp.Path()] {
-			return nil, errors.New("already imported %q", path)
+			return nil, nil, errors.New("already imported %q", path)
 		}
 		// 保存到 set 中
 		imps[imp.Path()] = true
@@ -209,14 +251,14 @@ func (o FileOptions) New(fd *descriptorpb.FileDescriptorProto, r Resolver) (prot
 		case 4:
 			l.StartLine, l.StartColumn, l.EndLine, l.EndColumn = int(s[0]), int(s[1]), int(s[2]), int(s[3])
 		default:
-			return nil, errors.New("invalid span: %v", s)
+			return nil, nil, errors.New("invalid span: %v", s)
 		}
 
 		// TODO: Validate that the span information is sensible?
 		// See https://github.com/protocolbuffers/protobuf/issues/6378.
 		if false && (l.EndLine < l.StartLine || l.StartLine < 0 || l.StartColumn < 0 || l.EndColumn < 0 ||
 			(l.StartLine == l.EndLine && l.EndColumn <= l.StartColumn)) {
-			return nil, errors.New("invalid span: %v", s)
+			return nil, nil, errors.New("invalid span: %v", s)
 		}
 		l.LeadingDetachedComments = loc.GetLeadingDetachedComments()
 		l.LeadingComments = loc.GetLeadingComments()
@@ -237,19 +279,19 @@ func (o FileOptions) New(fd *descriptorpb.FileDescriptorProto, r Resolver) (prot
 
 	r1 := make(descsByName)
 	if f.L1.Enums.List, err = r1.initEnumDeclarations(fd.GetEnumType(), f, sb); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if f.L1.Messages.List, err = r1.initMessagesDeclarations(fd.GetMessageType(), f, sb); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if f.L1.Extensions.List, err = r1.initExtensionDeclarations(fd.GetExtension(), f, sb); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if f.L1.Services.List, err = r1.initServiceDeclarations(fd.GetService(), f, sb); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Step 2: Resolve every dependency reference not handled by step 1.
@@ -260,27 +302,30 @@ func (o FileOptions) New(fd *descriptorpb.FileDescriptorProto, r Resolver) (prot
 		allowUnresolvable: o.AllowUnresolvable,
 	}
 	if err := r2.resolveMessageDependencies(f.L1.Messages.List, fd.GetMessageType()); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if err := r2.resolveExtensionDependencies(f.L1.Extensions.List, fd.GetExtension()); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if err := r2.resolveServiceDependencies(f.L1.Services.List, fd.GetService()); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Step 3: Validate every enum, message, and extension declaration.
-	if err := validateEnumDeclarations(f.L1.Enums.List, fd.GetEnumType()); err != nil {
-		return nil, err
+	ds := new(diagnostics)
+	validateEnumDeclarations(ds, f.L1.Enums.List, fd.GetEnumType())
+	validateMessageDeclarations(ds, f.L1.Messages.List, fd.GetMessageType())
+	validateExtensionDeclarations(ds, f.L1.Extensions.List, fd.GetExtension())
+	if err := ds.firstError(); err != nil && (!o.AllowInvalid || ds.fatalErr != nil) {
+		return nil, ds.list, err
 	}
-	if err := validateMessageDeclarations(f.L1.Messages.List, fd.GetMessageType()); err != nil {
-		return nil, err
-	}
-	if err := validateExtensionDeclarations(f.L1.Extensions.List, fd.GetExtension()); err != nil {
-		return nil, err
+
+	// Step 4: Check for problems which are not violations.
+	if o.Lint {
+		lintFile(ds, f)
 	}
 
-	return f, nil
+	return f, ds.list, nil
 }
 
 type importSet map[string]bool
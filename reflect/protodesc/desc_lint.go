@@ -0,0 +1,362 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protodesc
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/internal/errors"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Severity is the severity of a diagnostic.
+type Severity int8
+
+const (
+	// SeverityError is the severity of a violation of the semantics of
+	// protobuf, which is rejected by New unless FileOptions.AllowInvalid is set.
+	SeverityError Severity = 1 + iota
+	// SeverityWarning is the severity of a problem reported by the checks
+	// enabled by FileOptions.Lint, which is never rejected.
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return fmt.Sprintf("<unknown:%d>", s)
+	}
+}
+
+// Diagnostic is a problem found in a file descriptor.
+type Diagnostic struct {
+	Severity Severity
+
+	// Descriptor is the declaration with the problem.
+	Descriptor protoreflect.Descriptor
+
+	// Err describes the problem.
+	Err error
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%v: %v", d.Severity, d.Err)
+}
+
+// diagnostics collects the diagnostics of a file.
+type diagnostics struct {
+	list []Diagnostic
+
+	// fatalErr is the first violation reported by fatal.
+	fatalErr error
+}
+
+func (ds *diagnostics) error(d protoreflect.Descriptor, err error) {
+	ds.list = append(ds.list, Diagnostic{Severity: SeverityError, Descriptor: d, Err: err})
+}
+
+// fatal reports a violation which leaves the descriptor unusable,
+// such that New rejects it even if FileOptions.AllowInvalid is set.
+func (ds *diagnostics) fatal(d protoreflect.Descriptor, err error) {
+	ds.error(d, err)
+	if ds.fatalErr == nil {
+		ds.fatalErr = err
+	}
+}
+
+func (ds *diagnostics) warning(d protoreflect.Descriptor, f string, x ...interface{}) {
+	ds.list = append(ds.list, Diagnostic{Severity: SeverityWarning, Descriptor: d, Err: errors.New(f, x...)})
+}
+
+// firstError returns the error of the first diagnostic with SeverityError.
+func (ds *diagnostics) firstError() error {
+	for _, d := range ds.list {
+		if d.Severity == SeverityError {
+			return d.Err
+		}
+	}
+	return nil
+}
+
+// Field numbers of extensions of the descriptor options which are
+// reserved for use within individual organizations.
+// See https://github.com/protocolbuffers/protobuf/blob/main/docs/options.md.
+const (
+	minCustomOptionNumber = 50000
+	maxCustomOptionNumber = 99999
+)
+
+// lintFile reports the problems in fd which are not violations of the
+// semantics of protobuf, but which are likely to be mistakes or which do not
+// follow the style guide.
+func lintFile(ds *diagnostics, fd protoreflect.FileDescriptor) {
+	for _, s := range strings.Split(string(fd.Package()), ".") {
+		if s != "" && !isLowerSnakeCase(s) {
+			ds.warning(fd, "package %q should be in lower_snake_case", fd.Package())
+			break
+		}
+	}
+	lintImports(ds, fd)
+	lintEnums(ds, fd.Enums())
+	lintMessages(ds, fd.Messages())
+	lintExtensions(ds, fd.Extensions())
+	for i := 0; i < fd.Services().Len(); i++ {
+		sd := fd.Services().Get(i)
+		if !isUpperCamelCase(string(sd.Name())) {
+			ds.warning(sd, "service %q should be in UpperCamelCase", sd.FullName())
+		}
+		for j := 0; j < sd.Methods().Len(); j++ {
+			md := sd.Methods().Get(j)
+			if !isUpperCamelCase(string(md.Name())) {
+				ds.warning(md, "service method %q should be in UpperCamelCase", md.FullName())
+			}
+		}
+	}
+}
+
+func lintEnums(ds *diagnostics, eds protoreflect.EnumDescriptors) {
+	for i := 0; i < eds.Len(); i++ {
+		ed := eds.Get(i)
+		if !isUpperCamelCase(string(ed.Name())) {
+			ds.warning(ed, "enum %q should be in UpperCamelCase", ed.FullName())
+		}
+		for j := 0; j < ed.Values().Len(); j++ {
+			vd := ed.Values().Get(j)
+			if !isUpperSnakeCase(string(vd.Name())) {
+				ds.warning(vd, "enum value %q should be in UPPER_SNAKE_CASE", vd.FullName())
+			}
+		}
+		// The zero value is the default of fields which do not have presence,
+		// so it should not have any other meaning.
+		if ed.Syntax() == protoreflect.Proto3 && ed.Values().Len() > 0 {
+			if vd := ed.Values().Get(0); vd.Number() == 0 && !strings.HasSuffix(string(vd.Name()), "_UNSPECIFIED") {
+				ds.warning(vd, "enum value %q using proto3 semantics is the zero value and should have the suffix _UNSPECIFIED", vd.FullName())
+			}
+		}
+	}
+}
+
+func lintMessages(ds *diagnostics, mds protoreflect.MessageDescriptors) {
+	for i := 0; i < mds.Len(); i++ {
+		md := mds.Get(i)
+		if md.IsMapEntry() {
+			continue
+		}
+		if !isUpperCamelCase(string(md.Name())) {
+			ds.warning(md, "message %q should be in UpperCamelCase", md.FullName())
+		}
+		jsonNames := map[string]protoreflect.FieldDescriptor{}
+		for j := 0; j < md.Fields().Len(); j++ {
+			fd := md.Fields().Get(j)
+			if !isLowerSnakeCase(string(fd.Name())) {
+				ds.warning(fd, "message field %q should be in lower_snake_case", fd.FullName())
+			}
+			if fd2, ok := jsonNames[fd.JSONName()]; ok {
+				ds.warning(fd, "message field %q has conflicting JSON name %q with %q", fd.FullName(), fd.JSONName(), fd2.Name())
+			}
+			jsonNames[fd.JSONName()] = fd
+		}
+		for j := 0; j < md.Oneofs().Len(); j++ {
+			od := md.Oneofs().Get(j)
+			if !od.IsSynthetic() && !isLowerSnakeCase(string(od.Name())) {
+				ds.warning(od, "message oneof %q should be in lower_snake_case", od.FullName())
+			}
+		}
+		lintEnums(ds, md.Enums())
+		lintMessages(ds, md.Messages())
+		lintExtensions(ds, md.Extensions())
+	}
+}
+
+func lintExtensions(ds *diagnostics, xds protoreflect.ExtensionDescriptors) {
+	for i := 0; i < xds.Len(); i++ {
+		xd := xds.Get(i)
+		if !isLowerSnakeCase(string(xd.Name())) {
+			ds.warning(xd, "extension field %q should be in lower_snake_case", xd.FullName())
+		}
+		md := xd.ContainingMessage()
+		if md.ParentFile() != nil && md.ParentFile().Path() == "google/protobuf/descriptor.proto" && strings.HasSuffix(string(md.Name()), "Options") {
+			if n := xd.Number(); n < minCustomOptionNumber || n > maxCustomOptionNumber {
+				ds.warning(xd, "extension field %q of %q has number %d outside of the range %d to %d for custom options", xd.FullName(), md.FullName(), n, minCustomOptionNumber, maxCustomOptionNumber)
+			}
+		}
+	}
+}
+
+// lintImports reports the imports of fd which declare nothing that fd uses.
+// Public and weak imports are never reported, nor are any imports if
+// some options of fd are unknown fields, which may be custom options
+// declared in the imports.
+func lintImports(ds *diagnostics, fd protoreflect.FileDescriptor) {
+	used := map[string]bool{}
+	unknownOptions := false
+	useFile := func(d protoreflect.Descriptor) {
+		if d != nil && d.ParentFile() != nil {
+			used[d.ParentFile().Path()] = true
+		}
+	}
+	useOptions := func(d protoreflect.Descriptor) {
+		opts := d.Options()
+		if opts == nil {
+			return
+		}
+		m := opts.ProtoReflect()
+		if !m.IsValid() {
+			return
+		}
+		m.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+			if fd.IsExtension() {
+				useFile(fd)
+			}
+			return true
+		})
+		if len(m.GetUnknown()) > 0 {
+			unknownOptions = true
+		}
+	}
+	useField := func(fd protoreflect.FieldDescriptor) {
+		if fd.Message() != nil {
+			useFile(fd.Message())
+		}
+		if fd.Enum() != nil {
+			useFile(fd.Enum())
+		}
+		if fd.IsExtension() {
+			useFile(fd.ContainingMessage())
+		}
+	}
+	rangeDescriptors(fd, func(d protoreflect.Descriptor) {
+		useOptions(d)
+		switch d := d.(type) {
+		case protoreflect.FieldDescriptor:
+			useField(d)
+		case protoreflect.MethodDescriptor:
+			useFile(d.Input())
+			useFile(d.Output())
+		}
+	})
+	if unknownOptions {
+		return
+	}
+
+	// An import is used if it publicly imports a file which is used.
+	var isUsed func(protoreflect.FileDescriptor) bool
+	isUsed = func(f protoreflect.FileDescriptor) bool {
+		if used[f.Path()] {
+			return true
+		}
+		imps := f.Imports()
+		for i := 0; i < imps.Len(); i++ {
+			if imp := imps.Get(i); imp.IsPublic && isUsed(imp.FileDescriptor) {
+				return true
+			}
+		}
+		return false
+	}
+	for i := 0; i < fd.Imports().Len(); i++ {
+		imp := fd.Imports().Get(i)
+		if imp.IsPublic || imp.IsWeak || imp.IsPlaceholder() {
+			continue
+		}
+		if !isUsed(imp.FileDescriptor) {
+			ds.warning(fd, "import %q is unused", imp.Path())
+		}
+	}
+}
+
+// rangeDescriptors calls f for d and every declaration nested in d.
+func rangeDescriptors(d protoreflect.Descriptor, f func(protoreflect.Descriptor)) {
+	f(d)
+	if d, ok := d.(interface {
+		Messages() protoreflect.MessageDescriptors
+	}); ok {
+		for i := 0; i < d.Messages().Len(); i++ {
+			rangeDescriptors(d.Messages().Get(i), f)
+		}
+	}
+	if d, ok := d.(interface {
+		Enums() protoreflect.EnumDescriptors
+	}); ok {
+		for i := 0; i < d.Enums().Len(); i++ {
+			rangeDescriptors(d.Enums().Get(i), f)
+		}
+	}
+	if d, ok := d.(interface {
+		Extensions() protoreflect.ExtensionDescriptors
+	}); ok {
+		for i := 0; i < d.Extensions().Len(); i++ {
+			rangeDescriptors(d.Extensions().Get(i), f)
+		}
+	}
+	switch d := d.(type) {
+	case protoreflect.FileDescriptor:
+		for i := 0; i < d.Services().Len(); i++ {
+			rangeDescriptors(d.Services().Get(i), f)
+		}
+	case protoreflect.MessageDescriptor:
+		for i := 0; i < d.Fields().Len(); i++ {
+			f(d.Fields().Get(i))
+		}
+		for i := 0; i < d.Oneofs().Len(); i++ {
+			f(d.Oneofs().Get(i))
+		}
+	case protoreflect.EnumDescriptor:
+		for i := 0; i < d.Values().Len(); i++ {
+			f(d.Values().Get(i))
+		}
+	case protoreflect.ServiceDescriptor:
+		for i := 0; i < d.Methods().Len(); i++ {
+			f(d.Methods().Get(i))
+		}
+	}
+}
+
+// isUpperCamelCase reports whether s is a name such as "FooBar2".
+func isUpperCamelCase(s string) bool {
+	if s == "" || !isUpper(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isUpper(s[i]) && !isLower(s[i]) && !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isLowerSnakeCase reports whether s is a name such as "foo_bar2".
+func isLowerSnakeCase(s string) bool {
+	if s == "" || !isLower(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isLower(s[i]) && !isDigit(s[i]) && s[i] != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// isUpperSnakeCase reports whether s is a name such as "FOO_BAR2".
+func isUpperSnakeCase(s string) bool {
+	if s == "" || !isUpper(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isUpper(s[i]) && !isDigit(s[i]) && s[i] != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func isUpper(c byte) bool { return 'A' <= c && c <= 'Z' }
+func isLower(c byte) bool { return 'a' <= c && c <= 'z' }
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
@@ -16,6 +16,7 @@ import (
 	"google.golang.org/protobuf/reflect/protoregistry"
 
 	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
 )
 
 func mustParseFile(s string) *descriptorpb.FileDescriptorProto {
@@ -442,6 +443,7 @@ func TestNewFile(t *testing.T) {
 			message_type: [{name:"M" enum_type:[{
 				name:          "E"
 				reserved_name: ["foo", "foo"]
+				value:         [{name:"V" number:0}]
 			}]}]
 		`),
 		wantErr: `enum "M.E" reserved names has duplicate name: "foo"`,
@@ -473,6 +475,7 @@ func TestNewFile(t *testing.T) {
 			message_type: [{name:"M" enum_type:[{
 				name:           "E"
 				reserved_range: [{start:5 end:4}]
+				value:          [{name:"V" number:0}]
 			}]}]
 		`),
 		wantErr: `enum "M.E" reserved ranges has invalid range: 5 to 4`,
@@ -483,6 +486,7 @@ func TestNewFile(t *testing.T) {
 			message_type: [{name:"M" enum_type:[{
 				name:           "E"
 				reserved_range: [{start:1 end:1000}, {start:10 end:100}]
+				value:          [{name:"V" number:0}]
 			}]}]
 		`),
 		wantErr: `enum "M.E" reserved ranges has overlapping ranges: 1 to 1000 with 10 to 100`,
@@ -885,6 +889,154 @@ func TestNewFile(t *testing.T) {
 	}
 }
 
+func TestNewWithDiagnostics(t *testing.T) {
+	invalid := mustParseFile(`
+		syntax:  "proto2"
+		name:    "test.proto"
+		package: "test"
+		message_type: [{
+			name:  "M1"
+			field: [
+				{name:"a" number:1 label:LABEL_OPTIONAL type:TYPE_INT32},
+				{name:"b" number:2 label:LABEL_REPEATED type:TYPE_ENUM type_name:".test.E"}
+			]
+			reserved_range: [{start:2 end:3}]
+		}, {
+			name:           "M2"
+			reserved_range: [{start:5 end:4}]
+		}]
+		enum_type: [{name:"E" value:[{name:"ZERO" number:0}] options:{allow_alias:true}}]
+	`)
+	unusable := mustParseFile(`
+		syntax:  "proto2"
+		name:    "test.proto"
+		package: "test"
+		message_type: [{
+			name:  "M1"
+			field: [
+				{name:"a" number:1 label:LABEL_OPTIONAL type:TYPE_INT32},
+				{name:"b" number:1 label:LABEL_OPTIONAL type:TYPE_INT32}
+			]
+		}, {
+			name:       "M2"
+			field:      [{name:"f" number:1 label:LABEL_REPEATED type:TYPE_INT32 oneof_index:0}]
+			oneof_decl: [{name:"o"}]
+		}, {
+			name:        "M3"
+			field:       [{name:"m" number:1 label:LABEL_REPEATED type:TYPE_MESSAGE type_name:".test.M3.MEntry"}]
+			nested_type: [{
+				name:    "MEntry"
+				field:   [{name:"key" number:1 label:LABEL_OPTIONAL type:TYPE_INT32}]
+				options: {map_entry:true}
+			}]
+		}]
+		enum_type: [{name:"E"}]
+	`)
+	lint := mustParseFile(`
+		syntax:     "proto3"
+		name:       "lint.proto"
+		package:    "Test.lint"
+		dependency: ["google/protobuf/descriptor.proto", "proto2_enum.proto"]
+		message_type: [{
+			name:       "message_name"
+			field:      [
+				{name:"fooBar" number:1 label:LABEL_OPTIONAL type:TYPE_INT32 json_name:"foo"},
+				{name:"foo" number:2 label:LABEL_OPTIONAL type:TYPE_INT32 json_name:"foo"}
+			]
+		}]
+		enum_type: [{name:"Enum" value:[{name:"ZERO" number:0}, {name:"one" number:1}]}]
+		extension: [{name:"opt" number:1000 label:LABEL_OPTIONAL type:TYPE_INT32 extendee:".google.protobuf.FileOptions" json_name:"opt"}]
+		service: [{name:"service"}]
+	`)
+
+	r := new(protoregistry.Files)
+	if err := r.RegisterFile(descriptorpb.File_google_protobuf_descriptor_proto); err != nil {
+		t.Fatal(err)
+	}
+	dep, err := NewFile(proto2Enum, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RegisterFile(dep); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		label   string
+		inDesc  *descriptorpb.FileDescriptorProto
+		inOpts  FileOptions
+		want    []string
+		wantErr string
+	}{{
+		label:  "invalid",
+		inDesc: invalid,
+		want: []string{
+			`error: proto: enum "test.E" allows aliases, but none were found`,
+			`error: proto: message field "test.M1.b" must not use reserved number 2`,
+			`error: proto: message "test.M2" reserved ranges has invalid range: 5 to 3`,
+		},
+		wantErr: `enum "test.E" allows aliases, but none were found`,
+	}, {
+		label:  "invalid but allowed",
+		inDesc: invalid,
+		inOpts: FileOptions{AllowInvalid: true},
+		want: []string{
+			`error: proto: enum "test.E" allows aliases, but none were found`,
+			`error: proto: message field "test.M1.b" must not use reserved number 2`,
+			`error: proto: message "test.M2" reserved ranges has invalid range: 5 to 3`,
+		},
+	}, {
+		label:  "unusable",
+		inDesc: unusable,
+		inOpts: FileOptions{AllowInvalid: true},
+		want: []string{
+			`error: proto: enum "test.E" must contain at least one value declaration`,
+			`error: proto: message "test.M1" has conflicting fields: "b" with "a"`,
+			`error: proto: message field "test.M2.f" belongs in a oneof and must be optional`,
+			`error: proto: message field "test.M3.m" is an invalid map: message must have exactly two fields`,
+		},
+		wantErr: `enum "test.E" must contain at least one value declaration`,
+	}, {
+		label:  "lint disabled",
+		inDesc: lint,
+	}, {
+		label:  "lint",
+		inDesc: lint,
+		inOpts: FileOptions{Lint: true},
+		want: []string{
+			`warning: proto: package "Test.lint" should be in lower_snake_case`,
+			`warning: proto: import "proto2_enum.proto" is unused`,
+			`warning: proto: enum value "Test.lint.one" should be in UPPER_SNAKE_CASE`,
+			`warning: proto: enum value "Test.lint.ZERO" using proto3 semantics is the zero value and should have the suffix _UNSPECIFIED`,
+			`warning: proto: message "Test.lint.message_name" should be in UpperCamelCase`,
+			`warning: proto: message field "Test.lint.message_name.fooBar" should be in lower_snake_case`,
+			`warning: proto: message field "Test.lint.message_name.foo" has conflicting JSON name "foo" with "fooBar"`,
+			`warning: proto: extension field "Test.lint.opt" of "google.protobuf.FileOptions" has number 1000 outside of the range 50000 to 99999 for custom options`,
+			`warning: proto: service "Test.lint.service" should be in UpperCamelCase`,
+		},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			f, ds, err := tt.inOpts.NewWithDiagnostics(tt.inDesc, r)
+			var got []string
+			for _, d := range ds {
+				// Error messages may use a non-breaking space after the prefix.
+				got = append(got, strings.Replace(d.String(), "\u00a0", " ", -1))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("NewWithDiagnostics() diagnostics:\ngot:\n%v\nwant:\n%v", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			if ((err == nil) != (tt.wantErr == "")) || !strings.Contains(fmt.Sprint(err), tt.wantErr) {
+				t.Errorf("NewWithDiagnostics() error:\ngot:  %v\nwant: %v", err, tt.wantErr)
+			}
+			if (f == nil) != (err != nil) {
+				t.Errorf("NewWithDiagnostics() = %v, want a file if and only if there is no error", f)
+			}
+		})
+	}
+}
+
 func TestNewFiles(t *testing.T) {
 	fdset := &descriptorpb.FileDescriptorSet{
 		File: []*descriptorpb.FileDescriptorProto{
@@ -1180,3 +1332,66 @@ func TestSourceLocations(t *testing.T) {
 		t.Errorf("visited %d descriptor, expected 30", numDescs)
 	}
 }
+
+func TestAllowInvalidDynamic(t *testing.T) {
+	fd := mustParseFile(`
+		syntax:  "proto2"
+		name:    "test.proto"
+		package: "test"
+		message_type: [{
+			name:  "M"
+			field: [
+				{name:"a" number:1 label:LABEL_OPTIONAL type:TYPE_INT32},
+				{name:"b" number:2 label:LABEL_REPEATED type:TYPE_ENUM type_name:".test.E"},
+				{name:"c" number:3 label:LABEL_OPTIONAL type:TYPE_STRING oneof_index:0},
+				{name:"d" number:4 label:LABEL_OPTIONAL type:TYPE_MESSAGE type_name:".test.M" oneof_index:0},
+				{name:"e" number:5 label:LABEL_REPEATED type:TYPE_MESSAGE type_name:".test.M.EEntry"},
+				{name:"f" number:6 label:LABEL_OPTIONAL type:TYPE_INT32}
+			]
+			nested_type: [{
+				name:    "EEntry"
+				field:   [
+					{name:"key" number:1 label:LABEL_OPTIONAL type:TYPE_STRING},
+					{name:"value" number:2 label:LABEL_OPTIONAL type:TYPE_MESSAGE type_name:".test.M"}
+				]
+				options: {map_entry:true}
+			}]
+			oneof_decl:      [{name:"o"}]
+			reserved_range:  [{start:2 end:3}, {start:20 end:10}]
+			reserved_name:   ["f", "f"]
+			extension_range: [{start:6 end:7}]
+		}]
+		enum_type: [{name:"E" value:[{name:"ONE" number:1}, {name:"UNO" number:1}]}]
+	`)
+
+	f, ds, err := FileOptions{AllowInvalid: true}.NewWithDiagnostics(fd, nil)
+	if err != nil {
+		t.Fatalf("NewWithDiagnostics() error: %v", err)
+	}
+	if len(ds) != 2 {
+		t.Errorf("NewWithDiagnostics() diagnostics = %v, want one for E and one for M", ds)
+	}
+
+	md := f.Messages().ByName("M")
+	m := dynamicpb.NewMessage(md)
+	if err := prototext.Unmarshal([]byte(`
+		a: 1
+		b: [ONE, UNO]
+		d: {c: "x"}
+		e: {key: "k" value: {a: 2}}
+		f: 3
+	`), m); err != nil {
+		t.Fatalf("prototext.Unmarshal() error: %v", err)
+	}
+	b, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatalf("proto.Marshal() error: %v", err)
+	}
+	got := dynamicpb.NewMessage(md)
+	if err := proto.Unmarshal(b, got); err != nil {
+		t.Fatalf("proto.Unmarshal() error: %v", err)
+	}
+	if !proto.Equal(got, m) {
+		t.Errorf("round trip mismatch:\ngot:  %v\nwant: %v", got, m)
+	}
+}
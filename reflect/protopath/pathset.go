@@ -0,0 +1,37 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protopath
+
+import (
+	"google.golang.org/protobuf/internal/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// NewPathSet returns a proto.PathSet for messages of type md containing
+// the provided paths. Each path must consist of a Root step for md followed
+// by one or more FieldAccess steps.
+func NewPathSet(md protoreflect.MessageDescriptor, paths ...Path) (*proto.PathSet, error) {
+	s, err := proto.NewPathSet(md)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range paths {
+		if len(p) < 2 || p[0].Kind() != RootStep || p[0].MessageDescriptor().FullName() != md.FullName() {
+			return nil, errors.New("path %v is not rooted at %v", p, md.FullName())
+		}
+		fds := make([]protoreflect.FieldDescriptor, 0, len(p)-1)
+		for _, step := range p[1:] {
+			if step.Kind() != FieldAccessStep {
+				return nil, errors.New("path %v contains a %v step", p, step.Kind())
+			}
+			fds = append(fds, step.FieldDescriptor())
+		}
+		if err := s.AddFields(fds...); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
@@ -5,6 +5,8 @@
 package protoreflect
 
 import (
+	"reflect"
+
 	"google.golang.org/protobuf/internal/pragma"
 )
 
@@ -36,6 +38,7 @@ type (
 		Merge            func(mergeInput) mergeOutput
 		// 是否初始化
 		CheckInitialized func(checkInitializedInput) (checkInitializedOutput, error)
+		Reset            func(resetInput) resetOutput
 	}
 
 	supportFlags = uint64
@@ -73,7 +76,11 @@ type (
 			FindExtensionByName(field FullName) (ExtensionType, error)
 			FindExtensionByNumber(message FullName, field FieldNumber) (ExtensionType, error)
 		}
-		Depth int
+		Depth     int
+		Allocator interface {
+			New(t reflect.Type) reflect.Value
+			MakeSlice(t reflect.Type, n int) reflect.Value
+		}
 	}
 	unmarshalOutput = struct {
 		pragma.NoUnkeyedLiterals
@@ -99,4 +106,15 @@ type (
 	checkInitializedOutput = struct {
 		pragma.NoUnkeyedLiterals
 	}
+
+	// Reset Input/Output
+	resetInput = struct {
+		pragma.NoUnkeyedLiterals
+		Message Message
+		Flags   uint8
+	}
+	resetOutput = struct {
+		pragma.NoUnkeyedLiterals
+		Flags uint8
+	}
 )
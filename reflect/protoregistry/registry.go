@@ -104,6 +104,21 @@ type Files struct {
 	descsByName map[protoreflect.FullName]interface{}		// full name => desc
 	filesByPath map[string][]protoreflect.FileDescriptor	// import path => []fd{}
 
+	// extensionsByMessage contains all extensions declared in the files,
+	// including nested ones, by the full name of the message they extend.
+	extensionsByMessage map[protoreflect.FullName][]protoreflect.ExtensionDescriptor
+
+	// files contains the files in the order they were registered.
+	files []protoreflect.FileDescriptor
+
+	// filesByImport contains the files by the paths of the files they import.
+	// It is built lazily by indexImports and guarded by importMu, since
+	// the imports of a file may only be known once the file is fully
+	// initialized, which may look up other files in the registry.
+	importMu      sync.Mutex
+	filesByImport map[string][]protoreflect.FileDescriptor
+	numIndexed    int // number of files in filesByImport
+
 	// 文件数
 	numFiles    int
 }
@@ -135,6 +150,7 @@ func (r *Files) RegisterFile(file protoreflect.FileDescriptor) error {
 			"": &packageDescriptor{},
 		}
 		r.filesByPath = make(map[string][]protoreflect.FileDescriptor)
+		r.extensionsByMessage = make(map[protoreflect.FullName][]protoreflect.ExtensionDescriptor)
 	}
 
 	// 文件路径
@@ -200,6 +216,12 @@ func (r *Files) RegisterFile(file protoreflect.FileDescriptor) error {
 	// 将 file 注册到 import path 上
 	r.filesByPath[path] = append(r.filesByPath[path], file)
 
+	rangeExtensions(file, func(xd protoreflect.ExtensionDescriptor) {
+		name := xd.ContainingMessage().FullName()
+		r.extensionsByMessage[name] = append(r.extensionsByMessage[name], xd)
+	})
+	r.files = append(r.files, file)
+
 	// 更新文件数
 	r.numFiles++
 	return nil
@@ -437,6 +459,132 @@ func (r *Files) RangeFilesByPackage(name protoreflect.FullName, f func(protorefl
 	}
 }
 
+// NumExtensionsByMessage reports the number of registered extensions for
+// a given message type, including extensions declared in messages.
+func (r *Files) NumExtensionsByMessage(message protoreflect.FullName) int {
+	if r == nil {
+		return 0
+	}
+	if r == GlobalFiles {
+		globalMutex.RLock()
+		defer globalMutex.RUnlock()
+	}
+	return len(r.extensionsByMessage[message])
+}
+
+// RangeExtensionsByMessage iterates over all registered extensions filtered
+// by a given message type while f returns true, including extensions declared
+// in messages. The iteration order is undefined.
+func (r *Files) RangeExtensionsByMessage(message protoreflect.FullName, f func(protoreflect.ExtensionDescriptor) bool) {
+	if r == nil {
+		return
+	}
+	if r == GlobalFiles {
+		globalMutex.RLock()
+		defer globalMutex.RUnlock()
+	}
+	for _, xd := range r.extensionsByMessage[message] {
+		if !f(xd) {
+			return
+		}
+	}
+}
+
+// NumFilesByImport reports the number of registered files which directly
+// import the file with the given path.
+func (r *Files) NumFilesByImport(path string) int {
+	return len(r.filesByImportPath(path))
+}
+
+// RangeFilesByImport iterates over all registered files which directly import
+// the file with the given path while f returns true.
+// The iteration order is undefined.
+func (r *Files) RangeFilesByImport(path string, f func(protoreflect.FileDescriptor) bool) {
+	for _, file := range r.filesByImportPath(path) {
+		if !f(file) {
+			return
+		}
+	}
+}
+
+// RangeDependentFiles iterates over all registered files which directly or
+// transitively import the file with the given path while f returns true.
+// Each file is visited once, and the files which import the file directly
+// are visited before the files which import it transitively.
+func (r *Files) RangeDependentFiles(path string, f func(protoreflect.FileDescriptor) bool) {
+	seen := map[protoreflect.FileDescriptor]bool{}
+	queue := []string{path}
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+		for _, file := range r.filesByImportPath(path) {
+			if seen[file] {
+				continue
+			}
+			seen[file] = true
+			if !f(file) {
+				return
+			}
+			queue = append(queue, file.Path())
+		}
+	}
+}
+
+// filesByImportPath returns the registered files which directly import
+// the file with the given path.
+//
+// The files registered since the last call are added to the index first.
+// Their imports are accessed without holding globalMutex, since accessing
+// them may look up other files in GlobalFiles.
+func (r *Files) filesByImportPath(path string) []protoreflect.FileDescriptor {
+	if r == nil {
+		return nil
+	}
+	r.importMu.Lock()
+	defer r.importMu.Unlock()
+
+	if r == GlobalFiles {
+		globalMutex.RLock()
+	}
+	files := r.files[r.numIndexed:]
+	if r == GlobalFiles {
+		globalMutex.RUnlock()
+	}
+	if len(files) > 0 && r.filesByImport == nil {
+		r.filesByImport = make(map[string][]protoreflect.FileDescriptor)
+	}
+	for _, file := range files {
+		imps := file.Imports()
+		for i := 0; i < imps.Len(); i++ {
+			imp := imps.Get(i).Path()
+			r.filesByImport[imp] = append(r.filesByImport[imp], file)
+		}
+	}
+	r.numIndexed += len(files)
+	return r.filesByImport[path]
+}
+
+// rangeExtensions iterates over all extensions in a file,
+// including extensions declared in messages.
+func rangeExtensions(fd protoreflect.FileDescriptor, f func(protoreflect.ExtensionDescriptor)) {
+	var rangeMessages func(protoreflect.MessageDescriptors)
+	rangeMessages = func(mds protoreflect.MessageDescriptors) {
+		for i := 0; i < mds.Len(); i++ {
+			md := mds.Get(i)
+			xds := md.Extensions()
+			for j := 0; j < xds.Len(); j++ {
+				f(xds.Get(j))
+			}
+			rangeMessages(md.Messages())
+		}
+	}
+	xds := fd.Extensions()
+	for i := 0; i < xds.Len(); i++ {
+		f(xds.Get(i))
+	}
+	rangeMessages(fd.Messages())
+}
+
 // rangeTopLevelDescriptors iterates over all top-level descriptors in a file
 // which will be directly entered into the registry.
 func rangeTopLevelDescriptors(fd protoreflect.FileDescriptor, f func(protoreflect.Descriptor)) {
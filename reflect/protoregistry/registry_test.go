@@ -20,6 +20,7 @@ import (
 
 	testpb "google.golang.org/protobuf/internal/testprotos/registry"
 	"google.golang.org/protobuf/types/descriptorpb"
+	_ "google.golang.org/protobuf/types/pluginpb"
 )
 
 func mustMakeFile(s string) pref.FileDescriptor {
@@ -332,6 +333,123 @@ func TestFiles(t *testing.T) {
 	}
 }
 
+func TestFilesByExtensionAndImport(t *testing.T) {
+	mustMakeFileWithDeps := func(r *preg.Files, s string) {
+		pb := new(descriptorpb.FileDescriptorProto)
+		if err := prototext.Unmarshal([]byte(s), pb); err != nil {
+			t.Fatal(err)
+		}
+		fd, err := pdesc.NewFile(pb, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.RegisterFile(fd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := new(preg.Files)
+	mustMakeFileWithDeps(files, `
+		syntax:       "proto2"
+		name:         "a.proto"
+		package:      "test"
+		message_type: [{name:"A" extension_range:[{start:1 end:100}]}]
+	`)
+	mustMakeFileWithDeps(files, `
+		syntax:     "proto2"
+		name:       "b.proto"
+		package:    "test"
+		dependency: "a.proto"
+		extension:  [{name:"b1" number:1 label:LABEL_OPTIONAL type:TYPE_INT32 extendee:".test.A"}]
+		message_type: [{
+			name:      "B"
+			extension: [{name:"b2" number:2 label:LABEL_OPTIONAL type:TYPE_INT32 extendee:".test.A"}]
+			nested_type: [{
+				name:      "C"
+				extension: [{name:"b3" number:3 label:LABEL_OPTIONAL type:TYPE_INT32 extendee:".test.B"}]
+				extension_range: [{start:1 end:100}]
+			}]
+			extension_range: [{start:1 end:100}]
+		}]
+	`)
+	mustMakeFileWithDeps(files, `
+		syntax:            "proto2"
+		name:              "c.proto"
+		package:           "test"
+		dependency:        "b.proto"
+		public_dependency: 0
+	`)
+	mustMakeFileWithDeps(files, `
+		syntax:     "proto2"
+		name:       "d.proto"
+		package:    "test"
+		dependency: ["a.proto", "c.proto"]
+	`)
+
+	tests := []struct {
+		inMessage pref.FullName
+		want      []pref.FullName
+	}{
+		{"test.A", []pref.FullName{"test.B.b2", "test.b1"}},
+		{"test.B", []pref.FullName{"test.B.C.b3"}},
+		{"test.B.C", nil},
+	}
+	for _, tt := range tests {
+		var got []pref.FullName
+		files.RangeExtensionsByMessage(tt.inMessage, func(xd pref.ExtensionDescriptor) bool {
+			got = append(got, xd.FullName())
+			return true
+		})
+		if n := files.NumExtensionsByMessage(tt.inMessage); n != len(got) {
+			t.Errorf("NumExtensionsByMessage(%v) = %v, want %v", tt.inMessage, n, len(got))
+		}
+		if diff := cmp.Diff(tt.want, got, cmpopts.SortSlices(func(x, y pref.FullName) bool { return x < y })); diff != "" {
+			t.Errorf("RangeExtensionsByMessage(%v) mismatch (-want +got):\n%v", tt.inMessage, diff)
+		}
+	}
+
+	sortPaths := cmpopts.SortSlices(func(x, y string) bool { return x < y })
+	for _, tt := range []struct {
+		inPath         string
+		wantImports    []string
+		wantDependents []string
+	}{
+		{"a.proto", []string{"b.proto", "d.proto"}, []string{"b.proto", "c.proto", "d.proto"}},
+		{"b.proto", []string{"c.proto"}, []string{"c.proto", "d.proto"}},
+		{"d.proto", nil, nil},
+		{"missing.proto", nil, nil},
+	} {
+		var gotImports, gotDependents []string
+		files.RangeFilesByImport(tt.inPath, func(fd pref.FileDescriptor) bool {
+			gotImports = append(gotImports, fd.Path())
+			return true
+		})
+		if n := files.NumFilesByImport(tt.inPath); n != len(gotImports) {
+			t.Errorf("NumFilesByImport(%v) = %v, want %v", tt.inPath, n, len(gotImports))
+		}
+		if diff := cmp.Diff(tt.wantImports, gotImports, sortPaths); diff != "" {
+			t.Errorf("RangeFilesByImport(%v) mismatch (-want +got):\n%v", tt.inPath, diff)
+		}
+		files.RangeDependentFiles(tt.inPath, func(fd pref.FileDescriptor) bool {
+			gotDependents = append(gotDependents, fd.Path())
+			return true
+		})
+		if diff := cmp.Diff(tt.wantDependents, gotDependents, sortPaths); diff != "" {
+			t.Errorf("RangeDependentFiles(%v) mismatch (-want +got):\n%v", tt.inPath, diff)
+		}
+	}
+
+	// The imports of generated files are lazily initialized,
+	// which looks up the imported files in the registry.
+	var found bool
+	preg.GlobalFiles.RangeDependentFiles("google/protobuf/descriptor.proto", func(fd pref.FileDescriptor) bool {
+		found = fd.Path() == "google/protobuf/compiler/plugin.proto"
+		return !found
+	})
+	if !found {
+		t.Errorf("RangeDependentFiles(%q) did not visit %q", "google/protobuf/descriptor.proto", "google/protobuf/compiler/plugin.proto")
+	}
+}
+
 func TestTypes(t *testing.T) {
 	mt1 := pimpl.Export{}.MessageTypeOf(&testpb.Message1{})
 	et1 := pimpl.Export{}.EnumTypeOf(testpb.Enum1_ONE)
@@ -10,6 +10,8 @@
 package protoiface
 
 import (
+	"reflect"
+
 	"google.golang.org/protobuf/internal/pragma"
 	"google.golang.org/protobuf/reflect/protoreflect"
 )
@@ -39,6 +41,10 @@ type Methods = struct {
 
 	// CheckInitialized returns an error if any required fields in the message are not set.
 	CheckInitialized func(CheckInitializedInput) (CheckInitializedOutput, error)
+
+	// Reset clears all fields of a message.
+	// It is currently only called with the ResetKeepCapacity flag.
+	Reset func(ResetInput) ResetOutput
 }
 
 // SupportFlags indicate support for optional features.
@@ -50,6 +56,9 @@ const (
 
 	// SupportUnmarshalDiscardUnknown reports whether UnmarshalOptions.DiscardUnknown is supported.
 	SupportUnmarshalDiscardUnknown
+
+	// SupportUnmarshalReuseMemory reports whether UnmarshalReuseMemory is supported.
+	SupportUnmarshalReuseMemory
 )
 
 // SizeInput is input to the Size method.
@@ -103,7 +112,19 @@ type UnmarshalInput = struct {
 		FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error)
 		FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error)
 	}
-	Depth int
+	Depth     int
+	Allocator Allocator
+}
+
+// Allocator provides memory for values created by the Unmarshal method.
+// A nil Allocator indicates that values are allocated individually.
+type Allocator = interface {
+	// New returns a pointer to a new zero value of type t.
+	New(t reflect.Type) reflect.Value
+
+	// MakeSlice returns a new slice of type t with zero length
+	// and a capacity of at least n.
+	MakeSlice(t reflect.Type, n int) reflect.Value
 }
 
 // UnmarshalOutput is output from the Unmarshal method.
@@ -119,6 +140,19 @@ type UnmarshalInputFlags = uint8
 
 const (
 	UnmarshalDiscardUnknown UnmarshalInputFlags = 1 << iota
+
+	// UnmarshalAliasBuffer permits decoded bytes values
+	// to alias the input buffer.
+	UnmarshalAliasBuffer
+
+	// UnmarshalReuseMemory clears the message before unmarshaling,
+	// retaining the memory of its contents for reuse.
+	// It is only set for messages with SupportUnmarshalReuseMemory.
+	UnmarshalReuseMemory
+
+	// UnmarshalAliasStrings permits decoded string values
+	// to alias the input buffer.
+	UnmarshalAliasStrings
 )
 
 // UnmarshalOutputFlags are output from the Unmarshal method.
@@ -166,3 +200,39 @@ type CheckInitializedInput = struct {
 type CheckInitializedOutput = struct {
 	pragma.NoUnkeyedLiterals
 }
+
+// ResetInput is input to the Reset method.
+type ResetInput = struct {
+	pragma.NoUnkeyedLiterals
+
+	Message protoreflect.Message
+	Flags   ResetInputFlags
+}
+
+// ResetOutput is output from the Reset method.
+type ResetOutput = struct {
+	pragma.NoUnkeyedLiterals
+
+	Flags ResetOutputFlags
+}
+
+// ResetInputFlags configure the Reset method.
+type ResetInputFlags = uint8
+
+const (
+	// ResetKeepCapacity retains memory allocated for the message's contents
+	// for reuse by a later call to Unmarshal with the UnmarshalReuseMemory flag.
+	// Repeated fields are truncated to zero length and maps are emptied
+	// in place. Messages are dropped; they are only reused by Unmarshal
+	// with the UnmarshalReuseMemory flag.
+	ResetKeepCapacity ResetInputFlags = 1 << iota
+)
+
+// ResetOutputFlags are output from the Reset method.
+type ResetOutputFlags = uint8
+
+const (
+	// ResetComplete reports whether the reset was performed.
+	// If unset, the message must have been left unchanged.
+	ResetComplete ResetOutputFlags = 1 << iota
+)
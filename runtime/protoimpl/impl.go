@@ -37,6 +37,7 @@ type (
 	UnknownFields    = impl.UnknownFields
 	ExtensionFields  = impl.ExtensionFields
 	ExtensionFieldV1 = impl.ExtensionField
+	LazyFields       = impl.LazyFields
 
 	Pointer = impl.Pointer
 )
@@ -83,6 +83,7 @@ import (
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
 	reflect "reflect"
 	sort "sort"
+	strconv "strconv"
 	strings "strings"
 	sync "sync"
 )
@@ -377,16 +378,7 @@ func numValidPaths(m proto.Message, paths []string) int {
 			if md == nil {
 				return false // not within a message
 			}
-			fd := md.Fields().ByName(protoreflect.Name(field))
-			// The real field name of a group is the message name.
-			if fd == nil {
-				gd := md.Fields().ByName(protoreflect.Name(strings.ToLower(field)))
-				if gd != nil && gd.Kind() == protoreflect.GroupKind && string(gd.Message().Name()) == field {
-					fd = gd
-				}
-			} else if fd.Kind() == protoreflect.GroupKind && string(fd.Message().Name()) != field {
-				fd = nil
-			}
+			fd := findField(md, field)
 			if fd == nil {
 				return false // message has does not have this field
 			}
@@ -468,6 +460,357 @@ func rangeFields(path string, f func(field string) bool) bool {
 	}
 }
 
+// Filter clears all fields of m that are not selected by the mask,
+// including unknown fields.
+//
+// In addition to the paths accepted by IsValid, Filter, Prune, and Overwrite
+// accept paths which select individual entries of a map field by following
+// the name of the map field with a key. A key which contains a dot or
+// a backtick must be quoted with backticks, with any backtick in the key
+// doubled (for example, "labels.`example.com/name`"). For maps with
+// message values, the path may continue with fields of the value.
+func (x *FieldMask) Filter(m proto.Message) error {
+	t, err := newPathTree(m, x.GetPaths())
+	if err != nil {
+		return err
+	}
+	t.filter(m.ProtoReflect())
+	return nil
+}
+
+// Prune clears all fields of m that are selected by the mask.
+// See Filter for the paths which select map entries.
+func (x *FieldMask) Prune(m proto.Message) error {
+	t, err := newPathTree(m, x.GetPaths())
+	if err != nil {
+		return err
+	}
+	t.prune(m.ProtoReflect())
+	return nil
+}
+
+// Overwrite replaces the fields of dst selected by the mask with the
+// corresponding fields of src, which must have the same message type.
+// A selected field which is not populated in src is cleared in dst.
+// Values are copied, so that dst does not share memory with src.
+// See Filter for the paths which select map entries.
+func (x *FieldMask) Overwrite(dst, src proto.Message) error {
+	dm, sm := dst.ProtoReflect(), src.ProtoReflect()
+	if dm.Descriptor().FullName() != sm.Descriptor().FullName() {
+		return protoimpl.X.NewError("mismatching message types %q and %q", dm.Descriptor().FullName(), sm.Descriptor().FullName())
+	}
+	t, err := newPathTree(dst, x.GetPaths())
+	if err != nil {
+		return err
+	}
+	t.overwrite(dm, sm)
+	return nil
+}
+
+// pathTree is a set of paths parsed according to a message type.
+// Each node selects a field of a message, or an entry of a map field.
+type pathTree struct {
+	fd   protoreflect.FieldDescriptor // the selected field, or the map field of an entry
+	key  protoreflect.MapKey          // the key of a selected map entry
+	all  bool                         // whether the entire field or entry is selected
+	next []*pathTree                  // the fields or entries selected within
+}
+
+func newPathTree(m proto.Message, paths []string) (*pathTree, error) {
+	root := new(pathTree)
+	md0 := m.ProtoReflect().Descriptor()
+	for _, path := range paths {
+		md := md0
+		var mapField protoreflect.FieldDescriptor // set if the next segment is a map key
+		t := root
+		segs, ok := splitPath(path)
+	segments:
+		for _, seg := range segs {
+			switch {
+			case mapField != nil:
+				k, kok := parseMapKey(mapField.MapKey(), seg)
+				if !kok {
+					ok = false
+					break segments
+				}
+				t = t.entry(mapField, k)
+				md = mapField.MapValue().Message() // may be nil
+				mapField = nil
+			case md != nil:
+				fd := findField(md, seg)
+				if fd == nil {
+					ok = false
+					break segments
+				}
+				t = t.field(fd)
+				md = fd.Message() // may be nil
+				if fd.IsMap() {
+					mapField, md = fd, nil
+				} else if fd.IsList() {
+					md = nil
+				}
+			default:
+				ok = false
+				break segments
+			}
+		}
+		if !ok {
+			return nil, protoimpl.X.NewError("invalid path %q for message %q", path, md0.FullName())
+		}
+		t.all, t.next = true, nil
+	}
+	return root, nil
+}
+
+// field returns the node for the field fd, adding one if necessary.
+func (t *pathTree) field(fd protoreflect.FieldDescriptor) *pathTree {
+	if n := t.lookupField(fd); n != nil {
+		return n
+	}
+	n := &pathTree{fd: fd}
+	t.next = append(t.next, n)
+	return n
+}
+
+// entry returns the node for the entry k of the map field fd, adding one if necessary.
+func (t *pathTree) entry(fd protoreflect.FieldDescriptor, k protoreflect.MapKey) *pathTree {
+	if n := t.lookupEntry(k); n != nil {
+		return n
+	}
+	n := &pathTree{fd: fd, key: k}
+	t.next = append(t.next, n)
+	return n
+}
+
+func (t *pathTree) lookupField(fd protoreflect.FieldDescriptor) *pathTree {
+	for _, n := range t.next {
+		if n.fd.Number() == fd.Number() {
+			return n
+		}
+	}
+	return nil
+}
+
+func (t *pathTree) lookupEntry(k protoreflect.MapKey) *pathTree {
+	for _, n := range t.next {
+		if n.key.Interface() == k.Interface() {
+			return n
+		}
+	}
+	return nil
+}
+
+func (t *pathTree) filter(m protoreflect.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch n := t.lookupField(fd); {
+		case n == nil:
+			m.Clear(fd)
+		case n.all:
+		case fd.IsMap():
+			n.filterMap(m.Mutable(fd).Map())
+		default:
+			n.filter(m.Mutable(fd).Message())
+		}
+		return true
+	})
+	if len(m.GetUnknown()) > 0 {
+		m.SetUnknown(nil)
+	}
+}
+
+func (t *pathTree) filterMap(mp protoreflect.Map) {
+	var clear []protoreflect.MapKey
+	mp.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+		switch n := t.lookupEntry(k); {
+		case n == nil:
+			clear = append(clear, k)
+		case n.all:
+		default:
+			n.filter(v.Message())
+		}
+		return true
+	})
+	for _, k := range clear {
+		mp.Clear(k)
+	}
+}
+
+func (t *pathTree) prune(m protoreflect.Message) {
+	for _, n := range t.next {
+		switch fd := n.fd; {
+		case n.all:
+			m.Clear(fd)
+		case !m.Has(fd):
+		case fd.IsMap():
+			mp := m.Mutable(fd).Map()
+			for _, e := range n.next {
+				switch {
+				case !mp.Has(e.key):
+				case e.all:
+					mp.Clear(e.key)
+				default:
+					e.prune(mp.Mutable(e.key).Message())
+				}
+			}
+		default:
+			n.prune(m.Mutable(fd).Message())
+		}
+	}
+}
+
+func (t *pathTree) overwrite(dst, src protoreflect.Message) {
+	for _, n := range t.next {
+		switch fd := n.fd; {
+		case n.all:
+			copyField(dst, src, fd)
+		case !dst.Has(fd) && !src.Has(fd):
+		case fd.IsMap():
+			dmp, smp := dst.Mutable(fd).Map(), src.Get(fd).Map()
+			for _, e := range n.next {
+				switch {
+				case e.all && smp.Has(e.key):
+					dmp.Set(e.key, copyValue(fd.MapValue(), smp.Get(e.key), dmp.NewValue))
+				case e.all:
+					dmp.Clear(e.key)
+				case !dmp.Has(e.key) && !smp.Has(e.key):
+				case smp.Has(e.key):
+					e.overwrite(dmp.Mutable(e.key).Message(), smp.Get(e.key).Message())
+				default:
+					e.overwrite(dmp.Mutable(e.key).Message(), dmp.NewValue().Message())
+				}
+			}
+		default:
+			n.overwrite(dst.Mutable(fd).Message(), src.Get(fd).Message())
+		}
+	}
+}
+
+// copyField replaces the field fd of dst with a copy of the field in src.
+func copyField(dst, src protoreflect.Message, fd protoreflect.FieldDescriptor) {
+	dst.Clear(fd)
+	if !src.Has(fd) {
+		return
+	}
+	switch {
+	case fd.IsList():
+		sl, dl := src.Get(fd).List(), dst.Mutable(fd).List()
+		for i := 0; i < sl.Len(); i++ {
+			dl.Append(copyValue(fd, sl.Get(i), dl.NewElement))
+		}
+	case fd.IsMap():
+		smp, dmp := src.Get(fd).Map(), dst.Mutable(fd).Map()
+		smp.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			dmp.Set(k, copyValue(fd.MapValue(), v, dmp.NewValue))
+			return true
+		})
+	default:
+		dst.Set(fd, copyValue(fd, src.Get(fd), func() protoreflect.Value { return dst.NewField(fd) }))
+	}
+}
+
+// copyValue returns a copy of the value v of field fd.
+// Message values are copied into a new value obtained from newValue.
+func copyValue(fd protoreflect.FieldDescriptor, v protoreflect.Value, newValue func() protoreflect.Value) protoreflect.Value {
+	switch {
+	case fd.Message() != nil:
+		nv := newValue()
+		proto.Merge(nv.Message().Interface(), v.Message().Interface())
+		return nv
+	case fd.Kind() == protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes(append([]byte(nil), v.Bytes()...))
+	default:
+		return v
+	}
+}
+
+// findField returns the field of md with the given name in a path,
+// or nil if there is none.
+func findField(md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	fd := md.Fields().ByName(protoreflect.Name(name))
+	// The real field name of a group is the message name.
+	if fd == nil {
+		gd := md.Fields().ByName(protoreflect.Name(strings.ToLower(name)))
+		if gd != nil && gd.Kind() == protoreflect.GroupKind && string(gd.Message().Name()) == name {
+			fd = gd
+		}
+	} else if fd.Kind() == protoreflect.GroupKind && string(fd.Message().Name()) != name {
+		fd = nil
+	}
+	return fd
+}
+
+// splitPath splits a path into its dot-separated segments,
+// removing the backticks from quoted segments.
+func splitPath(path string) ([]string, bool) {
+	var segs []string
+	for {
+		var seg string
+		if strings.HasPrefix(path, "`") {
+			var b []byte
+			i := 1
+			for {
+				j := strings.IndexByte(path[i:], '`')
+				if j < 0 {
+					return nil, false // unterminated quote
+				}
+				b = append(b, path[i:i+j]...)
+				i += j + 1
+				if i < len(path) && path[i] == '`' {
+					b = append(b, '`')
+					i++
+					continue
+				}
+				break
+			}
+			seg, path = string(b), path[i:]
+			if len(path) > 0 && path[0] != '.' {
+				return nil, false
+			}
+		} else if i := strings.IndexByte(path, '.'); i >= 0 {
+			seg, path = path[:i], path[i:]
+		} else {
+			seg, path = path, ""
+		}
+		segs = append(segs, seg)
+		if len(path) == 0 {
+			return segs, true
+		}
+		path = path[1:]
+	}
+}
+
+// parseMapKey parses s as a key of the map key field fd.
+func parseMapKey(fd protoreflect.FieldDescriptor, s string) (protoreflect.MapKey, bool) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(s).MapKey(), true
+	case protoreflect.BoolKind:
+		switch s {
+		case "true":
+			return protoreflect.ValueOfBool(true).MapKey(), true
+		case "false":
+			return protoreflect.ValueOfBool(false).MapKey(), true
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		if v, err := strconv.ParseInt(s, 10, 32); err == nil {
+			return protoreflect.ValueOfInt32(int32(v)).MapKey(), true
+		}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return protoreflect.ValueOfInt64(v).MapKey(), true
+		}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		if v, err := strconv.ParseUint(s, 10, 32); err == nil {
+			return protoreflect.ValueOfUint32(uint32(v)).MapKey(), true
+		}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if v, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return protoreflect.ValueOfUint64(v).MapKey(), true
+		}
+	}
+	return protoreflect.MapKey{}, false
+}
+
 func (x *FieldMask) Reset() {
 	*x = FieldMask{}
 	if protoimpl.UnsafeEnabled {
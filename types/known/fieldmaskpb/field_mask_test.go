@@ -9,7 +9,9 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"google.golang.org/protobuf/encoding/prototext"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
 
 	testpb "google.golang.org/protobuf/internal/testprotos/test"
 	fmpb "google.golang.org/protobuf/types/known/fieldmaskpb"
@@ -336,3 +338,232 @@ func TestIsValid(t *testing.T) {
 		})
 	}
 }
+
+func newApplyMessage() *testpb.TestAllTypes {
+	return &testpb.TestAllTypes{
+		OptionalInt32:  proto.Int32(1),
+		OptionalString: proto.String("string"),
+		OptionalBytes:  []byte("bytes"),
+		Optionalgroup: &testpb.TestAllTypes_OptionalGroup{
+			A: proto.Int32(2),
+		},
+		OptionalNestedMessage: &testpb.TestAllTypes_NestedMessage{
+			A: proto.Int32(3),
+			Corecursive: &testpb.TestAllTypes{
+				OptionalInt64: proto.Int64(4),
+			},
+		},
+		RepeatedInt32:   []int32{5, 6},
+		MapInt32Int32:   map[int32]int32{7: 8, 9: 10},
+		MapStringString: map[string]string{"a": "b", "c.d": "e", "f`g": "h"},
+		MapStringNestedMessage: map[string]*testpb.TestAllTypes_NestedMessage{
+			"x": {A: proto.Int32(11), Corecursive: &testpb.TestAllTypes{OptionalInt32: proto.Int32(12)}},
+			"y": {A: proto.Int32(13)},
+		},
+	}
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		paths   []string
+		want    *testpb.TestAllTypes
+		wantErr bool
+	}{{
+		paths: nil,
+		want:  &testpb.TestAllTypes{},
+	}, {
+		paths: []string{"optional_int32", "OptionalGroup.a", "optional_nested_message.corecursive"},
+		want: &testpb.TestAllTypes{
+			OptionalInt32: proto.Int32(1),
+			Optionalgroup: &testpb.TestAllTypes_OptionalGroup{
+				A: proto.Int32(2),
+			},
+			OptionalNestedMessage: &testpb.TestAllTypes_NestedMessage{
+				Corecursive: &testpb.TestAllTypes{
+					OptionalInt64: proto.Int64(4),
+				},
+			},
+		},
+	}, {
+		paths: []string{"map_int32_int32.9", "map_string_string.`c.d`", "map_string_string.`f``g`", "map_string_nested_message.x.corecursive"},
+		want: &testpb.TestAllTypes{
+			MapInt32Int32:   map[int32]int32{9: 10},
+			MapStringString: map[string]string{"c.d": "e", "f`g": "h"},
+			MapStringNestedMessage: map[string]*testpb.TestAllTypes_NestedMessage{
+				"x": {Corecursive: &testpb.TestAllTypes{OptionalInt32: proto.Int32(12)}},
+			},
+		},
+	}, {
+		paths: []string{"map_string_nested_message.missing", "optional_nested_message.corecursive.optional_int32"},
+		want: &testpb.TestAllTypes{
+			MapStringNestedMessage: map[string]*testpb.TestAllTypes_NestedMessage{},
+			OptionalNestedMessage: &testpb.TestAllTypes_NestedMessage{
+				Corecursive: &testpb.TestAllTypes{},
+			},
+		},
+	}, {
+		paths:   []string{"no_such_field"},
+		wantErr: true,
+	}, {
+		paths:   []string{"map_int32_int32.x"},
+		wantErr: true,
+	}, {
+		paths:   []string{"map_string_string.a.b"},
+		wantErr: true,
+	}, {
+		paths:   []string{"map_string_string.`a"},
+		wantErr: true,
+	}, {
+		paths:   []string{"repeated_int32.0"},
+		wantErr: true,
+	}, {
+		paths:   []string{"optional_nested_message.no_such_field.corecursive.optional_int32"},
+		wantErr: true,
+	}, {
+		paths:   []string{"map_int32_int32.x.9"},
+		wantErr: true,
+	}}
+
+	for _, tt := range tests {
+		m := newApplyMessage()
+		err := (&fmpb.FieldMask{Paths: tt.paths}).Filter(m)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Filter(%q) error = %v, want error %v", tt.paths, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !proto.Equal(m, tt.want) {
+			t.Errorf("Filter(%q):\ngot:  %v\nwant: %v", tt.paths, prototext.Format(m), prototext.Format(tt.want))
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	m := newApplyMessage()
+	mask := &fmpb.FieldMask{Paths: []string{
+		"optional_int32",
+		"optional_bytes",
+		"OptionalGroup",
+		"optional_nested_message.corecursive.optional_int64",
+		"repeated_int32",
+		"map_int32_int32.7",
+		"map_string_string.`c.d`",
+		"map_string_nested_message.x.a",
+		"map_string_nested_message.z",
+	}}
+	if err := mask.Prune(m); err != nil {
+		t.Fatalf("Prune error: %v", err)
+	}
+	want := &testpb.TestAllTypes{
+		OptionalString: proto.String("string"),
+		OptionalNestedMessage: &testpb.TestAllTypes_NestedMessage{
+			A:           proto.Int32(3),
+			Corecursive: &testpb.TestAllTypes{},
+		},
+		MapInt32Int32:   map[int32]int32{9: 10},
+		MapStringString: map[string]string{"a": "b", "f`g": "h"},
+		MapStringNestedMessage: map[string]*testpb.TestAllTypes_NestedMessage{
+			"x": {Corecursive: &testpb.TestAllTypes{OptionalInt32: proto.Int32(12)}},
+			"y": {A: proto.Int32(13)},
+		},
+	}
+	if !proto.Equal(m, want) {
+		t.Errorf("Prune:\ngot:  %v\nwant: %v", prototext.Format(m), prototext.Format(want))
+	}
+}
+
+func TestOverwrite(t *testing.T) {
+	mask := &fmpb.FieldMask{Paths: []string{
+		"optional_int32",
+		"optional_string",
+		"optional_bytes",
+		"optional_nested_message.corecursive",
+		"repeated_int32",
+		"map_int32_int32.7",
+		"map_int32_int32.11",
+		"map_string_nested_message.x.a",
+		"map_string_nested_message.z",
+	}}
+	src := &testpb.TestAllTypes{
+		OptionalInt32: proto.Int32(100),
+		OptionalBytes: []byte("new"),
+		OptionalNestedMessage: &testpb.TestAllTypes_NestedMessage{
+			A: proto.Int32(101),
+			Corecursive: &testpb.TestAllTypes{
+				OptionalInt32: proto.Int32(102),
+			},
+		},
+		RepeatedInt32: []int32{103},
+		MapInt32Int32: map[int32]int32{11: 104, 9: 105},
+		MapStringNestedMessage: map[string]*testpb.TestAllTypes_NestedMessage{
+			"x": {A: proto.Int32(106)},
+			"z": {A: proto.Int32(107)},
+		},
+	}
+	want := &testpb.TestAllTypes{
+		OptionalInt32: proto.Int32(100),
+		OptionalBytes: []byte("new"),
+		Optionalgroup: &testpb.TestAllTypes_OptionalGroup{
+			A: proto.Int32(2),
+		},
+		OptionalNestedMessage: &testpb.TestAllTypes_NestedMessage{
+			A: proto.Int32(3),
+			Corecursive: &testpb.TestAllTypes{
+				OptionalInt32: proto.Int32(102),
+			},
+		},
+		RepeatedInt32:   []int32{103},
+		MapInt32Int32:   map[int32]int32{9: 10, 11: 104},
+		MapStringString: map[string]string{"a": "b", "c.d": "e", "f`g": "h"},
+		MapStringNestedMessage: map[string]*testpb.TestAllTypes_NestedMessage{
+			"x": {A: proto.Int32(106), Corecursive: &testpb.TestAllTypes{OptionalInt32: proto.Int32(12)}},
+			"y": {A: proto.Int32(13)},
+			"z": {A: proto.Int32(107)},
+		},
+	}
+
+	md := src.ProtoReflect().Descriptor()
+	dsrc := dynamicpb.NewMessage(md)
+	proto.Merge(dsrc, src)
+
+	dst := newApplyMessage()
+	if err := mask.Overwrite(dst, src); err != nil {
+		t.Fatalf("Overwrite error: %v", err)
+	}
+	if !proto.Equal(dst, want) {
+		t.Errorf("Overwrite:\ngot:  %v\nwant: %v", prototext.Format(dst), prototext.Format(want))
+	}
+	src.OptionalBytes[0] = 'N'
+	src.OptionalNestedMessage.Corecursive.OptionalInt32 = proto.Int32(0)
+	if !proto.Equal(dst, want) {
+		t.Errorf("Overwrite result shares memory with the source")
+	}
+
+	// Dynamic messages are handled the same way.
+	ddst := dynamicpb.NewMessage(md)
+	proto.Merge(ddst, newApplyMessage())
+	if err := mask.Overwrite(ddst, dsrc); err != nil {
+		t.Fatalf("Overwrite error: %v", err)
+	}
+	if !equalDynamic(ddst, want) {
+		t.Errorf("Overwrite of dynamic message:\ngot:  %v\nwant: %v", prototext.Format(ddst), prototext.Format(want))
+	}
+
+	if err := mask.Overwrite(dst, &fmpb.FieldMask{}); err == nil {
+		t.Errorf("Overwrite with mismatching message types succeeded, want error")
+	}
+}
+
+func equalDynamic(m proto.Message, want *testpb.TestAllTypes) bool {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return false
+	}
+	got := &testpb.TestAllTypes{}
+	if err := proto.Unmarshal(b, got); err != nil {
+		return false
+	}
+	return proto.Equal(got, want)
+}